@@ -0,0 +1,444 @@
+package gcache
+
+import (
+	"io"
+	"time"
+)
+
+// Backend is the storage of a cache returned by Adapt: a cache living elsewhere,
+// such as a remote one, or with another API, such as a KeyedCache, which provides
+// the basic operations the other methods of a Cache are built upon.
+type Backend interface {
+	// Get returns the value of key, loading it if the backend loads values,
+	// or KeyNotFoundError.
+	Get(key interface{}) (interface{}, error)
+	// GetIFPresent returns the value of key without loading it, or KeyNotFoundError.
+	GetIFPresent(key interface{}) (interface{}, error)
+	// Set sets the value of key, which expires after ttl if it is positive,
+	// or after the default expiration of the backend if it is zero.
+	Set(key, value interface{}, ttl time.Duration) error
+	// Remove removes key and reports whether it was stored.
+	Remove(key interface{}) (bool, error)
+	// Keys returns the keys of the unexpired entries.
+	Keys() ([]interface{}, error)
+}
+
+// Adapt returns a Cache storing its entries in b, so that code written against
+// the Cache interface can use it. Keys are validated with ComparableKey.
+//
+// Writes go through a lock of the returned Cache, so that GetOrSet, CompareAndSwap,
+// Update and the other read-modify-write methods are atomic with respect to each
+// other, but not to writes made to b by other means. Errors of b are reported as
+// missing keys by the methods which do not return an error.
+//
+// The Cache is a Swapper and a Dumper. It is not a Pinner, Indexer, Watcher or
+// Explainer, since b decides which entries it keeps and may be written by other
+// means, and Verify returns ErrUnsupported. SetWithPriority ignores the priority,
+// SetCapacity is ignored, and SetExpiration sets the expiration of the entries
+// set without one. Close closes b if it is an io.Closer.
+func Adapt(b Backend) Cache {
+	c := &adaptedCache{backend: b}
+	buildCache(&c.baseCache, New(1))
+	c.loadGroup.cache = c
+	return c
+}
+
+type adaptedCache struct {
+	baseCache
+	backend Backend
+}
+
+// set sets key in the backend with ttl, or with the expiration of the cache if it is zero (not thread safe).
+func (c *adaptedCache) set(key, value interface{}, ttl time.Duration) error {
+	if ttl == 0 && c.expiration != nil {
+		ttl = *c.expiration
+	}
+	c.written(key)
+	return c.backend.Set(key, value, ttl)
+}
+
+// remove removes key from the backend (not thread safe).
+func (c *adaptedCache) remove(key interface{}) bool {
+	c.written(key)
+	ok, err := c.backend.Remove(key)
+	return err == nil && ok
+}
+
+// peek returns the value of key without counting a hit or miss.
+func (c *adaptedCache) peek(key interface{}) (interface{}, bool) {
+	v, err := c.backend.GetIFPresent(key)
+	return v, err == nil
+}
+
+// count counts the hit or miss of a lookup of key which returned err.
+func (c *adaptedCache) count(key interface{}, err error) {
+	switch err {
+	case nil:
+		c.stats.recordHit(key)
+	case KeyNotFoundError:
+		c.stats.recordMiss(key)
+	}
+}
+
+func (c *adaptedCache) Set(key, value interface{}) {
+	c.SetWithExpire(key, value, 0)
+}
+
+// SetWithExpire sets a new key-value pair which expires after expiration,
+// or after the default expiration of the backend if it is zero.
+func (c *adaptedCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.set(key, value, expiration)
+}
+
+// Get returns the value of key from the backend, which may load it.
+func (c *adaptedCache) Get(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	v, err := c.backend.Get(key)
+	c.count(key, err)
+	return v, err
+}
+
+func (c *adaptedCache) GetAsync(key interface{}) <-chan Result {
+	ch := make(chan Result, 1)
+	go func() {
+		v, err := c.Get(key)
+		ch <- Result{Value: v, Err: err}
+	}()
+	return ch
+}
+
+func (c *adaptedCache) GetIFPresent(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	v, err := c.backend.GetIFPresent(key)
+	c.count(key, err)
+	return v, err
+}
+
+func (c *adaptedCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	if onLoad {
+		return c.backend.GetIFPresent(key)
+	}
+	return c.GetIFPresent(key)
+}
+
+func (c *adaptedCache) lookup(key interface{}) (interface{}, error) {
+	return c.backend.GetIFPresent(key)
+}
+
+func (c *adaptedCache) Has(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	_, ok := c.peek(key)
+	return ok
+}
+
+// GetALL returns the entries of the backend, which leaves out the expired ones.
+func (c *adaptedCache) GetALL() map[interface{}]interface{} {
+	items := make(map[interface{}]interface{})
+	for _, key := range c.Keys() {
+		if v, ok := c.peek(key); ok {
+			items[key] = v
+		}
+	}
+	return items
+}
+
+// GetALLIncludingExpired is GetALL, the backend does not list its expired entries.
+func (c *adaptedCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.GetALL()
+}
+
+func (c *adaptedCache) Snapshot() map[interface{}]interface{} {
+	return c.GetALL()
+}
+
+// Export writes the entries of the backend to w with codec, without their expiration.
+func (c *adaptedCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(c, w, codec)
+}
+
+func (c *adaptedCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(c, r, codec)
+}
+
+func (c *adaptedCache) entries() []SnapshotEntry {
+	items := c.GetALL()
+	entries := make([]SnapshotEntry, 0, len(items))
+	for k, v := range items {
+		entries = append(entries, SnapshotEntry{Key: k, Value: v})
+	}
+	return entries
+}
+
+func (c *adaptedCache) importEntries(entries []SnapshotEntry) {
+	c.restore(entries, c.Set, c.Touch)
+}
+
+func (c *adaptedCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{}, len(keys))
+	var firstErr error
+	for _, key := range keys {
+		if c.checkKey(key) != nil {
+			continue
+		}
+		v, err := c.Get(key)
+		switch {
+		case err == nil:
+			values[key] = v
+		case err != KeyNotFoundError && firstErr == nil:
+			firstErr = err
+		}
+	}
+	return values, firstErr
+}
+
+func (c *adaptedCache) Warm(keys []interface{}, concurrency int) error {
+	return c.warm(keys, concurrency, c.Has, func(key interface{}, _ bool) (interface{}, error) {
+		return c.Get(key)
+	})
+}
+
+func (c *adaptedCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return c.doShared(key, fn)
+	}
+	return c.doCached(key, fn, c.setLoaded)
+}
+
+// setLoaded stores the result of Do, unless key was set meanwhile, and returns the stored value.
+func (c *adaptedCache) setLoaded(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if v, ok := c.peek(key); c.superseded(key) && ok {
+		// the key was set while it was loading, keep the newer value
+		return v, nil
+	}
+	var d time.Duration
+	if ttl != nil {
+		d = *ttl
+	}
+	if err := c.set(key, value, d); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *adaptedCache) Remove(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	return c.remove(key)
+}
+
+func (c *adaptedCache) RemoveAll(keys ...interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.removeKeys(keys, c.remove)
+}
+
+func (c *adaptedCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	v, ok := c.peek(key)
+	if ok {
+		c.remove(key)
+	}
+	return v, ok
+}
+
+func (c *adaptedCache) RemoveGet(key interface{}) (interface{}, bool) {
+	return c.GetAndRemove(key)
+}
+
+func (c *adaptedCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if v, ok := c.peek(key); ok {
+		return v, true
+	}
+	c.set(key, value, 0)
+	return value, false
+}
+
+func (c *adaptedCache) CompareAndSwap(key, old, new interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if v, ok := c.peek(key); !ok || !equalValues(v, old) {
+		return false
+	}
+	return c.set(key, new, 0) == nil
+}
+
+func (c *adaptedCache) CompareAndDelete(key, old interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if v, ok := c.peek(key); !ok || !equalValues(v, old) {
+		return false
+	}
+	return c.remove(key)
+}
+
+// Update replaces the value for key with the result of fn, which receives the
+// current value and whether it exists. fn must not call back into the cache.
+func (c *adaptedCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	current, exists := c.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	return c.set(key, value, 0)
+}
+
+// Touch sets the value of key again, which restarts its expiration with ttl
+// if given, or with the default expiration otherwise.
+func (c *adaptedCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	v, ok := c.peek(key)
+	if !ok {
+		return false
+	}
+	var d time.Duration
+	if len(ttl) > 0 {
+		d = ttl[0]
+	}
+	return c.set(key, v, d) == nil
+}
+
+// SetWithPriority sets the value of key, the entries of a backend have no priority.
+func (c *adaptedCache) SetWithPriority(key, value interface{}, _ Priority) {
+	c.Set(key, value)
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (c *adaptedCache) RemoveAt(key interface{}, t time.Time) {
+	c.RemoveAfter(key, t.Sub(c.clock.Now()))
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (c *adaptedCache) RemoveAfter(key interface{}, d time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, d, c.Remove)
+}
+
+func (c *adaptedCache) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, delta)
+}
+
+func (c *adaptedCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, -delta)
+}
+
+// Purge removes all the keys of the backend.
+func (c *adaptedCache) Purge() {
+	c.RemoveAll(c.KeysIncludingExpired()...)
+}
+
+// SetCapacity is ignored, the capacity is that of the backend.
+func (c *adaptedCache) SetCapacity(int) {}
+
+func (c *adaptedCache) Keys() []interface{} {
+	keys, err := c.backend.Keys()
+	if err != nil {
+		return nil
+	}
+	return keys
+}
+
+// KeysIncludingExpired is Keys, the backend does not list its expired entries.
+func (c *adaptedCache) KeysIncludingExpired() []interface{} {
+	return c.Keys()
+}
+
+func (c *adaptedCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(c.Keys(), less)
+}
+
+func (c *adaptedCache) KeysWithPrefix(prefix string) []interface{} {
+	return c.keysWithPrefix(prefix, c.Keys, c.Has)
+}
+
+func (c *adaptedCache) Len() int {
+	return len(c.KeysIncludingExpired())
+}
+
+func (c *adaptedCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(c, w, format)
+}
+
+func (c *adaptedCache) dumpEntries() ([]dumpEntry, int) {
+	items := c.GetALLIncludingExpired()
+	now := c.clock.Now()
+	entries := make([]dumpEntry, 0, len(items))
+	for k, v := range items {
+		entries = append(entries, c.newDumpEntry(k, v, now))
+	}
+	return entries, c.dumpLimit
+}
+
+// Verify returns ErrUnsupported, the invariants of a backend are its own.
+func (c *adaptedCache) Verify() error {
+	return ErrUnsupported
+}
+
+// watch closes the channel right away, the changes of a backend cannot be watched.
+func (c *adaptedCache) watch(key, as interface{}) (<-chan ValueChange, CancelFunc) {
+	ch := make(chan ValueChange)
+	close(ch)
+	return ch, func() {}
+}
+
+func (c *adaptedCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(c, name)
+}
+
+// Close closes the cache, and the backend if it is an io.Closer.
+func (c *adaptedCache) Close() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	if closer, ok := c.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
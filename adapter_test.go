@@ -0,0 +1,321 @@
+package gcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapBackend is a Backend storing its entries in a map, recording their ttl.
+type mapBackend struct {
+	mu     sync.Mutex
+	values map[interface{}]interface{}
+	ttls   map[interface{}]time.Duration
+	closed bool
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{values: make(map[interface{}]interface{}), ttls: make(map[interface{}]time.Duration)}
+}
+
+func (b *mapBackend) Get(key interface{}) (interface{}, error) {
+	return b.GetIFPresent(key)
+}
+
+func (b *mapBackend) GetIFPresent(key interface{}) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v, ok := b.values[key]
+	if !ok {
+		return nil, KeyNotFoundError
+	}
+	return v, nil
+}
+
+func (b *mapBackend) Set(key, value interface{}, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = value
+	b.ttls[key] = ttl
+	return nil
+}
+
+func (b *mapBackend) Remove(key interface{}) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.values[key]
+	delete(b.values, key)
+	return ok, nil
+}
+
+func (b *mapBackend) Keys() ([]interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]interface{}, 0, len(b.values))
+	for k := range b.values {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (b *mapBackend) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestAdapt(t *testing.T) {
+	b := newMapBackend()
+	c := Adapt(b)
+
+	c.Set("a", 1)
+	if v, err := c.Get("a"); v != 1 || err != nil {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+	if _, err := c.Get("b"); err != KeyNotFoundError {
+		t.Errorf("Get() of a missing key = %v", err)
+	}
+	if v, err := c.GetIFPresent("a"); v != 1 || err != nil {
+		t.Errorf("GetIFPresent() = %v, %v", v, err)
+	}
+	if r := <-c.GetAsync("a"); r.Value != 1 || r.Err != nil {
+		t.Errorf("GetAsync() = %+v", r)
+	}
+	if c.HitCount() != 3 || c.MissCount() != 1 {
+		t.Errorf("unexpected stats %+v", c.Stats())
+	}
+	if !c.Has("a") || c.Has("b") {
+		t.Error("Has() did not look the backend up")
+	}
+	if _, err := c.Get([]int{1}); err == nil {
+		t.Error("Get() accepted an invalid key")
+	}
+}
+
+func TestAdaptExpiration(t *testing.T) {
+	b := newMapBackend()
+	c := Adapt(b)
+
+	c.Set("a", 1)
+	c.SetWithExpire("b", 1, time.Minute)
+	c.SetExpiration(time.Second)
+	c.Set("c", 1)
+	c.SetWithPriority("d", 1, HighPriority)
+	if b.ttls["a"] != 0 || b.ttls["b"] != time.Minute || b.ttls["c"] != time.Second || b.ttls["d"] != time.Second {
+		t.Errorf("the backend got the ttls %v", b.ttls)
+	}
+	if !c.Touch("a", time.Hour) || b.ttls["a"] != time.Hour {
+		t.Errorf("Touch() did not set the ttl, got %v", b.ttls["a"])
+	}
+	if !c.Touch("b") || b.ttls["b"] != time.Second {
+		t.Errorf("Touch() did not set the default ttl, got %v", b.ttls["b"])
+	}
+	if c.Touch("missing") {
+		t.Error("Touch() set a missing key")
+	}
+}
+
+func TestAdaptWrites(t *testing.T) {
+	c := Adapt(newMapBackend())
+
+	if v, loaded := c.GetOrSet("a", 1); v != 1 || loaded {
+		t.Errorf("GetOrSet() of a missing key = %v, %v", v, loaded)
+	}
+	if v, loaded := c.GetOrSet("a", 2); v != 1 || !loaded {
+		t.Errorf("GetOrSet() = %v, %v", v, loaded)
+	}
+	s := c.(Swapper)
+	if !s.CompareAndSwap("a", 1, 2) || s.CompareAndSwap("a", 1, 3) {
+		t.Error("CompareAndSwap() did not compare the current value")
+	}
+	if s.CompareAndDelete("a", 1) || !s.CompareAndDelete("a", 2) || c.Has("a") {
+		t.Error("CompareAndDelete() did not compare the current value")
+	}
+	err := c.Update("u", func(current interface{}, exists bool) (interface{}, error) {
+		if exists {
+			t.Errorf("Update() found %v", current)
+		}
+		return 1, nil
+	})
+	if v, _ := c.Get("u"); v != 1 || err != nil {
+		t.Errorf("Update() = %v, set %v", err, v)
+	}
+	failed := errors.New("failed")
+	if err := c.Update("u", func(interface{}, bool) (interface{}, error) { return nil, failed }); err != failed {
+		t.Errorf("Update() = %v", err)
+	}
+	if n, err := c.Increment("n", 5); n != 5 || err != nil {
+		t.Errorf("Increment() = %v, %v", n, err)
+	}
+	if n, err := c.Decrement("n", 2); n != 3 || err != nil {
+		t.Errorf("Decrement() = %v, %v", n, err)
+	}
+}
+
+func TestAdaptRemovals(t *testing.T) {
+	c := Adapt(newMapBackend())
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		c.Set(key, key)
+	}
+
+	if !c.Remove("a") || c.Remove("a") || c.Has("a") {
+		t.Error("Remove() did not remove the key once")
+	}
+	if n := c.RemoveAll("b", "c", "missing"); n != 2 {
+		t.Errorf("RemoveAll() = %v", n)
+	}
+	if v, ok := c.GetAndRemove("d"); v != "d" || !ok || c.Has("d") {
+		t.Errorf("GetAndRemove() = %v, %v", v, ok)
+	}
+	if v, ok := c.RemoveGet("e"); v != "e" || !ok || c.Has("e") {
+		t.Errorf("RemoveGet() = %v, %v", v, ok)
+	}
+	c.RemoveAfter("f", time.Millisecond)
+	c.RemoveAt("g", time.Now())
+	c.Set("g", "g")
+	for deadline := time.Now().Add(time.Second); c.Len() != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 0 {
+		t.Errorf("RemoveAfter() and RemoveAt() left %v", c.Keys())
+	}
+
+	c.Set("a", 1)
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Purge() left %v keys", c.Len())
+	}
+}
+
+func TestAdaptListing(t *testing.T) {
+	c := Adapt(newMapBackend())
+	c.Set("b", 2)
+	c.Set("a", 1)
+	c.Set(1, 3)
+
+	if c.Len() != 3 || len(c.Keys()) != 3 || len(c.KeysIncludingExpired()) != 3 {
+		t.Errorf("Len() = %v, Keys() = %v", c.Len(), c.Keys())
+	}
+	for name, items := range map[string]map[interface{}]interface{}{
+		"GetALL":                 c.GetALL(),
+		"GetALLIncludingExpired": c.GetALLIncludingExpired(),
+		"Snapshot":               c.Snapshot(),
+	} {
+		if len(items) != 3 || items["a"] != 1 || items[1] != 3 {
+			t.Errorf("%s() = %v", name, items)
+		}
+	}
+	keys := c.KeysSorted(func(a, b interface{}) bool { return fmt.Sprint(a) < fmt.Sprint(b) })
+	if fmt.Sprint(keys) != "[1 a b]" {
+		t.Errorf("KeysSorted() = %v", keys)
+	}
+	if keys := c.KeysWithPrefix("a"); fmt.Sprint(keys) != "[a]" {
+		t.Errorf("KeysWithPrefix() = %v", keys)
+	}
+	values, err := c.GetMulti([]interface{}{"a", "missing", []int{1}})
+	if len(values) != 1 || values["a"] != 1 || err != nil {
+		t.Errorf("GetMulti() = %v, %v", values, err)
+	}
+}
+
+func TestAdaptLoads(t *testing.T) {
+	c := Adapt(newMapBackend())
+
+	if v, err := c.Do("a", func() (interface{}, error) { return 1, nil }, true); v != 1 || err != nil || !c.Has("a") {
+		t.Errorf("Do() = %v, %v", v, err)
+	}
+	if v, err := c.Do("b", func() (interface{}, error) { return 2, nil }, false); v != 2 || err != nil || c.Has("b") {
+		t.Errorf("Do() without caching = %v, %v", v, err)
+	}
+	if err := c.Warm([]interface{}{"a"}, 2); err != nil {
+		t.Errorf("Warm() = %v", err)
+	}
+	if err := c.Warm([]interface{}{"a", "missing"}, 2); err == nil {
+		t.Error("Warm() did not report the key the backend could not get")
+	}
+}
+
+func TestAdaptExportImport(t *testing.T) {
+	c := Adapt(newMapBackend())
+	c.Set("a", "x")
+	c.Set("b", "y")
+	var buf bytes.Buffer
+	if err := c.Export(&buf, JSONSnapshotCodec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := Adapt(newMapBackend())
+	if err := imported.Import(&buf, JSONSnapshotCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if items := imported.GetALL(); len(items) != 2 || items["a"] != "x" || items["b"] != "y" {
+		t.Errorf("Import() set %v", items)
+	}
+}
+
+func TestAdaptDump(t *testing.T) {
+	c := Adapt(newMapBackend())
+	c.Set("a", 1)
+	var buf bytes.Buffer
+	if err := c.(Dumper).Dump(&buf, DumpText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "a") {
+		t.Errorf("Dump() wrote %q", buf.String())
+	}
+}
+
+func TestAdaptUnsupported(t *testing.T) {
+	c := Adapt(newMapBackend())
+	c.Set("a", 1)
+
+	if _, ok := c.(Pinner); ok {
+		t.Error("the adapted cache is a Pinner")
+	}
+	if _, ok := c.(Indexer); ok {
+		t.Error("the adapted cache is an Indexer")
+	}
+	if _, ok := c.(Watcher); ok {
+		t.Error("the adapted cache is a Watcher")
+	}
+	if _, ok := c.(Explainer); ok {
+		t.Error("the adapted cache is an Explainer")
+	}
+	if err := c.Verify(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Verify() = %v", err)
+	}
+
+	ns := c.Namespace("ns")
+	if ns.Pin("a") || len(ns.GetByIndex("index", 1)) != 0 || ns.Explain("a").Present {
+		t.Error("the namespace supports what the adapted cache does not")
+	}
+	if _, ok := <-func() <-chan ValueChange { ch, _ := ns.Watch("a"); return ch }(); ok {
+		t.Error("the namespace watched the adapted cache")
+	}
+}
+
+func TestAdaptNamespace(t *testing.T) {
+	c := Adapt(newMapBackend())
+	ns := c.Namespace("ns")
+	ns.Set("a", 1)
+	if v, err := ns.Get("a"); v != 1 || err != nil {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+	if c.Has("a") || c.Len() != 1 {
+		t.Errorf("the namespace set %v", c.Keys())
+	}
+}
+
+func TestAdaptClose(t *testing.T) {
+	b := newMapBackend()
+	c := Adapt(b)
+	if err := c.Close(); err != nil || !b.closed {
+		t.Errorf("Close() = %v, closed the backend: %v", err, b.closed)
+	}
+	if err := c.Close(); err != ClosedError {
+		t.Errorf("Close() twice = %v", err)
+	}
+}
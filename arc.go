@@ -2,6 +2,8 @@ package gcache
 
 import (
 	"container/list"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -23,59 +25,119 @@ func newARC(cb *CacheBuilder) *ARC {
 
 	c.init()
 	c.loadGroup.cache = c
+	c.evictKey = c.remove
+	c.monitorMemory(c.shed, c.Len)
+	c.startAutoSnapshot(c.Export)
 	return c
 }
 
 func (c *ARC) init() {
 	c.items = make(map[interface{}]*arcItem)
+	c.pinned = nil
+	c.priorities = nil
+	c.copies = nil // Snapshots in progress keep reading the previous entries
 	c.t1 = newARCList()
 	c.t2 = newARCList()
 	c.b1 = newARCList()
 	c.b2 = newARCList()
 }
 
+// removeLastT1 removes the least recent evictable key of t1, lowest class first.
+func (c *ARC) removeLastT1() (key interface{}, ok bool) {
+	c.evictByClass(func() bool {
+		key, ok = c.t1.RemoveLast(c.evictable)
+		return ok
+	})
+	return key, ok
+}
+
 func (c *ARC) replace(key interface{}) {
 	var old interface{}
-	if (c.t1.Len() > 0 && c.b2.Has(key) && c.t1.Len() == c.part) || (c.t1.Len() > c.part) {
-		old = c.t1.RemoveTail()
-		c.b1.PushFront(old)
-	} else if c.t2.l.Len() > 0 {
-		old = c.t2.RemoveTail()
-		c.b2.PushFront(old)
-	} else {
+	ok := false
+	c.evictByClass(func() bool {
+		if (c.t1.Len() > 0 && c.b2.Has(key) && c.t1.Len() == c.part) || (c.t1.Len() > c.part) {
+			if old, ok = c.t1.RemoveLast(c.evictable); ok {
+				c.b1.PushFront(old)
+			}
+		}
+		if !ok {
+			if old, ok = c.t2.RemoveLast(c.evictable); ok {
+				c.b2.PushFront(old)
+			}
+		}
+		return ok
+	})
+	if !ok {
 		return
 	}
 	item, ok := c.items[old]
 	if ok {
 		delete(c.items, old)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(item.key, item.value)
-		}
+		c.forget(old)
+		c.release(old, item.value)
+		c.recordEviction(old, item.created)
+		c.evicted(item.key, item.value, item.expiration, item.accessExpiration)
 	}
 }
 
 func (c *ARC) Set(key, value interface{}) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.set(key, value)
 }
 
+// SetWithExpire sets a new key-value pair which expires after expiration,
+// instead of the Expiration of the cache.
+func (c *ARC) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	it, err := c.set(key, value)
+	if err != nil {
+		return
+	}
+	t := c.clock.Now().Add(expiration)
+	it.(*arcItem).expiration = &t
+}
+
 func (c *ARC) set(key, value interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	c.written(key)
+	value, err := c.encode(key, value)
+	if err != nil {
+		c.remove(key)
+		return nil, err
+	}
+
 	item, ok := c.items[key]
 	if ok {
+		c.updated(key, item.value, value)
+		c.release(key, item.value)
 		item.value = value
 	} else {
 		item = &arcItem{
-			key:   key,
-			value: value,
+			key:     key,
+			value:   value,
+			created: c.clock.Now(),
 		}
 		c.items[key] = item
 	}
 
 	if c.expiration != nil {
-		t := time.Now().Add(*c.expiration)
+		t := c.clock.Now().Add(c.jitter(*c.expiration))
 		item.expiration = &t
 	}
+	item.accessExpiration = c.newAccessExpiration()
+	item.refreshAt = c.newRefreshAt()
+
+	defer func() {
+		c.added(key, value)
+	}()
+
+	if c.t1.Has(key) || c.t2.Has(key) {
+		return item, nil
+	}
 
 	if elt := c.b1.Lookup(key); elt != nil {
 		c.part = minInt(c.size, c.part+maxInt(c.b2.Len()/c.b1.Len(), 1))
@@ -97,14 +159,14 @@ func (c *ARC) set(key, value interface{}) (interface{}, error) {
 		if c.t1.Len() < c.size {
 			c.b1.RemoveTail()
 			c.replace(key)
-		} else {
-			pop := c.t1.RemoveTail()
+		} else if pop, ok := c.removeLastT1(); ok {
 			item, ok := c.items[pop]
 			if ok {
 				delete(c.items, pop)
-				if c.evictedFunc != nil {
-					(*c.evictedFunc)(item.key, item.value)
-				}
+				c.forget(pop)
+				c.release(pop, item.value)
+				c.recordEviction(pop, item.created)
+				c.evicted(item.key, item.value, item.expiration, item.accessExpiration)
 			}
 		}
 	} else {
@@ -119,10 +181,6 @@ func (c *ARC) set(key, value interface{}) (interface{}, error) {
 
 	c.t1.PushFront(key)
 
-	if c.addedFunc != nil {
-		(*c.addedFunc)(key, value)
-	}
-
 	return item, nil
 }
 
@@ -135,6 +193,12 @@ func (c *ARC) Get(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// GetAsync returns a channel which receives the result of Get for key once it is
+// available, without waiting for the LoaderFunc.
+func (c *ARC) GetAsync(key interface{}) <-chan Result {
+	return c.getAsync(key, c.getValue, c.getWithLoader)
+}
+
 // Get a value from cache pool using key if it exists.
 // If it dose not exists key, returns KeyNotFoundError.
 // And send a request which refresh value for specified key if cache object has LoaderFunc.
@@ -146,140 +210,697 @@ func (c *ARC) GetIFPresent(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
-func (c *ARC) get(key interface{}, onLoad bool) (interface{}, error) {
-	rl := false
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating the eviction policy or loading it.
+func (c *ARC) Has(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
 	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.items[key]
+	return ok && !item.IsExpired(c.clock)
+}
+
+// GetMulti returns the values of all keys which are cached or can be loaded.
+func (c *ARC) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	return c.getMulti(keys, c.getValue, c.getWithLoader, c.setLoaded)
+}
+
+// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+// concurrency loads at a time, e.g. to fill the cache before taking traffic.
+func (c *ARC) Warm(keys []interface{}, concurrency int) error {
+	return c.warm(keys, concurrency, c.Has, c.getWithLoader)
+}
+
+func (c *ARC) get(key interface{}, onLoad bool) (interface{}, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
 	if elt := c.t1.Lookup(key); elt != nil {
-		c.mu.RUnlock()
-		rl = true
-		c.mu.Lock()
-		c.t1.Remove(key, elt)
 		item := c.items[key]
-		if !item.IsExpired(nil) {
+		if !item.IsExpired(c.clock) {
+			c.t1.Remove(key, elt)
 			c.t2.PushFront(key)
-			c.mu.Unlock()
+			item.accessExpiration = c.newAccessExpiration()
 			if !onLoad {
-				c.stats.IncrHitCount()
+				c.stats.recordHit(key)
 			}
 			return item, nil
 		}
-		c.b2.PushFront(key)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(key, elt.Value)
+		if c.keepStale(item.expiration, item.accessExpiration) {
+			return c.miss(key, onLoad)
 		}
-		c.mu.Unlock()
-	}
-	if elt := c.t2.Lookup(key); elt != nil {
-		c.mu.RUnlock()
-		rl = true
-		c.mu.Lock()
+		c.t1.Remove(key, elt)
+		c.b2.PushFront(key)
+		delete(c.items, key)
+		c.forget(key)
+		c.release(key, item.value)
+		c.recordExpiration(item.created)
+		c.expired(key, item.value)
+	} else if elt := c.t2.Lookup(key); elt != nil {
 		item := c.items[key]
-		if !item.IsExpired(nil) {
+		if !item.IsExpired(c.clock) {
 			c.t2.MoveToFront(elt)
-			c.mu.Unlock()
+			item.accessExpiration = c.newAccessExpiration()
 			if !onLoad {
-				c.stats.IncrHitCount()
+				c.stats.recordHit(key)
 			}
 			return item, nil
 		}
+		if c.keepStale(item.expiration, item.accessExpiration) {
+			return c.miss(key, onLoad)
+		}
 		c.t2.Remove(key, elt)
 		c.b2.PushFront(key)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(key, elt.Value)
-		}
-		c.mu.Unlock()
+		delete(c.items, key)
+		c.forget(key)
+		c.release(key, item.value)
+		c.recordExpiration(item.created)
+		c.expired(key, item.value)
 	}
+	return c.miss(key, onLoad)
+}
 
-	if !rl {
-		c.mu.RUnlock()
+// lookup returns the stored value of key for the load group, without counting
+// a hit or miss. Expired entries are misses, and are left in place.
+func (c *ARC) lookup(key interface{}) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, nil
 	}
+	return nil, KeyNotFoundError
+}
+
+func (c *ARC) miss(key interface{}, onLoad bool) (interface{}, error) {
 	if !onLoad {
-		c.stats.IncrMissCount()
+		c.stats.recordMiss(key)
 	}
 	return nil, KeyNotFoundError
 }
 
 func (c *ARC) getValue(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
 	it, err := c.get(key, false)
 	if err != nil {
+		c.missed(key)
 		return nil, err
 	}
-	return it.(*arcItem).value, nil
+	item := it.(*arcItem)
+	if c.xfetchBeta > 0 || c.refreshesAfterWrite() {
+		c.mu.RLock()
+		v, expiration, delta, refreshAt := item.value, item.expiration, item.delta, item.refreshAt
+		c.mu.RUnlock()
+		c.refreshEarly(key, expiration, delta, c.setLoaded)
+		c.refreshAfterWrite(key, refreshAt, c.setLoaded)
+		return c.decode(key, v)
+	}
+	return c.decode(key, item.value)
+}
+
+// Do executes fn once for all the concurrent calls with the same key, which wait for
+// it and share its result, the way concurrent loads of a key share one call of the
+// LoaderFunc.
+func (c *ARC) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return c.doShared(key, fn)
+	}
+	v, err := c.doCached(key, fn, c.setLoaded)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(key, v)
 }
 
 func (c *ARC) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderFunc == nil {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if c.loaderExpireFunc == nil {
 		return nil, KeyNotFoundError
 	}
-	item, _, err := c.load(key, func(v interface{}, e error) (interface{}, error) {
-		if e == nil {
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			return c.set(key, v)
+	v, _, err := c.load(key, c.setLoaded, isWait)
+	if err != nil {
+		if err != KeyNotFoundError {
+			if v, ok := c.stale(key); ok {
+				return v, nil
+			}
 		}
-		return nil, e
-	}, isWait)
+		return nil, err
+	}
+	return c.decode(key, v)
+}
+
+// stale returns the value of an expired entry which StaleIfError still allows to serve.
+func (c *ARC) stale(key interface{}) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && c.keepStale(item.expiration, item.accessExpiration) {
+		return c.decoded(key, item.value)
+	}
+	return nil, false
+}
+
+// stores a loaded value along with its expiration and the time it took to load
+func (c *ARC) setLoaded(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if item, ok := c.items[key]; c.superseded(key) && ok && !item.IsExpired(c.clock) {
+		// the key was set while it was loading, keep the newer value
+		return item.value, nil
+	}
+	c.refreshing = true
+	it, err := c.set(key, value)
+	c.refreshing = false
 	if err != nil {
 		return nil, err
 	}
-	return item.(*arcItem).value, nil
+	item := it.(*arcItem)
+	if ttl != nil {
+		t := c.clock.Now().Add(*ttl)
+		item.expiration = &t
+	}
+	item.delta = elapsed
+	return item.value, nil
+}
+
+// peek returns the value for key if it is present and not expired,
+// without updating recency or statistics.
+func (c *ARC) peek(key interface{}) (interface{}, bool) {
+	if v, ok := c.stored(key); ok {
+		return c.decoded(key, v)
+	}
+	return nil, false
+}
+
+// stored returns the stored form of the value of key, if it is present.
+func (c *ARC) stored(key interface{}) (interface{}, bool) {
+	if !c.t1.Has(key) && !c.t2.Has(key) {
+		return nil, false
+	}
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, true
+	}
+	return nil, false
 }
 
 // Remove removes the provided key from the cache.
 func (c *ARC) Remove(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
 	return c.remove(key)
 }
 
-func (c *ARC) remove(key interface{}) bool {
-	if elt := c.t1.Lookup(key); elt != nil {
-		v := elt.Value.(*arcItem).value
-		c.t1.Remove(key, elt)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(key, v)
+// RemoveAll removes the provided keys from the cache under a single lock acquisition
+// and returns how many of them were in the cache.
+func (c *ARC) RemoveAll(keys ...interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.removeKeys(keys, c.remove)
+}
+
+// GetOrSet returns the existing value for the key if present.
+func (c *ARC) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		if v, ok := c.decoded(key, item.value); ok {
+			item.accessExpiration = c.newAccessExpiration()
+			if elt := c.t1.Lookup(key); elt != nil {
+				c.t1.Remove(key, elt)
+				c.t2.PushFront(key)
+				return v, true
+			}
+			if elt := c.t2.Lookup(key); elt != nil {
+				c.t2.MoveToFront(elt)
+				return v, true
+			}
 		}
-		return true
 	}
+	c.set(key, value)
+	return value, false
+}
+
+// Update atomically replaces the value for key with the result of fn,
+// which receives the current value and whether it exists.
+func (c *ARC) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	current, exists := c.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	_, err = c.set(key, value)
+	return err
+}
+
+// Increment atomically adds delta to the integer stored under key and returns the result.
+// int, int64 and uint64 values keep their type; a missing key is created holding delta.
+func (c *ARC) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, delta)
+}
+
+// Decrement atomically subtracts delta from the integer stored under key and returns the result.
+func (c *ARC) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, -delta)
+}
+
+// Touch resets the expiration of key without reading its value or counting an access.
+// The expiration is restarted with ttl if given, or with the default expiration otherwise.
+func (c *ARC) Touch(key interface{}, ttl ...time.Duration) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.peek(key); !ok {
+		return false
+	}
+	item := c.items[key]
+	item.expiration = c.touchedExpiration(item.expiration, ttl)
+	item.accessExpiration = c.newAccessExpiration()
+	return true
+}
+
+// Pin exempts key from capacity eviction until it is unpinned.
+func (c *ARC) Pin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.peek(key); !ok {
+		return false
+	}
+	c.pin(key)
+	return true
+}
+
+// Unpin makes key evictable again.
+func (c *ARC) Unpin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.unpin(key)
+}
+
+// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+func (c *ARC) SetWithPriority(key, value interface{}, priority Priority) {
+	c.mu.Lock()
+	defer c.unlock()
+	if _, err := c.set(key, value); err == nil {
+		c.prioritize(key, priority)
+	}
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the cache is equal to old.
+func (c *ARC) CompareAndSwap(key, old, new interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	_, err := c.set(key, new)
+	return err == nil
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (c *ARC) CompareAndDelete(key, old interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	return c.remove(key)
+}
+
+// GetAndRemove removes the provided key from the cache and returns its value.
+func (c *ARC) GetAndRemove(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.IsExpired(c.clock) {
+		c.expire(item.created, func() { c.remove(key) })
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, ok := c.decoded(key, item.value)
+	c.remove(key)
+	return value, ok
+}
+
+// RemoveGet removes the provided key from the cache and returns the value it held, so
+// that the value can be cleaned up.
+func (c *ARC) RemoveGet(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, _ := c.decoded(key, item.value)
+	return value, c.remove(key)
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (c *ARC) RemoveAt(key interface{}, t time.Time) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, t.Sub(c.clock.Now()), c.Remove)
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (c *ARC) RemoveAfter(key interface{}, d time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, d, c.Remove)
+}
 
-	if elt := c.t2.Lookup(key); elt != nil {
-		v := elt.Value.(*arcItem).value
+func (c *ARC) remove(key interface{}) bool {
+	if elt := c.t1.Lookup(key); elt != nil {
+		c.t1.Remove(key, elt)
+	} else if elt := c.t2.Lookup(key); elt != nil {
 		c.t2.Remove(key, elt)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(key, v)
-		}
-		return true
+	} else {
+		return false
 	}
 
-	return false
+	item := c.items[key]
+	delete(c.items, key)
+	c.forget(key)
+	c.release(key, item.value)
+	c.evicted(key, item.value, item.expiration, item.accessExpiration)
+	return true
 }
 
-// Keys returns a slice of the keys in the cache.
+// Keys returns a slice of the unexpired keys in the cache.
 func (c *ARC) Keys() []interface{} {
+	return c.keys(true)
+}
+
+// KeysIncludingExpired returns a slice of the keys in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than Keys.
+func (c *ARC) KeysIncludingExpired() []interface{} {
+	return c.keys(false)
+}
+
+func (c *ARC) keys(checkExpired bool) []interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).Keys()
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	keys := []interface{}{}
-	for key := range c.items {
-		keys = append(keys, key)
+	keys := make([]interface{}, 0, len(c.items))
+	for k, item := range c.items {
+		if checkExpired && item.IsExpired(c.clock) {
+			continue
+		}
+		keys = append(keys, k)
 	}
 	return keys
 }
 
-// Returns all key-value pairs in the cache.
+// KeysSorted returns the unexpired keys in the order given by less.
+func (c *ARC) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(c.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys which start with prefix, in order.
+func (c *ARC) KeysWithPrefix(prefix string) []interface{} {
+	return c.keysWithPrefix(prefix, c.Keys, c.Has)
+}
+
+// GetByIndex returns the unexpired entries whose attribute in the named Index is attr.
+func (c *ARC) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getByIndex(name, attr, c.peek)
+}
+
+// RemoveByIndex removes the entries whose attribute in the named Index is attr
+// under a single lock acquisition, and returns how many were removed.
+func (c *ARC) RemoveByIndex(name string, attr interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.removeByIndex(name, attr, c.remove)
+}
+
+// Namespace returns a view of the cache which keeps its keys apart from
+// the keys of the other namespaces and of the cache itself.
+func (c *ARC) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(c, name)
+}
+
+// Returns all unexpired key-value pairs in the cache.
 func (c *ARC) GetALL() map[interface{}]interface{} {
+	return c.all(true)
+}
+
+// GetALLIncludingExpired returns all key-value pairs in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than GetALL.
+func (c *ARC) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.all(false)
+}
+
+func (c *ARC) all(checkExpired bool) map[interface{}]interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).GetALL()
+	}
+	return c.getALL(checkExpired)
+}
+
+func (c *ARC) getALL(checkExpired bool) map[interface{}]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	m := make(map[interface{}]interface{})
 	for k, v := range c.items {
-		m[k] = v.value
+		if checkExpired && v.IsExpired(c.clock) {
+			continue
+		}
+		if value, ok := c.decoded(k, v.value); ok {
+			m[k] = value
+		}
 	}
 
 	return m
 }
 
+// Snapshot returns a consistent copy of the unexpired entries, as of when it is called.
+func (c *ARC) Snapshot() map[interface{}]interface{} {
+	return c.consistentCopy(func() ([]interface{}, func(interface{}) (interface{}, bool)) {
+		items := c.items
+		keys := make([]interface{}, 0, len(items))
+		for k, item := range items {
+			if !item.IsExpired(c.clock) {
+				keys = append(keys, k)
+			}
+		}
+		return keys, func(key interface{}) (interface{}, bool) {
+			item, ok := items[key]
+			if !ok {
+				return nil, false
+			}
+			return c.decoded(key, item.value)
+		}
+	})
+}
+
+// Export writes the unexpired entries, with their expiration, to w with codec.
+func (c *ARC) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(c, w, codec)
+}
+
+// Import sets the entries written by Export which have not expired yet,
+// restoring their expiration.
+func (c *ARC) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(c, r, codec)
+}
+
+func (c *ARC) entries() []SnapshotEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]SnapshotEntry, 0, len(c.items))
+	for k, item := range c.items {
+		if item.IsExpired(c.clock) {
+			continue
+		}
+		if v, ok := c.decoded(k, item.value); ok {
+			entries = append(entries, SnapshotEntry{Key: k, Value: v, Expiration: item.expiration})
+		}
+	}
+	return entries
+}
+
+func (c *ARC) importEntries(entries []SnapshotEntry) {
+	c.restore(entries, c.Set, c.Touch)
+}
+
+// Dump writes the entries of the cache with their age, hits and time to live to w in
+// format, for debugging.
+func (c *ARC) Dump(w io.Writer, format DumpFormat) error {
+	return dump(c, w, format)
+}
+
+// Watch returns a channel receiving the changes of key. See Watcher.
+func (c *ARC) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return c.watch(key, key)
+}
+
+func (c *ARC) dumpEntries() ([]dumpEntry, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]dumpEntry, 0, len(c.items))
+	for k, item := range c.items {
+		entries = append(entries, c.newDumpEntry(k, item.value, item.created, item.expiration, item.accessExpiration))
+	}
+	return entries, c.dumpLimit
+}
+
+// Verify checks the internal invariants of the cache and returns an InvariantError
+// describing the first one which does not hold.
+func (c *ARC) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	lists := []struct {
+		name string
+		al   *arcList
+	}{{"T1", c.t1}, {"T2", c.t2}, {"B1", c.b1}, {"B2", c.b2}}
+	seen := make(map[interface{}]string, len(c.items))
+	for _, l := range lists {
+		if err := l.al.verify(l.name); err != nil {
+			return err
+		}
+		for key := range l.al.keys {
+			if other, ok := seen[key]; ok {
+				return violated("key %v is in both %s and %s", key, other, l.name)
+			}
+			seen[key] = l.name
+		}
+	}
+	if n := c.t1.Len() + c.t2.Len(); n != len(c.items) {
+		return violated("T1 and T2 have %d entries but the cache has %d", n, len(c.items))
+	}
+	for key := range c.items {
+		if name := seen[key]; name != "T1" && name != "T2" {
+			return violated("key %v is not in T1 or T2", key)
+		}
+	}
+	if c.part < 0 || c.part > c.size {
+		return violated("target size of T1 %d is out of [0, %d]", c.part, c.size)
+	}
+	return c.verifyIndexes(func(key interface{}) bool {
+		_, ok := c.items[key]
+		return ok
+	})
+}
+
+// Explain reports the position of key in the eviction order.
+// ARC evicts from the tail of T1 (seen once) or T2 (seen repeatedly) depending
+// on its adaptive target size, so the rank is an approximation.
+func (c *ARC) Explain(key interface{}) EvictionExplanation {
+	if c.checkKey(key) != nil {
+		return notPresentExplanation(key)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	list, other, name := c.t1, c.t2, "T1"
+	if c.t2.Has(key) {
+		list, other, name = c.t2, c.t1, "T2"
+	} else if !c.t1.Has(key) {
+		return notPresentExplanation(key)
+	}
+
+	ex := EvictionExplanation{
+		Key:     key,
+		Present: true,
+		Expired: c.items[key].IsExpired(c.clock),
+	}
+	elt := list.Lookup(key)
+	for e := list.l.Back(); e != elt; e = e.Prev() {
+		ex.Rank++
+	}
+	ex.Recency = list.Len() - 1 - ex.Rank
+
+	victims := c.t2
+	if c.t1.Len() > c.part || c.t2.Len() == 0 {
+		victims = c.t1
+	}
+	if victims.Len() > 0 {
+		ex.NextVictim = victims.l.Back().Value
+	}
+	if victims != list {
+		ex.Rank += other.Len()
+	}
+	if ex.Rank == 0 {
+		ex.Reason = fmt.Sprintf("tail of %s which is above its target size, it is evicted next", name)
+	} else {
+		ex.Reason = fmt.Sprintf("in %s, about %d entries would be evicted first", name, ex.Rank)
+	}
+	ex.Class = c.priorityOf(key)
+	if c.isPinned(key) {
+		ex.Pinned = true
+		ex.Reason = "pinned, it is exempt from eviction"
+	}
+	return ex
+}
+
 // Len returns the number of items in the cache.
 func (c *ARC) Len() int {
 	c.mu.RLock()
@@ -288,24 +909,74 @@ func (c *ARC) Len() int {
 	return len(c.items)
 }
 
+// SetCapacity changes the maximum number of entries at runtime.
+func (c *ARC) SetCapacity(n int) {
+	if n <= 0 {
+		panic("gcache: size <= 0")
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.size = n
+	c.part = minInt(c.part, n)
+	for l := c.t1.Len() + c.t2.Len(); l > n; l = c.t1.Len() + c.t2.Len() {
+		c.replace(nil)
+		if c.t1.Len()+c.t2.Len() == l {
+			// only pinned entries are left
+			break
+		}
+	}
+	for c.b1.Len() > 0 && c.t1.Len()+c.b1.Len() > n {
+		c.b1.RemoveTail()
+	}
+	for c.b2.Len() > 0 && c.t1.Len()+c.t2.Len()+c.b1.Len()+c.b2.Len() > 2*n {
+		c.b2.RemoveTail()
+	}
+}
+
+// shed evicts up to n entries following the eviction policy and returns how many were evicted.
+func (c *ARC) shed(n int) int {
+	c.mu.Lock()
+	defer c.unlock()
+	evicted := 0
+	for ; evicted < n; evicted++ {
+		l := c.t1.Len() + c.t2.Len()
+		c.replace(nil)
+		if c.t1.Len()+c.t2.Len() == l {
+			// only pinned entries are left
+			break
+		}
+	}
+	return evicted
+}
+
 // Purge is used to completely clear the cache
 func (c *ARC) Purge() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if c.purgeEvict {
+		for key, item := range c.items {
+			c.evicted(key, item.value, item.expiration, item.accessExpiration)
+		}
+	}
 	c.init()
+	c.resetArena()
+	c.resetIndexes()
 }
 
-// returns boolean value whether this item is expired or not.
-func (it *arcItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := time.Now()
-		now = &t
+// Close cancels scheduled removals, waits for background loads to finish and drops all
+// entries.
+func (c *ARC) Close() error {
+	if err := c.close(); err != nil {
+		return err
 	}
-	return it.expiration.Before(*now)
+	c.Purge()
+	return nil
+}
+
+// returns boolean value whether this item is expired or not.
+func (it *arcItem) IsExpired(clock Clock) bool {
+	return isExpired(clock, it.expiration, it.accessExpiration) || released(it.value)
 }
 
 type arcList struct {
@@ -314,9 +985,13 @@ type arcList struct {
 }
 
 type arcItem struct {
-	key        interface{}
-	value      interface{}
-	expiration *time.Time
+	key              interface{}
+	value            interface{}
+	expiration       *time.Time
+	accessExpiration *time.Time
+	delta            time.Duration // time it took to load the value
+	refreshAt        *time.Time    // when to reload the value in the background
+	created          time.Time
 }
 
 func newARCList() *arcList {
@@ -360,6 +1035,30 @@ func (al *arcList) RemoveTail() interface{} {
 	return key
 }
 
+// RemoveLast removes the key closest to the tail which satisfies ok.
+func (al *arcList) RemoveLast(ok func(interface{}) bool) (interface{}, bool) {
+	for elt := al.l.Back(); elt != nil; elt = elt.Prev() {
+		if key := elt.Value; ok(key) {
+			al.Remove(key, elt)
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// verify checks that the keys of the list map to their elements.
+func (al *arcList) verify(name string) error {
+	if al.l.Len() != len(al.keys) {
+		return violated("%s has %d elements but %d keys", name, al.l.Len(), len(al.keys))
+	}
+	for e := al.l.Front(); e != nil; e = e.Next() {
+		if al.keys[e.Value] != e {
+			return violated("key %v of %s maps to another element", e.Value, name)
+		}
+	}
+	return nil
+}
+
 func (al *arcList) Len() int {
 	return al.l.Len()
 }
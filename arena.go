@@ -0,0 +1,191 @@
+package gcache
+
+import (
+	"sync"
+)
+
+const (
+	arenaChunkSize = 1 << 20 // bytes allocated at once for a size class
+	arenaMinSlot   = 64      // bytes in the slots of the smallest size class
+	arenaClasses   = 15      // size classes, from 64 bytes to arenaChunkSize
+)
+
+// Store []byte values in large preallocated chunks of memory rather than as
+// individual heap objects, so that a cache holding gigabytes of them does not
+// inflate garbage collection work. Values are copied in on Set and out on Get.
+// Values of other types, and values larger than a megabyte, are stored as is;
+// use SerializeFunc to store other types in the arena.
+// AddedFunc, EvictedFunc and WeightingFunc receive an ArenaRef in place of the value.
+func (cb *CacheBuilder) Bytes() *CacheBuilder {
+	cb.bytes = true
+	return cb
+}
+
+// ArenaRef is how a []byte value stored in the arena of a Bytes cache is referenced.
+// It holds no pointers, so the garbage collector does not need to scan it.
+type ArenaRef struct {
+	class  uint8
+	slot   uint32
+	gen    uint32 // generation of the slot when the value was stored
+	length uint32
+}
+
+// Len returns the length of the referenced value.
+func (ref ArenaRef) Len() int {
+	return int(ref.length)
+}
+
+// byteArena allocates fixed size slots from chunks of memory, with a size
+// class for every power of two. The slots of a size class are reused once
+// freed; a generation number per slot tells stale references apart.
+type byteArena struct {
+	mu      sync.Mutex
+	classes [arenaClasses]arenaClass
+}
+
+type arenaClass struct {
+	chunks [][]byte
+	gens   []uint32 // generation of each slot, incremented when it is freed
+	free   []uint32 // free slots
+}
+
+func newByteArena() *byteArena {
+	return &byteArena{}
+}
+
+func slotSize(class int) int {
+	return arenaMinSlot << uint(class)
+}
+
+// alloc copies data into a free slot and returns its reference, or false if data is too large.
+func (a *byteArena) alloc(data []byte) (ArenaRef, bool) {
+	class := 0
+	for slotSize(class) < len(data) {
+		class++
+		if class == arenaClasses {
+			return ArenaRef{}, false
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ac := &a.classes[class]
+	if len(ac.free) == 0 {
+		ac.grow(slotSize(class))
+	}
+	slot := ac.free[len(ac.free)-1]
+	ac.free = ac.free[:len(ac.free)-1]
+	copy(ac.slot(slot, slotSize(class)), data)
+	return ArenaRef{class: uint8(class), slot: slot, gen: ac.gens[slot], length: uint32(len(data))}, true
+}
+
+// load returns a copy of the value of ref, or false if its slot was freed since.
+func (a *byteArena) load(ref ArenaRef) ([]byte, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ac := &a.classes[ref.class]
+	if int(ref.slot) >= len(ac.gens) || ac.gens[ref.slot] != ref.gen {
+		return nil, false
+	}
+	data := make([]byte, ref.length)
+	copy(data, ac.slot(ref.slot, slotSize(int(ref.class))))
+	return data, true
+}
+
+// free makes the slot of ref available again.
+func (a *byteArena) free(ref ArenaRef) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ac := &a.classes[ref.class]
+	if int(ref.slot) >= len(ac.gens) || ac.gens[ref.slot] != ref.gen {
+		return
+	}
+	ac.gens[ref.slot]++
+	ac.free = append(ac.free, ref.slot)
+}
+
+// reset frees all slots, keeping the chunks for reuse.
+func (a *byteArena) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.classes {
+		ac := &a.classes[i]
+		ac.free = ac.free[:0]
+		for slot := range ac.gens {
+			ac.gens[slot]++
+			ac.free = append(ac.free, uint32(slot))
+		}
+	}
+}
+
+// grow allocates a chunk of slots of the given size.
+func (ac *arenaClass) grow(size int) {
+	ac.chunks = append(ac.chunks, make([]byte, arenaChunkSize))
+	first := len(ac.gens)
+	n := arenaChunkSize / size
+	ac.gens = append(ac.gens, make([]uint32, n)...)
+	for slot := first + n - 1; slot >= first; slot-- {
+		ac.free = append(ac.free, uint32(slot))
+	}
+}
+
+func (ac *arenaClass) slot(slot uint32, size int) []byte {
+	perChunk := arenaChunkSize / size
+	chunk := ac.chunks[int(slot)/perChunk]
+	offset := (int(slot) % perChunk) * size
+	return chunk[offset : offset+size]
+}
+
+// store returns the reference of value in the arena if it belongs there.
+func (c *baseCache) store(value interface{}) interface{} {
+	if data, ok := value.([]byte); ok {
+		if ref, ok := c.arena.alloc(data); ok {
+			return ref
+		}
+	}
+	return value
+}
+
+// fetch returns the value stored by store.
+func (c *baseCache) fetch(value interface{}) (interface{}, error) {
+	ref, ok := value.(ArenaRef)
+	if !ok {
+		return value, nil
+	}
+	data, ok := c.arena.load(ref)
+	if !ok {
+		return nil, KeyNotFoundError
+	}
+	return data, nil
+}
+
+// free frees the memory of a value which is no longer stored in the cache.
+func (c *baseCache) free(value interface{}) {
+	if wv, ok := value.(*WeakValue); ok && c.weak != nil {
+		c.weak.drop(wv)
+		return
+	}
+	if c.overflow != nil {
+		if dv, ok := value.(*DiskValue); ok {
+			c.overflow.remove(dv.Path)
+		}
+	}
+	if c.arena == nil {
+		return
+	}
+	if ref, ok := value.(ArenaRef); ok {
+		c.arena.free(ref)
+	}
+}
+
+// resetArena frees the memory and files of all values, when the cache is purged.
+func (c *baseCache) resetArena() {
+	if c.arena != nil {
+		c.arena.reset()
+	}
+	if c.overflow != nil {
+		c.overflow.reset()
+	}
+	if c.weak != nil {
+		c.weak.reset()
+	}
+}
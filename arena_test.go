@@ -0,0 +1,79 @@
+package gcache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestByteArena(t *testing.T) {
+	a := newByteArena()
+	small, ok := a.alloc([]byte("hello"))
+	if !ok || small.Len() != 5 {
+		t.Fatalf("unexpected ref %+v", small)
+	}
+	large, ok := a.alloc(make([]byte, 1000))
+	if !ok || large.class == small.class {
+		t.Fatalf("expected a larger size class, got %+v", large)
+	}
+	if _, ok := a.alloc(make([]byte, arenaChunkSize+1)); ok {
+		t.Error("expected values larger than a chunk to be rejected")
+	}
+
+	if data, ok := a.load(small); !ok || string(data) != "hello" {
+		t.Errorf("unexpected value %q", data)
+	}
+	a.free(small)
+	if _, ok := a.load(small); ok {
+		t.Error("expected a freed reference to be stale")
+	}
+	reused, _ := a.alloc([]byte("world"))
+	if reused.slot != small.slot {
+		t.Errorf("expected slot %v to be reused, got %v", small.slot, reused.slot)
+	}
+	a.free(small)
+	if data, ok := a.load(reused); !ok || string(data) != "world" {
+		t.Errorf("freeing a stale reference changed the slot: %q", data)
+	}
+
+	a.reset()
+	if _, ok := a.load(large); ok {
+		t.Error("expected references to be stale after reset")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Bytes().Build()
+		value := []byte("value")
+		cache.Set("a", value)
+		value[0] = 'V'
+		if v, err := cache.Get("a"); err != nil || !bytes.Equal(v.([]byte), []byte("value")) {
+			t.Errorf("%T: unexpected value %q, %v", cache, v, err)
+		}
+		cache.Set("a", []byte("other"))
+		if v, err := cache.Get("a"); err != nil || string(v.([]byte)) != "other" {
+			t.Errorf("%T: unexpected value %q, %v", cache, v, err)
+		}
+		cache.Set("b", "not bytes")
+		if v, err := cache.Get("b"); err != nil || v != "not bytes" {
+			t.Errorf("%T: unexpected value %v, %v", cache, v, err)
+		}
+		if v, ok := cache.GetAndRemove("a"); !ok || string(v.([]byte)) != "other" {
+			t.Errorf("%T: unexpected removed value %q", cache, v)
+		}
+		for i := 0; i < 2*size; i++ {
+			cache.Set(i, []byte{byte(i)})
+		}
+		for i := size; i < 2*size; i++ {
+			if v, err := cache.Get(i); err == nil && v.([]byte)[0] != byte(i) {
+				t.Errorf("%T: unexpected value %v for %v", cache, v, i)
+			}
+		}
+		cache.Purge()
+		cache.Set("c", []byte("after purge"))
+		if v, err := cache.Get("c"); err != nil || string(v.([]byte)) != "after purge" {
+			t.Errorf("%T: unexpected value %q, %v", cache, v, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package gcache
+
+// Result is the value or error of a Get, delivered by GetAsync.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// getAsync implements GetAsync on top of the getValue and getWithLoader methods of a cache.
+// Cached values and misses without a LoaderFunc are delivered before it returns.
+func (c *baseCache) getAsync(key interface{}, getValue func(interface{}) (interface{}, error), getWithLoader func(interface{}, bool) (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	v, err := getValue(key)
+	if err == nil || c.loaderExpireFunc == nil {
+		ch <- Result{Value: v, Err: err}
+		return ch
+	}
+	go func() {
+		v, err := getWithLoader(key, true)
+		ch <- Result{Value: v, Err: err}
+	}()
+	return ch
+}
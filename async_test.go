@@ -0,0 +1,49 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAsync(t *testing.T) {
+	release := make(chan struct{})
+	loader := func(key interface{}) (interface{}, error) {
+		<-release
+		return key.(string) + "!", nil
+	}
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.LoaderFunc(loader).Build()
+		cache.Set("a", "cached")
+		select {
+		case r := <-cache.GetAsync("a"):
+			if r.Value != "cached" || r.Err != nil {
+				t.Errorf("%T: GetAsync() delivered %+v", cache, r)
+			}
+		default:
+			t.Errorf("%T: GetAsync() did not deliver a cached value right away", cache)
+		}
+
+		ch := cache.GetAsync("b")
+		select {
+		case r := <-ch:
+			t.Fatalf("%T: GetAsync() delivered %+v before the load completed", cache, r)
+		case <-time.After(10 * time.Millisecond):
+		}
+		release <- struct{}{}
+		if r := <-ch; r.Value != "b!" || r.Err != nil {
+			t.Errorf("%T: GetAsync() delivered %+v", cache, r)
+		}
+	}
+}
+
+func TestGetAsyncWithoutLoader(t *testing.T) {
+	cache := New(8).LRU().Build()
+	select {
+	case r := <-cache.GetAsync("missing"):
+		if r.Err != KeyNotFoundError {
+			t.Errorf("GetAsync() delivered %+v", r)
+		}
+	default:
+		t.Errorf("GetAsync() did not deliver a miss right away")
+	}
+}
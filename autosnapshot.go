@@ -0,0 +1,141 @@
+package gcache
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io"
+	"time"
+)
+
+// SnapshotSink opens the writers which AutoSnapshot writes snapshots to,
+// e.g. a temporary file which its Close renames over the previous snapshot.
+// A snapshot is complete once its writer is closed without error.
+type SnapshotSink interface {
+	NewWriter() (io.WriteCloser, error)
+}
+
+// SnapshotSinkFunc adapts a function to a SnapshotSink.
+type SnapshotSinkFunc func() (io.WriteCloser, error)
+
+func (f SnapshotSinkFunc) NewWriter() (io.WriteCloser, error) {
+	return f()
+}
+
+// SnapshotErrorFunc is called with the error of an automatic snapshot which failed.
+type SnapshotErrorFunc func(error)
+
+// AutoSnapshot exports the cache to a new writer of sink every interval, and once
+// more when the cache is closed. Snapshots are encoded as JSON unless
+// AutoSnapshotCodec is set, and are skipped when they are identical to the last
+// one written. Failed snapshots are reported to the SnapshotErrorFunc, if any.
+func (cb *CacheBuilder) AutoSnapshot(interval time.Duration, sink SnapshotSink) *CacheBuilder {
+	cb.autoSnapshotInterval = interval
+	cb.autoSnapshotSink = sink
+	return cb
+}
+
+// Encode the snapshots of AutoSnapshot with codec.
+func (cb *CacheBuilder) AutoSnapshotCodec(codec SnapshotCodec) *CacheBuilder {
+	cb.autoSnapshotCodec = codec
+	return cb
+}
+
+// Set a function called with the error of every automatic snapshot which failed.
+func (cb *CacheBuilder) SnapshotErrorFunc(snapshotErrorFunc SnapshotErrorFunc) *CacheBuilder {
+	cb.snapshotErrorFunc = &snapshotErrorFunc
+	return cb
+}
+
+// autoSnapshotter writes the snapshots of AutoSnapshot.
+type autoSnapshotter struct {
+	interval  time.Duration
+	sink      SnapshotSink
+	codec     SnapshotCodec
+	errorFunc *SnapshotErrorFunc
+	stop      chan struct{}
+	done      chan struct{}
+	written   bool
+	sum       uint64 // of the last snapshot written
+}
+
+func newAutoSnapshotter(cb *CacheBuilder) *autoSnapshotter {
+	codec := cb.autoSnapshotCodec
+	if codec == nil {
+		codec = JSONSnapshotCodec{}
+	}
+	return &autoSnapshotter{
+		interval:  cb.autoSnapshotInterval,
+		sink:      cb.autoSnapshotSink,
+		codec:     codec,
+		errorFunc: cb.snapshotErrorFunc,
+	}
+}
+
+// write encodes a snapshot with export and writes it to a new writer of the sink,
+// unless it is identical to the last one written.
+func (as *autoSnapshotter) write(export func(io.Writer, SnapshotCodec) error) {
+	var buf bytes.Buffer
+	if err := export(&buf, as.codec); err != nil {
+		as.failed(err)
+		return
+	}
+	h := fnv.New64a()
+	h.Write(buf.Bytes())
+	sum := h.Sum64()
+	if as.written && sum == as.sum {
+		return
+	}
+	w, err := as.sink.NewWriter()
+	if err != nil {
+		as.failed(err)
+		return
+	}
+	_, err = w.Write(buf.Bytes())
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		as.failed(err)
+		return
+	}
+	as.written = true
+	as.sum = sum
+}
+
+func (as *autoSnapshotter) failed(err error) {
+	if as.errorFunc != nil {
+		(*as.errorFunc)(err)
+	}
+}
+
+// startAutoSnapshot starts writing the snapshots of AutoSnapshot with export, if it is set.
+func (c *baseCache) startAutoSnapshot(export func(io.Writer, SnapshotCodec) error) {
+	as := c.autoSnapshot
+	if as == nil {
+		return
+	}
+	as.stop = make(chan struct{})
+	as.done = make(chan struct{})
+	ticker := c.clock.NewTicker(as.interval)
+	go func() {
+		defer close(as.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-as.stop:
+				as.write(export)
+				return
+			case <-ticker.C():
+				as.write(export)
+			}
+		}
+	}()
+}
+
+// stopAutoSnapshot writes the last snapshot of AutoSnapshot and waits for it.
+func (c *baseCache) stopAutoSnapshot() {
+	if as := c.autoSnapshot; as != nil {
+		close(as.stop)
+		<-as.done
+	}
+}
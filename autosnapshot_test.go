@@ -0,0 +1,89 @@
+package gcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// snapshotBuffer is a writer of a testSink which sends its contents once closed.
+type snapshotBuffer struct {
+	bytes.Buffer
+	written chan<- []byte
+}
+
+func (b *snapshotBuffer) Close() error {
+	b.written <- b.Bytes()
+	return nil
+}
+
+func testSink(written chan<- []byte) SnapshotSink {
+	return SnapshotSinkFunc(func() (io.WriteCloser, error) {
+		return &snapshotBuffer{written: written}, nil
+	})
+}
+
+func TestAutoSnapshot(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		clock := NewFakeClock(time.Now())
+		written := make(chan []byte, 4)
+		cache := builder.Clock(clock).AutoSnapshot(time.Minute, testSink(written)).Build()
+		cache.Set("a", "1")
+
+		clock.Advance(time.Minute)
+		var snapshot []byte
+		select {
+		case snapshot = <-written:
+		case <-time.After(time.Second):
+			t.Fatalf("%T: no snapshot written", cache)
+		}
+		restored := New(8).LRU().Build()
+		if err := restored.Import(bytes.NewReader(snapshot), JSONSnapshotCodec{}); err != nil {
+			t.Fatalf("%T: Import() = %v", cache, err)
+		}
+		if v, err := restored.Get("a"); err != nil || v != "1" {
+			t.Errorf("%T: Get() = %v, %v from the snapshot", cache, v, err)
+		}
+
+		// Nothing changed, so neither the next tick nor Close write a snapshot.
+		clock.Advance(time.Minute)
+		cache.Set("b", "2")
+		cache.Close()
+		select {
+		case snapshot = <-written:
+		default:
+			t.Fatalf("%T: no snapshot written on Close", cache)
+		}
+		if !bytes.Contains(snapshot, []byte(`"b"`)) {
+			t.Errorf("%T: the snapshot written on Close is %s", cache, snapshot)
+		}
+		select {
+		case snapshot = <-written:
+			t.Errorf("%T: unchanged snapshot written, %s", cache, snapshot)
+		default:
+		}
+	}
+}
+
+func TestAutoSnapshotError(t *testing.T) {
+	failure := errors.New("disk full")
+	errs := make(chan error, 1)
+	cache := New(8).LRU().
+		AutoSnapshot(time.Hour, SnapshotSinkFunc(func() (io.WriteCloser, error) {
+			return nil, failure
+		})).
+		SnapshotErrorFunc(func(err error) { errs <- err }).
+		Build()
+	cache.Set("a", "1")
+	cache.Close()
+	select {
+	case err := <-errs:
+		if err != failure {
+			t.Errorf("SnapshotErrorFunc called with %v", err)
+		}
+	default:
+		t.Error("SnapshotErrorFunc not called")
+	}
+}
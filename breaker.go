@@ -0,0 +1,58 @@
+package gcache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var BackendUnavailableError = errors.New("Backend unavailable.")
+
+// breaker is a circuit breaker around the loader.
+// It opens after threshold consecutive failures and rejects loads for cooldown,
+// then lets a single trial load through which closes it again on success.
+type breaker struct {
+	mu        sync.Mutex
+	clock     Clock
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration, clock Clock) *breaker {
+	return &breaker{clock: clock, threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a load may call the backend.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.clock.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the result of an allowed load.
+// A KeyNotFoundError is not a failure of the backend.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || err == KeyNotFoundError {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.clock.Now().Add(b.cooldown)
+	}
+}
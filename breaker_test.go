@@ -0,0 +1,72 @@
+package gcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoaderBreaker(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		loadErr := errors.New("backend is down")
+		calls := 0
+		down := true
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				calls++
+				if down {
+					return nil, loadErr
+				}
+				return key, nil
+			}).
+			LoaderBreaker(2, 20*time.Millisecond).
+			Build()
+
+		for i := 0; i < 2; i++ {
+			if _, err := cache.Get(i); err != loadErr {
+				t.Errorf("%T: unexpected error %v", cache, err)
+			}
+		}
+		if _, err := cache.Get(2); err != BackendUnavailableError {
+			t.Errorf("%T: breaker should be open, got %v", cache, err)
+		}
+		if calls != 2 {
+			t.Errorf("%T: loader should not be called while the breaker is open, calls = %v", cache, calls)
+		}
+
+		// a failed trial load opens the breaker again
+		time.Sleep(30 * time.Millisecond)
+		if _, err := cache.Get(3); err != loadErr {
+			t.Errorf("%T: unexpected error %v", cache, err)
+		}
+		if _, err := cache.Get(4); err != BackendUnavailableError {
+			t.Errorf("%T: breaker should be open, got %v", cache, err)
+		}
+
+		// a successful trial load closes it
+		down = false
+		time.Sleep(30 * time.Millisecond)
+		for i := 5; i < 7; i++ {
+			if v, err := cache.Get(i); err != nil || v != i {
+				t.Errorf("%T: unexpected result %v, %v", cache, v, err)
+			}
+		}
+	}
+}
+
+func TestLoaderBreakerNotFound(t *testing.T) {
+	cache := New(8).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			return nil, KeyNotFoundError
+		}).
+		LoaderBreaker(1, time.Hour).
+		Build()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(i); err != KeyNotFoundError {
+			t.Errorf("missing keys should not open the breaker, got %v", err)
+		}
+	}
+}
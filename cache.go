@@ -2,7 +2,13 @@ package gcache
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,46 +22,324 @@ const (
 
 var KeyNotFoundError = errors.New("Key not found.")
 
+var NotIntegerError = errors.New("Value is not an integer.")
+
+var ClosedError = errors.New("Cache is closed.")
+
+var KeyLoadingError = errors.New("Key is loading.")
+
+// ErrUnsupported is returned by the operations which a cache cannot perform,
+// such as Verify on a Cache returned by Adapt. It is errors.ErrUnsupported.
+var ErrUnsupported = errors.ErrUnsupported
+
+// ConfigError describes why a CacheBuilder cannot build a cache.
+type ConfigError struct {
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return "Invalid cache configuration: " + e.Reason
+}
+
+// LoaderPanicError is returned in place of the result of a loader which panicked.
+type LoaderPanicError struct {
+	Key   interface{}
+	Value interface{}
+}
+
+func (e *LoaderPanicError) Error() string {
+	return fmt.Sprintf("Loader panicked for key %v: %v", e.Key, e.Value)
+}
+
+// Cache is implemented by the caches of every type. Optional capabilities are
+// separate interfaces, checked with a type assertion: Swapper, Pinner, Indexer,
+// Watcher, Dumper, Explainer and Rescorer.
 type Cache interface {
 	Set(interface{}, interface{})
+	SetWithExpire(key, value interface{}, expiration time.Duration)
 	Get(interface{}) (interface{}, error)
+	// GetAsync returns a channel which receives the result of Get for key once it is
+	// available, without waiting for the LoaderFunc. The channel is buffered, so the
+	// result can be picked up later or never.
+	GetAsync(interface{}) <-chan Result
 	GetIFPresent(interface{}) (interface{}, error)
+	Has(interface{}) bool
+	// GetALL returns the unexpired entries. With SnapshotInterval, the result may be
+	// up to one interval stale.
 	GetALL() map[interface{}]interface{}
+	// GetALLIncludingExpired returns the entries, including the expired ones which
+	// were not removed yet. With SnapshotInterval, the result may be up to one
+	// interval stale.
+	GetALLIncludingExpired() map[interface{}]interface{}
+	// Snapshot returns a consistent copy of the unexpired entries, as of when it
+	// is called. Unlike GetALL it does not hold the lock while copying, so writes
+	// carry on.
+	Snapshot() map[interface{}]interface{}
+	Export(w io.Writer, codec SnapshotCodec) error
+	Import(r io.Reader, codec SnapshotCodec) error
+	entries() []SnapshotEntry
+	importEntries([]SnapshotEntry)
+	// GetMulti returns the values of all keys which are cached or can be loaded.
+	// Missing keys are loaded in a single call of the BulkLoaderFunc if one is set,
+	// sharing the result of loads which are already in-flight for any of them. Keys
+	// which are not found are omitted from the result.
+	GetMulti([]interface{}) (map[interface{}]interface{}, error)
+	// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+	// concurrency loads at a time. It returns a WarmError with the keys which failed
+	// to load.
+	Warm(keys []interface{}, concurrency int) error
+	// Do executes fn once for all the concurrent calls with the same key. If
+	// cacheResult is true, fn is only executed when key is not cached and its value is
+	// stored under key as if it were loaded; calls share it with the loads of key.
+	// Otherwise fn is executed whether key is cached or not, and its result is not
+	// stored. A panic of fn is returned as a LoaderPanicError.
+	Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error)
 	get(interface{}, bool) (interface{}, error)
+	lookup(interface{}) (interface{}, error)
 	Remove(interface{}) bool
+	RemoveAll(keys ...interface{}) int
+	// GetAndRemove removes key and returns its value. The lookup and the removal
+	// happen under a single lock acquisition.
+	GetAndRemove(interface{}) (interface{}, bool)
+	// RemoveGet is GetAndRemove, but the value of an expired entry is returned too.
+	// The bool reports whether an entry was removed.
+	RemoveGet(interface{}) (interface{}, bool)
+	// GetOrSet returns the existing value for the key if present. Otherwise, it stores
+	// and returns the given value. The loaded result is true if the value was loaded,
+	// false if stored.
+	GetOrSet(interface{}, interface{}) (interface{}, bool)
+	// Update atomically replaces the value for key with the result of fn. fn runs
+	// under the cache lock and must not call back into the cache. If fn returns an
+	// error the cache is left unchanged.
+	Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error
+	// Touch resets the expiration of key without reading its value. The expiration is
+	// restarted with ttl if given, or with the default expiration otherwise. Returns
+	// false if the key is not present or already expired.
+	Touch(key interface{}, ttl ...time.Duration) bool
+	// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+	// Low priority entries are always evicted before normal ones, and normal before
+	// high, the cache's policy decides the order within a class. The class is kept
+	// until the entry is removed or assigned a new class.
+	SetWithPriority(key, value interface{}, priority Priority)
+	// RemoveAt schedules the removal of key at t, independent of its expiration.
+	// Scheduling the key again replaces the previous schedule.
+	RemoveAt(key interface{}, t time.Time)
+	// RemoveAfter is RemoveAt, after d.
+	RemoveAfter(key interface{}, d time.Duration)
+	Increment(key interface{}, delta int64) (int64, error)
+	Decrement(key interface{}, delta int64) (int64, error)
 	Purge()
+	// SetCapacity changes the capacity at runtime. When shrinking, entries are evicted
+	// by the cache's policy until they fit.
+	SetCapacity(int)
+	SetExpiration(time.Duration)
+	SetRefreshAfterWrite(time.Duration)
+	// Keys returns the unexpired keys. With SnapshotInterval, the result may be up to
+	// one interval stale.
 	Keys() []interface{}
+	// KeysIncludingExpired returns the keys, including the expired ones which were not
+	// removed yet. With SnapshotInterval, the result may be up to one interval stale.
+	KeysIncludingExpired() []interface{}
+	KeysSorted(less func(a, b interface{}) bool) []interface{}
+	// KeysWithPrefix returns the unexpired string keys which start with prefix, in
+	// order. Only the matching keys are read if the cache maintains OrderedKeys.
+	KeysWithPrefix(prefix string) []interface{}
 	Len() int
+	watch(key, as interface{}) (<-chan ValueChange, CancelFunc)
+	dumpEntries() ([]dumpEntry, int)
+	// Verify checks the internal invariants of the cache and returns an InvariantError
+	// describing the first one which does not hold. It is meant for tests and canary
+	// builds.
+	Verify() error
+	Namespace(name string) *NamespacedCache
+	// Close cancels scheduled removals, waits for background loads to finish and drops
+	// all entries. Loads fail with ClosedError afterwards, and closing a closed cache
+	// returns ClosedError.
+	Close() error
 
 	statsAccessor
 }
 
 type baseCache struct {
-	size        int
-	loaderFunc  *LoaderFunc
-	evictedFunc *EvictedFunc
-	addedFunc   *AddedFunc
-	expiration  *time.Duration
-	mu          sync.RWMutex
-	loadGroup   Group
+	size              int
+	loaderExpireFunc  *LoaderExpireFunc
+	bulkLoaderFunc    *BulkLoaderFunc
+	loaderErrorFunc   *LoaderErrorFunc
+	breaker           *breaker
+	loadSlots         chan struct{}
+	evictedFunc       *EvictedFunc
+	evictedEntryFunc  *EvictedEntryFunc
+	expiredFunc       *ExpiredFunc
+	expiring          bool // while an expired entry is removed
+	refreshing        bool // while a loaded value is set
+	keyValidator      KeyValidatorFunc
+	addedFunc         *AddedFunc
+	updatedFunc       *UpdatedFunc
+	missFunc          *MissFunc
+	rejectedFunc      *RejectedFunc
+	purgeEvict        bool
+	expiration        *time.Duration
+	expireAfterAccess *time.Duration
+	expirationJitter  float64
+	xfetchBeta        float64
+	maxStaleness      *time.Duration
+	refreshAfter      int64 // nanoseconds, accessed atomically
+	mu                sync.RWMutex
+	loadGroup         Group
+	snapshot          *snapshotter
+	removals          scheduledRemovals
+	pinned            map[interface{}]struct{}
+	watchers          map[interface{}]map[*watcher]struct{}
+	closed            int32
+	priorities        map[interface{}]Priority
+	evictClass        Priority
+	logger            *slog.Logger
+	slowLoadThreshold time.Duration
+	serializeFunc     SerializeFunc
+	deserializeFunc   DeserializeFunc
+	copyOnGet         CloneFunc
+	copyOnSet         CloneFunc
+	codec             Codec
+	compressThreshold int
+	memoryLimit       uint64
+	memoryGauge       MemoryGauge
+	memoryInterval    time.Duration
+	dumpLimit         int
+	memoryStop        chan struct{}
+	autoSnapshot      *autoSnapshotter
+	arena             *byteArena
+	overflow          *diskOverflow
+	weak              *weakValues
+	reads             *readBuffer
+	loads             map[interface{}]struct{} // keys being loaded and not written since
+	callbacks         []func()                 // callbacks to run once the cache is unlocked
+	callbackPool      *callbackPool
+	copies            []*copyLog // of the Snapshots in progress
+	ordered           *keyIndex
+	indexes           map[string]*valueIndex
+	quotas            map[string]*namespaceQuota
+	evictKey          func(interface{}) bool // removes an entry outside of the eviction policy
+	clock             Clock
 	*stats
 }
 
 type LoaderFunc func(interface{}) (interface{}, error)
 
+// LoaderExpireFunc loads a value along with its expiration.
+// A nil expiration falls back to the cache's default expiration.
+type LoaderExpireFunc func(interface{}) (interface{}, *time.Duration, error)
+
+// BulkLoaderFunc loads several keys in a single call.
+// Keys which are missing from the returned map are treated as not found.
+type BulkLoaderFunc func([]interface{}) (map[interface{}]interface{}, error)
+
+// LoaderErrorFunc is called with every error a loader returns, including panics.
+type LoaderErrorFunc func(interface{}, error)
+
+// EvictedFunc is called with the key and value of every entry removed from the cache.
+// It runs once the cache is unlocked, so it may call the cache.
 type EvictedFunc func(interface{}, interface{})
 
+// EvictedEntryFunc is called with every entry which is evicted or removed from the
+// cache other than by expiring, along with its Expiration. Score and Weight are not set.
+// It runs once the cache is unlocked, so it may call the cache.
+type EvictedEntryFunc func(SnapshotEntry)
+
+// RejectionReason tells why an entry was not admitted to the cache.
+type RejectionReason string
+
+// RejectedOverweight rejects an entry whose weight exceeds the capacity of a ScoreCache.
+const RejectedOverweight RejectionReason = "weight exceeds the capacity"
+
+// RejectedFunc is called with the key and value of every entry which is not admitted to the cache.
+// It runs once the cache is unlocked, so it may call the cache.
+type RejectedFunc func(key, value interface{}, reason RejectionReason)
+
+// MissFunc is called with every key which is missed by a lookup.
+// It runs on the goroutine of the lookup once the cache is unlocked, so it may call the cache.
+type MissFunc func(interface{})
+
+// UpdatedFunc is called with the key, the previous and the new value of every entry
+// which is overwritten. It runs once the cache is unlocked, so it may call the cache.
+type UpdatedFunc func(key, oldValue, newValue interface{})
+
+// ExpiredFunc is called with the key and value of every entry removed because it expired.
+// It runs once the cache is unlocked, so it may call the cache.
+type ExpiredFunc func(interface{}, interface{})
+
+// AddedFunc is called with the key and value of every entry set in the cache.
+// It runs once the cache is unlocked, so it may call the cache.
 type AddedFunc func(interface{}, interface{})
 
 type CacheBuilder struct {
-	tp            string
-	size          int
-	loaderFunc    *LoaderFunc
-	evictedFunc   *EvictedFunc
-	addedFunc     *AddedFunc
-	scoringFunc   ScoringFunc
-	weightingFunc WeightingFunc
-	expiration    *time.Duration
+	tp                   string
+	size                 int
+	loaderExpireFunc     *LoaderExpireFunc
+	bulkLoaderFunc       *BulkLoaderFunc
+	loaderErrorFunc      *LoaderErrorFunc
+	breakerThreshold     int
+	breakerCooldown      time.Duration
+	maxLoads             int
+	coalesceWindow       time.Duration
+	noWaitLoad           bool
+	hasher               HashFunc
+	keyValidator         KeyValidatorFunc
+	evictedFunc          *EvictedFunc
+	evictedEntryFunc     *EvictedEntryFunc
+	expiredFunc          *ExpiredFunc
+	addedFunc            *AddedFunc
+	updatedFunc          *UpdatedFunc
+	missFunc             *MissFunc
+	rejectedFunc         *RejectedFunc
+	purgeEvict           bool
+	scoringFunc          ScoringFunc
+	weightingFunc        WeightingFunc
+	expiration           *time.Duration
+	expireAfterAccess    *time.Duration
+	expirationJitter     float64
+	xfetchBeta           float64
+	maxStaleness         *time.Duration
+	refreshAfter         time.Duration
+	snapshotEvery        *time.Duration
+	statsWindow          time.Duration
+	statsBuckets         int
+	statsClassifier      func(interface{}) string
+	topKeys              int
+	serializeFunc        SerializeFunc
+	deserializeFunc      DeserializeFunc
+	copyOnGet            CloneFunc
+	copyOnSet            CloneFunc
+	codec                Codec
+	compressThreshold    int
+	memoryLimit          uint64
+	memoryGauge          MemoryGauge
+	memoryInterval       time.Duration
+	dumpLimit            int
+	autoSnapshotInterval time.Duration
+	autoSnapshotSink     SnapshotSink
+	autoSnapshotCodec    SnapshotCodec
+	snapshotErrorFunc    *SnapshotErrorFunc
+	bytes                bool
+	overflow             bool
+	overflowDir          string
+	overflowThreshold    int
+	weakValues           bool
+	callbackWorkers      int
+	callbackQueue        int
+	callbackOverflow     OverflowPolicy
+	clock                Clock
+	scoreDecay           *time.Duration
+	accessBoost          float64
+	fallbackScore        int
+	fallbackWeight       int
+	maxEntries           int
+	tieBreaker           TieBreaker
+	warmFrom             func() (key, value interface{}, ok bool)
+	orderedKeys          bool
+	indexes              map[string]IndexFunc
+	quotas               map[string]int
+	logger               *slog.Logger
+	slowLoadThreshold    time.Duration
 }
 
 func New(size int) *CacheBuilder {
@@ -63,15 +347,81 @@ func New(size int) *CacheBuilder {
 		panic("gcache: size <= 0")
 	}
 	return &CacheBuilder{
-		tp:   TYPE_SIMPLE,
-		size: size,
+		tp:                TYPE_SIMPLE,
+		size:              size,
+		fallbackWeight:    1,
+		slowLoadThreshold: DefaultSlowLoadThreshold,
+		compressThreshold: DefaultCompressionThreshold,
 	}
 }
 
 // Set a loader function.
 // loaderFunc: create a new value with this function if cached value is expired.
 func (cb *CacheBuilder) LoaderFunc(loaderFunc LoaderFunc) *CacheBuilder {
-	cb.loaderFunc = &loaderFunc
+	loaderExpireFunc := LoaderExpireFunc(func(key interface{}) (interface{}, *time.Duration, error) {
+		v, err := loaderFunc(key)
+		return v, nil, err
+	})
+	cb.loaderExpireFunc = &loaderExpireFunc
+	return cb
+}
+
+// Set a loader function which also returns the expiration of each value,
+// so that the data source can dictate per key freshness.
+// Replaces the LoaderFunc. ScoreCache entries do not expire and ignore it.
+func (cb *CacheBuilder) LoaderExpireFunc(loaderExpireFunc LoaderExpireFunc) *CacheBuilder {
+	cb.loaderExpireFunc = &loaderExpireFunc
+	return cb
+}
+
+// Set a bulk loader function which GetMulti uses to load all the keys
+// it misses in a single call. Without it, GetMulti loads the keys
+// one at a time with the LoaderFunc.
+func (cb *CacheBuilder) BulkLoaderFunc(bulkLoaderFunc BulkLoaderFunc) *CacheBuilder {
+	cb.bulkLoaderFunc = &bulkLoaderFunc
+	return cb
+}
+
+// Set a function which is called whenever the LoaderFunc or BulkLoaderFunc fails,
+// so that failures can be logged or metered in a single place.
+// A loader which panics fails with a LoaderPanicError.
+func (cb *CacheBuilder) LoaderErrorFunc(loaderErrorFunc LoaderErrorFunc) *CacheBuilder {
+	cb.loaderErrorFunc = &loaderErrorFunc
+	return cb
+}
+
+// Put a circuit breaker around the loader. After failureThreshold consecutive
+// failures, loads fail fast with a BackendUnavailableError for cooldown,
+// after which a single trial load decides whether the backend has recovered.
+func (cb *CacheBuilder) LoaderBreaker(failureThreshold int, cooldown time.Duration) *CacheBuilder {
+	cb.breakerThreshold = failureThreshold
+	cb.breakerCooldown = cooldown
+	return cb
+}
+
+// Bound the number of loader calls which run at the same time across all keys,
+// protecting the backend from miss storms, e.g. after a Purge.
+// Loads beyond the limit wait for a running one to finish.
+func (cb *CacheBuilder) MaxConcurrentLoads(n int) *CacheBuilder {
+	cb.maxLoads = n
+	return cb
+}
+
+// Let loads which arrive within window after a load of the same key completed
+// reuse its result instead of calling the loader again. This smooths out bursts
+// of misses even when the result is not cached, e.g. a KeyNotFoundError.
+// Keys removed within the window may still be served the reused result.
+func (cb *CacheBuilder) LoadCoalescingWindow(window time.Duration) *CacheBuilder {
+	cb.coalesceWindow = window
+	return cb
+}
+
+// Make Get return right away while the key is being loaded by another caller,
+// rather than wait for the load: it returns the stale value which StaleIfError
+// allows to serve, if any, or a KeyLoadingError. The caller which starts a
+// load still waits for it.
+func (cb *CacheBuilder) NoWaitLoad() *CacheBuilder {
+	cb.noWaitLoad = true
 	return cb
 }
 
@@ -105,6 +455,54 @@ func (cb *CacheBuilder) EvictedFunc(evictedFunc EvictedFunc) *CacheBuilder {
 	return cb
 }
 
+// Set a function which is called like the EvictedFunc, with the entries which are
+// evicted or removed other than by expiring, and which also gets when they would
+// have expired, e.g. to move them to another tier of storage without extending
+// their lifetime. Values are passed in the form they are stored in.
+func (cb *CacheBuilder) EvictedEntryFunc(evictedEntryFunc EvictedEntryFunc) *CacheBuilder {
+	cb.evictedEntryFunc = &evictedEntryFunc
+	return cb
+}
+
+// Set a function which is called with the previous and the new value
+// whenever a key which is already cached is set again, e.g. to detect changes.
+// The AddedFunc is not affected.
+func (cb *CacheBuilder) UpdatedFunc(updatedFunc UpdatedFunc) *CacheBuilder {
+	cb.updatedFunc = &updatedFunc
+	return cb
+}
+
+// Set a function which is called with every key which is looked up and not found
+// in the cache, before the LoaderFunc runs, e.g. to log unexpected misses or to
+// prefetch related keys. Lookups which do not count as misses, like Has, do not call it.
+func (cb *CacheBuilder) MissFunc(missFunc MissFunc) *CacheBuilder {
+	cb.missFunc = &missFunc
+	return cb
+}
+
+// Set a function which is called with every entry the cache refuses to admit,
+// along with the reason, so that the traffic bypassing the cache can be tracked.
+// Only a ScoreCache rejects entries, those heavier than its capacity.
+func (cb *CacheBuilder) RejectedFunc(rejectedFunc RejectedFunc) *CacheBuilder {
+	cb.rejectedFunc = &rejectedFunc
+	return cb
+}
+
+// Set a function which is called in place of the EvictedFunc with the entries
+// which are removed because they expired, so that stale data can be told apart
+// from entries evicted for lack of room. Without it the EvictedFunc gets both.
+func (cb *CacheBuilder) ExpiredFunc(expiredFunc ExpiredFunc) *CacheBuilder {
+	cb.expiredFunc = &expiredFunc
+	return cb
+}
+
+// Make Purge, and so Close, call the EvictedFunc with every entry it drops,
+// so that resources held by the values can be released. Purge does not by default.
+func (cb *CacheBuilder) PurgeEvict(evict bool) *CacheBuilder {
+	cb.purgeEvict = evict
+	return cb
+}
+
 func (cb *CacheBuilder) AddedFunc(addedFunc AddedFunc) *CacheBuilder {
 	cb.addedFunc = &addedFunc
 	return cb
@@ -120,13 +518,244 @@ func (cb *CacheBuilder) WeightingFunc(w WeightingFunc) *CacheBuilder {
 	return cb
 }
 
+// Set the score and weight a ScoreCache uses when the ScoringFunc or
+// WeightingFunc panics. Defaults to a score of 0 and a weight of 1,
+// the weight must be at least 1.
+func (cb *CacheBuilder) ScoringFallback(score, weight int) *CacheBuilder {
+	cb.fallbackScore = score
+	cb.fallbackWeight = weight
+	return cb
+}
+
+// Let the scores of a ScoreCache decay over time since the last access,
+// halving every halfLife, so that once popular items eventually become
+// eviction candidates. Scores are expected to be positive; items with
+// a score <= 0 are always evicted first.
+func (cb *CacheBuilder) ScoreDecay(halfLife time.Duration) *CacheBuilder {
+	cb.scoreDecay = &halfLife
+	return cb
+}
+
+// Boost the scores of a ScoreCache by how often items are read, so that
+// the effective score becomes score * (1 + blend * ln(1 + hits)).
+// This gives a cost-aware LFU hybrid; a blend of 0 disables the boost.
+func (cb *CacheBuilder) AccessBoost(blend float64) *CacheBuilder {
+	cb.accessBoost = blend
+	return cb
+}
+
+// Cap the number of entries of a ScoreCache in addition to its total weight.
+// The lowest scored items are evicted when either limit would be exceeded.
+func (cb *CacheBuilder) MaxEntries(n int) *CacheBuilder {
+	cb.maxEntries = n
+	return cb
+}
+
 func (cb *CacheBuilder) Expiration(expiration time.Duration) *CacheBuilder {
 	cb.expiration = &expiration
 	return cb
 }
 
+// Serve GetALL and Keys from a read-only snapshot of the cache which is
+// rebuilt at most once per interval, so their results may be up to one
+// interval stale. The interval must be positive.
+// Intended for monitoring consumers which poll the whole cache frequently.
+func (cb *CacheBuilder) SnapshotInterval(interval time.Duration) *CacheBuilder {
+	cb.snapshotEvery = &interval
+	return cb
+}
+
+// Make HitRate reflect the lookups of the last window only, rather than all
+// lookups since the cache was built. The window slides by window/buckets at a time.
+// HitCount, MissCount and Stats are not affected.
+func (cb *CacheBuilder) WindowedStats(window time.Duration, buckets int) *CacheBuilder {
+	cb.statsWindow = window
+	cb.statsBuckets = buckets
+	return cb
+}
+
+// Randomize the expiration of each entry by up to +/- fraction of its TTL,
+// so that entries written together do not all expire at the same instant.
+func (cb *CacheBuilder) ExpirationJitter(fraction float64) *CacheBuilder {
+	cb.expirationJitter = fraction
+	return cb
+}
+
+// Enable probabilistic early expiration (XFetch): entries nearing their
+// expiration are refreshed in the background by the LoaderFunc, with a
+// probability weighted by how long they took to load and by beta.
+// A beta of 1 is a good default; larger values refresh earlier.
+func (cb *CacheBuilder) XFetch(beta float64) *CacheBuilder {
+	cb.xfetchBeta = beta
+	return cb
+}
+
+// Expire entries once d has passed since they were last read or written.
+// Can be combined with Expiration, in which case whichever fires first wins.
+func (cb *CacheBuilder) ExpireAfterAccess(d time.Duration) *CacheBuilder {
+	cb.expireAfterAccess = &d
+	return cb
+}
+
+// Reload entries in the background with the LoaderFunc once d has passed since
+// they were written. Reads keep getting the current value until the reload completes.
+func (cb *CacheBuilder) RefreshAfterWrite(d time.Duration) *CacheBuilder {
+	cb.refreshAfter = d
+	return cb
+}
+
+// Keep expired entries for up to maxStaleness and serve them from Get when
+// the loader fails to refresh them, instead of returning the error.
+// This includes loads rejected by an open LoaderBreaker.
+// A loader returning KeyNotFoundError still makes the key a miss.
+func (cb *CacheBuilder) StaleIfError(maxStaleness time.Duration) *CacheBuilder {
+	cb.maxStaleness = &maxStaleness
+	return cb
+}
+
+// Build the cache. Panics if the configuration is invalid, see BuildE.
 func (cb *CacheBuilder) Build() Cache {
-	return cb.build()
+	c, err := cb.BuildE()
+	if err != nil {
+		panic("gcache: " + err.Error())
+	}
+	return c
+}
+
+// BuildE builds the cache, or returns a ConfigError if the configuration
+// is invalid or contains options which conflict with each other.
+func (cb *CacheBuilder) BuildE() (Cache, error) {
+	if err := cb.validate(); err != nil {
+		return nil, err
+	}
+	c := cb.build()
+	cb.seed(c)
+	return c, nil
+}
+
+// validate checks the configuration for values which would make the cache
+// misbehave at runtime and for options which the cache type would ignore.
+func (cb *CacheBuilder) validate() error {
+	invalid := func(reason string) error {
+		return &ConfigError{Reason: reason}
+	}
+	switch cb.tp {
+	case TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_SCORE:
+	default:
+		return invalid("unknown type " + cb.tp)
+	}
+	if cb.size <= 0 {
+		return invalid("size must be positive")
+	}
+
+	if cb.tp == TYPE_SCORE {
+		if cb.scoringFunc == nil || cb.weightingFunc == nil {
+			return invalid("SCORE requires a ScoringFunc and a WeightingFunc")
+		}
+		if cb.expiration != nil || cb.expireAfterAccess != nil || cb.maxStaleness != nil ||
+			cb.expirationJitter != 0 || cb.xfetchBeta != 0 || cb.refreshAfter != 0 || cb.expiredFunc != nil {
+			return invalid("SCORE entries do not expire")
+		}
+		if cb.scoreDecay != nil && *cb.scoreDecay <= 0 {
+			return invalid("ScoreDecay half-life must be positive")
+		}
+		if cb.accessBoost < 0 {
+			return invalid("AccessBoost must not be negative")
+		}
+		if cb.maxEntries < 0 {
+			return invalid("MaxEntries must not be negative")
+		}
+		if cb.fallbackWeight < 1 {
+			return invalid("ScoringFallback weight must be at least 1")
+		}
+	} else if cb.scoreDecay != nil || cb.accessBoost != 0 || cb.maxEntries != 0 || cb.tieBreaker != nil || cb.rejectedFunc != nil {
+		return invalid("ScoreDecay, AccessBoost, MaxEntries, TieBreaker and RejectedFunc require SCORE")
+	}
+
+	if cb.expiration != nil && *cb.expiration <= 0 {
+		return invalid("Expiration must be positive")
+	}
+	if cb.expireAfterAccess != nil && *cb.expireAfterAccess <= 0 {
+		return invalid("ExpireAfterAccess must be positive")
+	}
+	if cb.expirationJitter < 0 || cb.expirationJitter >= 1 {
+		return invalid("ExpirationJitter must be in [0, 1)")
+	}
+	if cb.expirationJitter != 0 && cb.expiration == nil {
+		return invalid("ExpirationJitter requires Expiration")
+	}
+	if cb.xfetchBeta < 0 {
+		return invalid("XFetch beta must not be negative")
+	}
+	if cb.xfetchBeta != 0 && cb.loaderExpireFunc == nil {
+		return invalid("XFetch requires a LoaderFunc")
+	}
+	if cb.refreshAfter < 0 {
+		return invalid("RefreshAfterWrite must not be negative")
+	}
+	if cb.refreshAfter != 0 && cb.loaderExpireFunc == nil {
+		return invalid("RefreshAfterWrite requires a LoaderFunc")
+	}
+	if cb.maxStaleness != nil && *cb.maxStaleness < 0 {
+		return invalid("StaleIfError must not be negative")
+	}
+	if cb.breakerThreshold < 0 || cb.breakerCooldown < 0 {
+		return invalid("LoaderBreaker must not be negative")
+	}
+	if cb.maxLoads < 0 {
+		return invalid("MaxConcurrentLoads must not be negative")
+	}
+	if cb.coalesceWindow < 0 {
+		return invalid("LoadCoalescingWindow must not be negative")
+	}
+	if cb.noWaitLoad && cb.loaderExpireFunc == nil {
+		return invalid("NoWaitLoad requires a LoaderFunc")
+	}
+	if cb.slowLoadThreshold < 0 {
+		return invalid("SlowLoadThreshold must not be negative")
+	}
+	if cb.statsWindow < 0 || cb.statsBuckets < 0 || (cb.statsWindow == 0) != (cb.statsBuckets == 0) {
+		return invalid("WindowedStats requires a positive window and number of buckets")
+	}
+	if cb.memoryInterval < 0 {
+		return invalid("MemoryCheckInterval must not be negative")
+	}
+	if cb.dumpLimit < 0 {
+		return invalid("DumpLimit must not be negative")
+	}
+	if cb.callbackWorkers < 0 || cb.callbackQueue < 0 {
+		return invalid("AsyncCallbacks requires a positive number of workers and a queue size that is not negative")
+	}
+	if cb.callbackWorkers == 0 && cb.callbackQueue != 0 {
+		return invalid("AsyncCallbacks requires a positive number of workers")
+	}
+	if cb.compressThreshold < 0 {
+		return invalid("CompressionThreshold must not be negative")
+	}
+	if cb.overflowThreshold < 0 {
+		return invalid("OverflowToDisk threshold must not be negative")
+	}
+	if cb.weakValues && (cb.bytes || cb.overflow) {
+		return invalid("WeakValues cannot be combined with Bytes or OverflowToDisk")
+	}
+	if cb.topKeys < 0 {
+		return invalid("TrackTopKeys must not be negative")
+	}
+	for _, max := range cb.quotas {
+		if max <= 0 {
+			return invalid("NamespaceQuota must be positive")
+		}
+	}
+	if cb.snapshotEvery != nil && *cb.snapshotEvery <= 0 {
+		return invalid("SnapshotInterval must be positive")
+	}
+	if cb.autoSnapshotSink != nil && cb.autoSnapshotInterval <= 0 {
+		return invalid("AutoSnapshot interval must be positive")
+	}
+	if cb.autoSnapshotSink == nil && (cb.autoSnapshotInterval != 0 || cb.autoSnapshotCodec != nil || cb.snapshotErrorFunc != nil) {
+		return invalid("AutoSnapshot requires a SnapshotSink")
+	}
+	return nil
 }
 
 func (cb *CacheBuilder) build() Cache {
@@ -148,20 +777,483 @@ func (cb *CacheBuilder) build() Cache {
 
 func buildCache(c *baseCache, cb *CacheBuilder) {
 	c.size = cb.size
-	c.loaderFunc = cb.loaderFunc
+	c.loaderExpireFunc = cb.loaderExpireFunc
+	c.bulkLoaderFunc = cb.bulkLoaderFunc
+	c.loaderErrorFunc = cb.loaderErrorFunc
+	c.clock = cb.clock
+	if c.clock == nil {
+		c.clock = RealClock{}
+	}
+	c.loadGroup.clock = c.clock
+	c.loadGroup.window = cb.coalesceWindow
+	c.loadGroup.noWait = cb.noWaitLoad
+	if cb.breakerThreshold > 0 {
+		c.breaker = newBreaker(cb.breakerThreshold, cb.breakerCooldown, c.clock)
+	}
+	if cb.maxLoads > 0 {
+		c.loadSlots = make(chan struct{}, cb.maxLoads)
+	}
 	c.expiration = cb.expiration
+	c.expireAfterAccess = cb.expireAfterAccess
+	c.expirationJitter = cb.expirationJitter
+	c.xfetchBeta = cb.xfetchBeta
+	c.maxStaleness = cb.maxStaleness
+	c.refreshAfter = int64(cb.refreshAfter)
 	c.addedFunc = cb.addedFunc
 	c.evictedFunc = cb.evictedFunc
+	c.evictedEntryFunc = cb.evictedEntryFunc
+	c.expiredFunc = cb.expiredFunc
+	c.updatedFunc = cb.updatedFunc
+	c.missFunc = cb.missFunc
+	c.rejectedFunc = cb.rejectedFunc
+	c.purgeEvict = cb.purgeEvict
+	c.evictClass = HighPriority
+	c.logger = cb.logger
+	c.serializeFunc = cb.serializeFunc
+	c.deserializeFunc = cb.deserializeFunc
+	c.copyOnGet = cb.copyOnGet
+	c.copyOnSet = cb.copyOnSet
+	c.keyValidator = cb.keyValidator
+	if c.keyValidator == nil {
+		c.keyValidator = ComparableKey
+	}
+	c.codec = cb.codec
+	c.compressThreshold = cb.compressThreshold
+	c.memoryLimit = cb.memoryLimit
+	if cb.autoSnapshotSink != nil {
+		c.autoSnapshot = newAutoSnapshotter(cb)
+	}
+	if cb.bytes {
+		c.arena = newByteArena()
+	}
+	if cb.overflow {
+		c.overflow = newDiskOverflow(cb.overflowDir, cb.overflowThreshold)
+	}
+	if cb.weakValues {
+		c.weak = newWeakValues()
+	}
+	if cb.orderedKeys {
+		c.ordered = &keyIndex{}
+	}
+	if len(cb.indexes) > 0 {
+		c.indexes = make(map[string]*valueIndex, len(cb.indexes))
+		for name, extract := range cb.indexes {
+			c.indexes[name] = newValueIndex(extract)
+		}
+	}
+	if len(cb.quotas) > 0 {
+		c.quotas = make(map[string]*namespaceQuota, len(cb.quotas))
+		for name, max := range cb.quotas {
+			c.quotas[name] = newNamespaceQuota(max)
+		}
+	}
+	if cb.callbackWorkers > 0 {
+		c.callbackPool = newCallbackPool(cb.callbackWorkers, cb.callbackQueue, cb.callbackOverflow)
+	}
+	c.memoryGauge = cb.memoryGauge
+	if c.memoryGauge == nil {
+		c.memoryGauge = HeapAlloc
+	}
+	c.memoryInterval = cb.memoryInterval
+	if c.memoryInterval == 0 {
+		c.memoryInterval = DefaultMemoryCheckInterval
+	}
+	c.dumpLimit = cb.dumpLimit
+	if c.dumpLimit == 0 {
+		c.dumpLimit = DefaultDumpLimit
+	}
+	c.slowLoadThreshold = cb.slowLoadThreshold
+	c.removals.logger = cb.logger
+	c.removals.clock = c.clock
 	c.stats = &stats{}
+	if cb.statsWindow > 0 {
+		c.stats.window = newSlidingWindow(cb.statsWindow, cb.statsBuckets, c.clock)
+	}
+	if cb.statsClassifier != nil {
+		c.stats.classes = newClassStats(cb.statsClassifier)
+	}
+	if cb.topKeys > 0 {
+		c.stats.topKeys = newTopKeys(cb.topKeys)
+	}
+	if cb.snapshotEvery != nil {
+		c.snapshot = newSnapshotter(*cb.snapshotEvery, c.clock)
+	}
+}
+
+// increment adds delta to the integer stored under key, keeping its type.
+// A missing key is created as an int64 holding delta.
+func increment(c Cache, key interface{}, delta int64) (int64, error) {
+	var n int64
+	err := c.Update(key, func(current interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			n = delta
+			return delta, nil
+		}
+		switch v := current.(type) {
+		case int:
+			v += int(delta)
+			n = int64(v)
+			return v, nil
+		case int64:
+			v += delta
+			n = v
+			return v, nil
+		case uint64:
+			v += uint64(delta)
+			n = int64(v)
+			return v, nil
+		default:
+			return nil, NotIntegerError
+		}
+	})
+	return n, err
+}
+
+// removeAll removes keys with remove and returns how many were removed.
+func removeAll(keys []interface{}, remove func(interface{}) bool) int {
+	removed := 0
+	for _, key := range keys {
+		if remove(key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// jitter randomizes the expiration duration d by the configured fraction.
+func (c *baseCache) jitter(d time.Duration) time.Duration {
+	if c.expirationJitter <= 0 {
+		return d
+	}
+	return d + time.Duration(float64(d)*c.expirationJitter*(2*rand.Float64()-1))
+}
+
+// keepStale reports whether an entry with the given expirations has not been
+// expired for longer than StaleIfError allows, and may still be served.
+func (c *baseCache) keepStale(expirations ...*time.Time) bool {
+	if c.maxStaleness == nil {
+		return false
+	}
+	bound := c.clock.Now().Add(-*c.maxStaleness)
+	for _, e := range expirations {
+		if e != nil && e.Before(bound) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetExpiration changes the expiration of entries written from now on.
+// A d of 0 disables it. Existing entries keep their expiration.
+// ScoreCache entries do not expire.
+func (c *baseCache) SetExpiration(d time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if d <= 0 {
+		c.expiration = nil
+		return
+	}
+	c.expiration = &d
+}
+
+// SetRefreshAfterWrite changes the refresh interval of entries written from now on.
+// A d of 0 disables it. ScoreCache entries are not refreshed.
+func (c *baseCache) SetRefreshAfterWrite(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.StoreInt64(&c.refreshAfter, int64(d))
+}
+
+func (c *baseCache) refreshesAfterWrite() bool {
+	return atomic.LoadInt64(&c.refreshAfter) > 0
+}
+
+// newRefreshAt returns when an entry written now is to be reloaded in the background,
+// or nil if entries are not refreshed after write.
+func (c *baseCache) newRefreshAt() *time.Time {
+	d := time.Duration(atomic.LoadInt64(&c.refreshAfter))
+	if d <= 0 {
+		return nil
+	}
+	t := c.clock.Now().Add(d)
+	return &t
+}
+
+// refreshAfterWrite reloads key in the background once refreshAt has passed.
+func (c *baseCache) refreshAfterWrite(key interface{}, refreshAt *time.Time, cb loadedFunc) {
+	if c.loaderExpireFunc == nil || refreshAt == nil || c.clock.Now().Before(*refreshAt) {
+		return
+	}
+	c.refresh(key, cb)
+}
+
+// newAccessExpiration returns the expiration of an entry which is read or written now,
+// or nil if entries do not expire after access.
+func (c *baseCache) newAccessExpiration() *time.Time {
+	if c.expireAfterAccess == nil {
+		return nil
+	}
+	t := c.clock.Now().Add(*c.expireAfterAccess)
+	return &t
 }
 
+// touchedExpiration returns the expiration of an entry which is touched,
+// restarting it with ttl if given or the default expiration otherwise.
+func (c *baseCache) touchedExpiration(current *time.Time, ttl []time.Duration) *time.Time {
+	var d time.Duration
+	switch {
+	case len(ttl) > 0:
+		d = ttl[0]
+	case c.expiration != nil:
+		d = c.jitter(*c.expiration)
+	default:
+		return current
+	}
+	t := c.clock.Now().Add(d)
+	return &t
+}
+
+// loadedFunc stores a value returned by the loader with its expiration, if any,
+// and returns the stored value, read under the lock.
+type loadedFunc func(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error)
+
 // load a new value using by specified key.
-func (c *baseCache) load(key interface{}, cb func(interface{}, error) (interface{}, error), isWait bool) (interface{}, bool, error) {
-	v, called, err := c.loadGroup.Do(key, func() (interface{}, error) {
-		return cb((*c.loaderFunc)(key))
-	}, isWait)
+func (c *baseCache) load(key interface{}, cb loadedFunc, isWait bool) (interface{}, bool, error) {
+	v, called, err := c.loadGroup.Do(key, c.loadFunc(key, cb), isWait)
 	if err != nil {
 		return nil, called, err
 	}
 	return v, called, nil
 }
+
+// refresh reloads key in the background even though it is still cached.
+func (c *baseCache) refresh(key interface{}, cb loadedFunc) {
+	c.loadGroup.refresh(key, c.loadFunc(key, cb))
+}
+
+// loadFunc returns a function which loads key and passes the result to cb.
+func (c *baseCache) loadFunc(key interface{}, cb loadedFunc) func() (interface{}, error) {
+	return c.loadFuncWith(key, cb, c.callLoader)
+}
+
+// loadFuncWith is loadFunc with load in place of the LoaderFunc.
+func (c *baseCache) loadFuncWith(key interface{}, cb loadedFunc, load func(interface{}) (interface{}, *time.Duration, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		c.mu.Lock()
+		if c.loads == nil {
+			c.loads = make(map[interface{}]struct{})
+		}
+		c.loads[key] = struct{}{}
+		c.unlock()
+
+		start := time.Now()
+		v, ttl, err := load(key)
+		if err != nil {
+			c.mu.Lock()
+			c.superseded(key)
+			c.unlock()
+		}
+		return cb(key, v, ttl, time.Since(start), err)
+	}
+}
+
+// written records that key was written, superseding a load in-flight for it (not thread safe).
+func (c *baseCache) written(key interface{}) {
+	if len(c.loads) > 0 {
+		delete(c.loads, key)
+	}
+}
+
+// superseded reports whether key was written since its load started,
+// so that the loaded value must not replace the newer one (not thread safe).
+// Values loaded in bulk are always superseded by a cached value.
+func (c *baseCache) superseded(key interface{}) bool {
+	_, loading := c.loads[key]
+	delete(c.loads, key)
+	return !loading
+}
+
+// callLoader calls the loader for key, turning a panic into a LoaderPanicError
+// so that it cannot take down the caller or leave the load group waiting forever.
+func (c *baseCache) callLoader(key interface{}) (v interface{}, ttl *time.Duration, err error) {
+	if c.isClosed() {
+		return nil, nil, ClosedError
+	}
+	if c.breaker != nil && !c.breaker.allow() {
+		return nil, nil, BackendUnavailableError
+	}
+	c.acquireLoadSlot()
+	defer c.releaseLoadSlot()
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			v, ttl, err = nil, nil, &LoaderPanicError{Key: key, Value: r}
+		}
+		elapsed := time.Since(start)
+		c.stats.RecordLoad(elapsed, err)
+		c.logLoad(key, elapsed, err)
+		if c.breaker != nil {
+			c.breaker.record(err)
+		}
+		if err != nil {
+			c.loaderError(key, err)
+		}
+	}()
+	return (*c.loaderExpireFunc)(key)
+}
+
+// callBulkLoader is callLoader for the BulkLoaderFunc.
+func (c *baseCache) callBulkLoader(keys []interface{}) (vs map[interface{}]interface{}, err error) {
+	if c.isClosed() {
+		return nil, ClosedError
+	}
+	if c.breaker != nil && !c.breaker.allow() {
+		return nil, BackendUnavailableError
+	}
+	c.acquireLoadSlot()
+	defer c.releaseLoadSlot()
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			vs, err = nil, &LoaderPanicError{Key: keys, Value: r}
+		}
+		elapsed := time.Since(start)
+		c.stats.RecordLoad(elapsed, err)
+		c.logLoad(keys, elapsed, err)
+		if c.breaker != nil {
+			c.breaker.record(err)
+		}
+		if err != nil {
+			for _, key := range keys {
+				c.loaderError(key, err)
+			}
+		}
+	}()
+	return (*c.bulkLoaderFunc)(keys)
+}
+
+// close marks the cache as closed, cancels scheduled removals, waits for
+// background loads to finish and writes the last automatic snapshot. Returns ClosedError if it was already closed.
+func (c *baseCache) close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return ClosedError
+	}
+	c.removals.stop()
+	if c.weak != nil {
+		c.weak.stop()
+	}
+	if c.memoryStop != nil {
+		close(c.memoryStop)
+	}
+	c.loadGroup.wait()
+	c.stopAutoSnapshot()
+	c.closeWatchers()
+	if c.callbackPool != nil {
+		c.callbackPool.close()
+	}
+	return nil
+}
+
+func (c *baseCache) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// acquireLoadSlot blocks until fewer than MaxConcurrentLoads loads are running.
+func (c *baseCache) acquireLoadSlot() {
+	if c.loadSlots != nil {
+		c.loadSlots <- struct{}{}
+	}
+}
+
+func (c *baseCache) releaseLoadSlot() {
+	if c.loadSlots != nil {
+		<-c.loadSlots
+	}
+}
+
+func (c *baseCache) loaderError(key interface{}, err error) {
+	if c.loaderErrorFunc != nil {
+		(*c.loaderErrorFunc)(key, err)
+	}
+}
+
+// getMulti implements GetMulti on top of the getValue and getWithLoader methods of a cache.
+// Missing keys are loaded in bulk if there is a BulkLoaderFunc, storing each value with cb.
+func (c *baseCache) getMulti(
+	keys []interface{},
+	getValue func(interface{}) (interface{}, error),
+	getWithLoader func(interface{}, bool) (interface{}, error),
+	cb loadedFunc,
+) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{}, len(keys))
+	var missing []interface{}
+	for _, key := range keys {
+		if c.checkKey(key) != nil {
+			continue
+		}
+		if v, err := getValue(key); err == nil {
+			values[key] = v
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	if c.bulkLoaderFunc == nil {
+		var firstErr error
+		for _, key := range missing {
+			v, err := getWithLoader(key, true)
+			if err == nil {
+				values[key] = v
+			} else if err != KeyNotFoundError && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return values, firstErr
+	}
+
+	items, err := c.loadGroup.DoMulti(missing, func(keys []interface{}) (map[interface{}]interface{}, error) {
+		start := time.Now()
+		vs, err := c.callBulkLoader(keys)
+		if err != nil {
+			return nil, err
+		}
+		elapsed := time.Since(start)
+		items := make(map[interface{}]interface{}, len(vs))
+		for key, v := range vs {
+			if stored, err := cb(key, v, nil, elapsed, nil); err == nil {
+				items[key] = stored
+			}
+		}
+		return items, nil
+	})
+	for key, stored := range items {
+		v, verr := c.decode(key, stored)
+		if verr != nil {
+			if err == nil {
+				err = verr
+			}
+			continue
+		}
+		values[key] = v
+	}
+	return values, err
+}
+
+// refreshEarly implements probabilistic early expiration (XFetch).
+// An entry which took delta to load is refreshed ahead of its expiration with
+// a probability that grows as the expiration approaches, so that hot keys are
+// reloaded by a single caller instead of a stampede when they expire.
+func (c *baseCache) refreshEarly(key interface{}, expiration *time.Time, delta time.Duration, cb loadedFunc) {
+	if c.loaderExpireFunc == nil || expiration == nil || delta <= 0 {
+		return
+	}
+	gap := time.Duration(-float64(delta) * c.xfetchBeta * math.Log(rand.Float64()))
+	if !c.clock.Now().Add(gap).Before(*expiration) {
+		c.refresh(key, cb)
+	}
+}
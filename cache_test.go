@@ -1,6 +1,7 @@
 package gcache
 
 import (
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -9,13 +10,7 @@ import (
 
 func TestLoaderFunc(t *testing.T) {
 	size := 2
-	var testCaches = []*CacheBuilder{
-		New(size).Simple(),
-		New(size).LRU(),
-		New(size).LFU(),
-		New(size).ARC(),
-	}
-	for _, builder := range testCaches {
+	for _, builder := range expiringCacheBuilders(size) {
 		var testCounter int64
 		counter := 1000
 		cache := builder.
@@ -45,3 +40,1216 @@ func TestLoaderFunc(t *testing.T) {
 		}
 	}
 }
+
+func TestLoaderExpireFunc(t *testing.T) {
+	size := 2
+	for _, builder := range expiringCacheBuilders(size) {
+		var loads int64
+		cache := builder.
+			Expiration(time.Hour).
+			LoaderExpireFunc(func(key interface{}) (interface{}, *time.Duration, error) {
+				atomic.AddInt64(&loads, 1)
+				if key == "short" {
+					ttl := 20 * time.Millisecond
+					return key, &ttl, nil
+				}
+				return key, nil, nil
+			}).Build()
+
+		for _, key := range []string{"short", "default"} {
+			if v, err := cache.Get(key); err != nil || v != key {
+				t.Errorf("unexpected result %v, %v", v, err)
+			}
+		}
+		if n := atomic.LoadInt64(&loads); n != 2 {
+			t.Errorf("loads != %v", n)
+		}
+		time.Sleep(40 * time.Millisecond)
+
+		if _, err := cache.GetIFPresent("short"); err != KeyNotFoundError {
+			t.Errorf("short should be expired")
+		}
+		if _, err := cache.GetIFPresent("default"); err != nil {
+			t.Errorf("default should not be expired: %v", err)
+		}
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if key.(int) < 0 {
+					return nil, KeyNotFoundError
+				}
+				return key.(int) * 10, nil
+			}).Build()
+		cache.Set(1, "cached")
+
+		values, err := cache.GetMulti([]interface{}{1, 2, -1})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(values) != 2 || values[1] != "cached" || values[2] != 20 {
+			t.Errorf("%T: unexpected values %v", cache, values)
+		}
+	}
+}
+
+func TestBulkLoaderFunc(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		loading := make(chan struct{})
+		release := make(chan struct{})
+		var bulkLoads [][]interface{}
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				close(loading)
+				<-release
+				return "single", nil
+			}).
+			BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+				bulkLoads = append(bulkLoads, keys)
+				values := make(map[interface{}]interface{})
+				for _, key := range keys {
+					if key != "unknown" {
+						values[key] = "bulk"
+					}
+				}
+				return values, nil
+			}).Build()
+		cache.Set("cached", "cached")
+
+		go cache.Get("inflight")
+		<-loading
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+
+		values, err := cache.GetMulti([]interface{}{"cached", "inflight", "a", "b", "a", "unknown"})
+		if err != nil {
+			t.Error(err)
+		}
+		expected := map[interface{}]interface{}{"cached": "cached", "inflight": "single", "a": "bulk", "b": "bulk"}
+		if len(values) != len(expected) {
+			t.Errorf("%T: unexpected values %v", cache, values)
+		}
+		for key, v := range expected {
+			if values[key] != v {
+				t.Errorf("%T: %v = %v, expected %v", cache, key, values[key], v)
+			}
+		}
+		if len(bulkLoads) != 1 || len(bulkLoads[0]) != 3 {
+			t.Errorf("%T: unexpected bulk loads %v", cache, bulkLoads)
+		}
+		if v, err := cache.GetIFPresent("a"); err != nil || v != "bulk" {
+			t.Errorf("%T: bulk loaded values should be cached: %v, %v", cache, v, err)
+		}
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	size := 2
+	for _, builder := range cacheBuilders(size) {
+		var failures []error
+		fail := true
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if fail {
+					panic("backend is down")
+				}
+				return "ok", nil
+			}).
+			LoaderErrorFunc(func(key interface{}, err error) {
+				failures = append(failures, err)
+			}).Build()
+
+		_, err := cache.Get("key")
+		if pe, ok := err.(*LoaderPanicError); !ok || pe.Key != "key" || pe.Value != "backend is down" {
+			t.Errorf("%T: unexpected error %v", cache, err)
+		}
+		if len(failures) != 1 || failures[0] != err {
+			t.Errorf("%T: unexpected failures %v", cache, failures)
+		}
+
+		fail = false
+		if v, err := cache.Get("key"); err != nil || v != "ok" {
+			t.Errorf("%T: load after a panic failed: %v, %v", cache, v, err)
+		}
+	}
+}
+
+func TestLoaderErrorFunc(t *testing.T) {
+	loadErr := errors.New("load failed")
+	var keys []interface{}
+	cache := New(8).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			return nil, loadErr
+		}).
+		BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			return nil, loadErr
+		}).
+		LoaderErrorFunc(func(key interface{}, err error) {
+			if err != loadErr {
+				t.Errorf("unexpected error %v", err)
+			}
+			keys = append(keys, key)
+		}).Build()
+
+	if _, err := cache.Get(1); err != loadErr {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := cache.GetMulti([]interface{}{2, 3}); err != loadErr {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(keys) != 3 {
+		t.Errorf("unexpected failures for %v", keys)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		loadErr := errors.New("backend is down")
+		var err error
+		cache := builder.
+			Expiration(10 * time.Millisecond).
+			StaleIfError(50 * time.Millisecond).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				return key, err
+			}).Build()
+
+		if v, err := cache.Get("key"); err != nil || v != "key" {
+			t.Errorf("%T: unexpected result %v, %v", cache, v, err)
+		}
+		err = loadErr
+		time.Sleep(20 * time.Millisecond)
+		if v, err := cache.Get("key"); err != nil || v != "key" {
+			t.Errorf("%T: stale value should be served: %v, %v", cache, v, err)
+		}
+
+		err = KeyNotFoundError
+		if _, err := cache.Get("key"); err != KeyNotFoundError {
+			t.Errorf("%T: missing keys should not be served stale: %v", cache, err)
+		}
+
+		err = loadErr
+		time.Sleep(50 * time.Millisecond)
+		if _, err := cache.Get("key"); err != loadErr {
+			t.Errorf("%T: unexpected error %v", cache, err)
+		}
+	}
+}
+
+func TestGetAndRemove(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		var evicted []interface{}
+		cache := builder.
+			EvictedFunc(func(key, value interface{}) {
+				evicted = append(evicted, key)
+			}).
+			Build()
+		cache.Set("key", "value")
+
+		v, ok := cache.GetAndRemove("key")
+		if !ok || v != "value" {
+			t.Errorf("GetAndRemove() = %v, %v; want value, true", v, ok)
+		}
+		if _, err := cache.GetIFPresent("key"); err != KeyNotFoundError {
+			t.Errorf("err should be %v, not %v", KeyNotFoundError, err)
+		}
+		if l := cache.Len(); l != 0 {
+			t.Errorf("Len() = %v; want 0", l)
+		}
+		if len(evicted) != 1 {
+			t.Errorf("evicted = %v; want [key]", evicted)
+		}
+
+		v, ok = cache.GetAndRemove("key")
+		if ok || v != nil {
+			t.Errorf("GetAndRemove() = %v, %v; want nil, false", v, ok)
+		}
+	}
+}
+
+func TestGetAndRemoveExpired(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		var expired, evicted int
+		cache := builder.Expiration(time.Millisecond).
+			ExpiredFunc(func(_, _ interface{}) { expired++ }).
+			EvictedFunc(func(_, _ interface{}) { evicted++ }).
+			Build()
+		cache.Set("key", "value")
+		time.Sleep(5 * time.Millisecond)
+
+		if v, ok := cache.GetAndRemove("key"); ok {
+			t.Errorf("GetAndRemove() = %v, %v; want nil, false", v, ok)
+		}
+		if l := cache.Len(); l != 0 {
+			t.Errorf("Len() = %v; want 0", l)
+		}
+		if expired != 1 || evicted != 0 || cache.Stats().Expirations != 1 {
+			t.Errorf("%T: expired %d, evicted %d, stats %+v", cache, expired, evicted, cache.Stats())
+		}
+	}
+}
+
+func TestRemoveGet(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		cache.Set("key", "value")
+
+		if v, ok := cache.RemoveGet("key"); !ok || v != "value" {
+			t.Errorf("%T: RemoveGet() = %v, %v; want value, true", cache, v, ok)
+		}
+		if cache.Has("key") {
+			t.Errorf("%T: expected key to be removed", cache)
+		}
+		if v, ok := cache.RemoveGet("key"); ok || v != nil {
+			t.Errorf("%T: RemoveGet() = %v, %v; want nil, false", cache, v, ok)
+		}
+	}
+
+	// the value of an expired entry is handed back for cleanup
+	clock := NewFakeClock(time.Now())
+	cache := New(size).LRU().Clock(clock).Expiration(time.Second).Build()
+	cache.Set("key", "value")
+	clock.Advance(2 * time.Second)
+	if v, ok := cache.RemoveGet("key"); !ok || v != "value" {
+		t.Errorf("RemoveGet() = %v, %v; want value, true", v, ok)
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		var evicted int
+		cache := builder.
+			EvictedFunc(func(_, _ interface{}) {
+				evicted++
+			}).
+			Build()
+		for i := 0; i < 4; i++ {
+			cache.Set(i, i)
+		}
+
+		if n := cache.RemoveAll(0, 2, 5); n != 2 {
+			t.Errorf("%T: RemoveAll() = %v; want 2", cache, n)
+		}
+		if cache.Has(0) || cache.Has(2) || !cache.Has(1) || !cache.Has(3) {
+			t.Errorf("%T: unexpected keys %v", cache, cache.KeysIncludingExpired())
+		}
+		if evicted != 2 {
+			t.Errorf("%T: evicted %v entries; want 2", cache, evicted)
+		}
+		if n := cache.RemoveAll(); n != 0 {
+			t.Errorf("%T: RemoveAll() = %v; want 0", cache, n)
+		}
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+
+		v, loaded := cache.GetOrSet("key", 1)
+		if loaded || v != 1 {
+			t.Errorf("GetOrSet() = %v, %v; want 1, false", v, loaded)
+		}
+		v, loaded = cache.GetOrSet("key", 2)
+		if !loaded || v != 1 {
+			t.Errorf("GetOrSet() = %v, %v; want 1, true", v, loaded)
+		}
+		if v, _ := cache.Get("key"); v != 1 {
+			t.Errorf("Get() = %v; want 1", v)
+		}
+	}
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.Build()
+		var stored int32
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, loaded := cache.GetOrSet("key", i); !loaded {
+					atomic.AddInt32(&stored, 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if stored != 1 {
+			t.Errorf("stored %v values; want 1", stored)
+		}
+	}
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.Build()
+		for _, c := range []Cache{cache, cache.Namespace("ns")} {
+			if _, ok := c.(interface {
+				Swapper
+				Pinner
+				Indexer
+				Watcher
+				Dumper
+				Explainer
+			}); !ok {
+				t.Errorf("%T does not implement the optional interfaces", c)
+			}
+		}
+		if _, ok := cache.(Rescorer); ok != (builder.tp == TYPE_SCORE) {
+			t.Errorf("%T: Rescorer = %v", cache, ok)
+		}
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+
+		if cache.(Swapper).CompareAndSwap("key", nil, 1) {
+			t.Error("CompareAndSwap should fail for a missing key")
+		}
+		cache.Set("key", 1)
+		if cache.(Swapper).CompareAndSwap("key", 2, 3) {
+			t.Error("CompareAndSwap should fail for a different value")
+		}
+		if !cache.(Swapper).CompareAndSwap("key", 1, 2) {
+			t.Error("CompareAndSwap should succeed")
+		}
+		if v, _ := cache.Get("key"); v != 2 {
+			t.Errorf("Get() = %v; want 2", v)
+		}
+
+		if cache.(Swapper).CompareAndDelete("key", 1) {
+			t.Error("CompareAndDelete should fail for a different value")
+		}
+		if !cache.(Swapper).CompareAndDelete("key", 2) {
+			t.Error("CompareAndDelete should succeed")
+		}
+		if cache.Len() != 0 {
+			t.Errorf("Len() = %v; want 0", cache.Len())
+		}
+	}
+}
+
+func TestCompareAndSwapValues(t *testing.T) {
+	for _, builder := range []*CacheBuilder{New(8).LRU(), New(8).LRU().Bytes()} {
+		cache := builder.Build()
+		cache.Set("k", []byte("a"))
+		if cache.(Swapper).CompareAndSwap("k", []byte("b"), []byte("c")) {
+			t.Error("CompareAndSwap matched different bytes")
+		}
+		if !cache.(Swapper).CompareAndSwap("k", []byte("a"), []byte("b")) {
+			t.Error("CompareAndSwap did not match equal bytes")
+		}
+		if !cache.(Swapper).CompareAndDelete("k", []byte("b")) || cache.Has("k") {
+			t.Error("CompareAndDelete did not match equal bytes")
+		}
+	}
+
+	cache := New(8).LRU().Build()
+	cache.Set("k", map[string]int{"a": 1})
+	if cache.(Swapper).CompareAndSwap("k", map[string]int{"a": 1}, 2) || cache.(Swapper).CompareAndDelete("k", nil) {
+		t.Error("uncomparable values matched")
+	}
+
+	type counter struct{ n int }
+	cache = New(8).LRU().CopyOnGet(func(v interface{}) interface{} {
+		c := *v.(*counter)
+		return &c
+	}).Build()
+	p := &counter{1}
+	cache.Set("k", p)
+	if v, _ := cache.Get("k"); v == p {
+		t.Fatal("Get() did not return a copy")
+	}
+	if !cache.(Swapper).CompareAndSwap("k", p, &counter{2}) {
+		t.Error("CompareAndSwap did not match the stored pointer")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.Build()
+		cache.Set("counter", 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					v, _ := cache.Get("counter")
+					if cache.(Swapper).CompareAndSwap("counter", v, v.(int)+1) {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if v, _ := cache.Get("counter"); v != 50 {
+			t.Errorf("counter = %v; want 50", v)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	size := 8
+	testCaches := append(expiringCacheBuilders(size),
+		New(size).SCORE().
+			ScoringFunc(func(_ interface{}) int { return 1 }).
+			WeightingFunc(func(v interface{}) int { return len(v.(string)) }))
+	for _, builder := range testCaches {
+		cache := builder.Build()
+		appendA := func(current interface{}, exists bool) (interface{}, error) {
+			if !exists {
+				return "a", nil
+			}
+			return current.(string) + "a", nil
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := cache.Update("key", appendA); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}
+		if v, _ := cache.Get("key"); v != "aaa" {
+			t.Errorf("Get() = %v; want aaa", v)
+		}
+
+		someErr := errors.New("some error")
+		err := cache.Update("key", func(current interface{}, exists bool) (interface{}, error) {
+			return "b", someErr
+		})
+		if err != someErr {
+			t.Errorf("err = %v; want %v", err, someErr)
+		}
+		if v, _ := cache.Get("key"); v != "aaa" {
+			t.Errorf("Get() = %v; want aaa", v)
+		}
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+
+		if n, err := cache.Increment("new", 3); n != 3 || err != nil {
+			t.Errorf("Increment() = %v, %v; want 3, nil", n, err)
+		}
+		if v, _ := cache.Get("new"); v != int64(3) {
+			t.Errorf("Get() = %#v; want int64(3)", v)
+		}
+
+		cache.Set("int", 1)
+		cache.Set("uint64", uint64(1))
+		if n, _ := cache.Increment("int", 2); n != 3 {
+			t.Errorf("Increment() = %v; want 3", n)
+		}
+		if n, _ := cache.Decrement("uint64", 1); n != 0 {
+			t.Errorf("Decrement() = %v; want 0", n)
+		}
+		if v, _ := cache.Get("int"); v != 3 {
+			t.Errorf("Get() = %#v; want 3", v)
+		}
+		if v, _ := cache.Get("uint64"); v != uint64(0) {
+			t.Errorf("Get() = %#v; want uint64(0)", v)
+		}
+
+		cache.Set("string", "1")
+		if _, err := cache.Increment("string", 1); err != NotIntegerError {
+			t.Errorf("err = %v; want %v", err, NotIntegerError)
+		}
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	cache := New(8).LRU().Build()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Increment("counter", 1)
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("counter"); v != int64(100) {
+		t.Errorf("counter = %v; want 100", v)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		cache := builder.Expiration(30 * time.Millisecond).Build()
+		cache.Set("default", 1)
+		cache.Set("ttl", 2)
+		cache.Set("expired", 3)
+
+		if cache.Touch("missing") {
+			t.Error("Touch should fail for a missing key")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if !cache.Touch("default") {
+			t.Error("Touch should succeed")
+		}
+		if !cache.Touch("ttl", time.Hour) {
+			t.Error("Touch should succeed")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if cache.Touch("expired") {
+			t.Error("Touch should fail for an expired key")
+		}
+		for _, key := range []string{"default", "ttl"} {
+			if _, err := cache.GetIFPresent(key); err != nil {
+				t.Errorf("%v should not be expired: %v", key, err)
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if _, err := cache.GetIFPresent("default"); err != KeyNotFoundError {
+			t.Errorf("default should be expired")
+		}
+		if _, err := cache.GetIFPresent("ttl"); err != nil {
+			t.Errorf("ttl should not be expired: %v", err)
+		}
+	}
+}
+
+func TestSetWithExpire(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.Clock(clock).Build()
+		cache.SetWithExpire("short", 1, time.Minute)
+		cache.Set("default", 2)
+		clock.Advance(2 * time.Minute)
+		if cache.Has("short") {
+			t.Errorf("%T: the key did not expire", cache)
+		}
+		if v, err := cache.Get("default"); v != 2 || err != nil {
+			t.Errorf("%T: Get(default) = %v, %v", cache, v, err)
+		}
+	}
+}
+
+func TestExpireAfterAccess(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		cache := builder.
+			ExpireAfterAccess(30 * time.Millisecond).
+			Expiration(100 * time.Millisecond).
+			Build()
+		cache.Set("read", 1)
+		cache.Set("unread", 2)
+
+		for i := 0; i < 3; i++ {
+			time.Sleep(20 * time.Millisecond)
+			if _, err := cache.Get("read"); err != nil {
+				t.Errorf("read should not be expired: %v", err)
+			}
+		}
+		if _, err := cache.Get("unread"); err != KeyNotFoundError {
+			t.Errorf("unread should be expired")
+		}
+
+		// the write expiration still applies
+		for i := 0; i < 3; i++ {
+			time.Sleep(20 * time.Millisecond)
+			cache.Get("read")
+		}
+		if _, err := cache.Get("read"); err != KeyNotFoundError {
+			t.Errorf("read should be expired")
+		}
+	}
+}
+
+func TestExpirationJitter(t *testing.T) {
+	cache := New(1000).
+		Simple().
+		Expiration(time.Second).
+		ExpirationJitter(0.5).
+		Build().(*SimpleCache)
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		cache.Set(i, i)
+	}
+	min, max := time.Duration(1<<62), time.Duration(0)
+	for _, item := range cache.items {
+		d := item.expiration.Sub(start)
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	if min < 500*time.Millisecond || max > 1600*time.Millisecond {
+		t.Errorf("expirations out of range: %v - %v", min, max)
+	}
+	if max-min < 500*time.Millisecond {
+		t.Errorf("expirations are not spread: %v - %v", min, max)
+	}
+}
+
+func TestXFetch(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		var loads int64
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				time.Sleep(10 * time.Millisecond)
+				return atomic.AddInt64(&loads, 1), nil
+			}).
+			Expiration(time.Second).
+			XFetch(1e6).
+			Build()
+
+		if v, _ := cache.Get("key"); v != int64(1) {
+			t.Errorf("Get() = %v; want 1", v)
+		}
+		// with such a large beta the entry is always close enough to its expiration
+		if v, _ := cache.Get("key"); v != int64(1) {
+			t.Errorf("Get() = %v; want 1", v)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if v, _ := cache.Get("key"); v != int64(2) {
+			t.Errorf("Get() = %v; want 2", v)
+		}
+	}
+}
+
+func TestXFetchDisabled(t *testing.T) {
+	var loads int64
+	cache := New(8).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return atomic.AddInt64(&loads, 1), nil
+		}).
+		Expiration(time.Second).
+		Build()
+
+	cache.Get("key")
+	cache.Get("key")
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := cache.Get("key"); v != int64(1) {
+		t.Errorf("Get() = %v; want 1", v)
+	}
+}
+
+func TestMaxConcurrentLoads(t *testing.T) {
+	size := 64
+	for _, builder := range cacheBuilders(size) {
+		var running, maxRunning int64
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				n := atomic.AddInt64(&running, 1)
+				for {
+					m := atomic.LoadInt64(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				return key, nil
+			}).
+			MaxConcurrentLoads(3).
+			Build()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if v, err := cache.Get(i); err != nil || v != i {
+					t.Errorf("unexpected result %v, %v", v, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if maxRunning > 3 {
+			t.Errorf("%T: %v loads ran at the same time", cache, maxRunning)
+		}
+	}
+}
+
+func TestLoadCoalescingWindow(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		var loads int64
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				atomic.AddInt64(&loads, 1)
+				return nil, KeyNotFoundError
+			}).
+			LoadCoalescingWindow(30 * time.Millisecond).
+			Build()
+
+		for i := 0; i < 5; i++ {
+			if _, err := cache.Get("missing"); err != KeyNotFoundError {
+				t.Errorf("unexpected error %v", err)
+			}
+		}
+		if n := atomic.LoadInt64(&loads); n != 1 {
+			t.Errorf("%T: misses within the window should reuse the load, loads = %v", cache, n)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		cache.Get("missing")
+		if n := atomic.LoadInt64(&loads); n != 2 {
+			t.Errorf("%T: misses after the window should load again, loads = %v", cache, n)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				return key, nil
+			}).Build()
+		cache.Set("key", "value")
+		cache.RemoveAfter("key", 10*time.Millisecond)
+
+		if err := cache.Close(); err != nil {
+			t.Error(err)
+		}
+		if l := cache.Len(); l != 0 {
+			t.Errorf("%T: Close should drop all entries, got %v", cache, l)
+		}
+		if _, err := cache.Get("key"); err != ClosedError {
+			t.Errorf("%T: loads should fail after Close, got %v", cache, err)
+		}
+		if err := cache.Close(); err != ClosedError {
+			t.Errorf("%T: unexpected error %v", cache, err)
+		}
+
+		cache.Set("key", "value")
+		time.Sleep(20 * time.Millisecond)
+		if _, err := cache.GetIFPresent("key"); err != nil {
+			t.Errorf("%T: Close should cancel scheduled removals", cache)
+		}
+	}
+}
+
+func TestCloseWaitsForBackgroundLoads(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		var loaded int32
+		loading := make(chan struct{})
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				close(loading)
+				time.Sleep(20 * time.Millisecond)
+				atomic.StoreInt32(&loaded, 1)
+				return key, nil
+			}).Build()
+
+		cache.GetIFPresent("key")
+		<-loading
+		if err := cache.Close(); err != nil {
+			t.Error(err)
+		}
+		if atomic.LoadInt32(&loaded) != 1 {
+			t.Errorf("%T: Close should wait for background loads", cache)
+		}
+	}
+}
+
+func TestBuildE(t *testing.T) {
+	score := func(_ interface{}) int { return 1 }
+	loader := func(key interface{}) (interface{}, error) { return key, nil }
+	invalid := []*CacheBuilder{
+		New(8).EvictType("unknown"),
+		New(8).SCORE(),
+		New(8).SCORE().ScoringFunc(score),
+		New(8).SCORE().ScoringFunc(score).WeightingFunc(score).Expiration(time.Second),
+		New(8).SCORE().ScoringFunc(score).WeightingFunc(score).ScoreDecay(0),
+		New(8).SCORE().ScoringFunc(score).WeightingFunc(score).ScoringFallback(0, 0),
+		New(8).LRU().SnapshotInterval(0),
+		New(8).LRU().MaxEntries(4),
+		New(8).LRU().Expiration(-time.Second),
+		New(8).LRU().ExpireAfterAccess(0),
+		New(8).LRU().ExpirationJitter(0.5),
+		New(8).LRU().Expiration(time.Second).ExpirationJitter(1.5),
+		New(8).LRU().Expiration(time.Second).XFetch(1),
+		New(8).LRU().LoaderFunc(loader).XFetch(-1),
+		New(8).LRU().MaxConcurrentLoads(-1),
+		New(8).LRU().NoWaitLoad(),
+		New(8).LRU().NamespaceQuota("tenant", 0),
+		New(8).LRU().AutoSnapshot(0, SnapshotSinkFunc(nil)),
+		New(8).LRU().AutoSnapshot(time.Second, nil),
+		New(8).LRU().OverflowToDisk("", -1),
+		New(8).LRU().Bytes().WeakValues(),
+		New(8).LRU().DumpLimit(-1),
+	}
+	for _, builder := range invalid {
+		c, err := builder.BuildE()
+		if _, ok := err.(*ConfigError); !ok || c != nil {
+			t.Errorf("%+v should be invalid, got %v, %v", builder, c, err)
+		}
+	}
+
+	c, err := New(8).LRU().Expiration(time.Second).ExpirationJitter(0.1).LoaderFunc(loader).XFetch(1).BuildE()
+	if err != nil || c == nil {
+		t.Errorf("unexpected result %v, %v", c, err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Build should panic on an invalid configuration")
+		}
+	}()
+	New(8).SCORE().Build()
+}
+
+func TestSetCapacity(t *testing.T) {
+	size := 8
+	testCaches := append(expiringCacheBuilders(size),
+		New(size).SCORE().
+			ScoringFunc(func(v interface{}) int { return v.(int) }).
+			WeightingFunc(func(_ interface{}) int { return 1 }))
+	for _, builder := range testCaches {
+		evicted := 0
+		cache := builder.
+			EvictedFunc(func(key, value interface{}) {
+				evicted++
+			}).Build()
+		for i := 0; i < size; i++ {
+			cache.Set(i, i)
+		}
+
+		cache.SetCapacity(3)
+		if l := cache.Len(); l != 3 {
+			t.Errorf("%T: unexpected length %v after shrinking", cache, l)
+		}
+		if evicted != size-3 {
+			t.Errorf("%T: %v entries were evicted", cache, evicted)
+		}
+		for i := 0; i < size; i++ {
+			cache.Set(i, i)
+		}
+		if l := cache.Len(); l > 3 {
+			t.Errorf("%T: unexpected length %v", cache, l)
+		}
+
+		cache.SetCapacity(2 * size)
+		for i := 0; i < 2*size; i++ {
+			cache.Set(i, i)
+		}
+		if l := cache.Len(); l <= size {
+			t.Errorf("%T: unexpected length %v after growing", cache, l)
+		}
+	}
+}
+
+func TestSetExpiration(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		cache := builder.Build()
+		cache.Set("before", 1)
+		cache.SetExpiration(20 * time.Millisecond)
+		cache.Set("after", 2)
+		time.Sleep(30 * time.Millisecond)
+
+		if _, err := cache.Get("before"); err != nil {
+			t.Errorf("%T: existing entries should keep their expiration: %v", cache, err)
+		}
+		if _, err := cache.Get("after"); err != KeyNotFoundError {
+			t.Errorf("%T: new entries should expire", cache)
+		}
+
+		cache.SetExpiration(0)
+		cache.Set("disabled", 3)
+		time.Sleep(30 * time.Millisecond)
+		if _, err := cache.Get("disabled"); err != nil {
+			t.Errorf("%T: expiration should be disabled: %v", cache, err)
+		}
+	}
+}
+
+func TestRefreshAfterWrite(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		var loads int64
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				return atomic.AddInt64(&loads, 1), nil
+			}).
+			RefreshAfterWrite(20 * time.Millisecond).
+			Build()
+
+		if v, _ := cache.Get("key"); v != int64(1) {
+			t.Errorf("%T: unexpected value %v", cache, v)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if v, _ := cache.Get("key"); v != int64(1) {
+			t.Errorf("%T: the current value should be served during a refresh, got %v", cache, v)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if v, _ := cache.Get("key"); v != int64(2) {
+			t.Errorf("%T: the value should be refreshed, got %v", cache, v)
+		}
+
+		cache.SetRefreshAfterWrite(0)
+		cache.Set("key", int64(0))
+		time.Sleep(30 * time.Millisecond)
+		cache.Get("key")
+		time.Sleep(10 * time.Millisecond)
+		if n := atomic.LoadInt64(&loads); n != 2 {
+			t.Errorf("%T: refresh should be disabled, loads = %v", cache, n)
+		}
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	for _, builder := range expiringCacheBuilders(10) {
+		gc := builder.LoaderFunc(func(key interface{}) (interface{}, error) {
+			if key == "missing" {
+				return nil, KeyNotFoundError
+			}
+			return key, nil
+		}).Build()
+		gc.Get("a")
+		gc.Get("a")
+		gc.Get("b")
+		gc.Get("missing")
+
+		if gc.LoadCount() != 3 || gc.LoadSuccessCount() != 2 || gc.LoadErrorCount() != 1 {
+			t.Errorf("%T: loads: %v, successes: %v, errors: %v", gc, gc.LoadCount(), gc.LoadSuccessCount(), gc.LoadErrorCount())
+		}
+		s := gc.Stats()
+		var bucketed uint64
+		for _, n := range s.LoadLatency {
+			bucketed += n
+		}
+		if bucketed != 3 {
+			t.Errorf("%T: %v loads in latency histogram != 3", gc, bucketed)
+		}
+		if s.AverageLoadTime() != gc.AverageLoadTime() {
+			t.Errorf("%T: %v != %v", gc, s.AverageLoadTime(), gc.AverageLoadTime())
+		}
+	}
+}
+
+func TestExpirationStats(t *testing.T) {
+	for _, builder := range expiringCacheBuilders(10) {
+		gc := builder.Expiration(time.Millisecond).Build()
+		gc.Set("a", 1)
+		gc.Set("b", 2)
+		time.Sleep(5 * time.Millisecond)
+		gc.Get("a")
+		gc.Get("b")
+
+		s := gc.Stats()
+		if s.Expirations != 2 || s.Evictions != 0 || s.Misses != 2 {
+			t.Errorf("%T: expirations: %v, evictions: %v, misses: %v", gc, s.Expirations, s.Evictions, s.Misses)
+		}
+		gc.ResetStats()
+		if s := gc.Stats(); s != (CacheStats{}) {
+			t.Errorf("%T: %+v != zero stats after ResetStats", gc, s)
+		}
+	}
+}
+
+func TestLifetimeStats(t *testing.T) {
+	for _, builder := range cacheBuilders(1) {
+		gc := builder.Build()
+		gc.Set("a", 1)
+		time.Sleep(20 * time.Millisecond)
+		gc.Set("b", 2)
+
+		s := gc.Stats()
+		if s.LifetimeCount() != 1 {
+			t.Errorf("%T: %v lifetimes != 1", gc, s.LifetimeCount())
+		}
+		if p := s.LifetimePercentile(50); p < 20*time.Millisecond {
+			t.Errorf("%T: p50 lifetime %v < 20ms", gc, p)
+		}
+	}
+}
+
+func TestSetDuringLoad(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		loading := make(chan struct{})
+		release := make(chan struct{})
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				close(loading)
+				<-release
+				return "loaded", nil
+			}).
+			Build()
+
+		done := make(chan interface{})
+		go func() {
+			v, _ := cache.Get("key")
+			done <- v
+		}()
+		<-loading
+		// the cache is not locked while loading
+		cache.Set("key", "set")
+		close(release)
+
+		if v := <-done; v != "set" {
+			t.Errorf("%T: the load returned %v rather than the value set meanwhile", cache, v)
+		}
+		if v, _ := cache.Get("key"); v != "set" {
+			t.Errorf("%T: the load overwrote the value set meanwhile with %v", cache, v)
+		}
+	}
+}
+
+func TestConcurrentGetSet(t *testing.T) {
+	size := 16
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return key, nil
+			}).
+			Build()
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					key := (g + i) % (2 * size)
+					if g%2 == 0 {
+						cache.Set(key, key)
+					} else if v, err := cache.Get(key); err != nil || v != key {
+						t.Errorf("%T: unexpected result %v, %v", cache, v, err)
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
+
+func TestCallbacksUseCache(t *testing.T) {
+	size := 2
+	for _, builder := range cacheBuilders(size) {
+		var cache Cache
+		var added, evicted []int
+		cache = builder.
+			AddedFunc(func(key, _ interface{}) {
+				// callbacks run once the cache is unlocked
+				added = append(added, cache.Len())
+			}).
+			EvictedFunc(func(key, _ interface{}) {
+				evicted = append(evicted, cache.Len())
+			}).
+			Build()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 3; i++ {
+				cache.Set(i, i)
+			}
+			cache.Remove(2)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%T: deadlocked calling the cache from a callback", cache)
+		}
+		if len(added) != 3 || added[0] != 1 || len(evicted) != 2 {
+			t.Errorf("%T: unexpected callbacks, added %v, evicted %v", cache, added, evicted)
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	size := 2
+	for _, builder := range cacheBuilders(size) {
+		var loads int
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				loads++
+				return key, nil
+			}).
+			Build()
+		cache.Set("a", 1)
+		if !cache.Has("a") {
+			t.Errorf("%T: expected a to be cached", cache)
+		}
+		if cache.Has("b") {
+			t.Errorf("%T: expected b not to be cached", cache)
+		}
+		if s := cache.Stats(); s.Hits != 0 || s.Misses != 0 || loads != 0 {
+			t.Errorf("%T: Has counted %+v and loaded %v times", cache, s, loads)
+		}
+	}
+
+	clock := NewFakeClock(time.Now())
+	cache := New(size).LRU().Clock(clock).Expiration(time.Second).Build()
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if !cache.Has("a") {
+		t.Error("expected a to be cached")
+	}
+	// Has does not update the recency of a
+	cache.Set("c", 3)
+	if cache.Has("a") || !cache.Has("b") {
+		t.Error("expected a to be evicted")
+	}
+	clock.Advance(2 * time.Second)
+	if cache.Has("b") {
+		t.Error("expected b to be expired")
+	}
+}
+
+func TestKeysIncludingExpired(t *testing.T) {
+	size := 8
+	testCaches := append(expiringCacheBuilders(size),
+		New(size).Simple().SnapshotInterval(time.Minute),
+		New(size).LRU().SnapshotInterval(time.Minute))
+	for _, builder := range testCaches {
+		clock := NewFakeClock(time.Now())
+		cache := builder.Clock(clock).Expiration(time.Second).Build()
+		cache.Set("expired", 1)
+		cache.Set("live", 2)
+		cache.Touch("live", time.Hour)
+		clock.Advance(2 * time.Second)
+
+		if keys := cache.KeysIncludingExpired(); len(keys) != 2 {
+			t.Errorf("%T: expected the raw keys to include the expired one, got %v", cache, keys)
+		}
+		if keys := cache.Keys(); len(keys) != 1 || keys[0] != "live" {
+			t.Errorf("%T: unexpected keys %v", cache, keys)
+		}
+		if all := cache.GetALLIncludingExpired(); len(all) != 2 {
+			t.Errorf("%T: expected the raw entries to include the expired one, got %v", cache, all)
+		}
+		if all := cache.GetALL(); len(all) != 1 || all["live"] != 2 {
+			t.Errorf("%T: unexpected entries %v", cache, all)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package gcache
+
+import (
+	"sync"
+	"time"
+)
+
+// added indexes key and queues the AddedFunc for it (not thread safe).
+func (c *baseCache) added(key, value interface{}) {
+	c.indexed(key, value)
+	if c.addedFunc != nil {
+		f := *c.addedFunc
+		c.callbacks = append(c.callbacks, func() { f(key, value) })
+	}
+}
+
+// updated queues the UpdatedFunc for key, whose value old is overwritten with new (not thread safe).
+func (c *baseCache) updated(key, old, new interface{}) {
+	if c.updatedFunc != nil {
+		f := *c.updatedFunc
+		c.callbacks = append(c.callbacks, func() { f(key, old, new) })
+	}
+}
+
+// rejected counts an entry which was not admitted for reason
+// and queues the RejectedFunc for it (not thread safe).
+func (c *baseCache) rejected(key, value interface{}, reason RejectionReason) {
+	c.stats.IncrRejectionCount()
+	if c.rejectedFunc != nil {
+		f := *c.rejectedFunc
+		c.callbacks = append(c.callbacks, func() { f(key, value, reason) })
+	}
+}
+
+// missed calls the MissFunc for key, which was not found in the cache.
+// The cache must not be locked.
+func (c *baseCache) missed(key interface{}) {
+	if c.missFunc != nil {
+		(*c.missFunc)(key)
+	}
+}
+
+// evicted queues the EvictedFunc and EvictedEntryFunc for key, which expires at the
+// earliest of expirations, or the ExpiredFunc while an expired entry is removed (not thread safe).
+func (c *baseCache) evicted(key, value interface{}, expirations ...*time.Time) {
+	if c.expiring {
+		c.expired(key, value)
+		return
+	}
+	c.changed(key, ValueEvicted, nil)
+	if c.evictedFunc != nil {
+		f := *c.evictedFunc
+		c.callbacks = append(c.callbacks, func() { f(key, value) })
+	}
+	if c.evictedEntryFunc != nil {
+		f := *c.evictedEntryFunc
+		e := SnapshotEntry{Key: key, Value: value}
+		for _, exp := range expirations {
+			if exp != nil && (e.Expiration == nil || exp.Before(*e.Expiration)) {
+				t := *exp
+				e.Expiration = &t
+			}
+		}
+		c.callbacks = append(c.callbacks, func() { f(e) })
+	}
+}
+
+// expired queues the ExpiredFunc for an entry removed because it expired,
+// or the EvictedFunc if there is no ExpiredFunc (not thread safe).
+func (c *baseCache) expired(key, value interface{}) {
+	c.changed(key, ValueExpired, nil)
+	switch {
+	case c.expiredFunc != nil:
+		f := *c.expiredFunc
+		c.callbacks = append(c.callbacks, func() { f(key, value) })
+	case c.evictedFunc != nil:
+		f := *c.evictedFunc
+		c.callbacks = append(c.callbacks, func() { f(key, value) })
+	}
+}
+
+// expire removes an expired entry, created at created, with remove
+// and counts its expiration (not thread safe).
+func (c *baseCache) expire(created time.Time, remove func()) {
+	c.expiring = true
+	remove()
+	c.expiring = false
+	c.recordExpiration(created)
+}
+
+// unlock unlocks the cache, then runs the callbacks queued while it was locked,
+// so that callbacks can use the cache without deadlocking.
+func (c *baseCache) unlock() {
+	callbacks := c.callbacks
+	c.callbacks = nil
+	c.mu.Unlock()
+	for _, f := range callbacks {
+		if c.callbackPool != nil {
+			if !c.callbackPool.submit(f) {
+				c.stats.IncrDroppedCallbackCount()
+			}
+		} else {
+			f()
+		}
+	}
+}
+
+// OverflowPolicy decides what happens to a callback when the queue of AsyncCallbacks is full.
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow waits until the queue has room, slowing down writes.
+	BlockOnOverflow OverflowPolicy = iota
+	// DropOnOverflow drops the callback.
+	DropOnOverflow
+)
+
+// AsyncCallbacks runs callbacks on the given number of background workers,
+// which take them from a queue of queueSize, rather than on the goroutine
+// writing to the cache. Callbacks may then run out of order.
+// Close waits for the queued callbacks to run; callbacks of writes after
+// Close run on the writing goroutine again.
+func (cb *CacheBuilder) AsyncCallbacks(workers, queueSize int) *CacheBuilder {
+	cb.callbackWorkers = workers
+	cb.callbackQueue = queueSize
+	return cb
+}
+
+// CallbackOverflow sets what happens to callbacks when the queue of AsyncCallbacks is full.
+// The default is BlockOnOverflow.
+func (cb *CacheBuilder) CallbackOverflow(policy OverflowPolicy) *CacheBuilder {
+	cb.callbackOverflow = policy
+	return cb
+}
+
+// callbackPool runs callbacks on a fixed number of workers.
+type callbackPool struct {
+	mu     sync.RWMutex // held for reading while submitting
+	closed bool
+	queue  chan func()
+	policy OverflowPolicy
+	wg     sync.WaitGroup
+}
+
+func newCallbackPool(workers, queueSize int, policy OverflowPolicy) *callbackPool {
+	p := &callbackPool{queue: make(chan func(), queueSize), policy: policy}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for f := range p.queue {
+				f()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues f, or runs it right away once the pool is closed.
+// It returns false if f was dropped.
+func (p *callbackPool) submit(f func()) bool {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		f()
+		return true
+	}
+	defer p.mu.RUnlock()
+	if p.policy == DropOnOverflow {
+		select {
+		case p.queue <- f:
+			return true
+		default:
+			return false
+		}
+	}
+	p.queue <- f
+	return true
+}
+
+// close waits for the queued callbacks to run.
+func (p *callbackPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,202 @@
+package gcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncCallbacks(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		var added, evicted int64
+		cache := builder.
+			AddedFunc(func(_, _ interface{}) { atomic.AddInt64(&added, 1) }).
+			EvictedFunc(func(_, _ interface{}) { atomic.AddInt64(&evicted, 1) }).
+			AsyncCallbacks(2, 1).
+			Build()
+		for i := 0; i < 2*size; i++ {
+			cache.Set(i, i)
+		}
+		// Close waits for the queued callbacks
+		cache.Close()
+		if added != int64(2*size) || evicted != int64(size) {
+			t.Errorf("%T: %v added and %v evicted callbacks", cache, added, evicted)
+		}
+
+		cache.Set("after", "close")
+		if added != int64(2*size)+1 {
+			t.Errorf("%T: expected callbacks to run synchronously after Close", cache)
+		}
+	}
+}
+
+func TestAsyncCallbacksDropOnOverflow(t *testing.T) {
+	block := make(chan struct{})
+	var once sync.Once
+	var added int64
+	cache := New(16).LRU().
+		AddedFunc(func(_, _ interface{}) {
+			once.Do(func() { <-block })
+			atomic.AddInt64(&added, 1)
+		}).
+		AsyncCallbacks(1, 2).
+		CallbackOverflow(DropOnOverflow).
+		Build()
+	for i := 0; i < 8; i++ {
+		cache.Set(i, i)
+	}
+	close(block)
+	cache.Close()
+
+	dropped := cache.Stats().DroppedCallbacks
+	if dropped == 0 || uint64(added)+dropped != 8 {
+		t.Errorf("%v callbacks ran and %v were dropped", added, dropped)
+	}
+}
+
+func TestAsyncCallbacksConfig(t *testing.T) {
+	for _, builder := range []*CacheBuilder{
+		New(1).AsyncCallbacks(-1, 1),
+		New(1).AsyncCallbacks(1, -1),
+		New(1).AsyncCallbacks(0, 1),
+	} {
+		if _, err := builder.BuildE(); err == nil {
+			t.Errorf("expected an invalid configuration")
+		}
+	}
+}
+
+func TestPurgeEvict(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		evicted := make(map[interface{}]interface{})
+		cache := builder.
+			EvictedFunc(func(key, value interface{}) {
+				evicted[key] = value
+			}).
+			Build()
+		cache.Set(1, "a")
+		cache.Purge()
+		if len(evicted) != 0 {
+			t.Errorf("%T: Purge called the EvictedFunc with %v", cache, evicted)
+		}
+
+		cache = builder.PurgeEvict(true).Build()
+		cache.Set(1, "a")
+		cache.Set(2, "b")
+		cache.Purge()
+		if len(evicted) != 2 || evicted[1] != "a" || evicted[2] != "b" {
+			t.Errorf("%T: Purge called the EvictedFunc with %v", cache, evicted)
+		}
+		cache.Set(3, "c")
+		cache.Close()
+		if len(evicted) != 3 || evicted[3] != "c" {
+			t.Errorf("%T: Close called the EvictedFunc with %v", cache, evicted)
+		}
+	}
+}
+
+func TestExpiredFunc(t *testing.T) {
+	size := 2
+	for _, builder := range expiringCacheBuilders(size) {
+		clock := NewFakeClock(time.Now())
+		var evicted, expired []interface{}
+		cache := builder.
+			Clock(clock).
+			Expiration(time.Second).
+			EvictedFunc(func(key, _ interface{}) {
+				evicted = append(evicted, key)
+			}).
+			ExpiredFunc(func(key, _ interface{}) {
+				expired = append(expired, key)
+			}).
+			Build()
+		cache.Set(1, 1)
+		clock.Advance(2 * time.Second)
+		if _, err := cache.GetIFPresent(1); err != KeyNotFoundError {
+			t.Errorf("%T: expected 1 to be expired, got %v", cache, err)
+		}
+		if len(expired) != 1 || expired[0] != 1 || len(evicted) != 0 {
+			t.Errorf("%T: expired %v, evicted %v", cache, expired, evicted)
+		}
+
+		cache.Remove(2)
+		cache.Set(2, 2)
+		cache.Remove(2)
+		if len(expired) != 1 || len(evicted) != 1 || evicted[0] != 2 {
+			t.Errorf("%T: expired %v, evicted %v", cache, expired, evicted)
+		}
+	}
+
+	// without an ExpiredFunc, expired entries go to the EvictedFunc
+	clock := NewFakeClock(time.Now())
+	var evicted []interface{}
+	cache := New(size).LRU().
+		Clock(clock).
+		Expiration(time.Second).
+		EvictedFunc(func(key, _ interface{}) {
+			evicted = append(evicted, key)
+		}).
+		Build()
+	cache.Set(1, 1)
+	clock.Advance(2 * time.Second)
+	cache.GetIFPresent(1)
+	if len(evicted) != 1 {
+		t.Errorf("evicted %v", evicted)
+	}
+}
+
+func TestUpdatedFunc(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		var updates [][3]interface{}
+		cache := builder.
+			UpdatedFunc(func(key, oldValue, newValue interface{}) {
+				updates = append(updates, [3]interface{}{key, oldValue, newValue})
+			}).
+			Build()
+		cache.Set("a", 1)
+		if len(updates) != 0 {
+			t.Errorf("%T: unexpected updates %v", cache, updates)
+		}
+		cache.Set("a", 2)
+		if len(updates) != 1 || updates[0] != [3]interface{}{"a", 1, 2} {
+			t.Errorf("%T: unexpected updates %v", cache, updates)
+		}
+	}
+}
+
+func TestMissFunc(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		var missed []interface{}
+		loaded := 0
+		var cache Cache
+		cache = builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if len(missed) != loaded+1 {
+					t.Errorf("%T: loader ran before the MissFunc", cache)
+				}
+				loaded++
+				return key, nil
+			}).
+			MissFunc(func(key interface{}) {
+				missed = append(missed, key)
+				// the cache is unlocked
+				cache.Has(key)
+			}).
+			Build()
+		cache.Set("a", 1)
+		cache.Get("a")
+		cache.Has("b")
+		if len(missed) != 0 {
+			t.Errorf("%T: unexpected misses %v", cache, missed)
+		}
+		cache.Get("b")
+		if len(missed) != 1 || missed[0] != "b" || loaded != 1 {
+			t.Errorf("%T: missed %v, loaded %v", cache, missed, loaded)
+		}
+	}
+}
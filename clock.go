@@ -0,0 +1,158 @@
+package gcache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a cache: expiration, refreshes, scheduled
+// removals, statistics windows and background checks all follow it.
+// Load durations are measured with the real time.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker returns a ticker which ticks every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer created by a Clock.
+type Timer interface {
+	// Stop prevents the timer from firing and reports whether it was still pending.
+	Stop() bool
+}
+
+// Ticker is a ticker created by a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Clock sets the source of time of the cache, which is the system clock by default.
+func (cb *CacheBuilder) Clock(clock Clock) *CacheBuilder {
+	cb.clock = clock
+	return cb
+}
+
+// RealClock is the system clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock which only moves when told to, so that tests of
+// expiration and background behavior do not need to sleep.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers which
+// are due in order. Timer functions run before Advance returns.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.now = t.at
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+			select {
+			case t.c <- c.now:
+			default:
+			}
+			continue
+		}
+		c.timers = c.timers[1:]
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("gcache: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return fakeTicker{t}
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	at     time.Time
+	f      func()
+	period time.Duration // of a ticker
+	c      chan time.Time
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.timers {
+		if other == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
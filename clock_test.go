@@ -0,0 +1,69 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	var fired []int
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, 0) })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("expected Stop to report whether the timer was pending")
+	}
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(1500 * time.Millisecond)
+	if len(fired) != 1 || fired[0] != 1 {
+		t.Errorf("unexpected timers fired %v", fired)
+	}
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(start.Add(time.Second)) {
+			t.Errorf("unexpected tick %v", tick)
+		}
+	default:
+		t.Error("expected the ticker to tick")
+	}
+	clock.Advance(time.Second)
+	if len(fired) != 2 || fired[1] != 2 {
+		t.Errorf("unexpected timers fired %v", fired)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(2500 * time.Millisecond)) {
+		t.Errorf("unexpected time %v", now)
+	}
+}
+
+func TestClockExpiration(t *testing.T) {
+	size := 8
+	for _, builder := range expiringCacheBuilders(size) {
+		clock := NewFakeClock(time.Now())
+		cache := builder.
+			Clock(clock).
+			Expiration(time.Minute).
+			Build()
+		cache.Set("expiring", 1)
+		cache.Set("removed", 2)
+		cache.RemoveAfter("removed", 30*time.Second)
+
+		clock.Advance(30 * time.Second)
+		if _, err := cache.Get("removed"); err != KeyNotFoundError {
+			t.Errorf("%T: expected the scheduled removal to have run", cache)
+		}
+		if _, err := cache.Get("expiring"); err != nil {
+			t.Errorf("%T: expected the entry not to be expired yet: %v", cache, err)
+		}
+		clock.Advance(31 * time.Second)
+		if _, err := cache.Get("expiring"); err != KeyNotFoundError {
+			t.Errorf("%T: expected the entry to be expired", cache)
+		}
+		if n := cache.Stats().LifetimeCount(); n != 1 {
+			t.Errorf("%T: %v lifetimes recorded", cache, n)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package gcache
+
+// CloneFunc returns a deep copy of a value.
+type CloneFunc func(value interface{}) interface{}
+
+// Return a copy of the values made with clone, rather than the values cached, so
+// that callers cannot mutate the values shared with other callers. Values are
+// copied by every method returning them, after DeserializeFunc.
+func (cb *CacheBuilder) CopyOnGet(clone CloneFunc) *CacheBuilder {
+	cb.copyOnGet = clone
+	return cb
+}
+
+// Cache a copy of the values made with clone, rather than the values given, so
+// that callers cannot mutate the cached values once they are set. Values are
+// copied before SerializeFunc, including the values loaded by the LoaderFunc.
+func (cb *CacheBuilder) CopyOnSet(clone CloneFunc) *CacheBuilder {
+	cb.copyOnSet = clone
+	return cb
+}
@@ -0,0 +1,31 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func cloneSlice(v interface{}) interface{} {
+	return append([]int(nil), v.([]int)...)
+}
+
+func TestCopyOnGetAndSet(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.CopyOnGet(cloneSlice).CopyOnSet(cloneSlice).Build()
+
+		value := []int{1, 2}
+		cache.Set("a", value)
+		value[0] = 100
+		got, err := cache.Get("a")
+		if err != nil || got.([]int)[0] != 1 {
+			t.Errorf("%T: Get() = %v, %v; mutating the value given to Set changed the cached one", cache, got, err)
+		}
+		got.([]int)[1] = 200
+		if got, _ := cache.Get("a"); got.([]int)[1] != 2 {
+			t.Errorf("%T: Get() = %v; mutating a value returned by Get changed the cached one", cache, got)
+		}
+		if all := cache.GetALLIncludingExpired(); all["a"].([]int)[1] != 2 {
+			t.Errorf("%T: GetALL() = %v", cache, all)
+		}
+	}
+}
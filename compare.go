@@ -0,0 +1,50 @@
+package gcache
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Swapper is implemented by the caches which can replace or remove a value
+// only if it is still the expected one. All the cache types are Swappers.
+//
+// CompareAndSwap and CompareAndDelete compare the value as it was set (or as
+// serialized by the SerializeFunc) with old: byte slices by content, other
+// values with ==. Values which cannot be compared, e.g. maps, never match.
+type Swapper interface {
+	CompareAndSwap(key, old, new interface{}) bool
+	CompareAndDelete(key, old interface{}) bool
+}
+
+// matches reports whether the stored value of key is equal to old, for
+// CompareAndSwap and CompareAndDelete. The value is compared as it was set,
+// or as serialized by the SerializeFunc after serializing old the same way,
+// so that the copies made by CopyOnGet or a DeserializeFunc do not matter.
+func (c *baseCache) matches(key, stored, old interface{}) bool {
+	v, err := c.unwrap(stored)
+	if err != nil {
+		return false
+	}
+	if c.serializeFunc != nil {
+		if old, err = c.serializeFunc(key, old); err != nil {
+			return false
+		}
+	}
+	return equalValues(v, old)
+}
+
+// equalValues reports whether a and b are equal. Byte slices are compared by
+// content, other values with ==, and values which cannot be compared are not equal.
+func equalValues(a, b interface{}) bool {
+	if x, ok := a.([]byte); ok {
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.ValueOf(a).Comparable() || !reflect.ValueOf(b).Comparable() {
+		return false
+	}
+	return a == b
+}
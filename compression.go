@@ -0,0 +1,111 @@
+package gcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// DefaultCompressionThreshold is the size in bytes above which values are compressed.
+const DefaultCompressionThreshold = 1024
+
+// Codec compresses values, e.g. with gzip, snappy or zstd.
+type Codec interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+// CompressedValue is how a compressed value is stored in the cache.
+// ScoringFunc, WeightingFunc, AddedFunc and EvictedFunc receive it in place of the value.
+type CompressedValue struct {
+	Data     []byte
+	Size     int  // of the uncompressed value
+	isString bool // the value was a string rather than a []byte
+}
+
+// Compress []byte and string values larger than the compression threshold with codec.
+// Combined with SerializeFunc, any value can be compressed.
+// Values are decompressed before they are returned, or passed to a DeserializeFunc.
+func (cb *CacheBuilder) Compression(codec Codec) *CacheBuilder {
+	cb.codec = codec
+	return cb
+}
+
+// Compress only values larger than n bytes, DefaultCompressionThreshold by default.
+func (cb *CacheBuilder) CompressionThreshold(n int) *CacheBuilder {
+	cb.compressThreshold = n
+	return cb
+}
+
+// compress returns value compressed if it is large enough to be worth it.
+func (c *baseCache) compress(value interface{}) (interface{}, error) {
+	var data []byte
+	isString := false
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data, isString = []byte(v), true
+	default:
+		return value, nil
+	}
+	if len(data) <= c.compressThreshold {
+		return value, nil
+	}
+	compressed, err := c.codec.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	c.stats.RecordCompression(len(data), len(compressed))
+	return &CompressedValue{Data: compressed, Size: len(data), isString: isString}, nil
+}
+
+// decompress returns the original form of a value returned by compress.
+func (c *baseCache) decompress(value interface{}) (interface{}, error) {
+	cv, ok := value.(*CompressedValue)
+	if !ok {
+		return value, nil
+	}
+	data, err := c.codec.Decompress(cv.Data)
+	if err != nil {
+		return nil, err
+	}
+	if cv.isString {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+// GzipCodec is a Codec which uses compress/gzip.
+type GzipCodec struct {
+	// Level is the gzip compression level, gzip.DefaultCompression if zero.
+	Level int
+}
+
+func (gc GzipCodec) Compress(data []byte) ([]byte, error) {
+	level := gc.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gc GzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,82 @@
+package gcache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGzipCodec(t *testing.T) {
+	data := bytes.Repeat([]byte("gcache "), 100)
+	compressed, err := GzipCodec{}.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("%v compressed bytes >= %v", len(compressed), len(data))
+	}
+	decompressed, err := GzipCodec{}.Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Errorf("decompressed data differs")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("gcache ", 100)
+	for _, builder := range append(expiringCacheBuilders(10),
+		New(10000).SCORE().
+			ScoringFunc(func(_ interface{}) int { return 1 }).
+			WeightingFunc(func(v interface{}) int {
+				if cv, ok := v.(*CompressedValue); ok {
+					return len(cv.Data)
+				}
+				return 1
+			})) {
+		gc := builder.Compression(GzipCodec{}).CompressionThreshold(100).Build()
+		gc.Set("small", "gcache")
+		gc.Set("large", large)
+		gc.Set("bytes", []byte(large))
+
+		if v, err := gc.Get("small"); err != nil || v != "gcache" {
+			t.Errorf("%T: small: %v, %v", gc, v, err)
+		}
+		if v, err := gc.Get("large"); err != nil || v != large {
+			t.Errorf("%T: large: %v", gc, err)
+		}
+		if v, err := gc.Get("bytes"); err != nil || !bytes.Equal(v.([]byte), []byte(large)) {
+			t.Errorf("%T: bytes: %v", gc, err)
+		}
+
+		s := gc.Stats()
+		if s.Compressions != 2 || s.UncompressedBytes != uint64(2*len(large)) {
+			t.Errorf("%T: compressions: %v, uncompressed bytes: %v", gc, s.Compressions, s.UncompressedBytes)
+		}
+		if r := s.CompressionRatio(); r <= 0 || r >= 0.5 {
+			t.Errorf("%T: unexpected compression ratio %v", gc, r)
+		}
+	}
+}
+
+func TestCompressionWithSerializeFunc(t *testing.T) {
+	gc := New(10).LRU().
+		SerializeFunc(gobSerialize).
+		DeserializeFunc(gobDeserialize).
+		Compression(GzipCodec{}).
+		CompressionThreshold(10).
+		Build()
+	value := make([]int, 100)
+	gc.Set("a", value)
+	v, err := gc.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.([]int)) != 100 {
+		t.Errorf("%v != 100 ints", len(v.([]int)))
+	}
+	if gc.Stats().Compressions != 1 {
+		t.Errorf("%v compressions != 1", gc.Stats().Compressions)
+	}
+}
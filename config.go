@@ -0,0 +1,127 @@
+package gcache
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// CacheConfig is a serializable cache configuration, e.g. loaded from a JSON file,
+// which lets operators tune a cache per environment without recompiling.
+// Zero values leave the corresponding option unset.
+// Functions such as the LoaderFunc cannot be serialized, add them to the builder
+// returned by Builder.
+type CacheConfig struct {
+	// Type is one of TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC and TYPE_SCORE.
+	// Defaults to TYPE_SIMPLE.
+	Type                 string   `json:"type"`
+	Size                 int      `json:"size"`
+	Expiration           Duration `json:"expiration"`
+	ExpireAfterAccess    Duration `json:"expire_after_access"`
+	ExpirationJitter     float64  `json:"expiration_jitter"`
+	XFetch               float64  `json:"xfetch"`
+	RefreshAfterWrite    Duration `json:"refresh_after_write"`
+	StaleIfError         Duration `json:"stale_if_error"`
+	BreakerThreshold     int      `json:"breaker_threshold"`
+	BreakerCooldown      Duration `json:"breaker_cooldown"`
+	MaxConcurrentLoads   int      `json:"max_concurrent_loads"`
+	LoadCoalescingWindow Duration `json:"load_coalescing_window"`
+	SlowLoadThreshold    Duration `json:"slow_load_threshold"`
+	SnapshotInterval     Duration `json:"snapshot_interval"`
+	StatsWindow          Duration `json:"stats_window"`
+	StatsWindowBuckets   int      `json:"stats_window_buckets"`
+	MemoryLimit          uint64   `json:"memory_limit"`
+	MemoryCheckInterval  Duration `json:"memory_check_interval"`
+	ScoreDecay           Duration `json:"score_decay"`
+	AccessBoost          float64  `json:"access_boost"`
+	MaxEntries           int      `json:"max_entries"`
+}
+
+// Duration is a time.Duration which is encoded as a string such as "1m30s".
+// Plain numbers are decoded as nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("Invalid duration.")
+	}
+	return nil
+}
+
+// NewFromConfig builds a cache from cfg, or returns a ConfigError if it is invalid.
+func NewFromConfig(cfg CacheConfig) (Cache, error) {
+	cb, err := cfg.Builder()
+	if err != nil {
+		return nil, err
+	}
+	return cb.BuildE()
+}
+
+// Builder returns a CacheBuilder configured by cfg,
+// to which functions such as the LoaderFunc can be added.
+func (cfg CacheConfig) Builder() (*CacheBuilder, error) {
+	if cfg.Size <= 0 {
+		return nil, &ConfigError{Reason: "size must be positive"}
+	}
+	cb := New(cfg.Size)
+	if cfg.Type != "" {
+		cb.EvictType(cfg.Type)
+	}
+	if cfg.Expiration != 0 {
+		cb.Expiration(time.Duration(cfg.Expiration))
+	}
+	if cfg.ExpireAfterAccess != 0 {
+		cb.ExpireAfterAccess(time.Duration(cfg.ExpireAfterAccess))
+	}
+	if cfg.RefreshAfterWrite != 0 {
+		cb.RefreshAfterWrite(time.Duration(cfg.RefreshAfterWrite))
+	}
+	if cfg.StaleIfError != 0 {
+		cb.StaleIfError(time.Duration(cfg.StaleIfError))
+	}
+	if cfg.BreakerThreshold != 0 {
+		cb.LoaderBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown))
+	}
+	if cfg.SlowLoadThreshold != 0 {
+		cb.SlowLoadThreshold(time.Duration(cfg.SlowLoadThreshold))
+	}
+	if cfg.SnapshotInterval != 0 {
+		cb.SnapshotInterval(time.Duration(cfg.SnapshotInterval))
+	}
+	if cfg.StatsWindow != 0 || cfg.StatsWindowBuckets != 0 {
+		cb.WindowedStats(time.Duration(cfg.StatsWindow), cfg.StatsWindowBuckets)
+	}
+	if cfg.MemoryLimit != 0 {
+		cb.MemoryLimit(cfg.MemoryLimit)
+	}
+	if cfg.MemoryCheckInterval != 0 {
+		cb.MemoryCheckInterval(time.Duration(cfg.MemoryCheckInterval))
+	}
+	if cfg.ScoreDecay != 0 {
+		cb.ScoreDecay(time.Duration(cfg.ScoreDecay))
+	}
+	cb.ExpirationJitter(cfg.ExpirationJitter).
+		XFetch(cfg.XFetch).
+		MaxConcurrentLoads(cfg.MaxConcurrentLoads).
+		LoadCoalescingWindow(time.Duration(cfg.LoadCoalescingWindow)).
+		AccessBoost(cfg.AccessBoost).
+		MaxEntries(cfg.MaxEntries)
+	return cb, nil
+}
@@ -0,0 +1,89 @@
+package gcache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	var cfg CacheConfig
+	err := json.Unmarshal([]byte(`{
+		"type": "lru",
+		"size": 2,
+		"expiration": "20ms",
+		"max_concurrent_loads": 4
+	}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Expiration != Duration(20*time.Millisecond) {
+		t.Errorf("unexpected expiration %v", cfg.Expiration)
+	}
+
+	cache, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.(*LRUCache); !ok {
+		t.Errorf("unexpected cache type %T", cache)
+	}
+	for i := 0; i < 3; i++ {
+		cache.Set(i, i)
+	}
+	if l := cache.Len(); l != 2 {
+		t.Errorf("unexpected length %v", l)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := cache.Get(2); err != KeyNotFoundError {
+		t.Errorf("entries should expire, got %v", err)
+	}
+}
+
+func TestNewFromConfigInvalid(t *testing.T) {
+	for _, cfg := range []CacheConfig{
+		{},
+		{Type: "unknown", Size: 8},
+		{Type: TYPE_SCORE, Size: 8},
+		{Size: 8, ExpirationJitter: 0.1},
+	} {
+		if _, err := NewFromConfig(cfg); err == nil {
+			t.Errorf("%+v should be invalid", cfg)
+		}
+	}
+}
+
+func TestCacheConfigBuilder(t *testing.T) {
+	cfg := CacheConfig{Type: TYPE_SCORE, Size: 8, MaxEntries: 2}
+	cb, err := cfg.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := cb.
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(_ interface{}) int { return 1 }).
+		BuildE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		cache.Set(i, i)
+	}
+	if l := cache.Len(); l != 2 {
+		t.Errorf("unexpected length %v", l)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	b, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil || string(b) != `"1m30s"` {
+		t.Errorf("unexpected encoding %s, %v", b, err)
+	}
+	var d Duration
+	if err := json.Unmarshal([]byte(`1000`), &d); err != nil || d != 1000 {
+		t.Errorf("unexpected decoding %v, %v", d, err)
+	}
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error("invalid durations should fail to decode")
+	}
+}
@@ -0,0 +1,40 @@
+package gcache
+
+import "time"
+
+// workKey keys the calls of Do whose results are not cached,
+// so that they are not mistaken for the loads of the same key.
+type workKey struct {
+	key interface{}
+}
+
+// callFunc calls the function given to Do for key, turning a panic into a LoaderPanicError
+// so that it cannot take down the caller or leave the load group waiting forever.
+func (c *baseCache) callFunc(key interface{}, fn func() (interface{}, error)) (v interface{}, err error) {
+	if c.isClosed() {
+		return nil, ClosedError
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, &LoaderPanicError{Key: key, Value: r}
+		}
+	}()
+	return fn()
+}
+
+// doShared implements Do for results which are not cached.
+func (c *baseCache) doShared(key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	return c.loadGroup.share(workKey{key}, func() (interface{}, error) {
+		return c.callFunc(key, fn)
+	})
+}
+
+// doCached implements Do for results which are cached: it returns the item of key
+// if it is cached, or else stores the result of fn with cb, sharing the loads of key.
+func (c *baseCache) doCached(key interface{}, fn func() (interface{}, error), cb loadedFunc) (interface{}, error) {
+	item, _, err := c.loadGroup.Do(key, c.loadFuncWith(key, cb, func(key interface{}) (interface{}, *time.Duration, error) {
+		v, err := c.callFunc(key, fn)
+		return v, nil, err
+	}), true)
+	return item, err
+}
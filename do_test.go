@@ -0,0 +1,73 @@
+package gcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheDo(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.Build()
+		for _, cacheResult := range []bool{false, true} {
+			var calls int32
+			release := make(chan struct{})
+			fn := func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "built", nil
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if v, err := cache.Do("index", fn, cacheResult); v != "built" || err != nil {
+						t.Errorf("%T: Do() = %v, %v", cache, v, err)
+					}
+				}()
+			}
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			if calls != 1 {
+				t.Errorf("%T: fn was called %d times, want 1", cache, calls)
+			}
+			if cache.Has("index") != cacheResult {
+				t.Errorf("%T: Has() = %v after Do with cacheResult %v", cache, !cacheResult, cacheResult)
+			}
+		}
+		if v, err := cache.Do("index", func() (interface{}, error) { return "rebuilt", nil }, true); v != "built" || err != nil {
+			t.Errorf("%T: Do() = %v, %v, want the cached value", cache, v, err)
+		}
+		if v, err := cache.Do("index", func() (interface{}, error) { return "rebuilt", nil }, false); v != "rebuilt" || err != nil {
+			t.Errorf("%T: Do() = %v, %v, want the result of fn", cache, v, err)
+		}
+		if _, err := cache.Do("panic", func() (interface{}, error) { panic("boom") }, true); err == nil {
+			t.Errorf("%T: Do() did not report a panic", cache)
+		} else if _, ok := err.(*LoaderPanicError); !ok {
+			t.Errorf("%T: Do() = %v, want a LoaderPanicError", cache, err)
+		}
+	}
+}
+
+func TestCacheDoSharesLoads(t *testing.T) {
+	release := make(chan struct{})
+	cache := New(8).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		<-release
+		return "loaded", nil
+	}).Build()
+	go cache.Get("a")
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan interface{})
+	go func() {
+		v, _ := cache.Do("a", func() (interface{}, error) { return "done", nil }, true)
+		done <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if v := <-done; v != "loaded" {
+		t.Errorf("Do() = %v, want the value of the load in-flight", v)
+	}
+}
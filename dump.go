@@ -0,0 +1,170 @@
+package gcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultDumpLimit is the number of entries Dump lists unless DumpLimit is set.
+const DefaultDumpLimit = 1000
+
+// DumpFormat selects how Dump writes the entries.
+type DumpFormat int
+
+const (
+	// DumpText writes a table aligned for reading in a terminal.
+	DumpText DumpFormat = iota
+	// DumpJSON writes an object with the number of entries and an array of
+	// the entries listed, whose keys and values are formatted with %v.
+	DumpJSON
+)
+
+// Set the number of entries Dump lists, DefaultDumpLimit if n is 0.
+func (cb *CacheBuilder) DumpLimit(n int) *CacheBuilder {
+	cb.dumpLimit = n
+	return cb
+}
+
+// Dumper is implemented by the caches which can list their entries with their
+// metadata for debugging. All the cache types are Dumpers.
+type Dumper interface {
+	// Dump writes the entries with their age, hits and time to live to w in
+	// format. At most DumpLimit entries are listed, in the order of their keys.
+	// Expired entries which were not removed yet are listed too.
+	Dump(w io.Writer, format DumpFormat) error
+}
+
+// dumpEntry is an entry of the cache along with the metadata Dump lists.
+type dumpEntry struct {
+	key     interface{}
+	value   interface{}
+	age     time.Duration
+	ttl     *time.Duration // nil if the entry does not expire
+	expired bool
+	pinned  bool
+	hits    uint64
+	scored  bool // whether score and weight are set
+	score   int
+	weight  int
+}
+
+type dumpJSONEntry struct {
+	Key     string  `json:"key"`
+	Value   string  `json:"value"`
+	Age     string  `json:"age"`
+	TTL     *string `json:"ttl,omitempty"`
+	Expired bool    `json:"expired,omitempty"`
+	Pinned  bool    `json:"pinned,omitempty"`
+	Hits    uint64  `json:"hits"`
+	Score   *int    `json:"score,omitempty"`
+	Weight  *int    `json:"weight,omitempty"`
+}
+
+type dumpListing struct {
+	Total   int             `json:"total"`
+	Entries []dumpJSONEntry `json:"entries"`
+}
+
+// newDumpEntry returns the dumpEntry of key, whose stored value is decoded,
+// or replaced with the error decoding it (not thread safe).
+func (c *baseCache) newDumpEntry(key, value interface{}, created time.Time, expirations ...*time.Time) dumpEntry {
+	now := c.clock.Now()
+	e := dumpEntry{key: key, age: now.Sub(created), pinned: c.isPinned(key)}
+	if v, err := c.decode(key, value); err != nil {
+		e.value = err
+	} else {
+		e.value = v
+	}
+	for _, exp := range expirations {
+		if exp == nil {
+			continue
+		}
+		if d := exp.Sub(now); e.ttl == nil || d < *e.ttl {
+			e.ttl = &d
+		}
+	}
+	e.expired = e.ttl != nil && *e.ttl < 0
+	return e
+}
+
+// dump writes the entries of c to w in format, ordered by the text of their keys
+// and capped at the DumpLimit of the cache.
+func dump(c Cache, w io.Writer, format DumpFormat) error {
+	if format != DumpText && format != DumpJSON {
+		return fmt.Errorf("Unknown dump format %d.", format)
+	}
+	entries, limit := c.dumpEntries()
+	texts := make(map[interface{}]string, len(entries))
+	for _, e := range entries {
+		texts[e.key] = fmt.Sprintf("%T:%v", e.key, e.key)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return texts[entries[i].key] < texts[entries[j].key]
+	})
+	total := len(entries)
+	if total > limit {
+		entries = entries[:limit]
+	}
+
+	if format == DumpJSON {
+		return dumpJSON(w, entries, total)
+	}
+	return dumpText(w, entries, total)
+}
+
+func dumpText(w io.Writer, entries []dumpEntry, total int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tAGE\tTTL\tHITS\tSCORE\tWEIGHT\tFLAGS")
+	for i := range entries {
+		e := &entries[i]
+		ttl, score, weight := "-", "-", "-"
+		var flags []string
+		if e.ttl != nil {
+			ttl = e.ttl.String()
+		}
+		if e.scored {
+			score, weight = fmt.Sprint(e.score), fmt.Sprint(e.weight)
+		}
+		if e.expired {
+			flags = append(flags, "expired")
+		}
+		if e.pinned {
+			flags = append(flags, "pinned")
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%s\t%d\t%s\t%s\t%s\n", e.key, e.value, e.age, ttl, e.hits, score, weight, strings.Join(flags, ","))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d of %d entries\n", len(entries), total)
+	return err
+}
+
+func dumpJSON(w io.Writer, entries []dumpEntry, total int) error {
+	d := dumpListing{Total: total, Entries: make([]dumpJSONEntry, len(entries))}
+	for i := range entries {
+		e := &entries[i]
+		je := dumpJSONEntry{
+			Key:     fmt.Sprint(e.key),
+			Value:   fmt.Sprint(e.value),
+			Age:     e.age.String(),
+			Expired: e.expired,
+			Pinned:  e.pinned,
+			Hits:    e.hits,
+		}
+		if e.ttl != nil {
+			ttl := e.ttl.String()
+			je.TTL = &ttl
+		}
+		if e.scored {
+			je.Score, je.Weight = &e.score, &e.weight
+		}
+		d.Entries[i] = je
+	}
+	return json.NewEncoder(w).Encode(d)
+}
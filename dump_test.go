@@ -0,0 +1,85 @@
+package gcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpText(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := New(8).LRU().Clock(clock).Build()
+	cache.Set("a", 1)
+	cache.Touch("a", time.Minute)
+	clock.Advance(time.Second)
+	cache.Set("b", 2)
+	cache.(Pinner).Pin("b")
+
+	var buf bytes.Buffer
+	if err := cache.(Dumper).Dump(&buf, DumpText); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Dump() wrote %q", buf.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "a" || f[1] != "1" || f[2] != "1s" || f[3] != "59s" {
+		t.Errorf("first entry is %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "b" || f[3] != "-" || f[len(f)-1] != "pinned" {
+		t.Errorf("second entry is %q", lines[2])
+	}
+	if lines[3] != "2 of 2 entries" {
+		t.Errorf("last line is %q", lines[3])
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	testCaches := append(expiringCacheBuilders(8),
+		New(8).SCORE().
+			ScoringFunc(func(_ interface{}) int { return 3 }).
+			WeightingFunc(func(_ interface{}) int { return 1 }))
+	for _, builder := range testCaches {
+		cache := builder.DumpLimit(2).Build()
+		for i := 0; i < 5; i++ {
+			cache.Set(i, i*10)
+		}
+		var buf bytes.Buffer
+		if err := cache.(Dumper).Dump(&buf, DumpJSON); err != nil {
+			t.Fatalf("%T: %v", cache, err)
+		}
+		var listing dumpListing
+		if err := json.Unmarshal(buf.Bytes(), &listing); err != nil {
+			t.Fatalf("%T: %v", cache, err)
+		}
+		if listing.Total != 5 || len(listing.Entries) != 2 {
+			t.Fatalf("%T: Dump() listed %d of %d entries", cache, len(listing.Entries), listing.Total)
+		}
+		if e := listing.Entries[1]; e.Key != "1" || e.Value != "10" {
+			t.Errorf("%T: second entry is %+v", cache, e)
+		}
+		if _, ok := cache.(*ScoreCache); ok && (listing.Entries[0].Score == nil || *listing.Entries[0].Score != 3) {
+			t.Errorf("%T: first entry is %+v", cache, listing.Entries[0])
+		}
+	}
+}
+
+func TestDumpNamespace(t *testing.T) {
+	cache := New(8).LRU().Build()
+	cache.Set("other", 0)
+	ns := cache.Namespace("ns")
+	ns.Set("a", 1)
+
+	var buf bytes.Buffer
+	if err := ns.Dump(&buf, DumpText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "1 of 1 entries\n") || strings.Contains(buf.String(), "other") {
+		t.Errorf("Dump() wrote %q", buf.String())
+	}
+	if err := cache.(Dumper).Dump(&buf, DumpFormat(-1)); err == nil {
+		t.Errorf("Dump() accepted an unknown format")
+	}
+}
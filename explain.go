@@ -0,0 +1,41 @@
+package gcache
+
+// Explainer is implemented by the caches which can explain where an entry
+// stands in their eviction order. All the cache types are Explainers.
+type Explainer interface {
+	Explain(key interface{}) EvictionExplanation
+}
+
+// EvictionExplanation describes where an entry stands in the eviction order of its cache.
+// It is meant for debugging policy behavior in production.
+type EvictionExplanation struct {
+	Key interface{}
+	// Present reports whether the key is in the cache.
+	Present bool
+	// Expired reports whether the entry has expired and will be dropped on its next access.
+	Expired bool
+	// Pinned reports whether the entry is exempt from eviction.
+	Pinned bool
+	// Class is the eviction class of the entry. Lower classes are evicted first,
+	// Rank only orders the entry within the policy.
+	Class Priority
+	// Rank is the number of entries which would be evicted before this one,
+	// or -1 if the policy does not define an order.
+	Rank int
+	// NextVictim is the key which would be evicted next, if any.
+	NextVictim interface{}
+	// Recency is the position of the entry counted from the most recently used one (LRU and ARC).
+	Recency int
+	// Frequency is the number of recorded accesses (LFU).
+	Frequency uint
+	// Score, Weight and Priority of the entry (ScoreCache).
+	Score    int
+	Weight   int
+	Priority float64
+	// Reason states why the entry would or would not be evicted next.
+	Reason string
+}
+
+func notPresentExplanation(key interface{}) EvictionExplanation {
+	return EvictionExplanation{Key: key, Rank: -1, Reason: "key is not in the cache"}
+}
@@ -0,0 +1,108 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestExplainNotPresent(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		ex := builder.Build().(Explainer).Explain("missing")
+		if ex.Present || ex.Rank != -1 || ex.Reason == "" {
+			t.Errorf("unexpected explanation %+v", ex)
+		}
+	}
+}
+
+func TestLRUExplain(t *testing.T) {
+	cache := New(8).LRU().Build()
+	for i := 0; i < 4; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(0)
+
+	ex := cache.(Explainer).Explain(1)
+	if !ex.Present || ex.Rank != 0 || ex.NextVictim != 1 || ex.Recency != 3 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(0)
+	if ex.Rank != 3 || ex.NextVictim != 1 || ex.Recency != 0 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+}
+
+func TestLFUExplain(t *testing.T) {
+	cache := New(8).LFU().Build()
+	for i := 0; i < 4; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(0)
+	cache.Get(0)
+	cache.Get(1)
+
+	ex := cache.(Explainer).Explain(0)
+	if ex.Rank != 3 || ex.Frequency != 2 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(1)
+	if ex.Rank != 2 || ex.Frequency != 1 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(2)
+	if ex.Rank != 0 || (ex.NextVictim != 2 && ex.NextVictim != 3) {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+}
+
+func TestARCExplain(t *testing.T) {
+	cache := New(8).ARC().Build()
+	for i := 0; i < 4; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(0)
+
+	ex := cache.(Explainer).Explain(1)
+	if ex.Rank != 0 || ex.NextVictim != 1 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(0)
+	if ex.Rank != 3 || ex.Recency != 0 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+}
+
+func TestScoreCacheExplain(t *testing.T) {
+	cache := New(8).
+		SCORE().
+		ScoringFunc(func(v interface{}) int { return v.(int) }).
+		WeightingFunc(func(_ interface{}) int { return 2 }).
+		Build()
+	for i := 1; i <= 4; i++ {
+		cache.Set(i, i)
+	}
+
+	ex := cache.(Explainer).Explain(1)
+	if ex.Rank != 0 || ex.NextVictim != 1 || ex.Score != 1 || ex.Weight != 2 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(3)
+	if ex.Rank != 2 || ex.NextVictim != 1 || ex.Score != 3 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	cache.(Pinner).Pin(1)
+	ex = cache.(Explainer).Explain(2)
+	if ex.Rank != 0 || ex.NextVictim != 2 {
+		t.Errorf("a pinned entry was reported as the next victim: %+v", ex)
+	}
+	ex = cache.(Explainer).Explain(3)
+	if ex.Rank != 1 || ex.NextVictim != 2 {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+	cache.SetWithPriority(4, 4, LowPriority)
+	ex = cache.(Explainer).Explain(3)
+	if ex.Rank != 2 || ex.NextVictim != 4 {
+		t.Errorf("the lower class was not evicted first: %+v", ex)
+	}
+	if ex = cache.(Explainer).Explain(4); ex.Rank != 0 || ex.Class != LowPriority {
+		t.Errorf("unexpected explanation %+v", ex)
+	}
+}
@@ -0,0 +1,85 @@
+package gcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SnapshotEntry is an entry of the cache as Export writes it and Import reads it.
+type SnapshotEntry struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+	// Expiration is when the entry expires, if it does.
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// Score and Weight are those of a ScoreCache entry. Import computes them
+	// again with the ScoringFunc and WeightingFunc of the importing cache.
+	Score  int `json:"score,omitempty"`
+	Weight int `json:"weight,omitempty"`
+}
+
+// SnapshotCodec encodes and decodes the entries written by Export, e.g. as JSON,
+// msgpack or protobuf, so that snapshots can be read by other languages and tools.
+type SnapshotCodec interface {
+	EncodeSnapshot(w io.Writer, entries []SnapshotEntry) error
+	DecodeSnapshot(r io.Reader) ([]SnapshotEntry, error)
+}
+
+// JSONSnapshotCodec encodes snapshots as a JSON array of entries.
+// Keys and values go through encoding/json, so numbers are imported as float64
+// and structs as maps unless the codec is wrapped to restore their types.
+type JSONSnapshotCodec struct{}
+
+func (JSONSnapshotCodec) EncodeSnapshot(w io.Writer, entries []SnapshotEntry) error {
+	return json.NewEncoder(w).Encode(entries)
+}
+
+func (JSONSnapshotCodec) DecodeSnapshot(r io.Reader) ([]SnapshotEntry, error) {
+	var entries []SnapshotEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// exportSnapshot writes the unexpired entries of c to w with codec.
+// The entries are copied under the lock and encoded once it is released,
+// ordered by the text of their keys so that unchanged caches export the same snapshot.
+func exportSnapshot(c Cache, w io.Writer, codec SnapshotCodec) error {
+	entries := c.entries()
+	texts := make(map[interface{}]string, len(entries))
+	for _, e := range entries {
+		texts[e.Key] = fmt.Sprintf("%T:%v", e.Key, e.Key)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return texts[entries[i].Key] < texts[entries[j].Key]
+	})
+	return codec.EncodeSnapshot(w, entries)
+}
+
+// importSnapshot reads entries written by Export from r with codec and sets them in c.
+func importSnapshot(c Cache, r io.Reader, codec SnapshotCodec) error {
+	entries, err := codec.DecodeSnapshot(r)
+	if err != nil {
+		return err
+	}
+	c.importEntries(entries)
+	return nil
+}
+
+// restore sets the entries which have not expired yet with set,
+// and restores their expiration with touch.
+func (c *baseCache) restore(entries []SnapshotEntry, set func(key, value interface{}), touch func(key interface{}, ttl ...time.Duration) bool) {
+	now := c.clock.Now()
+	for _, e := range entries {
+		if e.Expiration != nil && !e.Expiration.After(now) {
+			continue
+		}
+		set(e.Key, e.Value)
+		if e.Expiration != nil {
+			touch(e.Key, e.Expiration.Sub(now))
+		}
+	}
+}
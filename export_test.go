@@ -0,0 +1,89 @@
+package gcache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		clock := NewFakeClock(time.Now())
+		src := builder.Clock(clock).Build()
+		src.Set("a", "1")
+		src.Set("b", "2")
+
+		var buf bytes.Buffer
+		if err := src.Export(&buf, JSONSnapshotCodec{}); err != nil {
+			t.Fatalf("%T: Export() = %v", src, err)
+		}
+		dst := builder.Clock(clock).Build()
+		if err := dst.Import(&buf, JSONSnapshotCodec{}); err != nil {
+			t.Fatalf("%T: Import() = %v", dst, err)
+		}
+		for k, want := range map[string]string{"a": "1", "b": "2"} {
+			if v, err := dst.Get(k); err != nil || v != want {
+				t.Errorf("%T: Get(%v) = %v, %v; want %v", dst, k, v, err, want)
+			}
+		}
+	}
+}
+
+func TestExportImportExpiration(t *testing.T) {
+	for _, builder := range expiringCacheBuilders(8) {
+		clock := NewFakeClock(time.Now())
+		src := builder.Clock(clock).Build()
+		src.Set("short", 1)
+		src.Touch("short", time.Second)
+		src.Set("long", 2)
+		src.Touch("long", time.Minute)
+		src.Set("forever", 3)
+
+		var buf bytes.Buffer
+		if err := src.Export(&buf, JSONSnapshotCodec{}); err != nil {
+			t.Fatalf("%T: Export() = %v", src, err)
+		}
+		clock.Advance(2 * time.Second)
+
+		dst := builder.Clock(clock).Build()
+		if err := dst.Import(&buf, JSONSnapshotCodec{}); err != nil {
+			t.Fatalf("%T: Import() = %v", dst, err)
+		}
+		if dst.Has("short") {
+			t.Errorf("%T: an entry which expired after Export was imported", dst)
+		}
+		if !dst.Has("long") || !dst.Has("forever") {
+			t.Errorf("%T: unexpected keys %v", dst, dst.KeysIncludingExpired())
+		}
+
+		clock.Advance(time.Minute)
+		if dst.Has("long") {
+			t.Errorf("%T: the expiration of an imported entry was not restored", dst)
+		}
+		if !dst.Has("forever") {
+			t.Errorf("%T: an entry without expiration expired", dst)
+		}
+	}
+}
+
+func TestNamespaceExport(t *testing.T) {
+	cache := New(8).LRU().Build()
+	cache.Namespace("users").Set("1", "alice")
+	cache.Set("1", "shared")
+
+	var buf bytes.Buffer
+	if err := cache.Namespace("users").Export(&buf, JSONSnapshotCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	dst := New(8).LRU().Build()
+	if err := dst.Namespace("admins").Import(&buf, JSONSnapshotCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if l := dst.Len(); l != 1 {
+		t.Errorf("Len() = %v; want 1", l)
+	}
+	if v, err := dst.Namespace("admins").Get("1"); err != nil || v != "alice" {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+}
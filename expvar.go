@@ -0,0 +1,20 @@
+package gcache
+
+import (
+	"expvar"
+)
+
+// PublishExpvar exposes the hits, misses, hit rate, length and evictions of c
+// under name in expvar, e.g. at /debug/vars.
+// Like expvar.Publish, it panics if name is already published.
+func PublishExpvar(name string, c Cache) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"hits":      c.HitCount(),
+			"misses":    c.MissCount(),
+			"hit_rate":  c.HitRate(),
+			"length":    c.Len(),
+			"evictions": c.EvictionCount(),
+		}
+	}))
+}
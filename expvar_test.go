@@ -0,0 +1,40 @@
+package gcache
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// expvarRuns numbers the runs of the test, whose names cannot be published twice.
+var expvarRuns int32
+
+func TestPublishExpvar(t *testing.T) {
+	cache := New(2).LRU().Build()
+	name := fmt.Sprintf("gcache-expvar-test-%d", atomic.AddInt32(&expvarRuns, 1))
+	PublishExpvar(name, cache)
+	for i := 0; i < 3; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(2)
+	cache.Get(0)
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"hits":      1.0,
+		"misses":    1.0,
+		"hit_rate":  0.5,
+		"length":    2.0,
+		"evictions": 1.0,
+	}
+	for k, v := range expected {
+		if vars[k] != v {
+			t.Errorf("%v = %v, expected %v", k, vars[k], v)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/britt/gcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client calls a cache served by a Server. Its methods mirror those of the
+// gcache Cache interface which make sense remotely, with string keys and []byte values,
+// and Cache adapts it to the Cache interface.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a Client calling the Server at the other end of cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/"+method, req, resp, grpc.CallContentSubtype(codecName))
+	switch status.Code(err) {
+	case codes.NotFound:
+		return gcache.KeyNotFoundError
+	case codes.Unavailable:
+		if status.Convert(err).Message() == gcache.ClosedError.Error() {
+			return gcache.ClosedError
+		}
+	}
+	return err
+}
+
+// Get returns the value of key, loading it on the server if needed.
+// Returns gcache.KeyNotFoundError if there is none.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	var resp GetResponse
+	if err := c.invoke(ctx, "Get", &GetRequest{Key: key}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// Set sets the value of key, which expires after ttl if it is positive.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.invoke(ctx, "Set", &SetRequest{Key: key, Value: value, TTL: int64(ttl)}, &SetResponse{})
+}
+
+// Remove removes key and reports whether it was cached.
+func (c *Client) Remove(ctx context.Context, key string) (bool, error) {
+	var resp RemoveResponse
+	err := c.invoke(ctx, "Remove", &RemoveRequest{Key: key}, &resp)
+	return resp.Removed, err
+}
+
+// Stats returns the statistics and length of the cache.
+func (c *Client) Stats(ctx context.Context) (*StatsResponse, error) {
+	var resp StatsResponse
+	if err := c.invoke(ctx, "Stats", &StatsRequest{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Keys returns the string keys of the unexpired entries.
+func (c *Client) Keys(ctx context.Context) ([]string, error) {
+	var resp KeysResponse
+	if err := c.invoke(ctx, "Keys", &KeysRequest{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Cache returns a gcache Cache of the remote cache, whose calls time out after timeout
+// if it is positive. Keys must be strings. Values are sent like those of a Server:
+// []byte as is and strings as their bytes, while values received are []byte.
+// GetIFPresent gets the value like Get, which may load it on the server.
+// See gcache.Adapt for the behavior of the other methods.
+func (c *Client) Cache(timeout time.Duration) gcache.Cache {
+	return gcache.Adapt(&backend{client: c, timeout: timeout})
+}
+
+// backend is the gcache.Backend of the remote cache of a Client.
+type backend struct {
+	client  *Client
+	timeout time.Duration
+}
+
+func (b *backend) context() (context.Context, context.CancelFunc) {
+	if b.timeout > 0 {
+		return context.WithTimeout(context.Background(), b.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func stringKey(key interface{}) (string, error) {
+	if k, ok := key.(string); ok {
+		return k, nil
+	}
+	return "", fmt.Errorf("grpcserver: key of type %T is not a string", key)
+}
+
+func (b *backend) Get(key interface{}) (interface{}, error) {
+	k, err := stringKey(key)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := b.context()
+	defer cancel()
+	v, err := b.client.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (b *backend) GetIFPresent(key interface{}) (interface{}, error) {
+	return b.Get(key)
+}
+
+func (b *backend) Set(key, value interface{}, ttl time.Duration) error {
+	k, err := stringKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := encodeBytes(value)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := b.context()
+	defer cancel()
+	return b.client.Set(ctx, k, data, ttl)
+}
+
+func (b *backend) Remove(key interface{}) (bool, error) {
+	k, err := stringKey(key)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := b.context()
+	defer cancel()
+	return b.client.Remove(ctx, k)
+}
+
+func (b *backend) Keys() ([]interface{}, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	keys, err := b.client.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ks := make([]interface{}, len(keys))
+	for i, key := range keys {
+		ks[i] = key
+	}
+	return ks, nil
+}
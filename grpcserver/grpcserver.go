@@ -0,0 +1,188 @@
+// Package grpcserver exposes a gcache Cache over a small gRPC service, with
+// Get, Set, Remove, Stats and Keys methods, and provides a Client for it,
+// for sidecar and remote cache deployments.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/britt/gcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "gcache.Cache"
+
+type config struct {
+	encodeValue func(interface{}) ([]byte, error)
+	decodeValue func([]byte) (interface{}, error)
+}
+
+// Option configures a Server.
+type Option func(*config)
+
+// WithValueCodec sets how the values of the cache are converted to and from the bytes
+// sent over the wire. By default []byte values are sent as is, strings as their bytes,
+// other values cannot be sent, and values received are stored as []byte.
+func WithValueCodec(encode func(value interface{}) ([]byte, error), decode func([]byte) (interface{}, error)) Option {
+	return func(cfg *config) {
+		cfg.encodeValue = encode
+		cfg.decodeValue = decode
+	}
+}
+
+func encodeBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("grpcserver: cannot send a value of type %T", value)
+	}
+}
+
+func decodeBytes(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+// Server serves a cache over gRPC. Keys are strings.
+type Server struct {
+	cache gcache.Cache
+	cfg   *config
+}
+
+// NewServer returns a Server of c.
+func NewServer(c gcache.Cache, opts ...Option) *Server {
+	cfg := &config{encodeValue: encodeBytes, decodeValue: decodeBytes}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Server{cache: c, cfg: cfg}
+}
+
+// Register registers s with a gRPC server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// statusOf converts the errors of the cache to gRPC status errors.
+func statusOf(err error) error {
+	switch err {
+	case gcache.KeyNotFoundError:
+		return status.Error(codes.NotFound, err.Error())
+	case gcache.ClosedError:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (s *Server) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	v, err := s.cache.Get(req.Key)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	data, err := s.cfg.encodeValue(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &GetResponse{Value: data}, nil
+}
+
+func (s *Server) Set(_ context.Context, req *SetRequest) (*SetResponse, error) {
+	if req.TTL < 0 {
+		return nil, status.Error(codes.InvalidArgument, "grpcserver: negative TTL")
+	}
+	v, err := s.cfg.decodeValue(req.Value)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.TTL > 0 {
+		s.cache.SetWithExpire(req.Key, v, time.Duration(req.TTL))
+	} else {
+		s.cache.Set(req.Key, v)
+	}
+	return &SetResponse{}, nil
+}
+
+func (s *Server) Remove(_ context.Context, req *RemoveRequest) (*RemoveResponse, error) {
+	return &RemoveResponse{Removed: s.cache.Remove(req.Key)}, nil
+}
+
+func (s *Server) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return &StatsResponse{
+		Hits:      s.cache.HitCount(),
+		Misses:    s.cache.MissCount(),
+		Evictions: s.cache.EvictionCount(),
+		HitRate:   s.cache.HitRate(),
+		Len:       s.cache.Len(),
+	}, nil
+}
+
+func (s *Server) Keys(context.Context, *KeysRequest) (*KeysResponse, error) {
+	keys := s.cache.Keys()
+	resp := &KeysResponse{Keys: make([]string, 0, len(keys))}
+	for _, key := range keys {
+		if k, ok := key.(string); ok {
+			resp.Keys = append(resp.Keys, k)
+		}
+	}
+	return resp, nil
+}
+
+// CacheServer is the interface of the service, which Server implements.
+type CacheServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	Keys(context.Context, *KeysRequest) (*KeysResponse, error)
+}
+
+// unary returns the description of a unary method of the service,
+// whose requests are allocated by newReq and served by call.
+func unary(method string, newReq func() interface{}, call func(CacheServer, context.Context, interface{}) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(CacheServer), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(CacheServer), ctx, req)
+			})
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*CacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Get", func() interface{} { return new(GetRequest) }, func(s CacheServer, ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Get(ctx, req.(*GetRequest))
+		}),
+		unary("Set", func() interface{} { return new(SetRequest) }, func(s CacheServer, ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Set(ctx, req.(*SetRequest))
+		}),
+		unary("Remove", func() interface{} { return new(RemoveRequest) }, func(s CacheServer, ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Remove(ctx, req.(*RemoveRequest))
+		}),
+		unary("Stats", func() interface{} { return new(StatsRequest) }, func(s CacheServer, ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Stats(ctx, req.(*StatsRequest))
+		}),
+		unary("Keys", func() interface{} { return new(KeysRequest) }, func(s CacheServer, ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Keys(ctx, req.(*KeysRequest))
+		}),
+	},
+	Metadata: "gcache.proto",
+}
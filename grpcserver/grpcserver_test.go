@@ -0,0 +1,119 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/britt/gcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// loopback is a connection which serves the calls of a Client with the
+// handlers of the service, encoding the messages as they would be on the wire.
+type loopback struct {
+	grpc.ClientConnInterface
+	srv CacheServer
+}
+
+func (l loopback) Invoke(ctx context.Context, method string, args, reply interface{}, _ ...grpc.CallOption) error {
+	data, err := jsonCodec{}.Marshal(args)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(method, "/"+serviceName+"/")
+	for _, m := range serviceDesc.Methods {
+		if m.MethodName != name {
+			continue
+		}
+		resp, err := m.Handler(l.srv, ctx, func(req interface{}) error {
+			return jsonCodec{}.Unmarshal(data, req)
+		}, nil)
+		if err != nil {
+			return err
+		}
+		data, err := jsonCodec{}.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		return jsonCodec{}.Unmarshal(data, reply)
+	}
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	cache := gcache.New(10).LRU().Build()
+	client := NewClient(loopback{srv: NewServer(cache)})
+
+	if err := client.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	cache.Set("b", "2")
+	if v, err := client.Get(ctx, "a"); err != nil || string(v) != "1" {
+		t.Errorf("Get() = %s, %v", v, err)
+	}
+	if v, err := client.Get(ctx, "b"); err != nil || string(v) != "2" {
+		t.Errorf("Get() = %s, %v", v, err)
+	}
+	if keys, err := client.Keys(ctx); err != nil || len(keys) != 2 {
+		t.Errorf("Keys() = %v, %v", keys, err)
+	}
+	if removed, err := client.Remove(ctx, "a"); err != nil || !removed {
+		t.Errorf("Remove() = %v, %v", removed, err)
+	}
+	if stats, err := client.Stats(ctx); err != nil || stats.Hits != 2 || stats.Len != 1 {
+		t.Errorf("Stats() = %+v, %v", stats, err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	cache := gcache.New(10).LRU().Build()
+	NewServer(cache).Register(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := NewClient(cc)
+	ctx := context.Background()
+
+	if err := client.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := client.Get(ctx, "a"); err != nil || string(v) != "1" {
+		t.Errorf("Get() = %s, %v", v, err)
+	}
+	if _, err := client.Get(ctx, "missing"); err != gcache.KeyNotFoundError {
+		t.Errorf("Get() of a missing key = %v", err)
+	}
+
+	c := client.Cache(time.Second)
+	c.Set("b", "2")
+	if v, err := c.Get("b"); err != nil || string(v.([]byte)) != "2" {
+		t.Errorf("Cache().Get() = %v, %v", v, err)
+	}
+	if v, loaded := c.GetOrSet("c", []byte("3")); loaded || string(v.([]byte)) != "3" {
+		t.Errorf("Cache().GetOrSet() = %v, %v", v, loaded)
+	}
+	if n := c.Len(); n != 3 {
+		t.Errorf("Cache().Len() = %v", n)
+	}
+	if !c.Remove("a") || cache.Has("a") {
+		t.Error("Cache().Remove() did not remove the key on the server")
+	}
+	if _, err := c.Get(1); err == nil {
+		t.Error("Cache().Get() accepted a key which is not a string")
+	}
+}
@@ -0,0 +1,73 @@
+package grpcserver
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// The messages of the service are encoded as JSON, with the "gcache-json" content
+// subtype, so that clients in other languages need no generated code.
+
+type GetRequest struct {
+	Key string `json:"key"`
+}
+
+type GetResponse struct {
+	Value []byte `json:"value"`
+}
+
+type SetRequest struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	// TTL is the time to live of the entry in nanoseconds, the default expiration of the cache if zero.
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type SetResponse struct{}
+
+type RemoveRequest struct {
+	Key string `json:"key"`
+}
+
+type RemoveResponse struct {
+	Removed bool `json:"removed"`
+}
+
+type StatsRequest struct{}
+
+type StatsResponse struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+	Len       int     `json:"len"`
+}
+
+type KeysRequest struct{}
+
+type KeysResponse struct {
+	Keys []string `json:"keys"`
+}
+
+// codecName is the content subtype of the messages. It is specific to the package
+// so that registering the codec does not replace the "json" codec of other services.
+const codecName = "gcache-json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,151 @@
+package gcache
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// HashFunc hashes a key to pick its shard.
+type HashFunc func(key interface{}) uint64
+
+// Hash keys with hash to pick their shard in BuildShards, e.g. for custom key
+// types which DefaultHash would format with fmt. The default is DefaultHash.
+func (cb *CacheBuilder) Hasher(hash HashFunc) *CacheBuilder {
+	cb.hasher = hash
+	return cb
+}
+
+// DefaultHash hashes strings, byte slices, integers and keys implementing
+// Hash64() uint64 without allocating, using xxHash. Keys of other types are
+// hashed by their type and text, as formatted with fmt.
+func DefaultHash(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return xxh64(k)
+	case []byte:
+		return xxh64(k)
+	case int:
+		return xxh64Uint64(uint64(k))
+	case int8:
+		return xxh64Uint64(uint64(k))
+	case int16:
+		return xxh64Uint64(uint64(k))
+	case int32:
+		return xxh64Uint64(uint64(k))
+	case int64:
+		return xxh64Uint64(uint64(k))
+	case uint:
+		return xxh64Uint64(uint64(k))
+	case uint8:
+		return xxh64Uint64(uint64(k))
+	case uint16:
+		return xxh64Uint64(uint64(k))
+	case uint32:
+		return xxh64Uint64(uint64(k))
+	case uint64:
+		return xxh64Uint64(k)
+	case uintptr:
+		return xxh64Uint64(uint64(k))
+	case float64:
+		return xxh64Uint64(math.Float64bits(k))
+	case float32:
+		return xxh64Uint64(math.Float64bits(float64(k)))
+	case bool:
+		if k {
+			return xxh64Uint64(1)
+		}
+		return xxh64Uint64(0)
+	case interface{ Hash64() uint64 }:
+		return k.Hash64()
+	}
+	return xxh64(fmt.Sprintf("%T:%v", key, key))
+}
+
+// The primes of XXH64.
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 returns the XXH64 hash of b with seed 0.
+func xxh64[T string | []byte](b T) uint64 {
+	n := len(b)
+	var h uint64
+	i := 0
+	if n >= 32 {
+		p1 := xxPrime1
+		v1 := p1 + xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := -p1
+		for ; i+32 <= n; i += 32 {
+			v1 = xxRound(v1, le64(b, i))
+			v2 = xxRound(v2, le64(b, i+8))
+			v3 = xxRound(v3, le64(b, i+16))
+			v4 = xxRound(v4, le64(b, i+24))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+	for ; i+8 <= n; i += 8 {
+		h ^= xxRound(0, le64(b, i))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if i+4 <= n {
+		h ^= uint64(le32(b, i)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		i += 4
+	}
+	for ; i < n; i++ {
+		h ^= uint64(b[i]) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	return xxAvalanche(h)
+}
+
+// xxh64Uint64 returns the XXH64 hash of the 8 little endian bytes of v.
+func xxh64Uint64(v uint64) uint64 {
+	h := xxPrime5 + 8
+	h ^= xxRound(0, v)
+	h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	return xxAvalanche(h)
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func xxAvalanche(h uint64) uint64 {
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func le64[T string | []byte](b T, i int) uint64 {
+	return uint64(b[i]) | uint64(b[i+1])<<8 | uint64(b[i+2])<<16 | uint64(b[i+3])<<24 |
+		uint64(b[i+4])<<32 | uint64(b[i+5])<<40 | uint64(b[i+6])<<48 | uint64(b[i+7])<<56
+}
+
+func le32[T string | []byte](b T, i int) uint32 {
+	return uint32(b[i]) | uint32(b[i+1])<<8 | uint32(b[i+2])<<16 | uint32(b[i+3])<<24
+}
@@ -0,0 +1,47 @@
+package gcache
+
+import (
+	"testing"
+)
+
+type hashedKey struct{ id int }
+
+func (k hashedKey) Hash64() uint64 { return uint64(k.id) }
+
+func TestXXH64(t *testing.T) {
+	vectors := map[string]uint64{
+		"":    0xef46db3751d8e999,
+		"a":   0xd24ec4f1a98c6e5b,
+		"abc": 0x44bc2cf5ad770999,
+		"Nobody inspects the spammish repetition": 0xfbcea83c8a378bf1,
+	}
+	for s, want := range vectors {
+		if got := xxh64(s); got != want {
+			t.Errorf("xxh64(%q) = %#x, want %#x", s, got, want)
+		}
+		if got := xxh64([]byte(s)); got != want {
+			t.Errorf("xxh64([]byte(%q)) = %#x, want %#x", s, got, want)
+		}
+	}
+	if xxh64Uint64(42) != xxh64(string([]byte{42, 0, 0, 0, 0, 0, 0, 0})) {
+		t.Errorf("xxh64Uint64 differs from the hash of the bytes of the integer")
+	}
+}
+
+func TestDefaultHash(t *testing.T) {
+	if DefaultHash("key") != DefaultHash([]byte("key")) {
+		t.Errorf("strings and byte slices of the same bytes hash differently")
+	}
+	if DefaultHash(7) != DefaultHash(uint64(7)) || DefaultHash(7) == DefaultHash(8) {
+		t.Errorf("unexpected integer hashes")
+	}
+	if DefaultHash(hashedKey{3}) != 3 {
+		t.Errorf("Hash64 was not used")
+	}
+	if DefaultHash(struct{ A, B int }{1, 2}) == DefaultHash(struct{ A, B int }{2, 1}) {
+		t.Errorf("distinct struct keys hash the same")
+	}
+	if n := testing.AllocsPerRun(100, func() { DefaultHash("a somewhat longer key of more than 32 bytes") }); n != 0 {
+		t.Errorf("DefaultHash allocates %v times for a string", n)
+	}
+}
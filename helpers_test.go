@@ -34,3 +34,23 @@ func testGetCache(t *testing.T, gc Cache, numbers int) {
 		}
 	}
 }
+
+// cacheBuilders returns a builder of each cache type with size.
+// The entries of the SCORE cache all score and weigh 1.
+func cacheBuilders(size int) []*CacheBuilder {
+	return append(expiringCacheBuilders(size),
+		New(size).SCORE().
+			ScoringFunc(func(_ interface{}) int { return 1 }).
+			WeightingFunc(func(_ interface{}) int { return 1 }))
+}
+
+// expiringCacheBuilders returns a builder of each cache type with size
+// whose entries expire, which leaves out SCORE.
+func expiringCacheBuilders(size int) []*CacheBuilder {
+	return []*CacheBuilder{
+		New(size).Simple(),
+		New(size).LRU(),
+		New(size).LFU(),
+		New(size).ARC(),
+	}
+}
@@ -0,0 +1,262 @@
+// Package httpcache memoizes the responses of HTTP handlers in a gcache Cache.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+// Response is a response of a handler as it is cached.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// variants is cached under the key of a request whose response varies on the
+// headers of the request, and lists the keys its variants are cached under.
+type variants struct {
+	headers []string // canonical, sorted
+	keys    map[string]struct{}
+}
+
+// Weight returns the size in bytes of a cached Response, to be used as the
+// WeightingFunc of a ScoreCache so that its size is a budget in bytes.
+func Weight(value interface{}) int {
+	r, ok := value.(*Response)
+	if !ok {
+		return 1
+	}
+	n := len(r.Body)
+	for k, vs := range r.Header {
+		n += len(k)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// DefaultKey is the key of a request: its host and request URI.
+func DefaultKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+// cacheable reports whether the response to r with status and header may be cached.
+// Responses to requests with an Authorization header are only cached if they are
+// explicitly shared with a public, s-maxage or must-revalidate directive (RFC 9111, 3.5).
+func cacheable(r *http.Request, status int, header http.Header) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return false
+	}
+	shared := false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if name, _, ok := strings.Cut(directive, "="); ok {
+			directive = strings.TrimSpace(name)
+		}
+		switch directive {
+		case "no-store", "private", "no-cache":
+			return false
+		case "public", "s-maxage", "must-revalidate":
+			shared = true
+		}
+	}
+	return shared || r.Header.Get("Authorization") == ""
+}
+
+// varyHeaders returns the canonical, sorted names of the Vary header.
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// variantKey returns the key of the variant of the response to r under key.
+func variantKey(key string, headers []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range headers {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// recorder passes a response through to the client while recording it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header // as of WriteHeader
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.header == nil {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.header == nil {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// CacheMiddleware memoizes the responses to GET and HEAD requests in c, under the
+// key returned by keyFn, DefaultKey if nil. Responses expire after ttl if it is positive.
+// Responses with an uncacheable status, Set-Cookie, or a Cache-Control of no-store,
+// no-cache or private are not cached, nor are the responses to requests with an
+// Authorization header unless their Cache-Control is public, s-maxage or must-revalidate.
+// Responses with a Vary header are cached per value of the headers they vary on,
+// and are never cached for "Vary: *".
+func CacheMiddleware(c gcache.Cache, keyFn func(*http.Request) string, ttl time.Duration) func(http.Handler) http.Handler {
+	if keyFn == nil {
+		keyFn = DefaultKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := keyFn(r)
+			if resp, ok := lookup(c, key, r); ok {
+				serve(w, r, resp)
+				return
+			}
+			if r.Method == http.MethodHead {
+				// the body of the response is not written, it cannot be cached
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.header == nil {
+				rec.status, rec.header = http.StatusOK, w.Header().Clone()
+			}
+			if cacheable(r, rec.status, rec.header) {
+				store(c, key, r, &Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}, ttl)
+			}
+		})
+	}
+}
+
+// lookup returns the cached response to r under key.
+func lookup(c gcache.Cache, key string, r *http.Request) (*Response, bool) {
+	v, err := c.GetIFPresent(key)
+	if err != nil {
+		return nil, false
+	}
+	if vs, ok := v.(*variants); ok {
+		if v, err = c.GetIFPresent(variantKey(key, vs.headers, r)); err != nil {
+			return nil, false
+		}
+	}
+	resp, ok := v.(*Response)
+	return resp, ok
+}
+
+// store caches the response to r under key.
+func store(c gcache.Cache, key string, r *http.Request, resp *Response, ttl time.Duration) {
+	if headers := varyHeaders(resp.Header); len(headers) > 0 {
+		vkey := variantKey(key, headers, r)
+		c.Update(key, func(current interface{}, exists bool) (interface{}, error) {
+			vs, ok := current.(*variants)
+			if !ok || strings.Join(vs.headers, ",") != strings.Join(headers, ",") {
+				vs = &variants{headers: headers, keys: make(map[string]struct{})}
+			} else {
+				vs = &variants{headers: headers, keys: copyKeys(vs.keys)}
+			}
+			vs.keys[vkey] = struct{}{}
+			return vs, nil
+		})
+		key = vkey
+	}
+	if ttl > 0 {
+		c.SetWithExpire(key, resp, ttl)
+	} else {
+		c.Set(key, resp)
+	}
+}
+
+func copyKeys(keys map[string]struct{}) map[string]struct{} {
+	m := make(map[string]struct{}, len(keys)+1)
+	for k := range keys {
+		m[k] = struct{}{}
+	}
+	return m
+}
+
+// serve writes a cached response.
+func serve(w http.ResponseWriter, r *http.Request, resp *Response) {
+	h := w.Header()
+	for k, vs := range resp.Header {
+		h[k] = append([]string(nil), vs...)
+	}
+	w.WriteHeader(resp.Status)
+	if r.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
+
+// Invalidate removes the cached responses under key, including all their variants,
+// and reports whether there were any.
+func Invalidate(c gcache.Cache, key string) bool {
+	v, ok := c.RemoveGet(key)
+	if vs, isVariants := v.(*variants); isVariants {
+		keys := make([]interface{}, 0, len(vs.keys))
+		for k := range vs.keys {
+			keys = append(keys, k)
+		}
+		c.RemoveAll(keys...)
+	}
+	return ok
+}
+
+// InvalidateRequest removes the cached responses to requests like r,
+// whose key is computed with keyFn, DefaultKey if nil.
+func InvalidateRequest(c gcache.Cache, keyFn func(*http.Request) string, r *http.Request) bool {
+	if keyFn == nil {
+		keyFn = DefaultKey
+	}
+	return Invalidate(c, keyFn(r))
+}
+
+// InvalidatePrefix removes the cached responses whose key starts with prefix,
+// e.g. all the pages of a host or under a path, and returns how many keys were removed.
+func InvalidatePrefix(c gcache.Cache, prefix string) int {
+	n := 0
+	for _, key := range c.KeysWithPrefix(prefix) {
+		if k, ok := key.(string); ok && Invalidate(c, k) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+func get(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	clock := gcache.NewFakeClock(time.Now())
+	cache := gcache.New(100).LRU().Clock(clock).OrderedKeys().Build()
+	calls := 0
+	h := CacheMiddleware(cache, nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Header().Set("X-Call", fmt.Sprint(calls))
+		fmt.Fprintf(w, "%v %v", r.URL.Path, calls)
+	}))
+
+	first := get(h, "/a")
+	second := get(h, "/a")
+	if calls != 1 || second.Body.String() != "/a 1" || second.Header().Get("X-Call") != "1" || second.Code != first.Code {
+		t.Errorf("the response was not cached: %v calls, %v %q", calls, second.Code, second.Body)
+	}
+	for _, path := range []string{"/private", "/error"} {
+		before := calls
+		get(h, path)
+		get(h, path)
+		if calls != before+2 {
+			t.Errorf("the response of %v was cached", path)
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	if get(h, "/a"); calls != 6 {
+		t.Errorf("the response was served after its ttl")
+	}
+
+	get(h, "/b")
+	if !InvalidateRequest(cache, nil, httptest.NewRequest(http.MethodGet, "/b", nil)) {
+		t.Error("InvalidateRequest found nothing to invalidate")
+	}
+	before := calls
+	if get(h, "/b"); calls != before+1 {
+		t.Error("the invalidated response was served")
+	}
+	if n := InvalidatePrefix(cache, "example.com/"); n != 2 {
+		t.Errorf("InvalidatePrefix() = %v; want 2", n)
+	}
+}
+
+func TestCacheMiddlewareVary(t *testing.T) {
+	cache := gcache.New(100).LRU().Build()
+	calls := 0
+	h := CacheMiddleware(cache, nil, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprint(w, r.Header.Get("Accept-Language"))
+	}))
+
+	for _, lang := range []string{"en", "fr", "en", "fr"} {
+		if rec := get(h, "/", "Accept-Language", lang); rec.Body.String() != lang {
+			t.Errorf("got %q for %v", rec.Body, lang)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%v calls for 2 variants", calls)
+	}
+	Invalidate(cache, DefaultKey(httptest.NewRequest(http.MethodGet, "/", nil)))
+	if l := cache.Len(); l != 0 {
+		t.Errorf("Invalidate left %v entries", l)
+	}
+}
+
+func TestCacheMiddlewareAuthorization(t *testing.T) {
+	cache := gcache.New(100).LRU().Build()
+	calls := 0
+	h := CacheMiddleware(cache, nil, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else if r.URL.Path == "/shared" {
+			w.Header().Set("Cache-Control", "s-maxage=60")
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+
+	get(h, "/me", "Authorization", "Bearer alice")
+	if rec := get(h, "/me", "Authorization", "Bearer bob"); rec.Body.String() != "Bearer bob" || calls != 2 {
+		t.Errorf("the authorized response was cached: %q after %v calls", rec.Body, calls)
+	}
+	for _, path := range []string{"/public", "/shared"} {
+		before := calls
+		get(h, path, "Authorization", "Bearer alice")
+		get(h, path, "Authorization", "Bearer alice")
+		if calls != before+1 {
+			t.Errorf("the %v response was not cached", path)
+		}
+	}
+}
+
+func TestCacheMiddlewareScoreTTL(t *testing.T) {
+	clock := gcache.NewFakeClock(time.Now())
+	cache := gcache.New(100).SCORE().Clock(clock).
+		ScoringFunc(func(interface{}) int { return 1 }).
+		WeightingFunc(Weight).Build()
+	calls := 0
+	h := CacheMiddleware(cache, nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	get(h, "/")
+	get(h, "/")
+	clock.Advance(2 * time.Minute)
+	if get(h, "/"); calls != 2 {
+		t.Errorf("%v calls, want the response to be removed after its ttl", calls)
+	}
+}
+
+func TestWeight(t *testing.T) {
+	resp := &Response{Status: 200, Header: http.Header{"A": {"bc"}}, Body: []byte("hello")}
+	if w := Weight(resp); w != 8 {
+		t.Errorf("Weight() = %v; want 8", w)
+	}
+}
@@ -0,0 +1,210 @@
+// Package httpserver exposes a gcache Cache over a simple REST API, for debugging
+// and cross-language access:
+//
+//	GET    /cache/{key}            returns the value of key, loading it if needed
+//	PUT    /cache/{key}[?ttl=30s]  sets the value of key to the request body
+//	DELETE /cache/{key}            removes key
+//	GET    /stats                  returns the statistics of the cache as JSON
+//
+// Keys are the path unescaped strings, so "/" can be part of a key as %2F.
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+type config struct {
+	contentType string
+	encodeValue func(interface{}) ([]byte, error)
+	decodeValue func([]byte) (interface{}, error)
+	middleware  []func(http.Handler) http.Handler
+	maxBody     int64
+}
+
+// Option configures the handler.
+type Option func(*config)
+
+// DefaultMaxBodySize is the largest value a PUT accepts by default, in bytes.
+const DefaultMaxBodySize = 1 << 20
+
+// WithValueCodec sets how the values of the cache are converted to and from the bodies
+// of the requests, and the Content-Type of the values returned. By default []byte
+// values are returned as is, strings as their bytes, and bodies are stored as []byte.
+func WithValueCodec(contentType string, encode func(value interface{}) ([]byte, error), decode func([]byte) (interface{}, error)) Option {
+	return func(cfg *config) {
+		cfg.contentType = contentType
+		cfg.encodeValue = encode
+		cfg.decodeValue = decode
+	}
+}
+
+// WithJSONValues encodes the values as JSON, and stores the bodies decoded from JSON.
+func WithJSONValues() Option {
+	return WithValueCodec("application/json", json.Marshal, func(data []byte) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	})
+}
+
+// WithMiddleware wraps the handler with mw, e.g. to authenticate the requests.
+// Middleware is applied in order, the first one sees the requests first.
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(cfg *config) {
+		cfg.middleware = append(cfg.middleware, mw...)
+	}
+}
+
+// WithMaxBodySize sets the largest value a PUT accepts, DefaultMaxBodySize by default.
+func WithMaxBodySize(n int64) Option {
+	return func(cfg *config) {
+		cfg.maxBody = n
+	}
+}
+
+func encodeBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("httpserver: cannot encode a value of type %T", value)
+	}
+}
+
+func decodeBytes(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+type handler struct {
+	cache gcache.Cache
+	cfg   *config
+}
+
+// Handler returns an http.Handler serving c. Mount it with http.StripPrefix
+// to serve it under a path other than the root.
+func Handler(c gcache.Cache, opts ...Option) http.Handler {
+	cfg := &config{
+		contentType: "application/octet-stream",
+		encodeValue: encodeBytes,
+		decodeValue: decodeBytes,
+		maxBody:     DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var h http.Handler = &handler{cache: c, cfg: cfg}
+	for i := len(cfg.middleware) - 1; i >= 0; i-- {
+		h = cfg.middleware[i](h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	if path == "/stats" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.stats(w)
+		return
+	}
+	if !strings.HasPrefix(path, "/cache/") {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(path, "/cache/"))
+	if err != nil || key == "" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, key)
+	case http.MethodPut:
+		h.put(w, r, key)
+	case http.MethodDelete:
+		if !h.cache.Remove(key) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (h *handler) get(w http.ResponseWriter, key string) {
+	v, err := h.cache.Get(key)
+	switch err {
+	case nil:
+	case gcache.KeyNotFoundError:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case gcache.ClosedError:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	body, err := h.cfg.encodeValue(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", h.cfg.contentType)
+	w.Write(body)
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request, key string) {
+	var ttl time.Duration
+	if s := r.URL.Query().Get("ttl"); s != "" {
+		var err error
+		if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.cfg.maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	v, err := h.cfg.decodeValue(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.cache.Set(key, v)
+	if ttl > 0 {
+		h.cache.Touch(key, ttl)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) stats(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hits":      h.cache.HitCount(),
+		"misses":    h.cache.MissCount(),
+		"hit_rate":  h.cache.HitRate(),
+		"length":    h.cache.Len(),
+		"evictions": h.cache.EvictionCount(),
+	})
+}
@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+func do(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	clock := gcache.NewFakeClock(time.Now())
+	cache := gcache.New(10).LRU().Clock(clock).Build()
+	h := Handler(cache)
+
+	if rec := do(t, h, http.MethodPut, "/cache/a%2Fb?ttl=1m", "hello"); rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %v %s", rec.Code, rec.Body)
+	}
+	rec := do(t, h, http.MethodGet, "/cache/a%2Fb", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("GET = %v %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, http.MethodGet, "/cache/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET = %v for a missing key", rec.Code)
+	}
+
+	rec = do(t, h, http.MethodGet, "/stats", "")
+	var stats map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || stats["hits"] != 1 || stats["misses"] != 1 || stats["length"] != 1 {
+		t.Errorf("GET /stats = %s, %v", rec.Body, err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if rec := do(t, h, http.MethodGet, "/cache/a%2Fb", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET = %v after the ttl", rec.Code)
+	}
+
+	do(t, h, http.MethodPut, "/cache/c", "x")
+	if rec := do(t, h, http.MethodDelete, "/cache/c", ""); rec.Code != http.StatusNoContent || cache.Has("c") {
+		t.Errorf("DELETE = %v", rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/cache/c", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE = %v for a missing key", rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/cache/c", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %v", rec.Code)
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	cache := gcache.New(10).LRU().Build()
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := Handler(cache, WithJSONValues(), WithMiddleware(auth))
+
+	if rec := do(t, h, http.MethodGet, "/stats", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET = %v without authorization", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/cache/a", strings.NewReader(`{"n":1}`))
+	req.Header.Set("Authorization", "secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %v %s", rec.Code, rec.Body)
+	}
+	if v, err := cache.Get("a"); err != nil || v.(map[string]interface{})["n"] != 1.0 {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,157 @@
+package gcache
+
+// IndexFunc extracts the attribute of a value which an Index looks entries up by.
+// Attributes must be comparable; a nil attribute leaves the entry out of the index.
+type IndexFunc func(value interface{}) interface{}
+
+// Index maintains a secondary index of the entries by the attribute extract
+// returns for their values, e.g. the user of a session, so that GetByIndex and
+// RemoveByIndex can look up or invalidate all the entries of an attribute.
+// The index is kept up to date as entries are set, removed and evicted.
+func (cb *CacheBuilder) Index(name string, extract IndexFunc) *CacheBuilder {
+	if cb.indexes == nil {
+		cb.indexes = make(map[string]IndexFunc)
+	}
+	cb.indexes[name] = extract
+	return cb
+}
+
+// valueIndex maps the attributes extracted from the values to their keys.
+// Indexer is implemented by the caches which can look up and remove entries
+// by the attributes of their Indexes. All the cache types are Indexers.
+type Indexer interface {
+	// GetByIndex returns the unexpired entries whose attribute in the named
+	// Index is attr. An unknown index matches no entries.
+	GetByIndex(name string, attr interface{}) map[interface{}]interface{}
+	// RemoveByIndex removes the entries whose attribute in the named Index is
+	// attr, and returns how many were removed.
+	RemoveByIndex(name string, attr interface{}) int
+}
+
+type valueIndex struct {
+	extract IndexFunc
+	keys    map[interface{}]map[interface{}]struct{} // by attribute
+	attrs   map[interface{}]interface{}              // by key
+}
+
+func newValueIndex(extract IndexFunc) *valueIndex {
+	idx := &valueIndex{extract: extract}
+	idx.reset()
+	return idx
+}
+
+// add indexes key by the attribute of value, replacing its previous attribute (not thread safe).
+func (idx *valueIndex) add(key, value interface{}) {
+	idx.remove(key)
+	attr := idx.extract(value)
+	if attr == nil {
+		return
+	}
+	keys, ok := idx.keys[attr]
+	if !ok {
+		keys = make(map[interface{}]struct{})
+		idx.keys[attr] = keys
+	}
+	keys[key] = struct{}{}
+	idx.attrs[key] = attr
+}
+
+// remove drops key from the index (not thread safe).
+func (idx *valueIndex) remove(key interface{}) {
+	attr, ok := idx.attrs[key]
+	if !ok {
+		return
+	}
+	delete(idx.attrs, key)
+	keys := idx.keys[attr]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(idx.keys, attr)
+	}
+}
+
+func (idx *valueIndex) reset() {
+	idx.keys = make(map[interface{}]map[interface{}]struct{})
+	idx.attrs = make(map[interface{}]interface{})
+}
+
+// indexed records that key was stored with value, in the indexes and the namespace quota
+// of the cache, and notifies its watchers (not thread safe). value is the stored value,
+// which is decoded for the value indexes.
+func (c *baseCache) indexed(key, value interface{}) {
+	if c.refreshing {
+		c.changed(key, ValueRefreshed, value)
+	} else {
+		c.changed(key, ValueSet, value)
+	}
+	if c.ordered != nil {
+		c.ordered.add(key)
+	}
+	if len(c.indexes) > 0 {
+		v, ok := c.decoded(key, value)
+		for _, idx := range c.indexes {
+			if ok {
+				idx.add(key, v)
+			} else {
+				idx.remove(key)
+			}
+		}
+	}
+	c.enforceQuota(key)
+}
+
+// unindexed drops a removed key from the indexes and the namespace quota of the cache (not thread safe).
+func (c *baseCache) unindexed(key interface{}) {
+	if c.ordered != nil {
+		c.ordered.remove(key)
+	}
+	for _, idx := range c.indexes {
+		idx.remove(key)
+	}
+	if q := c.quotaOf(key); q != nil {
+		q.remove(key)
+	}
+}
+
+// resetIndexes empties the indexes and namespace quotas, when the cache is purged (not thread safe).
+func (c *baseCache) resetIndexes() {
+	if c.ordered != nil {
+		c.ordered.keys = nil
+	}
+	for _, idx := range c.indexes {
+		idx.reset()
+	}
+	for _, q := range c.quotas {
+		q.reset()
+	}
+}
+
+// getByIndex returns the entries whose attribute in the named index is attr,
+// reading their values with peek (not thread safe).
+func (c *baseCache) getByIndex(name string, attr interface{}, peek func(interface{}) (interface{}, bool)) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{})
+	idx, ok := c.indexes[name]
+	if !ok {
+		return m
+	}
+	for key := range idx.keys[attr] {
+		if v, ok := peek(key); ok {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// removeByIndex removes the entries whose attribute in the named index is attr
+// with remove, and returns how many were removed (not thread safe).
+func (c *baseCache) removeByIndex(name string, attr interface{}, remove func(interface{}) bool) int {
+	idx, ok := c.indexes[name]
+	if !ok {
+		return 0
+	}
+	keys := make([]interface{}, 0, len(idx.keys[attr]))
+	for key := range idx.keys[attr] {
+		keys = append(keys, key)
+	}
+	return removeAll(keys, remove)
+}
@@ -0,0 +1,63 @@
+package gcache
+
+import (
+	"testing"
+)
+
+type session struct {
+	user string
+}
+
+func TestIndex(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.
+			Index("user", func(value interface{}) interface{} {
+				if s, ok := value.(session); ok {
+					return s.user
+				}
+				return nil
+			}).
+			Build()
+		cache.Set("s1", session{"alice"})
+		cache.Set("s2", session{"bob"})
+		cache.Set("s3", session{"alice"})
+		cache.Set("other", 1)
+
+		if m := cache.(Indexer).GetByIndex("user", "alice"); len(m) != 2 || m["s1"] != (session{"alice"}) || m["s3"] != (session{"alice"}) {
+			t.Errorf("%T: GetByIndex() = %v", cache, m)
+		}
+		if m := cache.(Indexer).GetByIndex("unknown", "alice"); len(m) != 0 {
+			t.Errorf("%T: GetByIndex() = %v for an unknown index", cache, m)
+		}
+
+		// overwriting moves the entry to its new attribute
+		cache.Set("s3", session{"bob"})
+		if m := cache.(Indexer).GetByIndex("user", "bob"); len(m) != 2 {
+			t.Errorf("%T: GetByIndex() = %v after overwrite", cache, m)
+		}
+
+		if n := cache.(Indexer).RemoveByIndex("user", "bob"); n != 2 {
+			t.Errorf("%T: RemoveByIndex() = %v; want 2", cache, n)
+		}
+		if cache.Has("s2") || cache.Has("s3") || !cache.Has("s1") {
+			t.Errorf("%T: unexpected keys %v", cache, cache.KeysIncludingExpired())
+		}
+		if n := cache.(Indexer).RemoveByIndex("user", "bob"); n != 0 {
+			t.Errorf("%T: RemoveByIndex() = %v; want 0", cache, n)
+		}
+
+		// evicted entries leave the index
+		for i := 0; i < 2*size; i++ {
+			cache.Set(i, i)
+		}
+		if m := cache.(Indexer).GetByIndex("user", "alice"); len(m) != 0 {
+			t.Errorf("%T: GetByIndex() = %v after eviction", cache, m)
+		}
+		cache.Set("s4", session{"carol"})
+		cache.Purge()
+		if m := cache.(Indexer).GetByIndex("user", "carol"); len(m) != 0 {
+			t.Errorf("%T: GetByIndex() = %v after Purge", cache, m)
+		}
+	}
+}
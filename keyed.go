@@ -0,0 +1,468 @@
+package gcache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyedCacheBuilder builds a KeyedCache with the configuration of a CacheBuilder.
+type KeyedCacheBuilder[K comparable] struct {
+	cb *CacheBuilder
+}
+
+// StringKeys builds a KeyedCache for string keys instead of a Cache, whose keys are
+// stored and looked up without boxing them into interfaces, so that hits and updates
+// do not allocate. See KeyedCache for the options it supports.
+func (cb *CacheBuilder) StringKeys() *KeyedCacheBuilder[string] {
+	return &KeyedCacheBuilder[string]{cb: cb}
+}
+
+// IntKeys is StringKeys for int keys, such as IDs.
+func (cb *CacheBuilder) IntKeys() *KeyedCacheBuilder[int] {
+	return &KeyedCacheBuilder[int]{cb: cb}
+}
+
+// Uint64Keys is StringKeys for uint64 keys, such as IDs.
+func (cb *CacheBuilder) Uint64Keys() *KeyedCacheBuilder[uint64] {
+	return &KeyedCacheBuilder[uint64]{cb: cb}
+}
+
+// Build builds the cache, panicking if the configuration is invalid.
+func (kb *KeyedCacheBuilder[K]) Build() *KeyedCache[K] {
+	c, err := kb.BuildE()
+	if err != nil {
+		panic("gcache: " + err.Error())
+	}
+	return c
+}
+
+// BuildE builds the cache, or returns a ConfigError if the configuration is
+// invalid or uses options which a KeyedCache does not support.
+func (kb *KeyedCacheBuilder[K]) BuildE() (*KeyedCache[K], error) {
+	cb := kb.cb
+	if err := cb.validate(); err != nil {
+		return nil, err
+	}
+	if cb.tp != TYPE_LRU {
+		return nil, &ConfigError{Reason: "keyed caches are LRU caches, not " + cb.tp}
+	}
+	if keyedUnsupported(cb) {
+		return nil, &ConfigError{Reason: "keyed caches only support Expiration, LoaderFunc, EvictedFunc, ExpiredFunc, AddedFunc and Clock"}
+	}
+	c := &KeyedCache[K]{
+		size:             cb.size,
+		items:            make(map[K]*lruElement[keyedEntry[K]], cb.size),
+		list:             newLRUList[keyedEntry[K]](),
+		clock:            cb.clock,
+		expiration:       cb.expiration,
+		loaderExpireFunc: cb.loaderExpireFunc,
+		evictedFunc:      cb.evictedFunc,
+		expiredFunc:      cb.expiredFunc,
+		addedFunc:        cb.addedFunc,
+		loads:            make(map[K]*keyedLoad),
+	}
+	if c.clock == nil {
+		c.clock = RealClock{}
+	}
+	return c, nil
+}
+
+// keyedUnsupported reports whether cb sets an option which a KeyedCache does not support.
+// Options which only apply to SCORE caches are rejected by validate.
+func keyedUnsupported(cb *CacheBuilder) bool {
+	// loading
+	if cb.bulkLoaderFunc != nil || cb.loaderErrorFunc != nil || cb.breakerThreshold != 0 || cb.breakerCooldown != 0 ||
+		cb.maxLoads != 0 || cb.coalesceWindow != 0 || cb.noWaitLoad || cb.slowLoadThreshold != DefaultSlowLoadThreshold {
+		return true
+	}
+	// keys and callbacks
+	if cb.hasher != nil || cb.keyValidator != nil || cb.evictedEntryFunc != nil || cb.updatedFunc != nil ||
+		cb.missFunc != nil || cb.purgeEvict || cb.callbackWorkers != 0 || cb.callbackQueue != 0 || cb.callbackOverflow != 0 {
+		return true
+	}
+	// expiration
+	if cb.expireAfterAccess != nil || cb.expirationJitter != 0 || cb.xfetchBeta != 0 || cb.maxStaleness != nil || cb.refreshAfter != 0 {
+		return true
+	}
+	// values
+	if cb.serializeFunc != nil || cb.deserializeFunc != nil || cb.copyOnGet != nil || cb.copyOnSet != nil || cb.codec != nil ||
+		cb.compressThreshold != DefaultCompressionThreshold || cb.bytes || cb.weakValues ||
+		cb.overflow || cb.overflowDir != "" || cb.overflowThreshold != 0 || cb.memoryLimit != 0 || cb.memoryGauge != nil || cb.memoryInterval != 0 {
+		return true
+	}
+	// stats, snapshots and indexes
+	return cb.snapshotEvery != nil || cb.statsWindow != 0 || cb.statsBuckets != 0 || cb.statsClassifier != nil || cb.topKeys != 0 ||
+		cb.dumpLimit != 0 || cb.autoSnapshotInterval != 0 || cb.autoSnapshotSink != nil || cb.autoSnapshotCodec != nil ||
+		cb.snapshotErrorFunc != nil || cb.warmFrom != nil || cb.orderedKeys || cb.indexes != nil || cb.quotas != nil ||
+		cb.logger != nil || cb.scoringFunc != nil || cb.weightingFunc != nil || cb.fallbackScore != 0 || cb.fallbackWeight != 1
+}
+
+// KeyedCache is an LRU cache specialized for keys of type K. Keys are not boxed
+// into interfaces, and the entries of evicted keys are reused for new ones, so
+// that Get and Set do not allocate once the cache is full, unlike a Cache whose
+// keys are boxed by every call. Its callbacks and LoaderFunc still take boxed keys.
+//
+// A KeyedCache supports the Expiration, LoaderFunc, LoaderExpireFunc, EvictedFunc,
+// ExpiredFunc, AddedFunc and Clock options of a LRU builder. It shares its
+// recency list and expiration check with LRUCache.
+type KeyedCache[K comparable] struct {
+	mu               sync.Mutex
+	size             int
+	items            map[K]*lruElement[keyedEntry[K]]
+	list             *lruList[keyedEntry[K]]
+	clock            Clock
+	expiration       *time.Duration
+	loaderExpireFunc *LoaderExpireFunc
+	evictedFunc      *EvictedFunc
+	expiredFunc      *ExpiredFunc
+	addedFunc        *AddedFunc
+	loads            map[K]*keyedLoad
+	loading          sync.WaitGroup
+	closed           bool
+	stats
+}
+
+type keyedEntry[K comparable] struct {
+	key        K
+	value      interface{}
+	created    time.Time
+	expiration time.Time // zero if the entry does not expire
+}
+
+// keyedLoad is an in-flight load of a KeyedCache, shared by the concurrent Gets of its key.
+type keyedLoad struct {
+	done       chan struct{}
+	value      interface{}
+	err        error
+	superseded bool // the key was set while it was loading
+}
+
+// removal is an entry removed from a KeyedCache, whose callback runs once it is unlocked.
+type removal[K comparable] struct {
+	key     K
+	value   interface{}
+	expired bool
+}
+
+// Set inserts or updates the value of key, evicting the least recently used entry if the cache is full.
+func (c *KeyedCache[K]) Set(key K, value interface{}) {
+	c.store(key, value, c.expiration)
+}
+
+// SetWithExpire sets the value of key, which expires after expiration.
+func (c *KeyedCache[K]) SetWithExpire(key K, value interface{}, expiration time.Duration) {
+	c.store(key, value, &expiration)
+}
+
+// Get returns the value of key. If it is not cached, it is loaded with the LoaderFunc,
+// sharing the load with concurrent Gets of key, or KeyNotFoundError is returned.
+func (c *KeyedCache[K]) Get(key K) (interface{}, error) {
+	v, err := c.GetIFPresent(key)
+	if err == nil || c.loaderExpireFunc == nil {
+		return v, err
+	}
+	return c.load(key)
+}
+
+// GetIFPresent returns the value of key if it is cached, KeyNotFoundError otherwise.
+// Unlike Get, it does not load missing keys.
+func (c *KeyedCache[K]) GetIFPresent(key K) (interface{}, error) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if ok && c.isExpired(e) {
+		r := c.expire(e)
+		c.mu.Unlock()
+		c.removed(r)
+		ok = false
+	} else if ok {
+		c.list.MoveToFront(e)
+		v := e.Value.value
+		c.mu.Unlock()
+		c.IncrHitCount()
+		return v, nil
+	} else {
+		c.mu.Unlock()
+	}
+	c.IncrMissCount()
+	return nil, KeyNotFoundError
+}
+
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating its recency or loading it.
+func (c *KeyedCache[K]) Has(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	return ok && !c.isExpired(e)
+}
+
+// Remove removes key and reports whether it was cached.
+func (c *KeyedCache[K]) Remove(key K) bool {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	r := c.remove(e)
+	c.mu.Unlock()
+	c.removed(r)
+	return true
+}
+
+// Keys returns the unexpired keys, from the most to the least recently used.
+func (c *KeyedCache[K]) Keys() []K {
+	return c.keys(true)
+}
+
+// KeysIncludingExpired returns the cached keys, from the most to the least
+// recently used, including the expired ones which were not removed yet.
+func (c *KeyedCache[K]) KeysIncludingExpired() []K {
+	return c.keys(false)
+}
+
+func (c *KeyedCache[K]) keys(checkExpired bool) []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]K, 0, len(c.items))
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		if checkExpired && c.isExpired(e) {
+			continue
+		}
+		keys = append(keys, e.Value.key)
+	}
+	return keys
+}
+
+// Len returns the number of cached entries, including the expired ones which are not removed yet.
+func (c *KeyedCache[K]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Purge removes all the entries, without calling the EvictedFunc.
+func (c *KeyedCache[K]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[K]*lruElement[keyedEntry[K]], c.size)
+	c.list.Init()
+}
+
+// Close waits for the loads in flight to finish. Keys which are not cached
+// cannot be loaded anymore. Returns ClosedError if it was already closed.
+func (c *KeyedCache[K]) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ClosedError
+	}
+	c.closed = true
+	c.mu.Unlock()
+	c.loading.Wait()
+	return nil
+}
+
+// Cache returns a Cache of the entries of c, for code written against the Cache
+// interface, which boxes the keys again. Keys which are not of type K are rejected
+// with an InvalidKeyError. See Adapt for the behavior of the methods which a
+// KeyedCache does not have. Closing the Cache closes c.
+func (c *KeyedCache[K]) Cache() Cache {
+	return Adapt(keyedBackend[K]{c})
+}
+
+// keyedBackend is the Backend of the Cache of a KeyedCache.
+type keyedBackend[K comparable] struct {
+	c *KeyedCache[K]
+}
+
+func (b keyedBackend[K]) key(key interface{}) (K, error) {
+	k, ok := key.(K)
+	if !ok {
+		return k, &InvalidKeyError{Key: key, Reason: fmt.Sprintf("%T is not a %T", key, k)}
+	}
+	return k, nil
+}
+
+func (b keyedBackend[K]) Get(key interface{}) (interface{}, error) {
+	k, err := b.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return b.c.Get(k)
+}
+
+func (b keyedBackend[K]) GetIFPresent(key interface{}) (interface{}, error) {
+	k, err := b.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return b.c.GetIFPresent(k)
+}
+
+func (b keyedBackend[K]) Set(key, value interface{}, ttl time.Duration) error {
+	k, err := b.key(key)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		b.c.SetWithExpire(k, value, ttl)
+	} else {
+		b.c.Set(k, value)
+	}
+	return nil
+}
+
+func (b keyedBackend[K]) Remove(key interface{}) (bool, error) {
+	k, err := b.key(key)
+	if err != nil {
+		return false, err
+	}
+	return b.c.Remove(k), nil
+}
+
+func (b keyedBackend[K]) Keys() ([]interface{}, error) {
+	keys := b.c.Keys()
+	boxed := make([]interface{}, len(keys))
+	for i, key := range keys {
+		boxed[i] = key
+	}
+	return boxed, nil
+}
+
+func (b keyedBackend[K]) Close() error {
+	return b.c.Close()
+}
+
+func (c *KeyedCache[K]) store(key K, value interface{}, expiration *time.Duration) {
+	c.mu.Lock()
+	if l, ok := c.loads[key]; ok {
+		l.superseded = true
+	}
+	r, evicted := c.set(key, value, expiration)
+	c.mu.Unlock()
+	if evicted {
+		c.removed(r)
+	}
+	if c.addedFunc != nil {
+		(*c.addedFunc)(key, value)
+	}
+}
+
+// set stores value under key, returning the entry it evicted, if any (not thread safe).
+func (c *KeyedCache[K]) set(key K, value interface{}, expiration *time.Duration) (r removal[K], evicted bool) {
+	now := c.clock.Now()
+	e, ok := c.items[key]
+	if ok {
+		c.list.MoveToFront(e)
+	} else {
+		if len(c.items) >= c.size {
+			e = c.list.Back()
+			if c.isExpired(e) {
+				r = c.expire(e)
+			} else {
+				r = c.remove(e)
+				c.IncrEvictionCount()
+				c.RecordLifetime(now.Sub(e.Value.created))
+			}
+			evicted = true
+		} else {
+			e = &lruElement[keyedEntry[K]]{}
+		}
+		c.items[key] = e
+		c.list.PushElementFront(e)
+	}
+	e.Value = keyedEntry[K]{key: key, value: value, created: now}
+	if expiration != nil {
+		e.Value.expiration = now.Add(*expiration)
+	}
+	return r, evicted
+}
+
+// load loads key with the LoaderFunc, or waits for the load in flight.
+func (c *KeyedCache[K]) load(key K) (interface{}, error) {
+	c.mu.Lock()
+	if l, ok := c.loads[key]; ok {
+		c.mu.Unlock()
+		<-l.done
+		return l.value, l.err
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ClosedError
+	}
+	l := &keyedLoad{done: make(chan struct{})}
+	c.loads[key] = l
+	c.loading.Add(1)
+	c.mu.Unlock()
+	defer c.loading.Done()
+
+	var ttl *time.Duration
+	l.value, ttl, l.err = c.callLoader(key)
+	c.mu.Lock()
+	delete(c.loads, key)
+	var r removal[K]
+	evicted, added := false, false
+	if e, ok := c.items[key]; l.err == nil && l.superseded && ok && !c.isExpired(e) {
+		// the key was set while it was loading, keep the newer value
+		l.value = e.Value.value
+	} else if l.err == nil {
+		if ttl == nil {
+			ttl = c.expiration
+		}
+		r, evicted = c.set(key, l.value, ttl)
+		added = true
+	}
+	c.mu.Unlock()
+	close(l.done)
+	if evicted {
+		c.removed(r)
+	}
+	if added && c.addedFunc != nil {
+		(*c.addedFunc)(key, l.value)
+	}
+	return l.value, l.err
+}
+
+// callLoader calls the loader for key, turning a panic into a LoaderPanicError.
+func (c *KeyedCache[K]) callLoader(key K) (v interface{}, ttl *time.Duration, err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			v, ttl, err = nil, nil, &LoaderPanicError{Key: key, Value: r}
+		}
+		c.RecordLoad(time.Since(start), err)
+	}()
+	return (*c.loaderExpireFunc)(key)
+}
+
+func (c *KeyedCache[K]) isExpired(e *lruElement[keyedEntry[K]]) bool {
+	return !e.Value.expiration.IsZero() && isExpired(c.clock, &e.Value.expiration)
+}
+
+// remove unlinks e (not thread safe).
+func (c *KeyedCache[K]) remove(e *lruElement[keyedEntry[K]]) removal[K] {
+	delete(c.items, e.Value.key)
+	c.list.Remove(e)
+	return removal[K]{key: e.Value.key, value: e.Value.value}
+}
+
+// expire removes the expired entry e and counts its expiration (not thread safe).
+func (c *KeyedCache[K]) expire(e *lruElement[keyedEntry[K]]) removal[K] {
+	r := c.remove(e)
+	r.expired = true
+	c.IncrExpirationCount()
+	c.RecordLifetime(c.clock.Now().Sub(e.Value.created))
+	return r
+}
+
+// removed calls the ExpiredFunc or EvictedFunc of a removed entry, once the cache is unlocked.
+func (c *KeyedCache[K]) removed(r removal[K]) {
+	switch {
+	case r.expired && c.expiredFunc != nil:
+		(*c.expiredFunc)(r.key, r.value)
+	case c.evictedFunc != nil:
+		(*c.evictedFunc)(r.key, r.value)
+	}
+}
@@ -0,0 +1,237 @@
+package gcache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStringKeys(t *testing.T) {
+	var evicted []interface{}
+	c := New(2).LRU().
+		EvictedFunc(func(key, value interface{}) { evicted = append(evicted, key) }).
+		StringKeys().Build()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Fatalf("Get(a) = %v, %v", v, err)
+	}
+	c.Set("c", 3)
+	if c.Has("b") {
+		t.Errorf("the least recently used key was not evicted")
+	}
+	if fmt.Sprint(evicted) != "[b]" {
+		t.Errorf("evicted %v, want [b]", evicted)
+	}
+	if keys := c.KeysIncludingExpired(); fmt.Sprint(keys) != "[c a]" {
+		t.Errorf("KeysIncludingExpired() = %v, want [c a]", keys)
+	}
+	if _, err := c.Get("b"); err != KeyNotFoundError {
+		t.Errorf("Get(b) = %v, want KeyNotFoundError", err)
+	}
+	if !c.Remove("a") || c.Remove("a") || c.Len() != 1 {
+		t.Errorf("unexpected Remove, Len %d", c.Len())
+	}
+	if c.HitCount() != 1 || c.MissCount() != 1 || c.EvictionCount() != 1 {
+		t.Errorf("unexpected stats %+v", c.Stats())
+	}
+	c.Purge()
+	if c.Len() != 0 || len(c.KeysIncludingExpired()) != 0 {
+		t.Errorf("Purge left %d entries", c.Len())
+	}
+}
+
+func TestStringKeysExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	var expired []interface{}
+	c := New(10).LRU().Clock(clock).Expiration(time.Minute).
+		ExpiredFunc(func(key, value interface{}) { expired = append(expired, key) }).
+		StringKeys().Build()
+
+	c.Set("a", 1)
+	c.SetWithExpire("b", 2, time.Hour)
+	clock.Advance(2 * time.Minute)
+	if c.Has("a") || !c.Has("b") {
+		t.Errorf("Has(a) = %v, Has(b) = %v after a expired", c.Has("a"), c.Has("b"))
+	}
+	if keys := c.Keys(); fmt.Sprint(keys) != "[b]" {
+		t.Errorf("Keys() = %v, want [b]", keys)
+	}
+	if _, err := c.Get("a"); err != KeyNotFoundError {
+		t.Errorf("Get of an expired key returned %v", err)
+	}
+	if fmt.Sprint(expired) != "[a]" || c.Stats().Expirations != 1 || c.Len() != 1 {
+		t.Errorf("expired %v, stats %+v, Len %d", expired, c.Stats(), c.Len())
+	}
+}
+
+func TestStringKeysLoader(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := New(10).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v" + key.(string), nil
+	}).StringKeys().Build()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get("a"); err != nil || v != "va" {
+				t.Errorf("Get(a) = %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("the loader was called %d times, want 1", n)
+	}
+	if v, err := c.GetIFPresent("a"); err != nil || v != "va" {
+		t.Errorf("the loaded value was not cached: %v, %v", v, err)
+	}
+	if c.LoadSuccessCount() != 1 {
+		t.Errorf("LoadSuccessCount() = %d, want 1", c.LoadSuccessCount())
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("b"); err != ClosedError {
+		t.Errorf("Get of a missing key after Close returned %v, want ClosedError", err)
+	}
+	if err := c.Close(); err != ClosedError {
+		t.Errorf("second Close returned %v", err)
+	}
+}
+
+func TestStringKeysLoadSuperseded(t *testing.T) {
+	loading, release := make(chan struct{}), make(chan struct{})
+	c := New(10).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		close(loading)
+		<-release
+		return "loaded", nil
+	}).StringKeys().Build()
+
+	done := make(chan interface{})
+	go func() {
+		v, _ := c.Get("a")
+		done <- v
+	}()
+	<-loading
+	c.Set("a", "set")
+	close(release)
+	if v := <-done; v != "set" {
+		t.Errorf("Get() = %v, want the value set during the load", v)
+	}
+	if v, err := c.GetIFPresent("a"); v != "set" || err != nil {
+		t.Errorf("the load overwrote the newer value: %v, %v", v, err)
+	}
+}
+
+func TestStringKeysConfig(t *testing.T) {
+	for i, cb := range []*CacheBuilder{
+		New(10),
+		New(10).LFU(),
+		New(10).LRU().PurgeEvict(true),
+		New(10).LRU().ExpireAfterAccess(time.Minute),
+		New(10).LRU().KeyValidator(ComparableKey),
+		New(10).LRU().UpdatedFunc(func(key, old, new interface{}) {}),
+		New(10).LRU().OrderedKeys(),
+	} {
+		if _, err := cb.StringKeys().BuildE(); err == nil {
+			t.Errorf("%d: expected a ConfigError", i)
+		} else if _, ok := err.(*ConfigError); !ok {
+			t.Errorf("unexpected error type %T", err)
+		}
+	}
+}
+
+func TestStringKeysAllocs(t *testing.T) {
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	c := New(100).LRU().StringKeys().Build()
+	for _, key := range keys {
+		c.Set(key, 1)
+	}
+	i := 0
+	if n := testing.AllocsPerRun(1000, func() {
+		c.Set(keys[i%len(keys)], 1)
+		c.Get(keys[(i+50)%len(keys)])
+		i++
+	}); n != 0 {
+		t.Errorf("Get and Set allocate %v times", n)
+	}
+
+	gc := New(100).LRU().Build()
+	if n := testing.AllocsPerRun(1000, func() {
+		gc.Set(keys[i%len(keys)], 1)
+		gc.Get(keys[(i+50)%len(keys)])
+		i++
+	}); n == 0 {
+		t.Errorf("Cache does not allocate, the comparison is moot")
+	}
+}
+
+func TestIntKeys(t *testing.T) {
+	ic := New(100).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key.(int) * 2, nil
+	}).IntKeys().Build()
+	if v, err := ic.Get(21); err != nil || v != 42 {
+		t.Errorf("Get(21) = %v, %v", v, err)
+	}
+
+	uc := New(100).LRU().Uint64Keys().Build()
+	for id := uint64(0); id < 200; id++ {
+		uc.Set(id, 1)
+	}
+	if uc.Len() != 100 || uc.Has(99) || !uc.Has(100) {
+		t.Errorf("unexpected entries %v", uc.KeysIncludingExpired())
+	}
+	id := uint64(1 << 40)
+	if n := testing.AllocsPerRun(1000, func() {
+		uc.Set(id, 1)
+		uc.Get(id - 50)
+		id++
+	}); n != 0 {
+		t.Errorf("Get and Set allocate %v times", n)
+	}
+}
+
+func TestKeyedCacheAsCache(t *testing.T) {
+	kc := New(2).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key.(string) + "!", nil
+	}).StringKeys().Build()
+	var c Cache = kc.Cache()
+
+	c.Set("a", 1)
+	if v, err := kc.Get("a"); v != 1 || err != nil {
+		t.Errorf("the Cache did not set the KeyedCache: %v, %v", v, err)
+	}
+	if v, err := c.Get("b"); v != "b!" || err != nil {
+		t.Errorf("Get() = %v, %v; want the loaded value", v, err)
+	}
+	if !c.(Swapper).CompareAndSwap("a", 1, 2) || !kc.Has("a") {
+		t.Error("CompareAndSwap() did not swap the value")
+	}
+	if _, err := c.Get(1); err == nil {
+		t.Error("Get() accepted a key which is not a string")
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Errorf("Keys() = %v", keys)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.Close(); err != ClosedError {
+		t.Errorf("closing the Cache did not close the KeyedCache: %v", err)
+	}
+}
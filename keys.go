@@ -0,0 +1,57 @@
+package gcache
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KeyValidatorFunc returns an error if key must not be cached.
+type KeyValidatorFunc func(key interface{}) error
+
+// InvalidKeyError is returned for keys which the KeyValidator rejects.
+type InvalidKeyError struct {
+	Key    interface{}
+	Reason string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return fmt.Sprintf("Invalid key %#v: %s", e.Key, e.Reason)
+}
+
+// Validate keys with validate instead of ComparableKey. Keys it rejects are not
+// stored by Set and loads, Get and GetIFPresent return its error for them, and Has
+// and Remove report them missing. The error is logged with the Logger, if any.
+func (cb *CacheBuilder) KeyValidator(validate KeyValidatorFunc) *CacheBuilder {
+	cb.keyValidator = validate
+	return cb
+}
+
+// ComparableKey rejects the keys which cannot be map keys, such as slices, maps
+// and functions, or structs and arrays holding them, which would make the cache panic.
+// It is the KeyValidator unless another one is set.
+func ComparableKey(key interface{}) error {
+	switch key.(type) {
+	case nil, string, int, int64, uint64, int32, uint32:
+		return nil
+	}
+	if !reflect.ValueOf(key).Comparable() {
+		return &InvalidKeyError{Key: key, Reason: fmt.Sprintf("%T is not comparable", key)}
+	}
+	return nil
+}
+
+// checkKey validates key with the KeyValidator, logging its error if it is invalid.
+func (c *baseCache) checkKey(key interface{}) error {
+	err := c.keyValidator(key)
+	if err != nil && c.logger != nil {
+		c.logger.Warn("gcache: invalid key", "key", key, "error", err)
+	}
+	return err
+}
+
+// removeKeys removes the valid keys with remove and returns how many were removed (not thread safe).
+func (c *baseCache) removeKeys(keys []interface{}, remove func(interface{}) bool) int {
+	return removeAll(keys, func(key interface{}) bool {
+		return c.checkKey(key) == nil && remove(key)
+	})
+}
@@ -0,0 +1,119 @@
+package gcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComparableKey(t *testing.T) {
+	valid := []interface{}{nil, "a", 1, 1.5, struct{ A, B int }{1, 2}, [2]string{"a", "b"}, &struct{}{}}
+	for _, key := range valid {
+		if err := ComparableKey(key); err != nil {
+			t.Errorf("ComparableKey(%#v) = %v", key, err)
+		}
+	}
+	invalid := []interface{}{[]int{1}, map[string]int{}, func() {}, struct{ S []int }{}, [1]interface{}{[]byte("a")}}
+	for _, key := range invalid {
+		if _, ok := ComparableKey(key).(*InvalidKeyError); !ok {
+			t.Errorf("ComparableKey(%#v) accepted the key", key)
+		}
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.LoaderFunc(func(key interface{}) (interface{}, error) { return 1, nil }).Build()
+		key := []int{1, 2}
+		cache.Set(key, 1)
+		if _, err := cache.Get(key); err == nil || !strings.Contains(err.Error(), "[]int is not comparable") {
+			t.Errorf("%T: Get() = %v, want an InvalidKeyError", cache, err)
+		}
+		if _, err := cache.GetIFPresent(key); err == nil {
+			t.Errorf("%T: GetIFPresent() accepted the key", cache)
+		}
+		if cache.Has(key) || cache.Remove(key) || cache.Len() != 0 {
+			t.Errorf("%T: the invalid key was cached", cache)
+		}
+		if cache.MissCount() != 0 {
+			t.Errorf("%T: invalid keys counted as misses", cache)
+		}
+
+		if _, loaded := cache.GetOrSet(key, 1); loaded {
+			t.Errorf("%T: GetOrSet() loaded the key", cache)
+		}
+		if cache.Touch(key) || cache.(Pinner).Pin(key) || cache.(Pinner).Unpin(key) ||
+			cache.(Swapper).CompareAndSwap(key, 1, 2) || cache.(Swapper).CompareAndDelete(key, 1) {
+			t.Errorf("%T: the invalid key was found", cache)
+		}
+		if _, ok := cache.GetAndRemove(key); ok {
+			t.Errorf("%T: GetAndRemove() found the key", cache)
+		}
+		if _, ok := cache.RemoveGet(key); ok {
+			t.Errorf("%T: RemoveGet() found the key", cache)
+		}
+		if err := cache.Update(key, func(interface{}, bool) (interface{}, error) { return 1, nil }); err == nil {
+			t.Errorf("%T: Update() accepted the key", cache)
+		}
+		if _, err := cache.Increment(key, 1); err == nil {
+			t.Errorf("%T: Increment() accepted the key", cache)
+		}
+		if _, err := cache.Do(key, func() (interface{}, error) { return 1, nil }, false); err == nil {
+			t.Errorf("%T: Do() accepted the key", cache)
+		}
+		if cache.RemoveAll(key, "a") != 0 || cache.(Explainer).Explain(key).Present {
+			t.Errorf("%T: the invalid key was found", cache)
+		}
+		if vs, _ := cache.GetMulti([]interface{}{key, "a"}); len(vs) != 1 {
+			t.Errorf("%T: GetMulti() = %v", cache, vs)
+		}
+		if err := cache.Warm([]interface{}{key}, 1); err != nil {
+			t.Errorf("%T: Warm() = %v", cache, err)
+		}
+		if _, ok := <-func() <-chan ValueChange { ch, _ := cache.(Watcher).Watch(key); return ch }(); ok {
+			t.Errorf("%T: Watch() delivered a change", cache)
+		}
+		cache.RemoveAfter(key, 0)
+		cache.RemoveAt(key, time.Now())
+		if v, err := cache.Do("b", func() (interface{}, error) { return 2, nil }, true); v != 2 || err != nil {
+			t.Errorf("%T: Do() = %v, %v after invalid keys", cache, v, err)
+		}
+	}
+}
+
+func TestKeyValidator(t *testing.T) {
+	errEmpty := errors.New("empty key")
+	cache := New(8).LRU().KeyValidator(func(key interface{}) error {
+		if key == "" {
+			return errEmpty
+		}
+		return nil
+	}).Build()
+	cache.Set("", 1)
+	if _, err := cache.Get(""); err != errEmpty {
+		t.Errorf("Get() = %v, want the error of the validator", err)
+	}
+	cache.Set("a", 1)
+	if v, err := cache.Get("a"); v != 1 || err != nil {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+}
+
+func TestUnhashableKeyDoesNotDeadlock(t *testing.T) {
+	cache := New(8).LRU().KeyValidator(func(interface{}) error { return nil }).Build()
+	func() {
+		defer func() { recover() }()
+		cache.Do([]int{1}, func() (interface{}, error) { return 1, nil }, false)
+	}()
+	done := make(chan struct{})
+	go func() {
+		cache.Do("a", func() (interface{}, error) { return 1, nil }, true)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the load group stayed locked after a panic")
+	}
+}
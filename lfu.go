@@ -2,6 +2,8 @@ package gcache
 
 import (
 	"container/list"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -15,15 +17,22 @@ type LFUCache struct {
 func newLFUCache(cb *CacheBuilder) *LFUCache {
 	c := &LFUCache{}
 	buildCache(&c.baseCache, cb)
+	c.reads = newReadBuffer(c.applyRead)
 
 	c.init()
 	c.loadGroup.cache = c
+	c.evictKey = c.remove
+	c.monitorMemory(c.shed, c.Len)
+	c.startAutoSnapshot(c.Export)
 	return c
 }
 
 func (c *LFUCache) init() {
 	c.freqList = list.New()
 	c.items = make(map[interface{}]*lfuItem, c.size+1)
+	c.pinned = nil
+	c.priorities = nil
+	c.copies = nil // Snapshots in progress keep reading the previous entries
 	c.freqList.PushFront(&freqEntry{
 		freq:  0,
 		items: make(map[*lfuItem]byte),
@@ -33,14 +42,39 @@ func (c *LFUCache) init() {
 // set a new key-value pair
 func (c *LFUCache) Set(key, value interface{}) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.set(key, value)
 }
 
+// SetWithExpire sets a new key-value pair which expires after expiration,
+// instead of the Expiration of the cache.
+func (c *LFUCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	it, err := c.set(key, value)
+	if err != nil {
+		return
+	}
+	t := c.clock.Now().Add(expiration)
+	it.(*lfuItem).expiration = &t
+}
+
 func (c *LFUCache) set(key, value interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	c.written(key)
+	value, err := c.encode(key, value)
+	if err != nil {
+		c.remove(key)
+		return nil, err
+	}
+
 	// Check for existing item
 	item, ok := c.items[key]
 	if ok {
+		c.updated(key, item.value, value)
+		c.release(key, item.value)
 		item.value = value
 	} else {
 		// Verify size not exceeded
@@ -51,6 +85,7 @@ func (c *LFUCache) set(key, value interface{}) (interface{}, error) {
 			key:         key,
 			value:       value,
 			freqElement: nil,
+			created:     c.clock.Now(),
 		}
 		el := c.freqList.Front()
 		fe := el.Value.(*freqEntry)
@@ -61,13 +96,13 @@ func (c *LFUCache) set(key, value interface{}) (interface{}, error) {
 	}
 
 	if c.expiration != nil {
-		t := time.Now().Add(*c.expiration)
+		t := c.clock.Now().Add(c.jitter(*c.expiration))
 		item.expiration = &t
 	}
+	item.accessExpiration = c.newAccessExpiration()
+	item.refreshAt = c.newRefreshAt()
 
-	if c.addedFunc != nil {
-		(*c.addedFunc)(key, value)
-	}
+	c.added(key, value)
 
 	return item, nil
 }
@@ -83,6 +118,12 @@ func (c *LFUCache) Get(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// GetAsync returns a channel which receives the result of Get for key once it is
+// available, without waiting for the LoaderFunc.
+func (c *LFUCache) GetAsync(key interface{}) <-chan Result {
+	return c.getAsync(key, c.getValue, c.getWithLoader)
+}
+
 // Get a value from cache pool using key if it exists.
 // If it dose not exists key, returns KeyNotFoundError.
 // And send a request which refresh value for specified key if cache object has LoaderFunc.
@@ -94,61 +135,190 @@ func (c *LFUCache) GetIFPresent(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating the eviction policy or loading it.
+func (c *LFUCache) Has(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.items[key]
+	return ok && !item.IsExpired(c.clock)
+}
+
+// GetMulti returns the values of all keys which are cached or can be loaded.
+func (c *LFUCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	return c.getMulti(keys, c.getValue, c.getWithLoader, c.setLoaded)
+}
+
+// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+// concurrency loads at a time, e.g. to fill the cache before taking traffic.
+func (c *LFUCache) Warm(keys []interface{}, concurrency int) error {
+	return c.warm(keys, concurrency, c.Has, c.getWithLoader)
+}
+
 func (c *LFUCache) get(key interface{}, onLoad bool) (interface{}, error) {
 	c.mu.RLock()
 	item, ok := c.items[key]
+	fresh := ok && !item.IsExpired(c.clock)
 	c.mu.RUnlock()
+	if fresh {
+		c.recordRead(item)
+		if !onLoad {
+			c.stats.recordHit(key)
+		}
+		return item, nil
+	}
 
-	if ok {
-		if !item.IsExpired(nil) {
-			c.mu.Lock()
-			c.increment(item)
-			c.mu.Unlock()
-			if !onLoad {
-				c.stats.IncrHitCount()
+	if ok && !c.keepStale(item.expiration, item.accessExpiration) {
+		c.mu.Lock()
+		// buffered hits may have pushed back the access expiration
+		c.reads.drain()
+		if c.items[key] == item {
+			if !item.IsExpired(c.clock) {
+				c.applyRead(item, c.newAccessExpiration())
+				c.unlock()
+				if !onLoad {
+					c.stats.recordHit(key)
+				}
+				return item, nil
 			}
-			return item, nil
+			c.expire(item.created, func() { c.removeItem(item) })
 		}
-		c.mu.Lock()
-		c.removeItem(item)
-		c.mu.Unlock()
+		c.unlock()
 	}
 	if !onLoad {
-		c.stats.IncrMissCount()
+		c.stats.recordMiss(key)
+	}
+	return nil, KeyNotFoundError
+}
+
+// lookup returns the stored value of key for the load group, without counting
+// a hit or miss. Expired entries are misses, and are left in place.
+func (c *LFUCache) lookup(key interface{}) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, nil
 	}
 	return nil, KeyNotFoundError
 }
 
 func (c *LFUCache) getValue(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
 	it, err := c.get(key, false)
+	if err != nil {
+		c.missed(key)
+		return nil, err
+	}
+	item := it.(*lfuItem)
+	c.mu.RLock()
+	v, expiration, delta, refreshAt := item.value, item.expiration, item.delta, item.refreshAt
+	c.mu.RUnlock()
+	if c.xfetchBeta > 0 || c.refreshesAfterWrite() {
+		c.refreshEarly(key, expiration, delta, c.setLoaded)
+		c.refreshAfterWrite(key, refreshAt, c.setLoaded)
+	}
+	return c.decode(key, v)
+}
+
+// Do executes fn once for all the concurrent calls with the same key, which wait for
+// it and share its result, the way concurrent loads of a key share one call of the
+// LoaderFunc.
+func (c *LFUCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return c.doShared(key, fn)
+	}
+	v, err := c.doCached(key, fn, c.setLoaded)
 	if err != nil {
 		return nil, err
 	}
-	return it.(*lfuItem).value, nil
+	return c.decode(key, v)
 }
 
 func (c *LFUCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderFunc == nil {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if c.loaderExpireFunc == nil {
 		return nil, KeyNotFoundError
 	}
-	it, called, err := c.load(key, func(v interface{}, e error) (interface{}, error) {
-		if e == nil {
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			return c.set(key, v)
-		}
-		return nil, e
-	}, isWait)
+	v, called, err := c.load(key, c.setLoaded, isWait)
 	if err != nil {
+		if err != KeyNotFoundError {
+			if v, ok := c.stale(key); ok {
+				return v, nil
+			}
+		}
 		return nil, err
 	}
-	li := it.(*lfuItem)
 	if !called {
 		c.mu.Lock()
-		defer c.mu.Unlock()
-		c.increment(li)
+		if item, ok := c.items[key]; ok {
+			c.increment(item)
+		}
+		c.unlock()
+	}
+	return c.decode(key, v)
+}
+
+// stale returns the value of an expired entry which StaleIfError still allows to serve.
+func (c *LFUCache) stale(key interface{}) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && c.keepStale(item.expiration, item.accessExpiration) {
+		return c.decoded(key, item.value)
+	}
+	return nil, false
+}
+
+// stores a loaded value along with its expiration and the time it took to load
+func (c *LFUCache) setLoaded(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if item, ok := c.items[key]; c.superseded(key) && ok && !item.IsExpired(c.clock) {
+		// the key was set while it was loading, keep the newer value
+		return item.value, nil
 	}
-	return li.value, nil
+	c.refreshing = true
+	it, err := c.set(key, value)
+	c.refreshing = false
+	if err != nil {
+		return nil, err
+	}
+	item := it.(*lfuItem)
+	if ttl != nil {
+		t := c.clock.Now().Add(*ttl)
+		item.expiration = &t
+	}
+	item.delta = elapsed
+	return item.value, nil
+}
+
+// peek returns the value for key if it is present and not expired,
+// without updating frequency or statistics.
+func (c *LFUCache) peek(key interface{}) (interface{}, bool) {
+	if v, ok := c.stored(key); ok {
+		return c.decoded(key, v)
+	}
+	return nil, false
+}
+
+// stored returns the stored form of the value of key, if it is present.
+func (c *LFUCache) stored(key interface{}) (interface{}, bool) {
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, true
+	}
+	return nil, false
 }
 
 func (c *LFUCache) increment(item *lfuItem) {
@@ -170,31 +340,247 @@ func (c *LFUCache) increment(item *lfuItem) {
 
 // evict removes the least frequence item from the cache.
 func (c *LFUCache) evict(count int) {
-	entry := c.freqList.Front()
-	for i := 0; i < count; {
-		if entry == nil {
-			return
-		} else {
+	c.reads.drain()
+	i := 0
+	c.evictByClass(func() bool {
+		for entry := c.freqList.Front(); entry != nil; {
+			next := entry.Next()
 			for item, _ := range entry.Value.(*freqEntry).items {
 				if i >= count {
-					return
+					return true
+				}
+				if !c.evictable(item.key) {
+					continue
 				}
 				c.removeItem(item)
+				c.recordEviction(item.key, item.created)
 				i++
 			}
-			entry = entry.Next()
+			entry = next
 		}
+		return i >= count
+	})
+}
+
+// applyRead updates the frequency of an entry which was read,
+// unless it was removed since.
+func (c *LFUCache) applyRead(entry interface{}, accessExpiration *time.Time) {
+	item := entry.(*lfuItem)
+	if c.items[item.key] != item {
+		return
 	}
+	c.increment(item)
+	item.accessExpiration = laterExpiration(item.accessExpiration, accessExpiration)
 }
 
 // Removes the provided key from the cache.
 func (c *LFUCache) Remove(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.remove(key)
+}
+
+// RemoveAll removes the provided keys from the cache under a single lock acquisition
+// and returns how many of them were in the cache.
+func (c *LFUCache) RemoveAll(keys ...interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.removeKeys(keys, c.remove)
+}
+
+// GetOrSet returns the existing value for the key if present.
+func (c *LFUCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		if v, ok := c.decoded(key, item.value); ok {
+			c.increment(item)
+			item.accessExpiration = c.newAccessExpiration()
+			return v, true
+		}
+	}
+	c.set(key, value)
+	return value, false
+}
+
+// Update atomically replaces the value for key with the result of fn,
+// which receives the current value and whether it exists.
+func (c *LFUCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	current, exists := c.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	_, err = c.set(key, value)
+	return err
+}
+
+// Increment atomically adds delta to the integer stored under key and returns the result.
+// int, int64 and uint64 values keep their type; a missing key is created holding delta.
+func (c *LFUCache) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, delta)
+}
+
+// Decrement atomically subtracts delta from the integer stored under key and returns the result.
+func (c *LFUCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, -delta)
+}
+
+// Touch resets the expiration of key without reading its value or counting an access.
+// The expiration is restarted with ttl if given, or with the default expiration otherwise.
+func (c *LFUCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || item.IsExpired(c.clock) {
+		return false
+	}
+	item.expiration = c.touchedExpiration(item.expiration, ttl)
+	item.accessExpiration = c.newAccessExpiration()
+	return true
+}
+
+// Pin exempts key from capacity eviction until it is unpinned.
+func (c *LFUCache) Pin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.peek(key); !ok {
+		return false
+	}
+	c.pin(key)
+	return true
+}
+
+// Unpin makes key evictable again.
+func (c *LFUCache) Unpin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.unpin(key)
+}
+
+// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+func (c *LFUCache) SetWithPriority(key, value interface{}, priority Priority) {
+	c.mu.Lock()
+	defer c.unlock()
+	if _, err := c.set(key, value); err == nil {
+		c.prioritize(key, priority)
+	}
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the cache is equal to old.
+func (c *LFUCache) CompareAndSwap(key, old, new interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	_, err := c.set(key, new)
+	return err == nil
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (c *LFUCache) CompareAndDelete(key, old interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
 	return c.remove(key)
 }
 
+// GetAndRemove removes the provided key from the cache and returns its value.
+func (c *LFUCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.IsExpired(c.clock) {
+		c.expire(item.created, func() { c.removeItem(item) })
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, ok := c.decoded(key, item.value)
+	c.removeItem(item)
+	return value, ok
+}
+
+// RemoveGet removes the provided key from the cache and returns the value it held, so
+// that the value can be cleaned up.
+func (c *LFUCache) RemoveGet(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, _ := c.decoded(key, item.value)
+	c.removeItem(item)
+	return value, true
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (c *LFUCache) RemoveAt(key interface{}, t time.Time) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, t.Sub(c.clock.Now()), c.Remove)
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (c *LFUCache) RemoveAfter(key interface{}, d time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, d, c.Remove)
+}
+
 func (c *LFUCache) remove(key interface{}) bool {
 	if item, ok := c.items[key]; ok {
 		c.removeItem(item)
@@ -207,39 +593,262 @@ func (c *LFUCache) remove(key interface{}) bool {
 func (c *LFUCache) removeItem(item *lfuItem) {
 	delete(c.items, item.key)
 	delete(item.freqElement.Value.(*freqEntry).items, item)
-	if c.evictedFunc != nil {
-		(*c.evictedFunc)(item.key, item.value)
-	}
+	c.forget(item.key)
+	c.release(item.key, item.value)
+	c.evicted(item.key, item.value, item.expiration, item.accessExpiration)
 }
 
-// Returns a slice of the keys in the cache.
+// Returns a slice of the unexpired keys in the cache.
 func (c *LFUCache) Keys() []interface{} {
+	return c.keys(true)
+}
+
+// KeysIncludingExpired returns a slice of the keys in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than Keys.
+func (c *LFUCache) KeysIncludingExpired() []interface{} {
+	return c.keys(false)
+}
+
+func (c *LFUCache) keys(checkExpired bool) []interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).Keys()
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	keys := make([]interface{}, len(c.items))
-	i := 0
-	for k := range c.items {
-		keys[i] = k
-		i++
+	keys := make([]interface{}, 0, len(c.items))
+	for k, item := range c.items {
+		if checkExpired && item.IsExpired(c.clock) {
+			continue
+		}
+		keys = append(keys, k)
 	}
-
 	return keys
 }
 
-// Returns all key-value pairs in the cache.
+// KeysSorted returns the unexpired keys in the order given by less.
+func (c *LFUCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(c.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys which start with prefix, in order.
+func (c *LFUCache) KeysWithPrefix(prefix string) []interface{} {
+	return c.keysWithPrefix(prefix, c.Keys, c.Has)
+}
+
+// GetByIndex returns the unexpired entries whose attribute in the named Index is attr.
+func (c *LFUCache) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getByIndex(name, attr, c.peek)
+}
+
+// RemoveByIndex removes the entries whose attribute in the named Index is attr
+// under a single lock acquisition, and returns how many were removed.
+func (c *LFUCache) RemoveByIndex(name string, attr interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.removeByIndex(name, attr, c.remove)
+}
+
+// Namespace returns a view of the cache which keeps its keys apart from
+// the keys of the other namespaces and of the cache itself.
+func (c *LFUCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(c, name)
+}
+
+// Returns all unexpired key-value pairs in the cache.
 func (c *LFUCache) GetALL() map[interface{}]interface{} {
+	return c.all(true)
+}
+
+// GetALLIncludingExpired returns all key-value pairs in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than GetALL.
+func (c *LFUCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.all(false)
+}
+
+func (c *LFUCache) all(checkExpired bool) map[interface{}]interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).GetALL()
+	}
+	return c.getALL(checkExpired)
+}
+
+func (c *LFUCache) getALL(checkExpired bool) map[interface{}]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	m := make(map[interface{}]interface{})
 	for k, v := range c.items {
-		m[k] = v.value
+		if checkExpired && v.IsExpired(c.clock) {
+			continue
+		}
+		if value, ok := c.decoded(k, v.value); ok {
+			m[k] = value
+		}
 	}
 
 	return m
 }
 
+// Snapshot returns a consistent copy of the unexpired entries, as of when it is called.
+func (c *LFUCache) Snapshot() map[interface{}]interface{} {
+	return c.consistentCopy(func() ([]interface{}, func(interface{}) (interface{}, bool)) {
+		items := c.items
+		keys := make([]interface{}, 0, len(items))
+		for k, item := range items {
+			if !item.IsExpired(c.clock) {
+				keys = append(keys, k)
+			}
+		}
+		return keys, func(key interface{}) (interface{}, bool) {
+			item, ok := items[key]
+			if !ok {
+				return nil, false
+			}
+			return c.decoded(key, item.value)
+		}
+	})
+}
+
+// Export writes the unexpired entries, with their expiration, to w with codec.
+func (c *LFUCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(c, w, codec)
+}
+
+// Import sets the entries written by Export which have not expired yet,
+// restoring their expiration.
+func (c *LFUCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(c, r, codec)
+}
+
+func (c *LFUCache) entries() []SnapshotEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]SnapshotEntry, 0, len(c.items))
+	for k, item := range c.items {
+		if item.IsExpired(c.clock) {
+			continue
+		}
+		if v, ok := c.decoded(k, item.value); ok {
+			entries = append(entries, SnapshotEntry{Key: k, Value: v, Expiration: item.expiration})
+		}
+	}
+	return entries
+}
+
+func (c *LFUCache) importEntries(entries []SnapshotEntry) {
+	c.restore(entries, c.Set, c.Touch)
+}
+
+// Dump writes the entries of the cache with their age, frequency and time to live to w
+// in format, for debugging.
+func (c *LFUCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(c, w, format)
+}
+
+// Watch returns a channel receiving the changes of key. See Watcher.
+func (c *LFUCache) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return c.watch(key, key)
+}
+
+func (c *LFUCache) dumpEntries() ([]dumpEntry, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]dumpEntry, 0, len(c.items))
+	for k, item := range c.items {
+		e := c.newDumpEntry(k, item.value, item.created, item.expiration, item.accessExpiration)
+		e.hits = uint64(item.freqElement.Value.(*freqEntry).freq)
+		entries = append(entries, e)
+	}
+	return entries, c.dumpLimit
+}
+
+// Verify checks the internal invariants of the cache and returns an InvariantError
+// describing the first one which does not hold.
+func (c *LFUCache) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	front := c.freqList.Front()
+	if front == nil || front.Value.(*freqEntry).freq != 0 {
+		return violated("frequency list does not start at frequency 0")
+	}
+	n := 0
+	for e := front; e != nil; e = e.Next() {
+		entry := e.Value.(*freqEntry)
+		if prev := e.Prev(); prev != nil && prev.Value.(*freqEntry).freq >= entry.freq {
+			return violated("frequency %d follows frequency %d", entry.freq, prev.Value.(*freqEntry).freq)
+		}
+		for item := range entry.items {
+			if item.freqElement != e {
+				return violated("key %v is at frequency %d but points to another one", item.key, entry.freq)
+			}
+			if c.items[item.key] != item {
+				return violated("key %v at frequency %d maps to another item", item.key, entry.freq)
+			}
+		}
+		n += len(entry.items)
+	}
+	if n != len(c.items) {
+		return violated("frequency list has %d entries but the cache has %d", n, len(c.items))
+	}
+	return c.verifyIndexes(func(key interface{}) bool {
+		_, ok := c.items[key]
+		return ok
+	})
+}
+
+// Explain reports the position of key in the eviction order.
+// The least frequently used entries are evicted first,
+// entries with the same frequency are evicted in no particular order.
+func (c *LFUCache) Explain(key interface{}) EvictionExplanation {
+	if c.checkKey(key) != nil {
+		return notPresentExplanation(key)
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.reads.drain()
+
+	item, ok := c.items[key]
+	if !ok {
+		return notPresentExplanation(key)
+	}
+	ex := EvictionExplanation{
+		Key:       key,
+		Present:   true,
+		Expired:   item.IsExpired(c.clock),
+		Frequency: item.freqElement.Value.(*freqEntry).freq,
+	}
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		fe := e.Value.(*freqEntry)
+		if ex.NextVictim == nil {
+			for it := range fe.items {
+				ex.NextVictim = it.key
+				break
+			}
+		}
+		if e == item.freqElement {
+			break
+		}
+		ex.Rank += len(fe.items)
+	}
+	if ex.Rank == 0 {
+		ex.Reason = fmt.Sprintf("has the lowest frequency (%d), it is among the next entries to be evicted", ex.Frequency)
+	} else {
+		ex.Reason = fmt.Sprintf("%d entries were used less frequently", ex.Rank)
+	}
+	ex.Class = c.priorityOf(key)
+	if c.isPinned(key) {
+		ex.Pinned = true
+		ex.Reason = "pinned, it is exempt from eviction"
+	}
+	return ex
+}
+
 // Returns the number of items in the cache.
 func (c *LFUCache) Len() int {
 	c.mu.RLock()
@@ -248,12 +857,51 @@ func (c *LFUCache) Len() int {
 	return len(c.items)
 }
 
+// SetCapacity changes the maximum number of entries at runtime.
+func (c *LFUCache) SetCapacity(n int) {
+	if n <= 0 {
+		panic("gcache: size <= 0")
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.size = n
+	if l := len(c.items); l > n {
+		c.evict(l - n)
+	}
+}
+
+// shed evicts up to n entries following the eviction policy and returns how many were evicted.
+func (c *LFUCache) shed(n int) int {
+	c.mu.Lock()
+	defer c.unlock()
+	l := len(c.items)
+	c.evict(n)
+	return l - len(c.items)
+}
+
 // Completely clear the cache
 func (c *LFUCache) Purge() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if c.purgeEvict {
+		for key, item := range c.items {
+			c.evicted(key, item.value, item.expiration, item.accessExpiration)
+		}
+	}
 	c.init()
+	c.resetArena()
+	c.resetIndexes()
+}
+
+// Close cancels scheduled removals, waits for background loads to finish and drops all
+// entries.
+func (c *LFUCache) Close() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	c.Purge()
+	return nil
 }
 
 type freqEntry struct {
@@ -262,20 +910,17 @@ type freqEntry struct {
 }
 
 type lfuItem struct {
-	key         interface{}
-	value       interface{}
-	freqElement *list.Element
-	expiration  *time.Time
+	key              interface{}
+	value            interface{}
+	freqElement      *list.Element
+	expiration       *time.Time
+	accessExpiration *time.Time
+	delta            time.Duration // time it took to load the value
+	refreshAt        *time.Time    // when to reload the value in the background
+	created          time.Time
 }
 
 // returns boolean value whether this item is expired or not.
-func (it *lfuItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := time.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+func (it *lfuItem) IsExpired(clock Clock) bool {
+	return isExpired(clock, it.expiration, it.accessExpiration) || released(it.value)
 }
@@ -0,0 +1,65 @@
+package gcache
+
+import (
+	"math"
+	"time"
+)
+
+// lifetimeBuckets is the number of buckets of the entry lifetime histogram.
+// Bucket i counts lifetimes up to 2^(i/2) milliseconds, so that the histogram
+// spans from a millisecond to over a month with a relative error below 42%.
+const lifetimeBuckets = 64
+
+func lifetimeBucket(d time.Duration) int {
+	if d <= time.Millisecond {
+		return 0
+	}
+	i := int(math.Ceil(2 * math.Log2(float64(d)/float64(time.Millisecond))))
+	if i >= lifetimeBuckets {
+		return lifetimeBuckets - 1
+	}
+	return i
+}
+
+func lifetimeBound(i int) time.Duration {
+	return time.Duration(math.Pow(2, float64(i)/2) * float64(time.Millisecond))
+}
+
+// LifetimeCount returns the number of entries whose lifetime was recorded,
+// i.e. which were evicted or expired.
+func (s CacheStats) LifetimeCount() uint64 {
+	var n uint64
+	for _, c := range s.lifetimes {
+		n += c
+	}
+	return n
+}
+
+// LifetimePercentile returns the p-th percentile, from 0 to 100, of how long entries
+// stayed in the cache before they were evicted or expired, e.g. 99 for the p99.
+// Lifetimes are bucketed, so the value returned is an upper bound within 42% of the
+// actual one. Returns 0 if no entry was evicted or expired yet.
+func (s CacheStats) LifetimePercentile(p float64) time.Duration {
+	total := s.LifetimeCount()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var n uint64
+	for i, c := range s.lifetimes {
+		n += c
+		if n >= rank {
+			return lifetimeBound(i)
+		}
+	}
+	return lifetimeBound(lifetimeBuckets - 1)
+}
+
+// recordExpiration counts the expiration of an entry created at created.
+func (c *baseCache) recordExpiration(created time.Time) {
+	c.stats.IncrExpirationCount()
+	c.stats.RecordLifetime(c.clock.Now().Sub(created))
+}
@@ -0,0 +1,48 @@
+package gcache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// DefaultSlowLoadThreshold is how long a load may take before it is logged as slow.
+const DefaultSlowLoadThreshold = time.Second
+
+// Log evictions, loader errors, slow loads and scheduled removals to logger.
+// Evictions and scheduled removals are logged at debug level, loader errors and slow loads at warn level.
+func (cb *CacheBuilder) Logger(logger *slog.Logger) *CacheBuilder {
+	cb.logger = logger
+	return cb
+}
+
+// Log loads which take longer than d as slow, DefaultSlowLoadThreshold by default.
+func (cb *CacheBuilder) SlowLoadThreshold(d time.Duration) *CacheBuilder {
+	cb.slowLoadThreshold = d
+	return cb
+}
+
+// recordEviction counts and logs the eviction of key, which was created at created.
+func (c *baseCache) recordEviction(key interface{}, created time.Time) {
+	c.stats.IncrEvictionCount()
+	if c.stats.classes != nil {
+		c.stats.classes.of(key).IncrEvictionCount()
+	}
+	c.stats.RecordLifetime(c.clock.Now().Sub(created))
+	if c.logger != nil {
+		c.logger.Debug("gcache: evicted", "key", key)
+	}
+}
+
+// logLoad logs a load of key which took elapsed and failed with err, if any.
+// KeyNotFoundError is not logged as a failure.
+func (c *baseCache) logLoad(key interface{}, elapsed time.Duration, err error) {
+	if c.logger == nil {
+		return
+	}
+	if err != nil && err != KeyNotFoundError {
+		c.logger.Warn("gcache: load failed", "key", key, "elapsed", elapsed, "error", err)
+	}
+	if elapsed > c.slowLoadThreshold {
+		c.logger.Warn("gcache: slow load", "key", key, "elapsed", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+package gcache
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer collects log output from several goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *logBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.String()
+}
+
+func newTestLogger() (*slog.Logger, *logBuffer) {
+	lb := &logBuffer{}
+	return slog.New(slog.NewTextHandler(lb, &slog.HandlerOptions{Level: slog.LevelDebug})), lb
+}
+
+func TestLoggerEvictions(t *testing.T) {
+	for _, builder := range cacheBuilders(1) {
+		logger, lb := newTestLogger()
+		gc := builder.Logger(logger).Build()
+		gc.Set("a", 1)
+		gc.Set("b", 2)
+		if out := lb.String(); !strings.Contains(out, `msg="gcache: evicted" key=a`) {
+			t.Errorf("%T: eviction not logged: %q", gc, out)
+		}
+	}
+}
+
+func TestLoggerLoads(t *testing.T) {
+	loadErr := errors.New("backend down")
+	logger, lb := newTestLogger()
+	gc := New(10).LRU().
+		Logger(logger).
+		SlowLoadThreshold(time.Millisecond).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			switch key {
+			case "slow":
+				time.Sleep(5 * time.Millisecond)
+				return key, nil
+			case "missing":
+				return nil, KeyNotFoundError
+			}
+			return nil, loadErr
+		}).
+		Build()
+
+	gc.Get("slow")
+	gc.Get("broken")
+	gc.Get("missing")
+
+	out := lb.String()
+	if !strings.Contains(out, `level=WARN msg="gcache: slow load" key=slow`) {
+		t.Errorf("slow load not logged: %q", out)
+	}
+	if !strings.Contains(out, `level=WARN msg="gcache: load failed" key=broken`) {
+		t.Errorf("load error not logged: %q", out)
+	}
+	if strings.Contains(out, "key=missing") {
+		t.Errorf("KeyNotFoundError should not be logged: %q", out)
+	}
+}
+
+func TestLoggerScheduledRemovals(t *testing.T) {
+	logger, lb := newTestLogger()
+	gc := New(10).LRU().Logger(logger).Build()
+	gc.Set("a", 1)
+	gc.RemoveAfter("a", time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(lb.String(), `msg="gcache: scheduled removal" key=a removed=true`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduled removal not logged: %q", lb.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlowLoadThresholdValidation(t *testing.T) {
+	if _, err := New(10).SlowLoadThreshold(-time.Second).BuildE(); err == nil {
+		t.Error("negative SlowLoadThreshold should be rejected")
+	}
+}
@@ -1,37 +1,57 @@
 package gcache
 
 import (
-	"container/list"
+	"fmt"
+	"io"
 	"time"
 )
 
 // Discards the least recently used items first.
 type LRUCache struct {
 	baseCache
-	items     map[interface{}]*list.Element
-	evictList *list.List
+	items     map[interface{}]*lruElement[*lruItem]
+	evictList *lruList[*lruItem]
 }
 
 func newLRUCache(cb *CacheBuilder) *LRUCache {
 	c := &LRUCache{}
 	buildCache(&c.baseCache, cb)
+	c.reads = newReadBuffer(c.applyRead)
 
 	c.init()
 	c.loadGroup.cache = c
+	c.evictKey = c.remove
+	c.monitorMemory(c.shed, c.Len)
+	c.startAutoSnapshot(c.Export)
 	return c
 }
 
 func (c *LRUCache) init() {
-	c.evictList = list.New()
-	c.items = make(map[interface{}]*list.Element, c.size+1)
+	c.evictList = newLRUList[*lruItem]()
+	c.items = make(map[interface{}]*lruElement[*lruItem], c.size+1)
+	c.pinned = nil
+	c.priorities = nil
+	c.copies = nil // Snapshots in progress keep reading the previous entries
 }
 
 func (c *LRUCache) set(key, value interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	c.written(key)
+	value, err := c.encode(key, value)
+	if err != nil {
+		c.remove(key)
+		return nil, err
+	}
+
 	// Check for existing item
 	var item *lruItem
 	if it, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(it)
-		item = it.Value.(*lruItem)
+		item = it.Value
+		c.updated(key, item.value, value)
+		c.release(key, item.value)
 		item.value = value
 	} else {
 		// Verify size not exceeded
@@ -39,20 +59,21 @@ func (c *LRUCache) set(key, value interface{}) (interface{}, error) {
 			c.evict(1)
 		}
 		item = &lruItem{
-			key:   key,
-			value: value,
+			key:     key,
+			value:   value,
+			created: c.clock.Now(),
 		}
 		c.items[key] = c.evictList.PushFront(item)
 	}
 
 	if c.expiration != nil {
-		t := time.Now().Add(*c.expiration)
+		t := c.clock.Now().Add(c.jitter(*c.expiration))
 		item.expiration = &t
 	}
+	item.accessExpiration = c.newAccessExpiration()
+	item.refreshAt = c.newRefreshAt()
 
-	if c.addedFunc != nil {
-		(*c.addedFunc)(key, value)
-	}
+	c.added(key, value)
 
 	return item, nil
 }
@@ -60,10 +81,23 @@ func (c *LRUCache) set(key, value interface{}) (interface{}, error) {
 // set a new key-value pair
 func (c *LRUCache) Set(key, value interface{}) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.set(key, value)
 }
 
+// SetWithExpire sets a new key-value pair which expires after expiration,
+// instead of the Expiration of the cache.
+func (c *LRUCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	it, err := c.set(key, value)
+	if err != nil {
+		return
+	}
+	t := c.clock.Now().Add(expiration)
+	it.(*lruItem).expiration = &t
+}
+
 // Get a value from cache pool using key if it exists.
 // If it dose not exists key and has LoaderFunc,
 // generate a value using `LoaderFunc` method returns value.
@@ -75,6 +109,12 @@ func (c *LRUCache) Get(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// GetAsync returns a channel which receives the result of Get for key once it is
+// available, without waiting for the LoaderFunc.
+func (c *LRUCache) GetAsync(key interface{}) <-chan Result {
+	return c.getAsync(key, c.getValue, c.getWithLoader)
+}
+
 // Get a value from cache pool using key if it exists.
 // If it dose not exists key, returns KeyNotFoundError.
 // And send a request which refresh value for specified key if cache object has LoaderFunc.
@@ -86,78 +126,441 @@ func (c *LRUCache) GetIFPresent(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating the eviction policy or loading it.
+func (c *LRUCache) Has(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ent, ok := c.items[key]
+	return ok && !ent.Value.IsExpired(c.clock)
+}
+
+// GetMulti returns the values of all keys which are cached or can be loaded.
+func (c *LRUCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	return c.getMulti(keys, c.getValue, c.getWithLoader, c.setLoaded)
+}
+
+// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+// concurrency loads at a time, e.g. to fill the cache before taking traffic.
+func (c *LRUCache) Warm(keys []interface{}, concurrency int) error {
+	return c.warm(keys, concurrency, c.Has, c.getWithLoader)
+}
+
 func (c *LRUCache) get(key interface{}, onLoad bool) (interface{}, error) {
 	c.mu.RLock()
 	item, ok := c.items[key]
+	fresh := ok && !item.Value.IsExpired(c.clock)
 	c.mu.RUnlock()
+	if fresh {
+		c.recordRead(item)
+		if !onLoad {
+			c.stats.recordHit(key)
+		}
+		return item.Value, nil
+	}
 
 	if ok {
-		it := item.Value.(*lruItem)
-		if !it.IsExpired(nil) {
+		it := item.Value
+		if !c.keepStale(it.expiration, it.accessExpiration) {
 			c.mu.Lock()
-			defer c.mu.Unlock()
-			c.evictList.MoveToFront(item)
-			if !onLoad {
-				c.stats.IncrHitCount()
+			// buffered hits may have pushed back the access expiration
+			c.reads.drain()
+			if c.items[key] == item {
+				if !it.IsExpired(c.clock) {
+					c.applyRead(item, c.newAccessExpiration())
+					c.unlock()
+					if !onLoad {
+						c.stats.recordHit(key)
+					}
+					return it, nil
+				}
+				c.expire(it.created, func() { c.removeElement(item) })
 			}
-			return it, nil
+			c.unlock()
 		}
-		c.mu.Lock()
-		c.removeElement(item)
-		c.mu.Unlock()
 	}
 	if !onLoad {
-		c.stats.IncrMissCount()
+		c.stats.recordMiss(key)
+	}
+	return nil, KeyNotFoundError
+}
+
+// lookup returns the stored value of key for the load group, without counting
+// a hit or miss. Expired entries are misses, and are left in place.
+func (c *LRUCache) lookup(key interface{}) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ent, ok := c.items[key]; ok && !ent.Value.IsExpired(c.clock) {
+		return ent.Value.value, nil
 	}
 	return nil, KeyNotFoundError
 }
 
 func (c *LRUCache) getValue(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
 	it, err := c.get(key, false)
 	if err != nil {
+		c.missed(key)
 		return nil, err
 	}
-	return it.(*lruItem).value, nil
+	item := it.(*lruItem)
+	c.mu.RLock()
+	v, expiration, delta, refreshAt := item.value, item.expiration, item.delta, item.refreshAt
+	c.mu.RUnlock()
+	if c.xfetchBeta > 0 || c.refreshesAfterWrite() {
+		c.refreshEarly(key, expiration, delta, c.setLoaded)
+		c.refreshAfterWrite(key, refreshAt, c.setLoaded)
+	}
+	return c.decode(key, v)
+}
+
+// Do executes fn once for all the concurrent calls with the same key, which wait for
+// it and share its result, the way concurrent loads of a key share one call of the
+// LoaderFunc.
+func (c *LRUCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return c.doShared(key, fn)
+	}
+	v, err := c.doCached(key, fn, c.setLoaded)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(key, v)
 }
 
 func (c *LRUCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderFunc == nil {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if c.loaderExpireFunc == nil {
 		return nil, KeyNotFoundError
 	}
-	it, _, err := c.load(key, func(v interface{}, e error) (interface{}, error) {
-		if e == nil {
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			return c.set(key, v)
+	v, _, err := c.load(key, c.setLoaded, isWait)
+	if err != nil {
+		if err != KeyNotFoundError {
+			if v, ok := c.stale(key); ok {
+				return v, nil
+			}
 		}
-		return nil, e
-	}, isWait)
+		return nil, err
+	}
+	return c.decode(key, v)
+}
+
+// stale returns the value of an expired entry which StaleIfError still allows to serve.
+func (c *LRUCache) stale(key interface{}) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ent, ok := c.items[key]; ok {
+		it := ent.Value
+		if c.keepStale(it.expiration, it.accessExpiration) {
+			return c.decoded(key, it.value)
+		}
+	}
+	return nil, false
+}
+
+// stores a loaded value along with its expiration and the time it took to load
+func (c *LRUCache) setLoaded(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if ent, ok := c.items[key]; c.superseded(key) && ok && !ent.Value.IsExpired(c.clock) {
+		// the key was set while it was loading, keep the newer value
+		return ent.Value.value, nil
+	}
+	c.refreshing = true
+	it, err := c.set(key, value)
+	c.refreshing = false
 	if err != nil {
 		return nil, err
 	}
-	return it.(*lruItem).value, nil
+	item := it.(*lruItem)
+	if ttl != nil {
+		t := c.clock.Now().Add(*ttl)
+		item.expiration = &t
+	}
+	item.delta = elapsed
+	return item.value, nil
+}
+
+// peek returns the value for key if it is present and not expired,
+// without updating recency or statistics.
+func (c *LRUCache) peek(key interface{}) (interface{}, bool) {
+	if v, ok := c.stored(key); ok {
+		return c.decoded(key, v)
+	}
+	return nil, false
+}
+
+// stored returns the stored form of the value of key, if it is present.
+func (c *LRUCache) stored(key interface{}) (interface{}, bool) {
+	if ent, ok := c.items[key]; ok {
+		it := ent.Value
+		if !it.IsExpired(c.clock) {
+			return it.value, true
+		}
+	}
+	return nil, false
 }
 
 // evict removes the oldest item from the cache.
 func (c *LRUCache) evict(count int) {
-	for i := 0; i < count; i++ {
-		ent := c.evictList.Back()
-		if ent == nil {
-			return
-		} else {
-			c.removeElement(ent)
+	c.reads.drain()
+	i := 0
+	c.evictByClass(func() bool {
+		for ent := c.evictList.Back(); ent != nil && i < count; {
+			prev := ent.Prev()
+			if it := ent.Value; c.evictable(it.key) {
+				c.removeElement(ent)
+				c.recordEviction(it.key, it.created)
+				i++
+			}
+			ent = prev
 		}
+		return i >= count
+	})
+}
+
+// applyRead updates the recency of an entry which was read,
+// unless it was removed since.
+func (c *LRUCache) applyRead(entry interface{}, accessExpiration *time.Time) {
+	ent := entry.(*lruElement[*lruItem])
+	if c.items[ent.Value.key] != ent {
+		return
 	}
+	c.evictList.MoveToFront(ent)
+	it := ent.Value
+	it.accessExpiration = laterExpiration(it.accessExpiration, accessExpiration)
 }
 
 // Removes the provided key from the cache.
 func (c *LRUCache) Remove(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
 	return c.remove(key)
 }
 
+// RemoveAll removes the provided keys from the cache under a single lock acquisition
+// and returns how many of them were in the cache.
+func (c *LRUCache) RemoveAll(keys ...interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.removeKeys(keys, c.remove)
+}
+
+// GetOrSet returns the existing value for the key if present.
+func (c *LRUCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if ent, ok := c.items[key]; ok {
+		it := ent.Value
+		if !it.IsExpired(c.clock) {
+			if v, ok := c.decoded(key, it.value); ok {
+				c.evictList.MoveToFront(ent)
+				it.accessExpiration = c.newAccessExpiration()
+				return v, true
+			}
+		}
+	}
+	c.set(key, value)
+	return value, false
+}
+
+// Update atomically replaces the value for key with the result of fn,
+// which receives the current value and whether it exists.
+func (c *LRUCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	current, exists := c.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	_, err = c.set(key, value)
+	return err
+}
+
+// Increment atomically adds delta to the integer stored under key and returns the result.
+// int, int64 and uint64 values keep their type; a missing key is created holding delta.
+func (c *LRUCache) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, delta)
+}
+
+// Decrement atomically subtracts delta from the integer stored under key and returns the result.
+func (c *LRUCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, -delta)
+}
+
+// Touch resets the expiration of key without reading its value and marks it as recently used.
+// The expiration is restarted with ttl if given, or with the default expiration otherwise.
+func (c *LRUCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	it := ent.Value
+	if it.IsExpired(c.clock) {
+		return false
+	}
+	it.expiration = c.touchedExpiration(it.expiration, ttl)
+	it.accessExpiration = c.newAccessExpiration()
+	c.evictList.MoveToFront(ent)
+	return true
+}
+
+// Pin exempts key from capacity eviction until it is unpinned.
+func (c *LRUCache) Pin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.peek(key); !ok {
+		return false
+	}
+	c.pin(key)
+	return true
+}
+
+// Unpin makes key evictable again.
+func (c *LRUCache) Unpin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.unpin(key)
+}
+
+// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+func (c *LRUCache) SetWithPriority(key, value interface{}, priority Priority) {
+	c.mu.Lock()
+	defer c.unlock()
+	if _, err := c.set(key, value); err == nil {
+		c.prioritize(key, priority)
+	}
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the cache is equal to old.
+func (c *LRUCache) CompareAndSwap(key, old, new interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	_, err := c.set(key, new)
+	return err == nil
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (c *LRUCache) CompareAndDelete(key, old interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	return c.remove(key)
+}
+
+// GetAndRemove removes the provided key from the cache and returns its value.
+func (c *LRUCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := ent.Value
+	if it.IsExpired(c.clock) {
+		c.expire(it.created, func() { c.removeElement(ent) })
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, ok := c.decoded(key, it.value)
+	c.removeElement(ent)
+	return value, ok
+}
+
+// RemoveGet removes the provided key from the cache and returns the value it held, so
+// that the value can be cleaned up.
+func (c *LRUCache) RemoveGet(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, _ := c.decoded(key, ent.Value.value)
+	c.removeElement(ent)
+	return value, true
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (c *LRUCache) RemoveAt(key interface{}, t time.Time) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, t.Sub(c.clock.Now()), c.Remove)
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (c *LRUCache) RemoveAfter(key interface{}, d time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, d, c.Remove)
+}
+
 func (c *LRUCache) remove(key interface{}) bool {
 	if ent, ok := c.items[key]; ok {
 		c.removeElement(ent)
@@ -166,44 +569,243 @@ func (c *LRUCache) remove(key interface{}) bool {
 	return false
 }
 
-func (c *LRUCache) removeElement(e *list.Element) {
+func (c *LRUCache) removeElement(e *lruElement[*lruItem]) {
 	c.evictList.Remove(e)
-	entry := e.Value.(*lruItem)
+	entry := e.Value
 	delete(c.items, entry.key)
-	if c.evictedFunc != nil {
-		entry := e.Value.(*lruItem)
-		(*c.evictedFunc)(entry.key, entry.value)
-	}
+	c.forget(entry.key)
+	c.release(entry.key, entry.value)
+	c.evicted(entry.key, entry.value, entry.expiration, entry.accessExpiration)
 }
 
-// Returns a slice of the keys in the cache.
+// Returns a slice of the unexpired keys in the cache.
 func (c *LRUCache) Keys() []interface{} {
+	return c.keys(true)
+}
+
+// KeysIncludingExpired returns a slice of the keys in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than Keys.
+func (c *LRUCache) KeysIncludingExpired() []interface{} {
+	return c.keys(false)
+}
+
+func (c *LRUCache) keys(checkExpired bool) []interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).Keys()
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	keys := make([]interface{}, len(c.items))
-	i := 0
-	for k := range c.items {
-		keys[i] = k
-		i++
+	keys := make([]interface{}, 0, len(c.items))
+	for k, ent := range c.items {
+		if checkExpired && ent.Value.IsExpired(c.clock) {
+			continue
+		}
+		keys = append(keys, k)
 	}
-
 	return keys
 }
 
-// Returns all key-value pairs in the cache.
+// KeysSorted returns the unexpired keys in the order given by less.
+func (c *LRUCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(c.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys which start with prefix, in order.
+func (c *LRUCache) KeysWithPrefix(prefix string) []interface{} {
+	return c.keysWithPrefix(prefix, c.Keys, c.Has)
+}
+
+// GetByIndex returns the unexpired entries whose attribute in the named Index is attr.
+func (c *LRUCache) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getByIndex(name, attr, c.peek)
+}
+
+// RemoveByIndex removes the entries whose attribute in the named Index is attr
+// under a single lock acquisition, and returns how many were removed.
+func (c *LRUCache) RemoveByIndex(name string, attr interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.removeByIndex(name, attr, c.remove)
+}
+
+// Namespace returns a view of the cache which keeps its keys apart from
+// the keys of the other namespaces and of the cache itself.
+func (c *LRUCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(c, name)
+}
+
+// Returns all unexpired key-value pairs in the cache.
 func (c *LRUCache) GetALL() map[interface{}]interface{} {
+	return c.all(true)
+}
+
+// GetALLIncludingExpired returns all key-value pairs in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than GetALL.
+func (c *LRUCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.all(false)
+}
+
+func (c *LRUCache) all(checkExpired bool) map[interface{}]interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).GetALL()
+	}
+	return c.getALL(checkExpired)
+}
+
+func (c *LRUCache) getALL(checkExpired bool) map[interface{}]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	m := make(map[interface{}]interface{})
 	for k, v := range c.items {
-		m[k] = v.Value.(*lruItem).value
+		it := v.Value
+		if checkExpired && it.IsExpired(c.clock) {
+			continue
+		}
+		if value, ok := c.decoded(k, it.value); ok {
+			m[k] = value
+		}
 	}
 
 	return m
 }
 
+// Snapshot returns a consistent copy of the unexpired entries, as of when it is called.
+func (c *LRUCache) Snapshot() map[interface{}]interface{} {
+	return c.consistentCopy(func() ([]interface{}, func(interface{}) (interface{}, bool)) {
+		items := c.items
+		keys := make([]interface{}, 0, len(items))
+		for k, ent := range items {
+			if !ent.Value.IsExpired(c.clock) {
+				keys = append(keys, k)
+			}
+		}
+		return keys, func(key interface{}) (interface{}, bool) {
+			ent, ok := items[key]
+			if !ok {
+				return nil, false
+			}
+			return c.decoded(key, ent.Value.value)
+		}
+	})
+}
+
+// Export writes the unexpired entries, with their expiration, to w with codec.
+func (c *LRUCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(c, w, codec)
+}
+
+// Import sets the entries written by Export which have not expired yet,
+// restoring their expiration.
+func (c *LRUCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(c, r, codec)
+}
+
+func (c *LRUCache) entries() []SnapshotEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]SnapshotEntry, 0, len(c.items))
+	for k, ent := range c.items {
+		item := ent.Value
+		if item.IsExpired(c.clock) {
+			continue
+		}
+		if v, ok := c.decoded(k, item.value); ok {
+			entries = append(entries, SnapshotEntry{Key: k, Value: v, Expiration: item.expiration})
+		}
+	}
+	return entries
+}
+
+func (c *LRUCache) importEntries(entries []SnapshotEntry) {
+	c.restore(entries, c.Set, c.Touch)
+}
+
+// Dump writes the entries of the cache with their age, hits and time to live to w in
+// format, for debugging.
+func (c *LRUCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(c, w, format)
+}
+
+// Watch returns a channel receiving the changes of key. See Watcher.
+func (c *LRUCache) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return c.watch(key, key)
+}
+
+func (c *LRUCache) dumpEntries() ([]dumpEntry, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]dumpEntry, 0, len(c.items))
+	for k, ent := range c.items {
+		item := ent.Value
+		entries = append(entries, c.newDumpEntry(k, item.value, item.created, item.expiration, item.accessExpiration))
+	}
+	return entries, c.dumpLimit
+}
+
+// Verify checks the internal invariants of the cache and returns an InvariantError
+// describing the first one which does not hold.
+func (c *LRUCache) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.evictList.Len() != len(c.items) {
+		return violated("eviction list has %d entries but the cache has %d", c.evictList.Len(), len(c.items))
+	}
+	for e := c.evictList.Front(); e != nil; e = e.Next() {
+		item := e.Value
+		if c.items[item.key] != e {
+			return violated("key %v of the eviction list maps to another element", item.key)
+		}
+	}
+	return c.verifyIndexes(func(key interface{}) bool {
+		_, ok := c.items[key]
+		return ok
+	})
+}
+
+// Explain reports the position of key in the eviction order.
+// The least recently used entry is evicted first.
+func (c *LRUCache) Explain(key interface{}) EvictionExplanation {
+	if c.checkKey(key) != nil {
+		return notPresentExplanation(key)
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.reads.drain()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return notPresentExplanation(key)
+	}
+	ex := EvictionExplanation{
+		Key:        key,
+		Present:    true,
+		Expired:    ent.Value.IsExpired(c.clock),
+		NextVictim: c.evictList.Back().Value.key,
+	}
+	for e := c.evictList.Back(); e != ent; e = e.Prev() {
+		ex.Rank++
+	}
+	ex.Recency = c.evictList.Len() - 1 - ex.Rank
+	if ex.Rank == 0 {
+		ex.Reason = "least recently used entry, it is evicted next"
+	} else {
+		ex.Reason = fmt.Sprintf("%d entries were used less recently", ex.Rank)
+	}
+	ex.Class = c.priorityOf(key)
+	if c.isPinned(key) {
+		ex.Pinned = true
+		ex.Reason = "pinned, it is exempt from eviction"
+	}
+	return ex
+}
+
 // Returns the number of items in the cache.
 func (c *LRUCache) Len() int {
 	c.mu.RLock()
@@ -212,28 +814,65 @@ func (c *LRUCache) Len() int {
 	return len(c.items)
 }
 
+// SetCapacity changes the maximum number of entries at runtime.
+func (c *LRUCache) SetCapacity(n int) {
+	if n <= 0 {
+		panic("gcache: size <= 0")
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.size = n
+	if l := c.evictList.Len(); l > n {
+		c.evict(l - n)
+	}
+}
+
+// shed evicts up to n entries following the eviction policy and returns how many were evicted.
+func (c *LRUCache) shed(n int) int {
+	c.mu.Lock()
+	defer c.unlock()
+	l := c.evictList.Len()
+	c.evict(n)
+	return l - c.evictList.Len()
+}
+
 // Completely clear the cache
 func (c *LRUCache) Purge() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if c.purgeEvict {
+		for key, ent := range c.items {
+			it := ent.Value
+			c.evicted(key, it.value, it.expiration, it.accessExpiration)
+		}
+	}
 	c.init()
+	c.resetArena()
+	c.resetIndexes()
+}
+
+// Close cancels scheduled removals, waits for background loads to finish and drops all
+// entries.
+func (c *LRUCache) Close() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	c.Purge()
+	return nil
 }
 
 type lruItem struct {
-	key        interface{}
-	value      interface{}
-	expiration *time.Time
+	key              interface{}
+	value            interface{}
+	expiration       *time.Time
+	accessExpiration *time.Time
+	delta            time.Duration // time it took to load the value
+	refreshAt        *time.Time    // when to reload the value in the background
+	created          time.Time
 }
 
 // returns boolean value whether this item is expired or not.
-func (it *lruItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := time.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+func (it *lruItem) IsExpired(clock Clock) bool {
+	return isExpired(clock, it.expiration, it.accessExpiration) || released(it.value)
 }
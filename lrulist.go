@@ -0,0 +1,114 @@
+package gcache
+
+// lruList is the recency list of LRUCache and KeyedCache, from the most
+// to the least recently used entry. Unlike a container/list.List, it holds
+// values of type V without boxing them, and its elements can be reused.
+type lruList[V any] struct {
+	root lruElement[V] // root.next is the front, root.prev the back of the list
+	len  int
+}
+
+// lruElement is an element of an lruList.
+type lruElement[V any] struct {
+	Value      V
+	prev, next *lruElement[V]
+	list       *lruList[V]
+}
+
+func newLRUList[V any]() *lruList[V] {
+	return new(lruList[V]).Init()
+}
+
+// Init empties the list.
+func (l *lruList[V]) Init() *lruList[V] {
+	l.root.prev, l.root.next = &l.root, &l.root
+	l.len = 0
+	return l
+}
+
+// Len returns the number of elements of the list.
+func (l *lruList[V]) Len() int {
+	return l.len
+}
+
+// Front returns the most recently used element, or nil if the list is empty.
+func (l *lruList[V]) Front() *lruElement[V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// Back returns the least recently used element, or nil if the list is empty.
+func (l *lruList[V]) Back() *lruElement[V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// Next returns the next less recently used element, or nil.
+func (e *lruElement[V]) Next() *lruElement[V] {
+	if n := e.next; e.list != nil && n != &e.list.root {
+		return n
+	}
+	return nil
+}
+
+// Prev returns the next more recently used element, or nil.
+func (e *lruElement[V]) Prev() *lruElement[V] {
+	if p := e.prev; e.list != nil && p != &e.list.root {
+		return p
+	}
+	return nil
+}
+
+// PushFront inserts v at the front of the list and returns its element.
+func (l *lruList[V]) PushFront(v V) *lruElement[V] {
+	e := &lruElement[V]{Value: v}
+	l.insertAfter(e, &l.root)
+	return e
+}
+
+// PushBack inserts v at the back of the list and returns its element.
+func (l *lruList[V]) PushBack(v V) *lruElement[V] {
+	e := &lruElement[V]{Value: v}
+	l.insertAfter(e, l.root.prev)
+	return e
+}
+
+// PushElementFront inserts e, which was removed from the list, at its front,
+// so that removed elements can be reused without allocating.
+func (l *lruList[V]) PushElementFront(e *lruElement[V]) {
+	l.insertAfter(e, &l.root)
+}
+
+// MoveToFront moves e to the front of the list, if it is in the list.
+func (l *lruList[V]) MoveToFront(e *lruElement[V]) {
+	if e.list != l || l.root.next == e {
+		return
+	}
+	l.unlink(e)
+	l.insertAfter(e, &l.root)
+}
+
+// Remove removes e from the list, if it is in the list.
+func (l *lruList[V]) Remove(e *lruElement[V]) {
+	if e.list == l {
+		l.unlink(e)
+	}
+}
+
+func (l *lruList[V]) insertAfter(e, at *lruElement[V]) {
+	e.prev, e.next = at, at.next
+	at.next.prev = e
+	at.next = e
+	e.list = l
+	l.len++
+}
+
+func (l *lruList[V]) unlink(e *lruElement[V]) {
+	e.prev.next, e.next.prev = e.next, e.prev
+	e.prev, e.next, e.list = nil, nil, nil
+	l.len--
+}
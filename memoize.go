@@ -0,0 +1,78 @@
+package gcache
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoizedFunc is a function whose results Memoize caches by key.
+type MemoizedFunc func(ctx context.Context, key interface{}) (interface{}, error)
+
+// memoCall is an in-flight call of a memoized function.
+type memoCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// Memoize returns fn with its results cached in c, which should have no LoaderFunc.
+// Concurrent calls for a key which is not cached share a single call of fn, made
+// with the context of the first caller; the others stop waiting once their own
+// context is done. Results expire with the Expiration of c and are counted in its
+// stats, errors are not cached, and a panic of fn fails with a LoaderPanicError.
+func Memoize(c Cache, fn MemoizedFunc) MemoizedFunc {
+	var (
+		mu    sync.Mutex
+		calls = make(map[interface{}]*memoCall)
+	)
+	return func(ctx context.Context, key interface{}) (interface{}, error) {
+		if v, err := c.GetIFPresent(key); err == nil {
+			return v, nil
+		}
+		mu.Lock()
+		if cl, ok := calls[key]; ok {
+			mu.Unlock()
+			select {
+			case <-cl.done:
+				return cl.val, cl.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		cl := &memoCall{done: make(chan struct{})}
+		calls[key] = cl
+		mu.Unlock()
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					cl.val, cl.err = nil, &LoaderPanicError{Key: key, Value: r}
+				}
+			}()
+			cl.val, cl.err = fn(ctx, key)
+		}()
+		if cl.err == nil {
+			c.Set(key, cl.val)
+		}
+		mu.Lock()
+		delete(calls, key)
+		mu.Unlock()
+		close(cl.done)
+		return cl.val, cl.err
+	}
+}
+
+// MemoizeOf is Memoize for functions with typed keys and values.
+func MemoizeOf[K comparable, V any](c Cache, fn func(ctx context.Context, key K) (V, error)) func(ctx context.Context, key K) (V, error) {
+	memoized := Memoize(c, func(ctx context.Context, key interface{}) (interface{}, error) {
+		return fn(ctx, key.(K))
+	})
+	return func(ctx context.Context, key K) (V, error) {
+		v, err := memoized(ctx, key)
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		return v.(V), nil
+	}
+}
@@ -0,0 +1,98 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	cache := New(8).LRU().Build()
+	var calls int32
+	release := make(chan struct{})
+	square := Memoize(cache, func(ctx context.Context, key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return key.(int) * key.(int), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := square(context.Background(), 3); err != nil || v != 9 {
+				t.Errorf("square(3) = %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if v, err := square(context.Background(), 3); err != nil || v != 9 {
+		t.Errorf("square(3) = %v, %v", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("the function was called %v times; want 1", n)
+	}
+	if cache.HitCount() == 0 || cache.MissCount() == 0 {
+		t.Errorf("hits and misses not counted, %v and %v", cache.HitCount(), cache.MissCount())
+	}
+}
+
+func TestMemoizeErrors(t *testing.T) {
+	cache := New(8).LRU().Build()
+	failure := errors.New("failed")
+	calls := 0
+	f := Memoize(cache, func(ctx context.Context, key interface{}) (interface{}, error) {
+		calls++
+		if key == "panic" {
+			panic("boom")
+		}
+		return nil, failure
+	})
+	f(context.Background(), "a")
+	if _, err := f(context.Background(), "a"); err != failure || calls != 2 {
+		t.Errorf("f() = %v after %v calls; errors should not be cached", err, calls)
+	}
+	if _, err := f(context.Background(), "panic"); err == nil {
+		t.Error("a panic was not returned as an error")
+	} else if _, ok := err.(*LoaderPanicError); !ok {
+		t.Errorf("f() = %v; want a LoaderPanicError", err)
+	}
+}
+
+func TestMemoizeContext(t *testing.T) {
+	cache := New(8).LRU().Build()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	f := Memoize(cache, func(ctx context.Context, key interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+	go f(context.Background(), "a")
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f(ctx, "a"); err != context.Canceled {
+		t.Errorf("f() = %v; want the error of the canceled context", err)
+	}
+	close(release)
+}
+
+func TestMemoizeOf(t *testing.T) {
+	cache := New(8).LRU().Build()
+	length := MemoizeOf(cache, func(ctx context.Context, s string) (int, error) {
+		return len(s), nil
+	})
+	if n, err := length(context.Background(), "four"); err != nil || n != 4 {
+		t.Errorf("length() = %v, %v", n, err)
+	}
+	if !cache.Has("four") {
+		t.Error("the result was not cached")
+	}
+}
@@ -0,0 +1,77 @@
+package gcache
+
+import (
+	"runtime"
+	"time"
+)
+
+// DefaultMemoryCheckInterval is how often memory usage is checked against the MemoryLimit.
+const DefaultMemoryCheckInterval = time.Second
+
+// memoryShedFraction is the fraction of the entries evicted at each check
+// while memory usage is above the limit.
+const memoryShedFraction = 0.1
+
+// MemoryGauge reports the memory usage, in bytes, which is compared against the MemoryLimit.
+type MemoryGauge func() uint64
+
+// HeapAlloc is the default MemoryGauge, it reports the bytes of allocated heap objects.
+// It calls runtime.ReadMemStats, which briefly stops the world.
+func HeapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// Evict entries while the memory usage reported by the MemoryGauge exceeds limit bytes.
+// Memory usage is checked every DefaultMemoryCheckInterval unless MemoryCheckInterval is set,
+// and a tenth of the entries are evicted, following the cache's policy, at every check
+// until it is back under the limit. The monitor stops when the cache is closed.
+func (cb *CacheBuilder) MemoryLimit(limit uint64) *CacheBuilder {
+	cb.memoryLimit = limit
+	return cb
+}
+
+// Measure memory usage with gauge rather than HeapAlloc, e.g. the cgroup usage of the container.
+func (cb *CacheBuilder) MemoryGauge(gauge MemoryGauge) *CacheBuilder {
+	cb.memoryGauge = gauge
+	return cb
+}
+
+// Check memory usage against the MemoryLimit every interval.
+func (cb *CacheBuilder) MemoryCheckInterval(interval time.Duration) *CacheBuilder {
+	cb.memoryInterval = interval
+	return cb
+}
+
+// monitorMemory starts evicting entries with shed whenever memory usage exceeds the limit,
+// if there is one. shed evicts up to n entries and returns how many it evicted.
+func (c *baseCache) monitorMemory(shed func(n int) int, length func() int) {
+	if c.memoryLimit == 0 {
+		return
+	}
+	c.memoryStop = make(chan struct{})
+	go func() {
+		ticker := c.clock.NewTicker(c.memoryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.memoryStop:
+				return
+			case <-ticker.C():
+			}
+			usage := c.memoryGauge()
+			if usage <= c.memoryLimit {
+				continue
+			}
+			n := int(float64(length()) * memoryShedFraction)
+			if n < 1 {
+				n = 1
+			}
+			evicted := shed(n)
+			if c.logger != nil {
+				c.logger.Warn("gcache: memory limit exceeded", "usage", usage, "limit", c.memoryLimit, "evicted", evicted)
+			}
+		}
+	}()
+}
@@ -0,0 +1,44 @@
+package gcache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	for _, builder := range cacheBuilders(100) {
+		var usage uint64
+		gc := builder.
+			MemoryLimit(1000).
+			MemoryGauge(func() uint64 { return atomic.LoadUint64(&usage) }).
+			MemoryCheckInterval(time.Millisecond).
+			Build()
+		for i := 0; i < 100; i++ {
+			gc.Set(i, i)
+		}
+		gc.(Pinner).Pin(0)
+
+		time.Sleep(20 * time.Millisecond)
+		if gc.Len() != 100 {
+			t.Errorf("%T: entries evicted under the memory limit, %v left", gc, gc.Len())
+		}
+
+		atomic.StoreUint64(&usage, 2000)
+		deadline := time.Now().Add(time.Second)
+		for gc.Len() > 50 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		atomic.StoreUint64(&usage, 0)
+		if gc.Len() > 50 {
+			t.Errorf("%T: %v entries left over the memory limit", gc, gc.Len())
+		}
+		if _, err := gc.GetIFPresent(0); err != nil {
+			t.Errorf("%T: pinned entry evicted", gc)
+		}
+		if gc.EvictionCount() == 0 {
+			t.Errorf("%T: evictions not counted", gc)
+		}
+		gc.Close()
+	}
+}
@@ -0,0 +1,371 @@
+package gcache
+
+import (
+	"io"
+	"time"
+)
+
+// NamespacedKey is the key under which a Namespace stores the entries of its view
+// in the shared cache. The LoaderFunc, callbacks and indexes of the shared cache
+// receive it in place of the key used with the Namespace.
+type NamespacedKey struct {
+	Namespace string
+	Key       interface{}
+}
+
+// NamespacedCache is a view of a shared cache which keeps its keys apart from
+// the keys of the other namespaces, so that several subsystems can share one
+// sized cache without colliding. It is returned by the Namespace method of a cache.
+//
+// Purge and Close only drop the entries of the namespace. Capacity, expiration
+// and statistics are those of the shared cache, which may cap the entries
+// of the namespace with a NamespaceQuota.
+type NamespacedCache struct {
+	statsAccessor
+	cache Cache
+	name  string
+}
+
+func newNamespacedCache(c Cache, name string) *NamespacedCache {
+	return &NamespacedCache{statsAccessor: c, cache: c, name: name}
+}
+
+// key returns the key of the shared cache for key.
+func (n *NamespacedCache) key(key interface{}) interface{} {
+	return NamespacedKey{Namespace: n.name, Key: key}
+}
+
+// owns returns the key of the namespace for a key of the shared cache,
+// and reports whether it belongs to the namespace.
+func (n *NamespacedCache) owns(key interface{}) (interface{}, bool) {
+	if nk, ok := key.(NamespacedKey); ok && nk.Namespace == n.name {
+		return nk.Key, true
+	}
+	return nil, false
+}
+
+func (n *NamespacedCache) keys(keys []interface{}) []interface{} {
+	nkeys := make([]interface{}, len(keys))
+	for i, key := range keys {
+		nkeys[i] = n.key(key)
+	}
+	return nkeys
+}
+
+// own returns the entries of m which belong to the namespace, under their keys in the namespace.
+func (n *NamespacedCache) own(m map[interface{}]interface{}) map[interface{}]interface{} {
+	owned := make(map[interface{}]interface{})
+	for key, v := range m {
+		if k, ok := n.owns(key); ok {
+			owned[k] = v
+		}
+	}
+	return owned
+}
+
+func (n *NamespacedCache) Set(key, value interface{}) {
+	n.cache.Set(n.key(key), value)
+}
+
+func (n *NamespacedCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	n.cache.SetWithExpire(n.key(key), value, expiration)
+}
+
+func (n *NamespacedCache) Get(key interface{}) (interface{}, error) {
+	return n.cache.Get(n.key(key))
+}
+
+func (n *NamespacedCache) GetAsync(key interface{}) <-chan Result {
+	return n.cache.GetAsync(n.key(key))
+}
+
+func (n *NamespacedCache) GetIFPresent(key interface{}) (interface{}, error) {
+	return n.cache.GetIFPresent(n.key(key))
+}
+
+func (n *NamespacedCache) Has(key interface{}) bool {
+	return n.cache.Has(n.key(key))
+}
+
+func (n *NamespacedCache) GetALL() map[interface{}]interface{} {
+	return n.own(n.cache.GetALL())
+}
+
+func (n *NamespacedCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return n.own(n.cache.GetALLIncludingExpired())
+}
+
+func (n *NamespacedCache) Snapshot() map[interface{}]interface{} {
+	return n.own(n.cache.Snapshot())
+}
+
+func (n *NamespacedCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(n, w, codec)
+}
+
+func (n *NamespacedCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(n, r, codec)
+}
+
+func (n *NamespacedCache) entries() []SnapshotEntry {
+	var entries []SnapshotEntry
+	for _, e := range n.cache.entries() {
+		if k, ok := n.owns(e.Key); ok {
+			e.Key = k
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (n *NamespacedCache) importEntries(entries []SnapshotEntry) {
+	nentries := make([]SnapshotEntry, len(entries))
+	for i, e := range entries {
+		e.Key = n.key(e.Key)
+		nentries[i] = e
+	}
+	n.cache.importEntries(nentries)
+}
+
+func (n *NamespacedCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	m, err := n.cache.GetMulti(n.keys(keys))
+	return n.own(m), err
+}
+
+func (n *NamespacedCache) Warm(keys []interface{}, concurrency int) error {
+	err := n.cache.Warm(n.keys(keys), concurrency)
+	if werr, ok := err.(*WarmError); ok {
+		errs := make(map[interface{}]error, len(werr.Errors))
+		for key, err := range werr.Errors {
+			if k, ok := n.owns(key); ok {
+				errs[k] = err
+			}
+		}
+		return &WarmError{Errors: errs}
+	}
+	return err
+}
+
+func (n *NamespacedCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	return n.cache.get(n.key(key), onLoad)
+}
+
+func (n *NamespacedCache) lookup(key interface{}) (interface{}, error) {
+	return n.cache.lookup(n.key(key))
+}
+
+func (n *NamespacedCache) Remove(key interface{}) bool {
+	return n.cache.Remove(n.key(key))
+}
+
+func (n *NamespacedCache) RemoveAll(keys ...interface{}) int {
+	return n.cache.RemoveAll(n.keys(keys)...)
+}
+
+func (n *NamespacedCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	return n.cache.GetAndRemove(n.key(key))
+}
+
+func (n *NamespacedCache) RemoveGet(key interface{}) (interface{}, bool) {
+	return n.cache.RemoveGet(n.key(key))
+}
+
+func (n *NamespacedCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	return n.cache.GetOrSet(n.key(key), value)
+}
+
+// CompareAndSwap returns false if the shared cache is not a Swapper.
+func (n *NamespacedCache) CompareAndSwap(key, old, new interface{}) bool {
+	s, ok := n.cache.(Swapper)
+	return ok && s.CompareAndSwap(n.key(key), old, new)
+}
+
+// CompareAndDelete returns false if the shared cache is not a Swapper.
+func (n *NamespacedCache) CompareAndDelete(key, old interface{}) bool {
+	s, ok := n.cache.(Swapper)
+	return ok && s.CompareAndDelete(n.key(key), old)
+}
+
+func (n *NamespacedCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	return n.cache.Update(n.key(key), fn)
+}
+
+func (n *NamespacedCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	return n.cache.Touch(n.key(key), ttl...)
+}
+
+// Pin returns false if the shared cache is not a Pinner.
+func (n *NamespacedCache) Pin(key interface{}) bool {
+	p, ok := n.cache.(Pinner)
+	return ok && p.Pin(n.key(key))
+}
+
+func (n *NamespacedCache) Unpin(key interface{}) bool {
+	p, ok := n.cache.(Pinner)
+	return ok && p.Unpin(n.key(key))
+}
+
+func (n *NamespacedCache) SetWithPriority(key, value interface{}, priority Priority) {
+	n.cache.SetWithPriority(n.key(key), value, priority)
+}
+
+func (n *NamespacedCache) RemoveAt(key interface{}, t time.Time) {
+	n.cache.RemoveAt(n.key(key), t)
+}
+
+func (n *NamespacedCache) RemoveAfter(key interface{}, d time.Duration) {
+	n.cache.RemoveAfter(n.key(key), d)
+}
+
+func (n *NamespacedCache) Increment(key interface{}, delta int64) (int64, error) {
+	return n.cache.Increment(n.key(key), delta)
+}
+
+func (n *NamespacedCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return n.cache.Decrement(n.key(key), delta)
+}
+
+// PurgeNamespace removes all the entries of the namespace, leaving the other namespaces alone.
+func (n *NamespacedCache) PurgeNamespace() {
+	var keys []interface{}
+	for _, key := range n.cache.KeysIncludingExpired() {
+		if _, ok := n.owns(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	n.cache.RemoveAll(keys...)
+}
+
+// Purge is PurgeNamespace.
+func (n *NamespacedCache) Purge() {
+	n.PurgeNamespace()
+}
+
+// SetCapacity changes the capacity of the shared cache.
+func (n *NamespacedCache) SetCapacity(size int) {
+	n.cache.SetCapacity(size)
+}
+
+// SetExpiration changes the expiration of the shared cache.
+func (n *NamespacedCache) SetExpiration(d time.Duration) {
+	n.cache.SetExpiration(d)
+}
+
+// SetRefreshAfterWrite changes the refresh interval of the shared cache.
+func (n *NamespacedCache) SetRefreshAfterWrite(d time.Duration) {
+	n.cache.SetRefreshAfterWrite(d)
+}
+
+func (n *NamespacedCache) Keys() []interface{} {
+	return n.ownKeys(n.cache.Keys())
+}
+
+func (n *NamespacedCache) KeysIncludingExpired() []interface{} {
+	return n.ownKeys(n.cache.KeysIncludingExpired())
+}
+
+// ownKeys returns the keys of the namespace among the keys of the shared cache, without the namespace.
+func (n *NamespacedCache) ownKeys(shared []interface{}) []interface{} {
+	var keys []interface{}
+	for _, key := range shared {
+		if k, ok := n.owns(key); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (n *NamespacedCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(n.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys of the namespace which start with prefix, in order.
+// The OrderedKeys index of the shared cache does not cover namespaced keys, so all keys are filtered.
+func (n *NamespacedCache) KeysWithPrefix(prefix string) []interface{} {
+	return withPrefix(n.Keys(), prefix)
+}
+
+// GetByIndex returns no entries if the shared cache is not an Indexer.
+func (n *NamespacedCache) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	i, ok := n.cache.(Indexer)
+	if !ok {
+		return make(map[interface{}]interface{})
+	}
+	return n.own(i.GetByIndex(name, attr))
+}
+
+// RemoveByIndex removes the entries of the namespace whose attribute in the named Index is attr,
+// and returns how many were removed.
+func (n *NamespacedCache) RemoveByIndex(name string, attr interface{}) int {
+	var keys []interface{}
+	for key := range n.GetByIndex(name, attr) {
+		keys = append(keys, key)
+	}
+	return n.RemoveAll(keys...)
+}
+
+// Len returns the number of entries in the namespace.
+func (n *NamespacedCache) Len() int {
+	return len(n.KeysIncludingExpired())
+}
+
+// Explain explains the entry of key as if it was not present if the shared cache is not an Explainer.
+func (n *NamespacedCache) Explain(key interface{}) EvictionExplanation {
+	e, ok := n.cache.(Explainer)
+	if !ok {
+		return notPresentExplanation(key)
+	}
+	ex := e.Explain(n.key(key))
+	ex.Key = key
+	if k, ok := n.owns(ex.NextVictim); ok {
+		ex.NextVictim = k
+	}
+	return ex
+}
+
+// Do executes fn once for the concurrent calls with the same key in the namespace.
+func (n *NamespacedCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	return n.cache.Do(n.key(key), fn, cacheResult)
+}
+
+// Watch returns a channel receiving the changes of key in the namespace.
+func (n *NamespacedCache) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return n.watch(key, key)
+}
+
+func (n *NamespacedCache) watch(key, as interface{}) (<-chan ValueChange, CancelFunc) {
+	return n.cache.watch(n.key(key), as)
+}
+
+// Dump writes the entries of the namespace with their metadata to w in format.
+func (n *NamespacedCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(n, w, format)
+}
+
+func (n *NamespacedCache) dumpEntries() ([]dumpEntry, int) {
+	all, limit := n.cache.dumpEntries()
+	var entries []dumpEntry
+	for _, e := range all {
+		if k, ok := n.owns(e.key); ok {
+			e.key = k
+			entries = append(entries, e)
+		}
+	}
+	return entries, limit
+}
+
+// Verify checks the internal invariants of the shared cache.
+func (n *NamespacedCache) Verify() error {
+	return n.cache.Verify()
+}
+
+// Namespace returns a view of the namespace which is itself namespaced.
+func (n *NamespacedCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(n, name)
+}
+
+// Close purges the namespace. The shared cache is left open.
+func (n *NamespacedCache) Close() error {
+	n.PurgeNamespace()
+	return nil
+}
@@ -0,0 +1,66 @@
+package gcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		users := cache.Namespace("users")
+		orders := cache.Namespace("orders")
+
+		users.Set("1", "alice")
+		orders.Set("1", "book")
+		cache.Set("1", "shared")
+
+		if v, err := users.Get("1"); err != nil || v != "alice" {
+			t.Errorf("%T: users.Get() = %v, %v", cache, v, err)
+		}
+		if v, err := orders.Get("1"); err != nil || v != "book" {
+			t.Errorf("%T: orders.Get() = %v, %v", cache, v, err)
+		}
+		if v, err := cache.Get("1"); err != nil || v != "shared" {
+			t.Errorf("%T: Get() = %v, %v", cache, v, err)
+		}
+		if v, err := cache.Get(NamespacedKey{"users", "1"}); err != nil || v != "alice" {
+			t.Errorf("%T: Get() = %v, %v for the namespaced key", cache, v, err)
+		}
+
+		users.Set("2", "bob")
+		if keys := users.KeysWithPrefix(""); !reflect.DeepEqual(keys, []interface{}{"1", "2"}) {
+			t.Errorf("%T: users.Keys() = %v", cache, keys)
+		}
+		if m := users.GetALL(); len(m) != 2 || m["2"] != "bob" {
+			t.Errorf("%T: users.GetALL() = %v", cache, m)
+		}
+		if l := users.Len(); l != 2 {
+			t.Errorf("%T: users.Len() = %v; want 2", cache, l)
+		}
+
+		users.PurgeNamespace()
+		if l := users.Len(); l != 0 {
+			t.Errorf("%T: users.Len() = %v after PurgeNamespace", cache, l)
+		}
+		if l := cache.Len(); l != 2 || !orders.Has("1") || !cache.Has("1") {
+			t.Errorf("%T: PurgeNamespace removed the entries of other namespaces, %v", cache, cache.KeysIncludingExpired())
+		}
+	}
+}
+
+func TestNamespaceLoader(t *testing.T) {
+	cache := New(8).LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			nk := key.(NamespacedKey)
+			return nk.Namespace + "/" + nk.Key.(string), nil
+		}).
+		Build()
+	if v, err := cache.Namespace("users").Get("1"); err != nil || v != "users/1" {
+		t.Errorf("Get() = %v, %v", v, err)
+	}
+	if !cache.Has(NamespacedKey{"users", "1"}) {
+		t.Errorf("unexpected keys %v", cache.KeysIncludingExpired())
+	}
+}
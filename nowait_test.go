@@ -0,0 +1,61 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoWaitLoad(t *testing.T) {
+	release := make(chan struct{})
+	loader := func(key interface{}) (interface{}, error) {
+		<-release
+		return "loaded", nil
+	}
+	for _, builder := range cacheBuilders(8) {
+		cache := builder.LoaderFunc(loader).NoWaitLoad().Build()
+		done := make(chan interface{})
+		go func() {
+			v, _ := cache.Get("a")
+			done <- v
+		}()
+		time.Sleep(10 * time.Millisecond)
+		if v, err := cache.Get("a"); err != KeyLoadingError {
+			t.Errorf("%T: Get() = %v, %v during a load, want KeyLoadingError", cache, v, err)
+		}
+		release <- struct{}{}
+		if v := <-done; v != "loaded" {
+			t.Errorf("%T: the loading Get() = %v", cache, v)
+		}
+		if v, err := cache.Get("a"); v != "loaded" || err != nil {
+			t.Errorf("%T: Get() = %v, %v after the load", cache, v, err)
+		}
+	}
+}
+
+func TestNoWaitLoadServesStale(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	release := make(chan struct{})
+	cache := New(8).LRU().Clock(clock).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			<-release
+			return "fresh", nil
+		}).
+		Expiration(time.Second).
+		StaleIfError(time.Minute).
+		NoWaitLoad().
+		Build()
+	cache.Set("a", "stale")
+	clock.Advance(2 * time.Second)
+
+	done := make(chan struct{})
+	go func() {
+		cache.Get("a")
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if v, err := cache.Get("a"); v != "stale" || err != nil {
+		t.Errorf("Get() = %v, %v during a load, want the stale value", v, err)
+	}
+	close(release)
+	<-done
+}
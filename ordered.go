@@ -0,0 +1,99 @@
+package gcache
+
+import (
+	"sort"
+	"strings"
+)
+
+// OrderedKeys maintains an index of the string keys of the cache in order,
+// so that KeysWithPrefix scans only the matching key range instead of all keys.
+// Keys of other types are not indexed.
+func (cb *CacheBuilder) OrderedKeys() *CacheBuilder {
+	cb.orderedKeys = true
+	return cb
+}
+
+// keyIndex keeps the string keys of the cache sorted.
+type keyIndex struct {
+	keys []string
+}
+
+// add indexes key if it is a string and not indexed yet (not thread safe).
+func (ki *keyIndex) add(key interface{}) {
+	s, ok := key.(string)
+	if !ok {
+		return
+	}
+	i := sort.SearchStrings(ki.keys, s)
+	if i < len(ki.keys) && ki.keys[i] == s {
+		return
+	}
+	ki.keys = append(ki.keys, "")
+	copy(ki.keys[i+1:], ki.keys[i:])
+	ki.keys[i] = s
+}
+
+// remove drops key from the index (not thread safe).
+func (ki *keyIndex) remove(key interface{}) {
+	s, ok := key.(string)
+	if !ok {
+		return
+	}
+	i := sort.SearchStrings(ki.keys, s)
+	if i < len(ki.keys) && ki.keys[i] == s {
+		ki.keys = append(ki.keys[:i], ki.keys[i+1:]...)
+	}
+}
+
+// withPrefix returns the indexed keys which start with prefix, in order (not thread safe).
+func (ki *keyIndex) withPrefix(prefix string) []interface{} {
+	var keys []interface{}
+	for i := sort.SearchStrings(ki.keys, prefix); i < len(ki.keys) && strings.HasPrefix(ki.keys[i], prefix); i++ {
+		keys = append(keys, ki.keys[i])
+	}
+	return keys
+}
+
+// keysWithPrefix returns the unexpired string keys which start with prefix, in order.
+// It reads the ordered index if there is one, checking each key with has,
+// and filters all the keys otherwise.
+func (c *baseCache) keysWithPrefix(prefix string, all func() []interface{}, has func(interface{}) bool) []interface{} {
+	if c.ordered == nil {
+		return withPrefix(all(), prefix)
+	}
+
+	c.mu.RLock()
+	indexed := c.ordered.withPrefix(prefix)
+	c.mu.RUnlock()
+	matched := make([]interface{}, 0, len(indexed))
+	for _, key := range indexed {
+		if has(key) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// withPrefix returns the string keys which start with prefix, in order.
+func withPrefix(keys []interface{}, prefix string) []interface{} {
+	var matched []string
+	for _, key := range keys {
+		if s, ok := key.(string); ok && strings.HasPrefix(s, prefix) {
+			matched = append(matched, s)
+		}
+	}
+	sort.Strings(matched)
+	sorted := make([]interface{}, len(matched))
+	for i, key := range matched {
+		sorted[i] = key
+	}
+	return sorted
+}
+
+// sortKeys sorts keys in place with less and returns them.
+func sortKeys(keys []interface{}, less func(a, b interface{}) bool) []interface{} {
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+	return keys
+}
@@ -0,0 +1,68 @@
+package gcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeysWithPrefix(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		for _, ordered := range []bool{false, true} {
+			if ordered {
+				builder.OrderedKeys()
+			}
+			cache := builder.Build()
+			for _, key := range []string{"user:3", "order:1", "user:1", "user:2", "users"} {
+				cache.Set(key, key)
+			}
+			cache.Set(1, 1)
+
+			want := []interface{}{"user:1", "user:2", "user:3"}
+			if keys := cache.KeysWithPrefix("user:"); !reflect.DeepEqual(keys, want) {
+				t.Errorf("%T: KeysWithPrefix() = %v; want %v", cache, keys, want)
+			}
+			cache.Remove("user:2")
+			want = []interface{}{"user:1", "user:3", "users"}
+			if keys := cache.KeysWithPrefix("user"); !reflect.DeepEqual(keys, want) {
+				t.Errorf("%T: KeysWithPrefix() = %v; want %v", cache, keys, want)
+			}
+			if keys := cache.KeysWithPrefix("none"); len(keys) != 0 {
+				t.Errorf("%T: KeysWithPrefix() = %v; want none", cache, keys)
+			}
+			cache.Purge()
+			if keys := cache.KeysWithPrefix(""); len(keys) != 0 {
+				t.Errorf("%T: KeysWithPrefix() = %v after Purge", cache, keys)
+			}
+		}
+	}
+}
+
+func TestKeysWithPrefixEviction(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := New(2).LRU().OrderedKeys().Clock(clock).Expiration(time.Second).Build()
+	cache.Set("a:1", 1)
+	cache.Set("a:2", 2)
+	cache.Set("a:3", 3)
+	if keys := cache.KeysWithPrefix("a:"); !reflect.DeepEqual(keys, []interface{}{"a:2", "a:3"}) {
+		t.Errorf("KeysWithPrefix() = %v after eviction", keys)
+	}
+	clock.Advance(2 * time.Second)
+	if keys := cache.KeysWithPrefix("a:"); len(keys) != 0 {
+		t.Errorf("KeysWithPrefix() = %v after expiration", keys)
+	}
+}
+
+func TestKeysSorted(t *testing.T) {
+	cache := New(8).Simple().Build()
+	for i := 0; i < 5; i++ {
+		cache.Set(i, i)
+	}
+	keys := cache.KeysSorted(func(a, b interface{}) bool {
+		return a.(int) > b.(int)
+	})
+	if want := []interface{}{4, 3, 2, 1, 0}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("KeysSorted() = %v; want %v", keys, want)
+	}
+}
@@ -0,0 +1,250 @@
+// Package otelgcache instruments gcache caches with OpenTelemetry traces and metrics.
+package otelgcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/britt/gcache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/britt/gcache/otelgcache"
+
+type config struct {
+	name           string
+	strategy       string
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithName sets the cache.name attribute.
+func WithName(name string) Option {
+	return func(cfg *config) {
+		cfg.name = name
+	}
+}
+
+// WithStrategy sets the cache.strategy attribute, which Wrap otherwise derives from the cache type.
+func WithStrategy(strategy string) Option {
+	return func(cfg *config) {
+		cfg.strategy = strategy
+	}
+}
+
+// WithTracerProvider sets the tracer provider, the global one is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider, the global one is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(cfg *config) {
+		cfg.meterProvider = mp
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func (cfg *config) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("cache.name", cfg.name),
+		attribute.String("cache.strategy", cfg.strategy),
+	}
+}
+
+// strategyOf returns the eviction strategy of c.
+func strategyOf(c gcache.Cache) string {
+	switch c.(type) {
+	case *gcache.SimpleCache:
+		return gcache.TYPE_SIMPLE
+	case *gcache.LRUCache:
+		return gcache.TYPE_LRU
+	case *gcache.LFUCache:
+		return gcache.TYPE_LFU
+	case *gcache.ARC:
+		return gcache.TYPE_ARC
+	case *gcache.ScoreCache:
+		return gcache.TYPE_SCORE
+	default:
+		return "unknown"
+	}
+}
+
+type cache struct {
+	gcache.Cache
+	tracer       trace.Tracer
+	attrs        []attribute.KeyValue
+	registration metric.Registration
+}
+
+// Wrap returns c instrumented with a span for every Get, GetIFPresent and GetMulti,
+// with a hit or miss event, and with observable metrics for its hits, misses,
+// evictions, hit ratio and length. Close unregisters the metrics.
+// To trace loader executions and record their duration, wrap the LoaderFunc with LoaderFunc.
+func Wrap(c gcache.Cache, opts ...Option) (gcache.Cache, error) {
+	cfg := newConfig(opts)
+	if cfg.strategy == "" {
+		cfg.strategy = strategyOf(c)
+	}
+	w := &cache{
+		Cache:  c,
+		tracer: cfg.tracerProvider.Tracer(instrumentationName),
+		attrs:  cfg.attributes(),
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	hits, err := meter.Int64ObservableCounter("gcache.hits", metric.WithDescription("Number of cache hits"))
+	if err != nil {
+		return nil, err
+	}
+	misses, err := meter.Int64ObservableCounter("gcache.misses", metric.WithDescription("Number of cache misses"))
+	if err != nil {
+		return nil, err
+	}
+	evictions, err := meter.Int64ObservableCounter("gcache.evictions", metric.WithDescription("Number of entries evicted to make room for others"))
+	if err != nil {
+		return nil, err
+	}
+	hitRatio, err := meter.Float64ObservableGauge("gcache.hit_ratio", metric.WithDescription("Rate of lookups which were hits"))
+	if err != nil {
+		return nil, err
+	}
+	length, err := meter.Int64ObservableGauge("gcache.length", metric.WithDescription("Number of entries in the cache"))
+	if err != nil {
+		return nil, err
+	}
+	attrs := metric.WithAttributes(w.attrs...)
+	w.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(hits, int64(c.HitCount()), attrs)
+		o.ObserveInt64(misses, int64(c.MissCount()), attrs)
+		o.ObserveInt64(evictions, int64(c.EvictionCount()), attrs)
+		o.ObserveFloat64(hitRatio, c.HitRate(), attrs)
+		o.ObserveInt64(length, int64(c.Len()), attrs)
+		return nil
+	}, hits, misses, evictions, hitRatio, length)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+var errProbe = errors.New("otelgcache: probe")
+
+// present reports whether key is cached without counting a lookup or loading it.
+// Update leaves the cache unchanged when its function fails.
+func (c *cache) present(key interface{}) bool {
+	var present bool
+	c.Cache.Update(key, func(_ interface{}, exists bool) (interface{}, error) {
+		present = exists
+		return nil, errProbe
+	})
+	return present
+}
+
+func (c *cache) start(name string) trace.Span {
+	_, span := c.tracer.Start(context.Background(), name, trace.WithAttributes(c.attrs...))
+	return span
+}
+
+func end(span trace.Span, hit bool, err error) {
+	if hit {
+		span.AddEvent("hit")
+	} else {
+		span.AddEvent("miss")
+	}
+	if err != nil && err != gcache.KeyNotFoundError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *cache) Get(key interface{}) (interface{}, error) {
+	span := c.start("gcache.Get")
+	hit := c.present(key)
+	v, err := c.Cache.Get(key)
+	end(span, hit && err == nil, err)
+	return v, err
+}
+
+func (c *cache) GetIFPresent(key interface{}) (interface{}, error) {
+	span := c.start("gcache.GetIFPresent")
+	v, err := c.Cache.GetIFPresent(key)
+	end(span, err == nil, err)
+	return v, err
+}
+
+func (c *cache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	span := c.start("gcache.GetMulti")
+	hits := 0
+	for _, key := range keys {
+		if c.present(key) {
+			hits++
+		}
+	}
+	values, err := c.Cache.GetMulti(keys)
+	span.SetAttributes(
+		attribute.Int("cache.keys", len(keys)),
+		attribute.Int("cache.hits", hits),
+		attribute.Int("cache.found", len(values)),
+	)
+	end(span, hits == len(keys), err)
+	return values, err
+}
+
+func (c *cache) Close() error {
+	c.registration.Unregister()
+	return c.Cache.Close()
+}
+
+// LoaderFunc returns f instrumented with a span for every execution and
+// a gcache.load.duration histogram of the time it takes, in seconds.
+func LoaderFunc(f gcache.LoaderFunc, opts ...Option) (gcache.LoaderFunc, error) {
+	cfg := newConfig(opts)
+	tracer := cfg.tracerProvider.Tracer(instrumentationName)
+	duration, err := cfg.meterProvider.Meter(instrumentationName).Float64Histogram(
+		"gcache.load.duration",
+		metric.WithDescription("Duration of loader executions"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	attrs := cfg.attributes()
+	return func(key interface{}) (interface{}, error) {
+		ctx, span := tracer.Start(context.Background(), "gcache.load", trace.WithAttributes(attrs...))
+		start := time.Now()
+		v, err := f(key)
+		status := "ok"
+		if err != nil {
+			status = "error"
+			if err != gcache.KeyNotFoundError {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+		duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(append(attrs, attribute.String("status", status))...))
+		span.End()
+		return v, err
+	}, nil
+}
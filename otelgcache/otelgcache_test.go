@@ -0,0 +1,173 @@
+package otelgcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/britt/gcache"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func events(span sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, ev := range span.Events() {
+		names = append(names, ev.Name)
+	}
+	return names
+}
+
+func TestWrapSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	c, err := Wrap(gcache.New(10).LRU().Build(), WithName("users"), WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", 1)
+	if _, err := c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetIFPresent("b"); err != gcache.KeyNotFoundError {
+		t.Fatalf("GetIFPresent: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%v spans != 2", len(spans))
+	}
+	for i, want := range []struct{ name, event string }{{"gcache.Get", "hit"}, {"gcache.GetIFPresent", "miss"}} {
+		if spans[i].Name() != want.name {
+			t.Errorf("span %v: %v != %v", i, spans[i].Name(), want.name)
+		}
+		if evs := events(spans[i]); len(evs) != 1 || evs[0] != want.event {
+			t.Errorf("span %v: events %v != [%v]", i, evs, want.event)
+		}
+		attrs := map[string]string{}
+		for _, kv := range spans[i].Attributes() {
+			attrs[string(kv.Key)] = kv.Value.AsString()
+		}
+		if attrs["cache.name"] != "users" || attrs["cache.strategy"] != gcache.TYPE_LRU {
+			t.Errorf("span %v: attributes %v", i, attrs)
+		}
+	}
+}
+
+func TestWrapGetMissWithLoader(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	loader, err := LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key, nil
+	}, WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Wrap(gcache.New(10).ARC().LoaderFunc(loader).Build(), WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("%v spans != 3", len(spans))
+	}
+	if spans[0].Name() != "gcache.load" {
+		t.Errorf("%v != gcache.load", spans[0].Name())
+	}
+	if evs := events(spans[1]); len(evs) != 1 || evs[0] != "miss" {
+		t.Errorf("first Get: events %v != [miss]", evs)
+	}
+	if evs := events(spans[2]); len(evs) != 1 || evs[0] != "hit" {
+		t.Errorf("second Get: events %v != [hit]", evs)
+	}
+	if c.Len() != 1 {
+		t.Errorf("%v != 1", c.Len())
+	}
+}
+
+func TestLoaderFuncError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	loadErr := errors.New("backend down")
+	loader, err := LoaderFunc(func(key interface{}) (interface{}, error) {
+		return nil, loadErr
+	}, WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader("a"); err != loadErr {
+		t.Fatalf("%v != %v", err, loadErr)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Description != loadErr.Error() {
+		t.Errorf("error not recorded on load span")
+	}
+}
+
+func TestWrapMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	loader, err := LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key, nil
+	}, WithMeterProvider(mp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Wrap(gcache.New(1).LRU().LoaderFunc(loader).Build(), WithMeterProvider(mp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				got[m.Name] = float64(data.DataPoints[0].Value)
+			case metricdata.Gauge[int64]:
+				got[m.Name] = float64(data.DataPoints[0].Value)
+			case metricdata.Gauge[float64]:
+				got[m.Name] = data.DataPoints[0].Value
+			case metricdata.Histogram[float64]:
+				got[m.Name] = float64(data.DataPoints[0].Count)
+			}
+		}
+	}
+	want := map[string]float64{
+		"gcache.hits":          1,
+		"gcache.misses":        2,
+		"gcache.evictions":     1,
+		"gcache.hit_ratio":     1.0 / 3,
+		"gcache.length":        1,
+		"gcache.load.duration": 2,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%v: %v != %v", name, got[name], v)
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
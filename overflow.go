@@ -0,0 +1,121 @@
+package gcache
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+)
+
+// Store []byte and string values larger than thresholdBytes in files of dir, the
+// default directory for temporary files if empty, keeping only a DiskValue in memory,
+// so that large blobs can be served without weighing on memory.
+// Values are written after SerializeFunc, so combined with it any value can overflow,
+// and are read back from disk before they are returned. Files are deleted once their
+// entry is overwritten, removed or purged. Values which cannot be written are not stored.
+func (cb *CacheBuilder) OverflowToDisk(dir string, thresholdBytes int) *CacheBuilder {
+	cb.overflowDir = dir
+	cb.overflowThreshold = thresholdBytes
+	cb.overflow = true
+	return cb
+}
+
+// DiskValue is how a value overflowed to disk is stored in the cache.
+// ScoringFunc, WeightingFunc, AddedFunc and EvictedFunc receive it in place of the value.
+type DiskValue struct {
+	Path     string
+	Size     int  // of the value
+	isString bool // the value was a string rather than a []byte
+}
+
+// diskOverflow tracks the files of the values overflowed to disk.
+type diskOverflow struct {
+	dir       string
+	threshold int
+	mu        sync.Mutex
+	files     map[string]struct{}
+}
+
+func newDiskOverflow(dir string, threshold int) *diskOverflow {
+	return &diskOverflow{dir: dir, threshold: threshold, files: make(map[string]struct{})}
+}
+
+// write stores data in a new file and returns its path.
+func (o *diskOverflow) write(data []byte) (string, error) {
+	f, err := os.CreateTemp(o.dir, "gcache-")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	o.mu.Lock()
+	o.files[f.Name()] = struct{}{}
+	o.mu.Unlock()
+	return f.Name(), nil
+}
+
+func (o *diskOverflow) remove(path string) {
+	o.mu.Lock()
+	delete(o.files, path)
+	o.mu.Unlock()
+	os.Remove(path)
+}
+
+// reset deletes all the files.
+func (o *diskOverflow) reset() {
+	o.mu.Lock()
+	files := o.files
+	o.files = make(map[string]struct{})
+	o.mu.Unlock()
+	for path := range files {
+		os.Remove(path)
+	}
+}
+
+// spill returns value as a DiskValue if it is too large to be kept in memory.
+func (c *baseCache) spill(value interface{}) (interface{}, error) {
+	var data []byte
+	isString := false
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data, isString = []byte(v), true
+	default:
+		return value, nil
+	}
+	if len(data) <= c.overflow.threshold {
+		return value, nil
+	}
+	path, err := c.overflow.write(data)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskValue{Path: path, Size: len(data), isString: isString}, nil
+}
+
+// unspill returns the original form of a value returned by spill.
+func (c *baseCache) unspill(value interface{}) (interface{}, error) {
+	dv, ok := value.(*DiskValue)
+	if !ok {
+		return value, nil
+	}
+	data, err := os.ReadFile(dv.Path)
+	if err != nil {
+		return nil, err
+	}
+	if dv.isString {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+// sizeOfDiskValue is the memory footprint of dv, without its data.
+func sizeOfDiskValue(dv *DiskValue) int {
+	return int(unsafe.Sizeof(*dv)) + len(dv.Path)
+}
@@ -0,0 +1,58 @@
+package gcache
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func countFiles(t *testing.T, dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestOverflowToDisk(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		dir := t.TempDir()
+		var added interface{}
+		cache := builder.
+			OverflowToDisk(dir, 4).
+			AddedFunc(func(_, value interface{}) { added = value }).
+			Build()
+
+		cache.Set("small", "abc")
+		if _, ok := added.(*DiskValue); ok || countFiles(t, dir) != 0 {
+			t.Errorf("%T: a value under the threshold overflowed", cache)
+		}
+		cache.Set("large", "hello world")
+		if dv, ok := added.(*DiskValue); !ok || dv.Size != 11 || countFiles(t, dir) != 1 {
+			t.Errorf("%T: the large value did not overflow, stored as %#v", cache, added)
+		}
+		cache.Set("blob", []byte("large bytes"))
+		for key, want := range map[string]interface{}{"small": "abc", "large": "hello world"} {
+			if v, err := cache.Get(key); err != nil || v != want {
+				t.Errorf("%T: Get(%v) = %v, %v; want %v", cache, key, v, err, want)
+			}
+		}
+		if v, err := cache.Get("blob"); err != nil || !bytes.Equal(v.([]byte), []byte("large bytes")) {
+			t.Errorf("%T: Get(blob) = %v, %v", cache, v, err)
+		}
+
+		cache.Set("large", "hello again")
+		if n := countFiles(t, dir); n != 2 {
+			t.Errorf("%T: %v files after overwriting a value; want 2", cache, n)
+		}
+		cache.Remove("large")
+		if n := countFiles(t, dir); n != 1 {
+			t.Errorf("%T: %v files after removing a value; want 1", cache, n)
+		}
+		cache.Purge()
+		if n := countFiles(t, dir); n != 0 {
+			t.Errorf("%T: %v files after Purge; want 0", cache, n)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package peers turns gcache caches running in several processes into a
+// distributed read-through cache. The peers form a consistent hash ring, each key
+// is owned by one of them, and the Gets of the others are forwarded to the owner
+// over HTTP, so that every key is loaded and cached by a single process.
+package peers
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/britt/gcache"
+)
+
+// DefaultBasePath is the path under which an HTTPPool serves the keys of its peer.
+const DefaultBasePath = "/_gcache/"
+
+type config struct {
+	basePath    string
+	replicas    int
+	client      *http.Client
+	encodeValue func(interface{}) ([]byte, error)
+	decodeValue func([]byte) (interface{}, error)
+}
+
+// Option configures an HTTPPool.
+type Option func(*config)
+
+// WithBasePath sets the path under which the pool serves keys, DefaultBasePath by default.
+// All the peers must use the same path.
+func WithBasePath(path string) Option {
+	return func(cfg *config) {
+		cfg.basePath = path
+	}
+}
+
+// WithReplicas sets the number of points per peer on the ring, DefaultReplicas by default.
+// All the peers must use the same number.
+func WithReplicas(replicas int) Option {
+	return func(cfg *config) {
+		cfg.replicas = replicas
+	}
+}
+
+// WithClient sets the HTTP client which requests the other peers, http.DefaultClient by default.
+func WithClient(client *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = client
+	}
+}
+
+// WithValueCodec sets how values are sent between peers, gob by default,
+// which requires the concrete types of the values to be registered with gob.Register.
+func WithValueCodec(encode func(value interface{}) ([]byte, error), decode func([]byte) (interface{}, error)) Option {
+	return func(cfg *config) {
+		cfg.encodeValue = encode
+		cfg.decodeValue = decode
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		basePath:    DefaultBasePath,
+		client:      http.DefaultClient,
+		encodeValue: gobEncode,
+		decodeValue: gobDecode,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+func gobEncode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// PeerError is returned by Get when the owner of a key failed to return it.
+type PeerError struct {
+	Peer string
+	Err  error
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("peers: peer %v failed: %v", e.Peer, e.Err)
+}
+
+func (e *PeerError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPPool is the peer of a process in a pool of caches. It serves the keys it owns
+// to the other peers from its cache, and forwards the Gets of other keys to their owner.
+type HTTPPool struct {
+	self  string
+	cache gcache.Cache
+	cfg   *config
+
+	mu   sync.RWMutex
+	ring *Ring
+}
+
+// NewHTTPPool returns the peer at the base URL self, e.g. "http://10.0.0.1:8000",
+// serving the keys it owns from c. The pool must be served at self with an http.Server,
+// and given the URLs of all the peers with Set.
+func NewHTTPPool(self string, c gcache.Cache, opts ...Option) *HTTPPool {
+	p := &HTTPPool{self: self, cache: c, cfg: newConfig(opts)}
+	p.ring = NewRing(p.cfg.replicas)
+	return p
+}
+
+// Set replaces the peers of the pool with the base URLs peers, which should include self.
+func (p *HTTPPool) Set(peers ...string) {
+	ring := NewRing(p.cfg.replicas, peers...)
+	p.mu.Lock()
+	p.ring = ring
+	p.mu.Unlock()
+}
+
+// Owner returns the base URL of the peer which owns key, self if there are no peers.
+func (p *HTTPPool) Owner(key string) string {
+	p.mu.RLock()
+	owner := p.ring.Owner(key)
+	p.mu.RUnlock()
+	if owner == "" {
+		return p.self
+	}
+	return owner
+}
+
+// Get returns the value of key from the cache of its owner, which loads it if needed.
+// If the owner cannot be reached the key is loaded from the local cache instead,
+// and a PeerError is returned only if that fails too.
+func (p *HTTPPool) Get(key string) (interface{}, error) {
+	owner := p.Owner(key)
+	if owner == p.self {
+		return p.cache.Get(key)
+	}
+	v, err := p.fetch(owner, key)
+	if err == nil || err == gcache.KeyNotFoundError {
+		return v, err
+	}
+	if v, lerr := p.cache.Get(key); lerr == nil {
+		return v, nil
+	}
+	return nil, &PeerError{Peer: owner, Err: err}
+}
+
+// fetch requests the value of key from peer.
+func (p *HTTPPool) fetch(peer, key string) (interface{}, error) {
+	u := strings.TrimSuffix(peer, "/") + p.cfg.basePath + url.PathEscape(key)
+	resp, err := p.cfg.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return p.cfg.decodeValue(body)
+	case http.StatusNotFound:
+		return nil, gcache.KeyNotFoundError
+	default:
+		return nil, errors.New(strings.TrimSpace(string(body)))
+	}
+}
+
+// ServeHTTP serves the requests of the other peers for the keys this peer owns.
+// Keys are always read from the local cache, never forwarded.
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.EscapedPath(), p.cfg.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), p.cfg.basePath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v, err := p.cache.Get(key)
+	if err == gcache.KeyNotFoundError {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := p.cfg.encodeValue(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
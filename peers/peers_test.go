@@ -0,0 +1,104 @@
+package peers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/britt/gcache"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(0, "a", "b", "c")
+	owners := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		owners[ring.Owner(fmt.Sprint(i))]++
+	}
+	for _, peer := range []string{"a", "b", "c"} {
+		if owners[peer] < 100 {
+			t.Errorf("peer %v owns %v keys of 1000", peer, owners[peer])
+		}
+	}
+
+	smaller := NewRing(0, "a", "b")
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint(i)
+		if owner := ring.Owner(key); owner != "c" && smaller.Owner(key) != owner {
+			t.Errorf("key %v moved from %v to %v when c left", key, owner, smaller.Owner(key))
+		}
+	}
+	if owner := NewRing(0).Owner("a"); owner != "" {
+		t.Errorf("Owner() = %v for an empty ring", owner)
+	}
+}
+
+func TestHTTPPool(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		loads = make(map[string][]string) // keys loaded by each peer
+		pools []*HTTPPool
+		urls  []string
+	)
+	for i := 0; i < 3; i++ {
+		var pool *HTTPPool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pool.ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		self := srv.URL
+		cache := gcache.New(100).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+			if key == "missing" {
+				return nil, gcache.KeyNotFoundError
+			}
+			mu.Lock()
+			loads[self] = append(loads[self], key.(string))
+			mu.Unlock()
+			return "value of " + key.(string), nil
+		}).Build()
+		pool = NewHTTPPool(self, cache)
+		pools = append(pools, pool)
+		urls = append(urls, self)
+	}
+	for _, pool := range pools {
+		pool.Set(urls...)
+	}
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key/%v", i)
+		for _, pool := range pools {
+			if v, err := pool.Get(key); err != nil || v != "value of "+key {
+				t.Fatalf("Get(%v) = %v, %v", key, v, err)
+			}
+		}
+	}
+	total := 0
+	for peer, keys := range loads {
+		total += len(keys)
+		for _, key := range keys {
+			if owner := pools[0].Owner(key); owner != peer {
+				t.Errorf("%v loaded %v, which is owned by %v", peer, key, owner)
+			}
+		}
+	}
+	if total != 30 {
+		t.Errorf("%v loads for 30 keys", total)
+	}
+	for _, pool := range pools {
+		if _, err := pool.Get("missing"); err != gcache.KeyNotFoundError {
+			t.Errorf("Get() = %v for a missing key", err)
+		}
+	}
+}
+
+func TestHTTPPoolUnreachablePeer(t *testing.T) {
+	cache := gcache.New(10).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		return "local", nil
+	}).Build()
+	pool := NewHTTPPool("http://self", cache)
+	pool.Set("http://127.0.0.1:1")
+	if v, err := pool.Get("a"); err != nil || v != "local" {
+		t.Errorf("Get() = %v, %v; want the value loaded locally", v, err)
+	}
+}
@@ -0,0 +1,50 @@
+package peers
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the number of points each peer has on a Ring by default.
+const DefaultReplicas = 50
+
+// Ring assigns keys to peers by consistent hashing, so that adding or removing
+// a peer only moves the keys of that peer. Each peer is hashed at several points
+// of the ring to spread the keys evenly.
+type Ring struct {
+	replicas int
+	hashes   []uint32 // sorted
+	owners   map[uint32]string
+}
+
+// NewRing returns a Ring of peers with replicas points per peer,
+// DefaultReplicas if replicas is not positive.
+func NewRing(replicas int, peers ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	r := &Ring{replicas: replicas, owners: make(map[uint32]string)}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = peer
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Owner returns the peer which owns key, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
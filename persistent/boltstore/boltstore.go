@@ -0,0 +1,96 @@
+// Package boltstore implements the Store of the persistent package with BoltDB.
+package boltstore
+
+import (
+	"github.com/britt/gcache/persistent"
+	bolt "go.etcd.io/bbolt"
+)
+
+var defaultBucket = []byte("gcache")
+
+// Store keeps the entries of a persistent tier in a bucket of a BoltDB database.
+type Store struct {
+	db     *bolt.DB
+	bucket []byte
+	owned  bool // the database was opened by Open
+}
+
+// Open opens or creates the BoltDB database at path and returns a Store in its "gcache" bucket.
+// Closing the Store closes the database.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	s, err := New(db, string(defaultBucket))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// New returns a Store in the named bucket of db, creating the bucket if needed.
+// Closing the Store leaves db open.
+func New(db *bolt.DB, bucket string) (*Store, error) {
+	s := &Store{db: db, bucket: []byte(bucket)}
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(s.bucket).Get(key)
+		if v == nil {
+			return persistent.ErrNotFound
+		}
+		// v is only valid during the transaction
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	return value, err
+}
+
+func (s *Store) Put(key, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put(key, value)
+	})
+}
+
+func (s *Store) Delete(key []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete(key)
+	})
+}
+
+func (s *Store) Clear() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(s.bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(s.bucket)
+		return err
+	})
+}
+
+func (s *Store) ForEach(fn func(key, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(fn)
+	})
+}
+
+// Close closes the database if the Store opened it.
+func (s *Store) Close() error {
+	if s.owned {
+		return s.db.Close()
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package boltstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/britt/gcache"
+	"github.com/britt/gcache/persistent"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "gcache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Get([]byte("a")); err != persistent.ErrNotFound {
+		t.Errorf("Get() = %v; want ErrNotFound", err)
+	}
+	if err := s.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put([]byte("b"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Get([]byte("a")); err != nil || string(v) != "1" {
+		t.Errorf("Get() = %q, %v", v, err)
+	}
+	n := 0
+	if err := s.ForEach(func(key, value []byte) error { n++; return nil }); err != nil || n != 2 {
+		t.Errorf("ForEach() visited %v entries, %v", n, err)
+	}
+	if err := s.Delete([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete([]byte("a")); err != nil {
+		t.Errorf("Delete() of a missing key = %v", err)
+	}
+	if _, err := s.Get([]byte("a")); err != persistent.ErrNotFound {
+		t.Errorf("Get() = %v after Delete", err)
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get([]byte("b")); err != persistent.ErrNotFound {
+		t.Errorf("Get() = %v after Clear", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "gcache.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s, err := New(db, "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := persistent.Wrap(gcache.New(1).LRU(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Errorf("Get() = %v, %v; want the spilled value", v, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.View(func(*bolt.Tx) error { return nil }); err != nil {
+		t.Errorf("Close closed a database it did not open: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package persistent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirStore is a Store which keeps every value in a file of a directory,
+// named after the SHA-256 of its key.
+type DirStore struct {
+	dir string
+}
+
+// OpenDir returns a DirStore in dir, creating it if needed.
+func OpenDir(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (s *DirStore) path(key []byte) string {
+	sum := sha256.Sum256(key)
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *DirStore) Get(key []byte) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put writes value to a temporary file which is renamed over the previous one,
+// so that readers never see a partially written value.
+func (s *DirStore) Put(key, value []byte) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s *DirStore) Delete(key []byte) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *DirStore) Clear() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEach calls fn with the values of the directory, and the names of their files
+// in place of their keys, which are not kept.
+func (s *DirStore) ForEach(fn func(key, value []byte) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn([]byte(e.Name()), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
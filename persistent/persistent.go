@@ -0,0 +1,424 @@
+// Package persistent adds an on-disk overflow tier to gcache caches: the entries
+// evicted from memory spill to an embedded key-value store, and are reloaded from
+// it on a miss before the LoaderFunc is called.
+package persistent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+// ErrNotFound is returned by Store.Get for keys which are not stored.
+var ErrNotFound = errors.New("persistent: key not found")
+
+// Store is an embedded key-value store, such as the BoltDB one of the boltstore
+// package or a directory opened with OpenDir. It must be safe for concurrent use.
+type Store interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(key []byte) error
+	// Clear removes all the keys.
+	Clear() error
+	// ForEach calls fn with every key and value, stopping at the first error it returns.
+	// The key and value are only valid during the call.
+	ForEach(fn func(key, value []byte) error) error
+}
+
+type config struct {
+	encodeKey   func(interface{}) ([]byte, error)
+	encodeValue func(interface{}) ([]byte, error)
+	decodeValue func([]byte) (interface{}, error)
+	errorFunc   func(key interface{}, err error)
+	clock       gcache.Clock
+}
+
+// Option configures the overflow tier.
+type Option func(*config)
+
+// WithKeyEncoder sets how keys are encoded in the store, EncodeKey by default.
+// Distinct keys must have distinct encodings.
+func WithKeyEncoder(encode func(key interface{}) ([]byte, error)) Option {
+	return func(cfg *config) {
+		cfg.encodeKey = encode
+	}
+}
+
+// WithValueCodec sets how values are encoded in the store, gob by default,
+// which requires the concrete types of the values to be registered with gob.Register.
+// Keys are encoded with it too, so that GetALL can list the stored entries.
+func WithValueCodec(encode func(value interface{}) ([]byte, error), decode func([]byte) (interface{}, error)) Option {
+	return func(cfg *config) {
+		cfg.encodeValue = encode
+		cfg.decodeValue = decode
+	}
+}
+
+// WithErrorFunc sets a function called with the errors of the store and of the codecs,
+// which are otherwise ignored: an entry which fails to spill is lost, and one which
+// fails to be reloaded is treated as missing.
+func WithErrorFunc(f func(key interface{}, err error)) Option {
+	return func(cfg *config) {
+		cfg.errorFunc = f
+	}
+}
+
+// WithClock sets the clock which tells whether the stored entries expired,
+// which must be the Clock of the cache, gcache.RealClock by default.
+func WithClock(clock gcache.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = clock
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		encodeKey:   EncodeKey,
+		encodeValue: gobEncode,
+		decodeValue: gobDecode,
+		clock:       gcache.RealClock{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// EncodeKey encodes strings and byte slices as is, and other keys as their type
+// and value formatted with fmt, e.g. "int:42".
+func EncodeKey(key interface{}) ([]byte, error) {
+	switch k := key.(type) {
+	case string:
+		return append([]byte("string:"), k...), nil
+	case []byte:
+		return append([]byte("[]uint8:"), k...), nil
+	default:
+		return []byte(fmt.Sprintf("%T:%v", key, key)), nil
+	}
+}
+
+func gobEncode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type cache struct {
+	gcache.Cache
+	store Store
+	cfg   *config
+}
+
+// Wrap builds the cache of cb with an overflow tier in store. Entries evicted from
+// memory are written to store along with their expiration, and Get, GetIFPresent,
+// GetMulti, Warm and Has look keys up in store when they are not in memory, moving
+// the entries they find back into memory with the rest of their lifetime, while
+// GetALL includes the stored entries. Stored entries which expired are dropped.
+// Set and the removal methods remove the key from store, and Purge clears it.
+//
+// Wrap sets the EvictedEntryFunc of cb, its EvictedFunc and ExpiredFunc are still
+// called. Expired entries are not spilled. SCORE caches, which remove entries
+// rather than expire them, are not supported. Values are spilled in the form they
+// are stored in, so SerializeFunc, Compression and Bytes are not supported either.
+// The store is not closed with the cache.
+func Wrap(cb *gcache.CacheBuilder, store Store, opts ...Option) (gcache.Cache, error) {
+	w := &cache{store: store, cfg: newConfig(opts)}
+	c, err := cb.EvictedEntryFunc(w.spill).BuildE()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.(*gcache.ScoreCache); ok {
+		c.Close()
+		return nil, errors.New("persistent: SCORE caches are not supported")
+	}
+	w.Cache = c
+	return w, nil
+}
+
+func (c *cache) failed(key interface{}, err error) {
+	if c.cfg.errorFunc != nil {
+		c.cfg.errorFunc(key, err)
+	}
+}
+
+// spill writes an entry evicted from memory to the store.
+func (c *cache) spill(e gcache.SnapshotEntry) {
+	k, err := c.cfg.encodeKey(e.Key)
+	if err != nil {
+		c.failed(e.Key, err)
+		return
+	}
+	data, err := c.encodeRecord(e)
+	if err != nil {
+		c.failed(e.Key, err)
+		return
+	}
+	if err := c.store.Put(k, data); err != nil {
+		c.failed(e.Key, err)
+	}
+}
+
+// encodeRecord encodes an entry as it is stored: when it expires in Unix nanoseconds,
+// zero if it does not, then the length of its encoded key, its key and its value.
+func (c *cache) encodeRecord(e gcache.SnapshotEntry) ([]byte, error) {
+	k, err := c.cfg.encodeValue(e.Key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.cfg.encodeValue(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	var expiration int64
+	if e.Expiration != nil {
+		expiration = e.Expiration.UnixNano()
+	}
+	data := make([]byte, 8, 8+binary.MaxVarintLen64+len(k)+len(v))
+	binary.BigEndian.PutUint64(data, uint64(expiration))
+	data = binary.AppendUvarint(data, uint64(len(k)))
+	data = append(data, k...)
+	return append(data, v...), nil
+}
+
+var errCorrupt = errors.New("persistent: corrupt record")
+
+// decodeRecord decodes a record written by encodeRecord.
+func (c *cache) decodeRecord(data []byte) (gcache.SnapshotEntry, error) {
+	var e gcache.SnapshotEntry
+	if len(data) < 8 {
+		return e, errCorrupt
+	}
+	if expiration := int64(binary.BigEndian.Uint64(data)); expiration != 0 {
+		t := time.Unix(0, expiration)
+		e.Expiration = &t
+	}
+	n, size := binary.Uvarint(data[8:])
+	if size <= 0 || uint64(len(data)-8-size) < n {
+		return e, errCorrupt
+	}
+	k := data[8+size : 8+size+int(n)]
+	var err error
+	if e.Key, err = c.cfg.decodeValue(k); err != nil {
+		return e, err
+	}
+	e.Value, err = c.cfg.decodeValue(data[8+size+int(n):])
+	return e, err
+}
+
+// expired reports whether the stored entry e expired.
+func (c *cache) expired(e gcache.SnapshotEntry) bool {
+	return e.Expiration != nil && !e.Expiration.After(c.cfg.clock.Now())
+}
+
+// drop removes key from the store.
+func (c *cache) drop(key interface{}) {
+	k, err := c.cfg.encodeKey(key)
+	if err == nil {
+		err = c.store.Delete(k)
+	}
+	if err != nil {
+		c.failed(key, err)
+	}
+}
+
+// stored returns the entry of key in the store, dropping it if it expired.
+func (c *cache) stored(key interface{}) (gcache.SnapshotEntry, bool) {
+	k, err := c.cfg.encodeKey(key)
+	if err != nil {
+		c.failed(key, err)
+		return gcache.SnapshotEntry{}, false
+	}
+	data, err := c.store.Get(k)
+	if err == ErrNotFound {
+		return gcache.SnapshotEntry{}, false
+	}
+	if err == nil {
+		var e gcache.SnapshotEntry
+		if e, err = c.decodeRecord(data); err == nil {
+			if c.expired(e) {
+				c.drop(key)
+				return gcache.SnapshotEntry{}, false
+			}
+			return e, true
+		}
+	}
+	c.failed(key, err)
+	return gcache.SnapshotEntry{}, false
+}
+
+// reload moves the entry of key from the store back into memory, if it is there,
+// with the rest of its lifetime.
+func (c *cache) reload(key interface{}) (interface{}, bool) {
+	e, ok := c.stored(key)
+	if !ok {
+		return nil, false
+	}
+	if e.Expiration != nil {
+		c.Cache.SetWithExpire(key, e.Value, e.Expiration.Sub(c.cfg.clock.Now()))
+	} else {
+		c.Cache.Set(key, e.Value)
+	}
+	c.drop(key)
+	return e.Value, true
+}
+
+func (c *cache) Get(key interface{}) (interface{}, error) {
+	if !c.Cache.Has(key) {
+		if v, ok := c.reload(key); ok {
+			return v, nil
+		}
+	}
+	return c.Cache.Get(key)
+}
+
+func (c *cache) GetIFPresent(key interface{}) (interface{}, error) {
+	if !c.Cache.Has(key) {
+		if v, ok := c.reload(key); ok {
+			return v, nil
+		}
+	}
+	return c.Cache.GetIFPresent(key)
+}
+
+// GetMulti reloads the stored keys, and gets the others from the cache.
+func (c *cache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{}, len(keys))
+	missing := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if !c.Cache.Has(key) {
+			if v, ok := c.reload(key); ok {
+				values[key] = v
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	loaded, err := c.Cache.GetMulti(missing)
+	for k, v := range loaded {
+		values[k] = v
+	}
+	return values, err
+}
+
+// Warm reloads the stored keys, and warms the cache with the others.
+func (c *cache) Warm(keys []interface{}, concurrency int) error {
+	missing := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if c.Cache.Has(key) {
+			continue
+		}
+		if _, ok := c.reload(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+	return c.Cache.Warm(missing, concurrency)
+}
+
+// GetALL returns the unexpired entries in memory and in the store.
+func (c *cache) GetALL() map[interface{}]interface{} {
+	return c.all(c.Cache.GetALL(), true)
+}
+
+// GetALLIncludingExpired returns the entries in memory and in the store,
+// including the expired ones which were not removed yet.
+func (c *cache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.all(c.Cache.GetALLIncludingExpired(), false)
+}
+
+// all adds the stored entries to a copy of the entries in memory,
+// leaving out the expired ones if checkExpired is true.
+func (c *cache) all(inMemory map[interface{}]interface{}, checkExpired bool) map[interface{}]interface{} {
+	items := make(map[interface{}]interface{})
+	for k, v := range inMemory {
+		items[k] = v
+	}
+	err := c.store.ForEach(func(_, data []byte) error {
+		e, err := c.decodeRecord(data)
+		if err != nil {
+			return err
+		}
+		if _, ok := items[e.Key]; !ok && !(checkExpired && c.expired(e)) {
+			items[e.Key] = e.Value
+		}
+		return nil
+	})
+	if err != nil {
+		c.failed(nil, err)
+	}
+	return items
+}
+
+func (c *cache) Has(key interface{}) bool {
+	if c.Cache.Has(key) {
+		return true
+	}
+	_, ok := c.stored(key)
+	return ok
+}
+
+func (c *cache) Set(key, value interface{}) {
+	c.Cache.Set(key, value)
+	c.drop(key)
+}
+
+func (c *cache) Remove(key interface{}) bool {
+	ok := c.Cache.Remove(key)
+	c.drop(key)
+	return ok
+}
+
+func (c *cache) RemoveAll(keys ...interface{}) int {
+	n := c.Cache.RemoveAll(keys...)
+	for _, key := range keys {
+		c.drop(key)
+	}
+	return n
+}
+
+func (c *cache) GetAndRemove(key interface{}) (interface{}, bool) {
+	v, ok := c.Cache.GetAndRemove(key)
+	if !ok {
+		v, ok = c.storedValue(key)
+	}
+	c.drop(key)
+	return v, ok
+}
+
+func (c *cache) RemoveGet(key interface{}) (interface{}, bool) {
+	v, ok := c.Cache.RemoveGet(key)
+	if !ok {
+		v, ok = c.storedValue(key)
+	}
+	c.drop(key)
+	return v, ok
+}
+
+// storedValue returns the value of key in the store.
+func (c *cache) storedValue(key interface{}) (interface{}, bool) {
+	e, ok := c.stored(key)
+	return e.Value, ok
+}
+
+func (c *cache) Purge() {
+	c.Cache.Purge()
+	if err := c.store.Clear(); err != nil {
+		c.failed(nil, err)
+	}
+}
@@ -0,0 +1,160 @@
+package persistent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+func TestWrap(t *testing.T) {
+	store, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loads := 0
+	c, err := Wrap(gcache.New(2).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		loads++
+		return key.(int) * 10, nil
+	}), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		c.Set(i, i)
+	}
+	if !c.Has(0) {
+		t.Fatal("the evicted entry was not spilled")
+	}
+	if v, err := c.Get(0); err != nil || v != 0 {
+		t.Errorf("Get() = %v, %v; want the spilled value", v, err)
+	}
+	if v, err := c.Get(1); err != nil || v != 1 {
+		t.Errorf("Get() = %v, %v; want the spilled value", v, err)
+	}
+	if loads != 0 {
+		t.Errorf("the LoaderFunc was called %v times for spilled entries", loads)
+	}
+	if v, err := c.Get(3); err != nil || v != 30 || loads != 1 {
+		t.Errorf("Get() = %v, %v after %v loads", v, err, loads)
+	}
+
+	c.Remove(0)
+	c.Remove(1)
+	if c.Has(0) || c.Has(1) {
+		t.Error("removed entries are still stored")
+	}
+	c.Set(4, 4)
+	c.Purge()
+	if c.Has(2) || c.Has(3) || c.Has(4) {
+		t.Error("Purge left entries in the store")
+	}
+}
+
+func TestWrapExpiration(t *testing.T) {
+	store, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := gcache.NewFakeClock(time.Now())
+	c, err := Wrap(gcache.New(1).LRU().Clock(clock).Expiration(time.Second), store, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", 1)
+	clock.Advance(2 * time.Second)
+	if _, err := c.Get("a"); err != gcache.KeyNotFoundError {
+		t.Errorf("Get() = %v; the expired entry was spilled", err)
+	}
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if !c.Has("a") {
+		t.Fatal("the evicted entry was not spilled")
+	}
+	clock.Advance(time.Hour)
+	if v, err := c.Get("a"); err != gcache.KeyNotFoundError {
+		t.Errorf("Get() = %v, %v; the spilled entry outlived its expiration", v, err)
+	}
+	if c.Has("a") {
+		t.Error("the expired entry is still stored")
+	}
+
+	c.Set("c", 3)
+	c.Set("d", 4)
+	clock.Advance(500 * time.Millisecond)
+	if v, err := c.Get("c"); err != nil || v != 3 {
+		t.Fatalf("Get() = %v, %v; want the spilled value", v, err)
+	}
+	clock.Advance(600 * time.Millisecond)
+	if _, err := c.Get("c"); err != gcache.KeyNotFoundError {
+		t.Errorf("Get() = %v; the reloaded entry got a new lifetime", err)
+	}
+}
+
+func TestWrapCallbacks(t *testing.T) {
+	store, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := gcache.NewFakeClock(time.Now())
+	var evicted, expired []interface{}
+	c, err := Wrap(gcache.New(1).LRU().Clock(clock).Expiration(time.Second).
+		EvictedFunc(func(key, _ interface{}) { evicted = append(evicted, key) }).
+		ExpiredFunc(func(key, _ interface{}) { expired = append(expired, key) }), store, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", 1)
+	c.Set("b", 2)
+	clock.Advance(2 * time.Second)
+	c.Get("b")
+	if fmt.Sprint(evicted) != "[a]" || fmt.Sprint(expired) != "[b]" {
+		t.Errorf("evicted %v, expired %v; want [a] and [b]", evicted, expired)
+	}
+}
+
+func TestWrapMulti(t *testing.T) {
+	store, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loads := 0
+	c, err := Wrap(gcache.New(2).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		loads++
+		return key.(int) * 10, nil
+	}), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		c.Set(i, i)
+	}
+	if all := c.GetALL(); len(all) != 4 || all[0] != 0 || all[3] != 3 {
+		t.Errorf("GetALL() = %v; want the stored entries too", all)
+	}
+	if vs, err := c.GetMulti([]interface{}{0, 1}); err != nil || vs[0] != 0 || vs[1] != 1 || loads != 0 {
+		t.Errorf("GetMulti() = %v, %v after %v loads; want the spilled values", vs, err, loads)
+	}
+	if err := c.Warm([]interface{}{2, 3, 4}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 1 {
+		t.Errorf("Warm loaded %v keys; want only the missing one", loads)
+	}
+	if v, err := c.Get(2); err != nil || v != 2 {
+		t.Errorf("Get() = %v, %v; want the spilled value", v, err)
+	}
+}
+
+func TestWrapScore(t *testing.T) {
+	store, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := func(interface{}) int { return 1 }
+	if _, err := Wrap(gcache.New(2).SCORE().ScoringFunc(score).WeightingFunc(score), store); err == nil {
+		t.Error("Wrap accepted a SCORE cache")
+	}
+}
@@ -0,0 +1,41 @@
+package gcache
+
+// Pinner is implemented by the caches whose entries can be exempted from
+// capacity eviction. All the cache types are Pinners.
+type Pinner interface {
+	// Pin exempts key from capacity eviction until it is unpinned. Pinned
+	// entries still expire and can be removed explicitly. Returns false if the
+	// key is not in the cache.
+	Pin(key interface{}) bool
+	// Unpin makes key evictable again. Returns false if the key was not pinned.
+	Unpin(key interface{}) bool
+}
+
+// pin exempts key from eviction (not thread safe).
+func (c *baseCache) pin(key interface{}) {
+	if c.pinned == nil {
+		c.pinned = make(map[interface{}]struct{})
+	}
+	c.pinned[key] = struct{}{}
+}
+
+// unpin makes key evictable again and reports whether it was pinned (not thread safe).
+func (c *baseCache) unpin(key interface{}) bool {
+	if _, ok := c.pinned[key]; !ok {
+		return false
+	}
+	delete(c.pinned, key)
+	return true
+}
+
+// isPinned reports whether key is pinned (not thread safe).
+func (c *baseCache) isPinned(key interface{}) bool {
+	_, ok := c.pinned[key]
+	return ok
+}
+
+// evictable reports whether capacity eviction may remove key,
+// which must be unpinned and in a class the current eviction pass admits (not thread safe).
+func (c *baseCache) evictable(key interface{}) bool {
+	return !c.isPinned(key) && c.priorityOf(key) <= c.evictClass
+}
@@ -0,0 +1,65 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+
+		if cache.(Pinner).Pin("config") {
+			t.Error("Pin should fail for a missing key")
+		}
+		cache.Set("config", "value")
+		if !cache.(Pinner).Pin("config") {
+			t.Error("Pin should succeed")
+		}
+
+		for i := 0; i < 3*size; i++ {
+			cache.Set(i, i)
+		}
+		if v, err := cache.GetIFPresent("config"); err != nil || v != "value" {
+			t.Errorf("pinned entry was evicted: %v, %v", v, err)
+		}
+
+		if ex := cache.(Explainer).Explain("config"); !ex.Pinned {
+			t.Errorf("unexpected explanation %+v", ex)
+		}
+
+		if !cache.(Pinner).Unpin("config") {
+			t.Error("Unpin should succeed")
+		}
+		if cache.(Pinner).Unpin("config") {
+			t.Error("Unpin should fail for an unpinned key")
+		}
+
+		cache.(Pinner).Pin("config")
+		if !cache.Remove("config") {
+			t.Error("pinned entries can be removed")
+		}
+		if cache.(Pinner).Unpin("config") {
+			t.Error("Remove should unpin the key")
+		}
+	}
+}
+
+func TestPinAll(t *testing.T) {
+	size := 2
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		cache.Set(1, 1)
+		cache.Set(2, 2)
+		cache.(Pinner).Pin(1)
+		cache.(Pinner).Pin(2)
+
+		// nothing can be evicted, so the cache grows beyond its size
+		cache.Set(3, 3)
+		for i := 1; i <= 2; i++ {
+			if _, err := cache.GetIFPresent(i); err != nil {
+				t.Errorf("pinned entry %v was evicted", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package gcache
+
+// Priority is the eviction class of an entry.
+// Entries of a lower class are always evicted before entries of a higher class,
+// the underlying policy decides the order within a class.
+type Priority int
+
+const (
+	LowPriority Priority = iota - 1
+	NormalPriority
+	HighPriority
+)
+
+// prioritize sets the eviction class of key (not thread safe).
+func (c *baseCache) prioritize(key interface{}, priority Priority) {
+	if priority == NormalPriority {
+		delete(c.priorities, key)
+		return
+	}
+	if c.priorities == nil {
+		c.priorities = make(map[interface{}]Priority)
+	}
+	c.priorities[key] = priority
+}
+
+// priorityOf returns the eviction class of key (not thread safe).
+func (c *baseCache) priorityOf(key interface{}) Priority {
+	if p, ok := c.priorities[key]; ok {
+		return p
+	}
+	return NormalPriority
+}
+
+// forget drops the pin, the eviction class and the index entries of a removed key (not thread safe).
+func (c *baseCache) forget(key interface{}) {
+	c.unindexed(key)
+	c.unpin(key)
+	delete(c.priorities, key)
+}
+
+// evictByClass calls evict once per eviction class, from low to high,
+// until it reports that enough entries have been evicted.
+// Each pass makes one more class evictable (not thread safe).
+func (c *baseCache) evictByClass(evict func() bool) {
+	defer func() { c.evictClass = HighPriority }()
+	if len(c.priorities) == 0 {
+		c.evictClass = HighPriority
+		evict()
+		return
+	}
+	for c.evictClass = LowPriority; c.evictClass < HighPriority; c.evictClass++ {
+		if evict() {
+			return
+		}
+	}
+	evict()
+}
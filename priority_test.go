@@ -0,0 +1,51 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestSetWithPriority(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+
+		cache.SetWithPriority("high", "h", HighPriority)
+		for i := 0; i < size-2; i++ {
+			cache.Set(i, i)
+		}
+		cache.SetWithPriority("low", "l", LowPriority)
+
+		cache.Set("normal", "n")
+		if _, err := cache.GetIFPresent("low"); err != KeyNotFoundError {
+			t.Errorf("%T: low priority entry should be evicted first", cache)
+		}
+
+		for i := 0; i < 3*size; i++ {
+			cache.Set(i, i)
+		}
+		if v, err := cache.GetIFPresent("high"); err != nil || v != "h" {
+			t.Errorf("%T: high priority entry was evicted: %v, %v", cache, v, err)
+		}
+		if ex := cache.(Explainer).Explain("high"); ex.Class != HighPriority {
+			t.Errorf("%T: unexpected explanation %+v", cache, ex)
+		}
+
+		cache.SetWithPriority("high", "h", NormalPriority)
+		if ex := cache.(Explainer).Explain("high"); ex.Class != NormalPriority {
+			t.Errorf("%T: unexpected explanation %+v", cache, ex)
+		}
+	}
+}
+
+func TestSetWithPriorityAllHigh(t *testing.T) {
+	size := 2
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		for i := 0; i < 3*size; i++ {
+			cache.SetWithPriority(i, i, HighPriority)
+		}
+		if l := cache.Len(); l > size {
+			t.Errorf("%T: high priority entries should still be evicted, got %d entries", cache, l)
+		}
+	}
+}
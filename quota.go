@@ -0,0 +1,89 @@
+package gcache
+
+import (
+	"container/list"
+	"time"
+)
+
+// NamespaceQuota caps the number of entries of a Namespace at maxEntries, so that
+// one subsystem cannot monopolize the shared cache. Setting a new key in a namespace
+// which is at its quota evicts the oldest unpinned entry of that namespace,
+// leaving the other namespaces alone. The capacity of the cache still applies.
+func (cb *CacheBuilder) NamespaceQuota(name string, maxEntries int) *CacheBuilder {
+	if cb.quotas == nil {
+		cb.quotas = make(map[string]int)
+	}
+	cb.quotas[name] = maxEntries
+	return cb
+}
+
+// namespaceQuota keeps the keys of a namespace in the order they were added.
+type namespaceQuota struct {
+	max      int
+	order    *list.List // of *quotaEntry, oldest first
+	elements map[interface{}]*list.Element
+}
+
+type quotaEntry struct {
+	key     interface{}
+	created time.Time
+}
+
+func newNamespaceQuota(max int) *namespaceQuota {
+	q := &namespaceQuota{max: max}
+	q.reset()
+	return q
+}
+
+// add tracks key if it is not tracked yet (not thread safe).
+func (q *namespaceQuota) add(key interface{}, now time.Time) {
+	if _, ok := q.elements[key]; !ok {
+		q.elements[key] = q.order.PushBack(&quotaEntry{key: key, created: now})
+	}
+}
+
+// remove stops tracking key (not thread safe).
+func (q *namespaceQuota) remove(key interface{}) {
+	if el, ok := q.elements[key]; ok {
+		q.order.Remove(el)
+		delete(q.elements, key)
+	}
+}
+
+func (q *namespaceQuota) reset() {
+	q.order = list.New()
+	q.elements = make(map[interface{}]*list.Element)
+}
+
+// quotaOf returns the quota of the namespace of key, or nil if it has none (not thread safe).
+func (c *baseCache) quotaOf(key interface{}) *namespaceQuota {
+	if len(c.quotas) == 0 {
+		return nil
+	}
+	if nk, ok := key.(NamespacedKey); ok {
+		return c.quotas[nk.Namespace]
+	}
+	return nil
+}
+
+// enforceQuota tracks a stored key against the quota of its namespace and
+// evicts the oldest unpinned entries of the namespace while it is over (not thread safe).
+func (c *baseCache) enforceQuota(key interface{}) {
+	q := c.quotaOf(key)
+	if q == nil {
+		return
+	}
+	q.add(key, c.clock.Now())
+	for el := q.order.Front(); el != nil && q.order.Len() > q.max; {
+		entry := el.Value.(*quotaEntry)
+		el = el.Next()
+		if entry.key == key || c.isPinned(entry.key) {
+			continue
+		}
+		if c.evictKey(entry.key) {
+			c.recordEviction(entry.key, entry.created)
+		} else {
+			q.remove(entry.key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestNamespaceQuota(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		var evicted []interface{}
+		cache := builder.
+			NamespaceQuota("noisy", 2).
+			EvictedFunc(func(key, _ interface{}) {
+				evicted = append(evicted, key)
+			}).
+			Build()
+		noisy := cache.Namespace("noisy")
+		quiet := cache.Namespace("quiet")
+		quiet.Set(1, 1)
+		quiet.Set(2, 2)
+
+		noisy.Set(1, 1)
+		noisy.Pin(1)
+		noisy.Set(2, 2)
+		noisy.Set(3, 3)
+		noisy.Set(4, 4)
+		if l := noisy.Len(); l != 2 {
+			t.Errorf("%T: noisy.Len() = %v; want 2", cache, l)
+		}
+		if !noisy.Has(1) || !noisy.Has(4) {
+			t.Errorf("%T: unexpected noisy keys %v", cache, noisy.KeysIncludingExpired())
+		}
+		if l := quiet.Len(); l != 2 {
+			t.Errorf("%T: quiet.Len() = %v; want 2", cache, l)
+		}
+		if len(evicted) != 2 || cache.EvictionCount() != 2 {
+			t.Errorf("%T: evicted %v, counted %v", cache, evicted, cache.EvictionCount())
+		}
+
+		// overwriting does not count against the quota
+		noisy.Set(4, 5)
+		if l := noisy.Len(); l != 2 {
+			t.Errorf("%T: noisy.Len() = %v; want 2", cache, l)
+		}
+		noisy.Remove(4)
+		noisy.Set(5, 5)
+		if !noisy.Has(1) || !noisy.Has(5) {
+			t.Errorf("%T: unexpected noisy keys %v", cache, noisy.KeysIncludingExpired())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package gcache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	readBufferStripes = 16 // buffers readers are spread over
+	readBufferSize    = 32 // reads held by each buffer before they are applied
+)
+
+// readBuffer records cache hits so that the eviction policy is updated for them
+// in batches under the write lock, rather than once per hit (as in BP-Wrapper).
+// Readers only take the lock of one of the stripes, so concurrent hits do not
+// contend. Hits are applied in order within a stripe, and in approximate order
+// overall. They are dropped when a buffer is full and the lock is busy, unless
+// they extend an ExpireAfterAccess expiration.
+type readBuffer struct {
+	stripes [readBufferStripes]readStripe
+	apply   func(entry interface{}, accessExpiration *time.Time)
+}
+
+type readStripe struct {
+	mu    sync.Mutex
+	n     int
+	reads [readBufferSize]bufferedRead
+}
+
+type bufferedRead struct {
+	entry            interface{}
+	accessExpiration *time.Time
+}
+
+func newReadBuffer(apply func(entry interface{}, accessExpiration *time.Time)) *readBuffer {
+	return &readBuffer{apply: apply}
+}
+
+// record buffers a hit on entry and returns whether it was buffered,
+// and whether its buffer is full.
+func (b *readBuffer) record(entry interface{}, accessExpiration *time.Time) (buffered, full bool) {
+	s := &b.stripes[rand.Intn(readBufferStripes)]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n < readBufferSize {
+		s.reads[s.n] = bufferedRead{entry, accessExpiration}
+		s.n++
+		buffered = true
+	}
+	return buffered, s.n == readBufferSize
+}
+
+// drain applies the buffered hits, stripe by stripe.
+// The cache must be locked for writing.
+func (b *readBuffer) drain() {
+	for i := range b.stripes {
+		s := &b.stripes[i]
+		s.mu.Lock()
+		for j := 0; j < s.n; j++ {
+			b.apply(s.reads[j].entry, s.reads[j].accessExpiration)
+			s.reads[j] = bufferedRead{}
+		}
+		s.n = 0
+		s.mu.Unlock()
+	}
+}
+
+// recordRead buffers a hit on entry, applying the buffered hits when the
+// buffer is full and no other goroutine holds the lock. A hit which could not
+// be buffered is applied after them if it extends the access expiration,
+// waiting for the lock. The cache must not be locked.
+func (c *baseCache) recordRead(entry interface{}) {
+	accessExpiration := c.newAccessExpiration()
+	buffered, full := c.reads.record(entry, accessExpiration)
+	switch {
+	case !buffered && accessExpiration != nil:
+		c.mu.Lock()
+		c.reads.drain()
+		c.reads.apply(entry, accessExpiration)
+		c.unlock()
+	case full && c.mu.TryLock():
+		c.reads.drain()
+		c.unlock()
+	}
+}
+
+// laterExpiration returns the later of the access expirations current and t,
+// as the hits setting them may be applied out of order.
+func laterExpiration(current, t *time.Time) *time.Time {
+	if current != nil && t != nil && current.After(*t) {
+		return current
+	}
+	return t
+}
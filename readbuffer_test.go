@@ -0,0 +1,112 @@
+package gcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadBufferDrain(t *testing.T) {
+	applied := make(map[interface{}]int)
+	b := newReadBuffer(func(entry interface{}, _ *time.Time) {
+		applied[entry]++
+	})
+	for i := 0; i < 10; i++ {
+		b.record(i, nil)
+	}
+	b.drain()
+	if len(applied) != 10 {
+		t.Fatalf("expected 10 reads, got %v", applied)
+	}
+	applied = make(map[interface{}]int)
+	b.drain()
+	if len(applied) != 0 {
+		t.Errorf("expected the buffer to be empty, got %v", applied)
+	}
+}
+
+func TestBufferedReads(t *testing.T) {
+	size := 4
+	var testCaches = []*CacheBuilder{
+		New(size).LRU(),
+		New(size).LFU(),
+	}
+	for _, builder := range testCaches {
+		cache := builder.Build()
+		for i := 0; i < size; i++ {
+			cache.Set(i, i)
+		}
+		// hits are buffered, and applied before the next eviction
+		for i := 0; i < size-1; i++ {
+			cache.Get(i)
+		}
+		cache.Set(size, size)
+		if _, err := cache.GetIFPresent(size - 1); err != KeyNotFoundError {
+			t.Errorf("%T: expected the entry which was not read to be evicted", cache)
+		}
+		for i := 0; i < size-1; i++ {
+			if _, err := cache.GetIFPresent(i); err != nil {
+				t.Errorf("%T: expected %v to be cached", cache, i)
+			}
+		}
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	size := 64
+	var testCaches = []*CacheBuilder{
+		New(size).LRU(),
+		New(size).LFU(),
+	}
+	for _, builder := range testCaches {
+		cache := builder.Build()
+		for i := 0; i < size; i++ {
+			cache.Set(i, i)
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					key := (g + i) % size
+					if v, err := cache.Get(key); err != nil || v != key {
+						t.Errorf("%T: unexpected value %v, %v", cache, v, err)
+						return
+					}
+					if i%100 == 0 {
+						cache.Set(key, key)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		if n := cache.HitCount(); n != 8000 {
+			t.Errorf("%T: unexpected hit count %v", cache, n)
+		}
+	}
+}
+
+func TestDroppedReadsExtendAccessExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := New(4).LRU().Clock(clock).ExpireAfterAccess(time.Minute).Build().(*LRUCache)
+	cache.Set("a", 1)
+	// fill every buffer, so that the next hit cannot be buffered
+	ent := cache.items["a"]
+	stale := clock.Now().Add(time.Minute)
+	for i := range cache.reads.stripes {
+		s := &cache.reads.stripes[i]
+		for ; s.n < readBufferSize; s.n++ {
+			s.reads[s.n] = bufferedRead{entry: ent, accessExpiration: &stale}
+		}
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, err := cache.Get("a"); err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	clock.Advance(45 * time.Second)
+	if _, err := cache.GetIFPresent("a"); err != nil {
+		t.Errorf("the hit which was not buffered did not extend the expiration: %v", err)
+	}
+}
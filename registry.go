@@ -0,0 +1,92 @@
+package gcache
+
+import (
+	"sort"
+	"sync"
+)
+
+// CacheRegistry holds named caches so that instrumentation and admin endpoints
+// can enumerate all caches of a process.
+type CacheRegistry struct {
+	mu     sync.RWMutex
+	caches map[string]Cache
+}
+
+var defaultRegistry = NewRegistry()
+
+func NewRegistry() *CacheRegistry {
+	return &CacheRegistry{caches: make(map[string]Cache)}
+}
+
+// Registry returns the package level registry used by Register and Lookup.
+func Registry() *CacheRegistry {
+	return defaultRegistry
+}
+
+// Register adds c to the package level registry under name.
+func Register(name string, c Cache) {
+	defaultRegistry.Register(name, c)
+}
+
+// Unregister removes name from the package level registry.
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
+}
+
+// Lookup returns the cache registered under name in the package level registry.
+func Lookup(name string) (Cache, bool) {
+	return defaultRegistry.Lookup(name)
+}
+
+// Register adds c under name, replacing any cache registered under the same name.
+func (r *CacheRegistry) Register(name string, c Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches[name] = c
+}
+
+// Unregister removes the cache registered under name.
+func (r *CacheRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.caches, name)
+}
+
+// Lookup returns the cache registered under name.
+func (r *CacheRegistry) Lookup(name string) (Cache, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.caches[name]
+	return c, ok
+}
+
+// Names returns the names of all registered caches in sorted order.
+func (r *CacheRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Each calls fn for every registered cache in name order.
+// fn may register and unregister caches.
+func (r *CacheRegistry) Each(fn func(name string, c Cache)) {
+	for _, name := range r.Names() {
+		if c, ok := r.Lookup(name); ok {
+			fn(name, c)
+		}
+	}
+}
+
+// Purge purges the cache registered under name and reports whether there is one.
+func (r *CacheRegistry) Purge(name string) bool {
+	c, ok := r.Lookup(name)
+	if ok {
+		c.Purge()
+	}
+	return ok
+}
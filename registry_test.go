@@ -0,0 +1,55 @@
+package gcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	users := New(8).LRU().Build()
+	sessions := New(8).LFU().Build()
+	r.Register("users", users)
+	r.Register("sessions", sessions)
+
+	if c, ok := r.Lookup("users"); !ok || c != users {
+		t.Errorf("unexpected lookup %v, %v", c, ok)
+	}
+	if _, ok := r.Lookup("unknown"); ok {
+		t.Error("unknown caches should not be found")
+	}
+
+	var names []string
+	r.Each(func(name string, c Cache) {
+		names = append(names, name)
+	})
+	if !reflect.DeepEqual(names, []string{"sessions", "users"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	users.Set("key", "value")
+	if !r.Purge("users") || users.Len() != 0 {
+		t.Error("Purge should purge the named cache")
+	}
+	if r.Purge("unknown") {
+		t.Error("Purge should fail for unknown caches")
+	}
+
+	r.Unregister("users")
+	if _, ok := r.Lookup("users"); ok {
+		t.Error("users should be unregistered")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	c := New(8).Build()
+	Register("default-registry-test", c)
+	defer Unregister("default-registry-test")
+
+	if found, ok := Lookup("default-registry-test"); !ok || found != c {
+		t.Errorf("unexpected lookup %v, %v", found, ok)
+	}
+	if _, ok := Registry().Lookup("default-registry-test"); !ok {
+		t.Error("Registry should return the package level registry")
+	}
+}
@@ -0,0 +1,58 @@
+package gcache
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// scheduledRemovals keeps the timers of invalidations scheduled with RemoveAt and RemoveAfter.
+type scheduledRemovals struct {
+	mu      sync.Mutex
+	clock   Clock
+	timers  map[interface{}]Timer
+	stopped bool
+	logger  *slog.Logger
+}
+
+// schedule calls remove for key after d has elapsed.
+// A previously scheduled removal of the same key is replaced.
+func (sr *scheduledRemovals) schedule(key interface{}, d time.Duration, remove func(interface{}) bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.stopped {
+		return
+	}
+	if sr.timers == nil {
+		sr.timers = make(map[interface{}]Timer)
+	}
+	if t, ok := sr.timers[key]; ok {
+		t.Stop()
+	}
+	var t Timer
+	t = sr.clock.AfterFunc(d, func() {
+		sr.mu.Lock()
+		if sr.timers[key] == t {
+			delete(sr.timers, key)
+		}
+		sr.mu.Unlock()
+		removed := remove(key)
+		if sr.logger != nil {
+			sr.logger.Debug("gcache: scheduled removal", "key", key, "removed", removed)
+		}
+	})
+	sr.timers[key] = t
+}
+
+// stop cancels all scheduled removals and ignores any scheduled later.
+func (sr *scheduledRemovals) stop() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for _, t := range sr.timers {
+		t.Stop()
+	}
+	sr.timers = nil
+	sr.stopped = true
+}
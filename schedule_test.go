@@ -0,0 +1,35 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemoveAfter(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		cache.Set("after", 1)
+		cache.Set("at", 2)
+		cache.Set("rescheduled", 3)
+
+		cache.RemoveAfter("after", 10*time.Millisecond)
+		cache.RemoveAt("at", time.Now().Add(10*time.Millisecond))
+		cache.RemoveAfter("rescheduled", 10*time.Millisecond)
+		cache.RemoveAfter("rescheduled", time.Hour)
+
+		if l := cache.Len(); l != 3 {
+			t.Errorf("Len() = %v; want 3", l)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		for _, key := range []string{"after", "at"} {
+			if _, err := cache.GetIFPresent(key); err != KeyNotFoundError {
+				t.Errorf("%v should have been removed", key)
+			}
+		}
+		if _, err := cache.GetIFPresent("rescheduled"); err != nil {
+			t.Errorf("rescheduled should not have been removed: %v", err)
+		}
+	}
+}
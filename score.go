@@ -1,6 +1,12 @@
 package gcache
 
-import "container/heap"
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
 
 // TODO: See if there is a way to get rid of the flag arguments
 
@@ -14,6 +20,19 @@ type ScoreCache struct {
 	computeScore  ScoringFunc
 	computeWeight WeightingFunc
 	totalWeight   int
+	halfLife      time.Duration
+	epoch         time.Time
+	accessBoost   float64
+	fallback      scoredFallback
+	maxEntries    int
+	tieBreaker    TieBreaker
+	seq           uint64 // orders insertions and uses for the TieBreaker
+}
+
+// scoredFallback is used when a ScoringFunc or WeightingFunc panics.
+type scoredFallback struct {
+	score  int
+	weight int
 }
 
 // ScoringFunc computes the eviction priority for the queue
@@ -28,53 +47,132 @@ func newScoreCache(cb *CacheBuilder) *ScoreCache {
 	buildCache(&c.baseCache, cb)
 	c.computeScore = cb.scoringFunc
 	c.computeWeight = cb.weightingFunc
+	if cb.scoreDecay != nil {
+		c.halfLife = *cb.scoreDecay
+		c.epoch = c.clock.Now()
+	}
+	c.accessBoost = cb.accessBoost
+	c.maxEntries = cb.maxEntries
+	c.tieBreaker = cb.tieBreaker
+	c.fallback = scoredFallback{score: cb.fallbackScore, weight: cb.fallbackWeight}
 
 	c.reset()
 	c.loadGroup.cache = c
+	c.evictKey = c.remove
+	c.monitorMemory(c.shed, c.Len)
+	c.startAutoSnapshot(c.Export)
 	return c
 }
 
 func (sc *ScoreCache) reset() {
-	newHeap := priorityHeap([]*scoredItem{})
-	sc.evictList = &newHeap
+	sc.evictList = &priorityHeap{tieBreaker: sc.tieBreaker}
 	heap.Init(sc.evictList)
 	sc.items = make(map[interface{}]*scoredItem)
+	sc.totalWeight = 0
+	sc.pinned = nil
+	sc.priorities = nil
+	sc.copies = nil // Snapshots in progress keep reading the previous entries
 }
 
 // Get returns an item from the cache if it is present. If it is not present
 // it attempts to load it using the LoaderFunc.
 func (sc *ScoreCache) Get(key interface{}) (interface{}, error) {
-	sc.mu.RLock()
-	defer sc.mu.RUnlock()
-
-	item, err := sc.getItem(key, true)
+	v, err := sc.getValue(key)
 	if err != nil {
 		return sc.getWithLoader(key, true)
 	}
-	return item.value, nil
+	return v, nil
+}
+
+// GetAsync returns a channel which receives the result of Get for key once it is
+// available, without waiting for the LoaderFunc.
+func (sc *ScoreCache) GetAsync(key interface{}) <-chan Result {
+	return sc.getAsync(key, sc.getValue, sc.getWithLoader)
 }
 
 // GetIFPresent returns an item from the cache if it is present in cache and a KeyNotFoundError if it is not.
 // It does not attempt to load the item
 func (sc *ScoreCache) GetIFPresent(key interface{}) (interface{}, error) {
+	return sc.getValue(key)
+}
+
+// gets the value of a cached item and records the access
+func (sc *ScoreCache) getValue(key interface{}) (interface{}, error) {
+	if err := sc.checkKey(key); err != nil {
+		return nil, err
+	}
 	sc.mu.RLock()
-	defer sc.mu.RUnlock()
 	item, err := sc.getItem(key, true)
+	if err != nil {
+		sc.mu.RUnlock()
+		if item != nil {
+			sc.expireReleased(item)
+		}
+		sc.missed(key)
+		return nil, err
+	}
+	v := item.value
+	sc.mu.RUnlock()
 
-	if item != nil {
-		return item.value, nil
+	sc.touch(item)
+	return sc.decode(key, v)
+}
+
+// expireReleased removes item, whose WeakValue was released, as an expired entry.
+func (sc *ScoreCache) expireReleased(item *scoredItem) {
+	sc.mu.Lock()
+	defer sc.unlock()
+	if sc.items[item.key] == item && released(item.value) {
+		sc.expire(item.created, func() { sc.removeItem(item) })
 	}
-	return nil, err
+}
+
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating the eviction policy or loading it.
+func (sc *ScoreCache) Has(key interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	item, ok := sc.items[key]
+	return ok && !released(item.value)
+}
+
+// GetMulti returns the values of all keys which are cached or can be loaded.
+func (sc *ScoreCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	return sc.getMulti(keys, sc.getValue, sc.getWithLoader, sc.setLoaded)
+}
+
+// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+// concurrency loads at a time, e.g. to fill the cache before taking traffic.
+func (sc *ScoreCache) Warm(keys []interface{}, concurrency int) error {
+	return sc.warm(keys, concurrency, sc.Has, sc.getWithLoader)
 }
 
 // GetALL returns all if the cached values
 func (sc *ScoreCache) GetALL() map[interface{}]interface{} {
+	if sc.snapshot != nil {
+		return sc.snapshot.get(sc.getALL, true).GetALL()
+	}
+	return sc.getALL(true)
+}
+
+// GetALLIncludingExpired is GetALL, ScoreCache entries do not expire.
+func (sc *ScoreCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return sc.GetALL()
+}
+
+// getALL copies the entries, checkExpired has no effect as they do not expire.
+func (sc *ScoreCache) getALL(_ bool) map[interface{}]interface{} {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 
 	m := make(map[interface{}]interface{})
 	for k, v := range sc.items {
-		m[k] = v.value
+		if value, ok := sc.decoded(k, v.value); ok {
+			m[k] = value
+		}
 	}
 
 	return m
@@ -83,88 +181,732 @@ func (sc *ScoreCache) GetALL() map[interface{}]interface{} {
 // Set adds a key, value pair to the cache
 func (sc *ScoreCache) Set(key, value interface{}) {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlock()
 	sc.set(key, value)
 }
 
+// SetWithExpire adds a key, value pair to the cache and schedules its removal
+// after expiration, like RemoveAfter, since SCORE entries do not expire.
+func (sc *ScoreCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	sc.mu.Lock()
+	defer sc.unlock()
+	if _, err := sc.set(key, value); err != nil {
+		return
+	}
+	sc.removals.schedule(key, expiration, sc.Remove)
+}
+
 // set an item without locking and return the item
-func (sc *ScoreCache) set(key, value interface{}) *scoredItem {
-	// Check for existing item
-	existing, err := sc.getItem(key, false)
-	if err == nil {
-		sc.totalWeight -= existing.weight
+func (sc *ScoreCache) set(key, value interface{}) (*scoredItem, error) {
+	if err := sc.checkKey(key); err != nil {
+		return nil, err
+	}
+	sc.written(key)
+	value, err := sc.encode(key, value)
+	if err != nil {
+		if item, ok := sc.items[key]; ok {
+			sc.removeItem(item)
+		}
+		return nil, err
+	}
+
+	// Check for existing item, even if its WeakValue was released
+	if existing, ok := sc.items[key]; ok {
+		sc.updated(key, existing.value, value)
+		sc.release(key, existing.value)
 		existing.value = value
-		existing.score = sc.computeScore(value)
-		existing.weight = sc.computeWeight(value)
-		sc.totalWeight += existing.weight
-		idx, _ := sc.getIndex(key)
-		heap.Fix(sc.evictList, idx)
-		return existing
+		sc.indexed(key, value)
+		existing.accessed = sc.clock.Now()
+		existing.used = sc.nextSeq()
+		sc.rescore(existing)
+		sc.fit(existing)
+		return existing, nil
 	}
 
 	// Otherwise add to cache
 	item := sc.newScoredItem(key, value)
+	if item.weight > sc.size {
+		// the item can never fit, so it is not cached at all
+		item.index = -1
+		sc.rejected(key, value, RejectedOverweight)
+		sc.free(item.value)
+		return item, nil
+	}
 	// Verify item will not exceed total weight
 	if sc.totalWeight+item.weight > sc.size {
-		sc.evictUntil(item.weight)
+		sc.evictUntil(sc.totalWeight + item.weight - sc.size)
+	}
+	// Verify item will not exceed the number of entries
+	for sc.maxEntries > 0 && len(sc.items) >= sc.maxEntries {
+		if !sc.evictLowest() {
+			break
+		}
 	}
 	heap.Push(sc.evictList, item)
 	sc.items[key] = item
 	sc.totalWeight += item.weight
 
-	sc.addedCallback(key, value)
+	sc.added(key, value)
 
-	return item
+	return item, nil
 }
 
 // Remove deletes an item
 func (sc *ScoreCache) Remove(key interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	return sc.remove(key)
+}
+
+// RemoveAll removes the provided keys from the cache under a single lock acquisition
+// and returns how many of them were in the cache.
+func (sc *ScoreCache) RemoveAll(keys ...interface{}) int {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlock()
 
+	return sc.removeKeys(keys, sc.remove)
+}
+
+// remove deletes the item stored under key, if any
+func (sc *ScoreCache) remove(key interface{}) bool {
 	if item, ok := sc.items[key]; ok {
-		delete(sc.items, key)
-		index := -1
+		sc.removeItem(item)
+		return true
+	}
+	return false
+}
 
-		for i, it := range []*scoredItem(*sc.evictList) {
-			if it.key == key {
-				index = i
-				break
-			}
+// GetOrSet returns the existing value for the key if present.
+func (sc *ScoreCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if sc.checkKey(key) != nil {
+		return nil, false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	if item, ok := sc.items[key]; ok {
+		if v, ok := sc.decoded(key, item.value); ok {
+			sc.access(item)
+			return v, true
 		}
+	}
+	sc.set(key, value)
+	return value, false
+}
+
+// Update atomically replaces the value for key with the result of fn,
+// which receives the current value and whether it exists.
+func (sc *ScoreCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := sc.checkKey(key); err != nil {
+		return err
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	current, exists := sc.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	_, err = sc.set(key, value)
+	return err
+}
+
+// Increment atomically adds delta to the integer stored under key and returns the result.
+// int, int64 and uint64 values keep their type; a missing key is created holding delta.
+func (sc *ScoreCache) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(sc, key, delta)
+}
+
+// Decrement atomically subtracts delta from the integer stored under key and returns the result.
+func (sc *ScoreCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(sc, key, -delta)
+}
 
-		if index > 0 {
-			heap.Remove(sc.evictList, index)
-			sc.totalWeight -= item.weight
-			sc.evictedCallback(item.key, item.value)
-			return true
+// Touch reports whether key is present.
+// ScoreCache entries do not expire, so there is no expiration to reset.
+func (sc *ScoreCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	_, ok := sc.items[key]
+	return ok
+}
+
+// Pin exempts key from capacity and score based eviction until it is unpinned.
+// Pinned entries can still be removed explicitly.
+func (sc *ScoreCache) Pin(key interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	if _, ok := sc.peek(key); !ok {
+		return false
+	}
+	sc.pin(key)
+	return true
+}
+
+// Unpin makes key evictable again.
+func (sc *ScoreCache) Unpin(key interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	return sc.unpin(key)
+}
+
+// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+func (sc *ScoreCache) SetWithPriority(key, value interface{}, priority Priority) {
+	sc.mu.Lock()
+	defer sc.unlock()
+	if _, err := sc.set(key, value); err != nil {
+		return
+	}
+	if _, ok := sc.items[key]; ok {
+		sc.prioritize(key, priority)
+	}
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the cache is equal to old.
+func (sc *ScoreCache) CompareAndSwap(key, old, new interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	if v, ok := sc.stored(key); !ok || !sc.matches(key, v, old) {
+		return false
+	}
+	_, err := sc.set(key, new)
+	return err == nil
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (sc *ScoreCache) CompareAndDelete(key, old interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	if v, ok := sc.stored(key); !ok || !sc.matches(key, v, old) {
+		return false
+	}
+	sc.removeItem(sc.items[key])
+	return true
+}
+
+// GetAndRemove removes the provided key from the cache and returns its value.
+func (sc *ScoreCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	if sc.checkKey(key) != nil {
+		return nil, false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	item, ok := sc.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, ok := sc.decoded(key, item.value)
+	sc.removeItem(item)
+	return value, ok
+}
+
+// RemoveGet removes the provided key from the cache and returns the value it held, so
+// that the value can be cleaned up.
+func (sc *ScoreCache) RemoveGet(key interface{}) (interface{}, bool) {
+	if sc.checkKey(key) != nil {
+		return nil, false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	item, ok := sc.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, _ := sc.decoded(key, item.value)
+	sc.removeItem(item)
+	return value, true
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (sc *ScoreCache) RemoveAt(key interface{}, t time.Time) {
+	if sc.checkKey(key) != nil {
+		return
+	}
+	sc.removals.schedule(key, t.Sub(sc.clock.Now()), sc.Remove)
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (sc *ScoreCache) RemoveAfter(key interface{}, d time.Duration) {
+	if sc.checkKey(key) != nil {
+		return
+	}
+	sc.removals.schedule(key, d, sc.Remove)
+}
+
+// removes an item from the cache and calls the eviction handler
+func (sc *ScoreCache) removeItem(item *scoredItem) {
+	delete(sc.items, item.key)
+	sc.forget(item.key)
+	sc.release(item.key, item.value)
+	heap.Remove(sc.evictList, item.index)
+	sc.totalWeight -= item.weight
+	sc.evicted(item.key, item.value)
+}
+
+// Rescorer is implemented by the caches whose entries can be scored again when the
+// signals their scores depend on change, such as a ScoreCache.
+type Rescorer interface {
+	Rescore(key interface{}) bool
+	RescoreAll()
+}
+
+// Rescore recomputes the score and weight of the item stored under key
+// and restores its position in the eviction queue.
+// Items which became heavier than the cache are removed and rejected, and
+// the lowest scored items are evicted until the others fit.
+// Returns false if the key is not in the cache.
+func (sc *ScoreCache) Rescore(key interface{}) bool {
+	if sc.checkKey(key) != nil {
+		return false
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	item, ok := sc.items[key]
+	if !ok {
+		return false
+	}
+	sc.rescore(item)
+	sc.fit(item)
+	return true
+}
+
+// fit restores the position of an item whose score and weight were recomputed,
+// rejecting it if it is heavier than the cache, and evicts the lowest scored
+// items until the cache fits its size again (not thread safe).
+func (sc *ScoreCache) fit(item *scoredItem) {
+	if item.weight > sc.size {
+		sc.removeItem(item)
+		sc.rejected(item.key, item.value, RejectedOverweight)
+		return
+	}
+	heap.Fix(sc.evictList, item.index)
+	sc.evictOverweight()
+}
+
+// RescoreAll recomputes the score and weight of every item in the cache, like Rescore.
+// Use it when scores depend on external signals which have changed.
+func (sc *ScoreCache) RescoreAll() {
+	sc.mu.Lock()
+	defer sc.unlock()
+
+	var overweight []*scoredItem
+	for _, item := range sc.items {
+		sc.rescore(item)
+		if item.weight > sc.size {
+			overweight = append(overweight, item)
 		}
 	}
-	return false
+	heap.Init(sc.evictList)
+	for _, item := range overweight {
+		sc.removeItem(item)
+		sc.rejected(item.key, item.value, RejectedOverweight)
+	}
+	sc.evictOverweight()
+}
+
+// recomputes the score and weight of an item without fixing the heap
+func (sc *ScoreCache) rescore(item *scoredItem) {
+	sc.totalWeight -= item.weight
+	item.score = sc.score(item.value)
+	item.weight = sc.weight(item.value)
+	sc.totalWeight += item.weight
+	item.priority = sc.priority(item)
 }
 
-// Purge removes all items from the cache without calling eviction handlers
+// score computes the score of a value.
+// If the ScoringFunc panics the fallback score is used instead.
+func (sc *ScoreCache) score(value interface{}) (score int) {
+	defer func() {
+		if recover() != nil {
+			score = sc.fallback.score
+		}
+	}()
+	return sc.computeScore(value)
+}
+
+// weight computes the weight of a value. Weights below 1 are raised to 1,
+// so that no entry is free and they cannot corrupt the total weight.
+// If the WeightingFunc panics the fallback weight is used instead.
+func (sc *ScoreCache) weight(value interface{}) (weight int) {
+	defer func() {
+		if recover() != nil {
+			weight = sc.fallback.weight
+		}
+		if weight < 1 {
+			weight = 1
+		}
+	}()
+	return sc.computeWeight(value)
+}
+
+// priority computes the position of an item in the eviction queue.
+// It starts from the score, boosted by score * blend * ln(1 + hits) when
+// access boosting is enabled. With decay the effective score
+// score * 2^-(age/halfLife) is compared in log space relative to the cache
+// creation time, which keeps the heap order stable as time passes.
+func (sc *ScoreCache) priority(item *scoredItem) float64 {
+	score := float64(item.score)
+	if sc.accessBoost > 0 {
+		score *= 1 + sc.accessBoost*math.Log1p(float64(item.hits))
+	}
+	if sc.halfLife <= 0 {
+		return score
+	}
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	return math.Log2(score) + float64(item.accessed.Sub(sc.epoch))/float64(sc.halfLife)
+}
+
+// touch records an access to an item, which restarts the decay of its score
+// and counts towards its access boost.
+func (sc *ScoreCache) touch(item *scoredItem) {
+	if !sc.tracksAccess() {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+	if existing, ok := sc.items[item.key]; !ok || existing != item {
+		// evicted or replaced in the meantime
+		return
+	}
+	sc.access(item)
+}
+
+// access records an access to an item without locking
+func (sc *ScoreCache) access(item *scoredItem) {
+	if !sc.tracksAccess() {
+		return
+	}
+	item.accessed = sc.clock.Now()
+	item.used = sc.nextSeq()
+	item.hits++
+	item.priority = sc.priority(item)
+	heap.Fix(sc.evictList, item.index)
+}
+
+// tracksAccess reports whether accesses change the eviction order.
+func (sc *ScoreCache) tracksAccess() bool {
+	return sc.halfLife > 0 || sc.accessBoost > 0 || sc.tieBreaker != nil
+}
+
+func (sc *ScoreCache) nextSeq() uint64 {
+	sc.seq++
+	return sc.seq
+}
+
+// evicts the lowest scored items until the total weight fits the cache size
+func (sc *ScoreCache) evictOverweight() {
+	if sc.totalWeight > sc.size {
+		sc.evictUntil(sc.totalWeight - sc.size)
+	}
+}
+
+// SetCapacity changes the maximum total weight at runtime.
+// When shrinking, the lowest scored items are evicted until they fit.
+func (sc *ScoreCache) SetCapacity(n int) {
+	if n <= 0 {
+		panic("gcache: size <= 0")
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+	sc.size = n
+	sc.evictOverweight()
+}
+
+// shed evicts up to n entries following the eviction policy and returns how many were evicted.
+func (sc *ScoreCache) shed(n int) int {
+	sc.mu.Lock()
+	defer sc.unlock()
+	evicted := 0
+	for evicted < n && sc.evictLowest() {
+		evicted++
+	}
+	return evicted
+}
+
+// Purge removes all items from the cache, calling the EvictedFunc only with PurgeEvict
 func (sc *ScoreCache) Purge() {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	defer sc.unlock()
+	if sc.purgeEvict {
+		for key, item := range sc.items {
+			sc.evicted(key, item.value)
+		}
+	}
 	sc.reset()
+	sc.resetArena()
+	sc.resetIndexes()
+}
+
+// Close cancels scheduled removals, waits for background loads to finish and drops all
+// entries.
+func (sc *ScoreCache) Close() error {
+	if err := sc.close(); err != nil {
+		return err
+	}
+	sc.Purge()
+	return nil
 }
 
 // Keys returns all of the keys in the cache
 func (sc *ScoreCache) Keys() []interface{} {
+	if sc.snapshot != nil {
+		return sc.snapshot.get(sc.getALL, true).Keys()
+	}
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 
-	keys := make([]interface{}, len(sc.items))
-	i := 0
+	keys := make([]interface{}, 0, len(sc.items))
 	for k := range sc.items {
-		keys[i] = k
-		i++
+		keys = append(keys, k)
 	}
-
 	return keys
 }
 
+// KeysIncludingExpired is Keys, ScoreCache entries do not expire.
+func (sc *ScoreCache) KeysIncludingExpired() []interface{} {
+	return sc.Keys()
+}
+
+// KeysSorted returns the unexpired keys in the order given by less.
+func (sc *ScoreCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(sc.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys which start with prefix, in order.
+func (sc *ScoreCache) KeysWithPrefix(prefix string) []interface{} {
+	return sc.keysWithPrefix(prefix, sc.Keys, sc.Has)
+}
+
+// GetByIndex returns the unexpired entries whose attribute in the named Index is attr.
+func (sc *ScoreCache) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.getByIndex(name, attr, sc.peek)
+}
+
+// RemoveByIndex removes the entries whose attribute in the named Index is attr
+// under a single lock acquisition, and returns how many were removed.
+func (sc *ScoreCache) RemoveByIndex(name string, attr interface{}) int {
+	sc.mu.Lock()
+	defer sc.unlock()
+	return sc.removeByIndex(name, attr, sc.remove)
+}
+
+// Namespace returns a view of the cache which keeps its keys apart from
+// the keys of the other namespaces and of the cache itself.
+func (sc *ScoreCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(sc, name)
+}
+
+// Snapshot returns a consistent copy of the entries, as of when it is called.
+func (sc *ScoreCache) Snapshot() map[interface{}]interface{} {
+	return sc.consistentCopy(func() ([]interface{}, func(interface{}) (interface{}, bool)) {
+		items := sc.items
+		keys := make([]interface{}, 0, len(items))
+		for k := range items {
+			keys = append(keys, k)
+		}
+		return keys, func(key interface{}) (interface{}, bool) {
+			item, ok := items[key]
+			if !ok {
+				return nil, false
+			}
+			return sc.decoded(key, item.value)
+		}
+	})
+}
+
+// Export writes the unexpired entries, with their expiration, to w with codec.
+func (sc *ScoreCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(sc, w, codec)
+}
+
+// Import sets the entries written by Export which have not expired yet,
+// restoring their expiration.
+func (sc *ScoreCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(sc, r, codec)
+}
+
+func (sc *ScoreCache) entries() []SnapshotEntry {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	entries := make([]SnapshotEntry, 0, len(sc.items))
+	for k, item := range sc.items {
+		if v, ok := sc.decoded(k, item.value); ok {
+			entries = append(entries, SnapshotEntry{Key: k, Value: v, Score: item.score, Weight: item.weight})
+		}
+	}
+	return entries
+}
+
+func (sc *ScoreCache) importEntries(entries []SnapshotEntry) {
+	sc.restore(entries, sc.Set, sc.Touch)
+}
+
+// Dump writes the entries of the cache with their age, hits, score and weight to w in
+// format, for debugging.
+func (sc *ScoreCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(sc, w, format)
+}
+
+// Watch returns a channel receiving the changes of key. See Watcher.
+func (sc *ScoreCache) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return sc.watch(key, key)
+}
+
+func (sc *ScoreCache) dumpEntries() ([]dumpEntry, int) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	entries := make([]dumpEntry, 0, len(sc.items))
+	for k, item := range sc.items {
+		e := sc.newDumpEntry(k, item.value, item.created)
+		e.hits = item.hits
+		e.scored, e.score, e.weight = true, item.score, item.weight
+		entries = append(entries, e)
+	}
+	return entries, sc.dumpLimit
+}
+
+// Verify checks the internal invariants of the cache and returns an InvariantError
+// describing the first one which does not hold.
+func (sc *ScoreCache) Verify() error {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	h := sc.evictList
+	if h.Len() != len(sc.items) {
+		return violated("heap has %d entries but the cache has %d", h.Len(), len(sc.items))
+	}
+	weight := 0
+	for i, item := range h.items {
+		if item.index != i {
+			return violated("key %v is at index %d of the heap but records %d", item.key, i, item.index)
+		}
+		if sc.items[item.key] != item {
+			return violated("key %v of the heap maps to another item", item.key)
+		}
+		if i > 0 && h.Less(i, (i-1)/2) {
+			return violated("key %v is ordered before its parent %v in the heap", item.key, h.items[(i-1)/2].key)
+		}
+		weight += item.weight
+	}
+	if weight != sc.totalWeight {
+		return violated("total weight is %d but the entries weigh %d", sc.totalWeight, weight)
+	}
+	return sc.verifyIndexes(func(key interface{}) bool {
+		_, ok := sc.items[key]
+		return ok
+	})
+}
+
+// Explain reports the position of key in the eviction order.
+// The entries with the lowest priority, derived from their score, are evicted first.
+func (sc *ScoreCache) Explain(key interface{}) EvictionExplanation {
+	if sc.checkKey(key) != nil {
+		return notPresentExplanation(key)
+	}
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	item, ok := sc.items[key]
+	if !ok {
+		return notPresentExplanation(key)
+	}
+	ex := EvictionExplanation{
+		Key:      key,
+		Present:  true,
+		Score:    item.score,
+		Weight:   item.weight,
+		Priority: item.priority,
+	}
+	if victim := sc.nextVictim(); victim != nil {
+		ex.NextVictim = victim.key
+	}
+	class := sc.priorityOf(key)
+	for _, it := range sc.evictList.items {
+		if sc.isPinned(it.key) {
+			continue
+		}
+		if c := sc.priorityOf(it.key); c < class || c == class && it.priority < item.priority {
+			ex.Rank++
+		}
+	}
+	switch {
+	case ex.NextVictim == key:
+		ex.Reason = fmt.Sprintf("lowest priority entry (score %d), it is evicted next", item.score)
+	case ex.Rank == 0:
+		ex.Reason = fmt.Sprintf("shares the lowest priority (score %d) with the next victim", item.score)
+	default:
+		ex.Reason = fmt.Sprintf("%d entries have a lower priority", ex.Rank)
+	}
+	ex.Class = class
+	if sc.isPinned(key) {
+		ex.Pinned = true
+		ex.Reason = "pinned, it is exempt from eviction"
+	}
+	return ex
+}
+
+// nextVictim returns the entry evictLowest would evict next, or nil when every
+// entry is pinned: the lowest priority entry of the lowest class, as evictByClass
+// evicts the lower classes first. It leaves the heap untouched.
+func (sc *ScoreCache) nextVictim() *scoredItem {
+	var victim *scoredItem
+	var victimClass Priority
+	for i, it := range sc.evictList.items {
+		if sc.isPinned(it.key) {
+			continue
+		}
+		class := sc.priorityOf(it.key)
+		if victim == nil || class < victimClass || class == victimClass && sc.evictList.Less(i, victim.index) {
+			victim, victimClass = it, class
+		}
+	}
+	return victim
+}
+
+// TotalWeight returns the sum of the weights of the entries.
+func (sc *ScoreCache) TotalWeight() int {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.totalWeight
+}
+
 // Len returns the number of items in the cache
 func (sc *ScoreCache) Len() int {
 	sc.mu.RLock()
@@ -172,114 +914,214 @@ func (sc *ScoreCache) Len() int {
 	return len(sc.items)
 }
 
-// loads an item using the loaderFunc
+// Do executes fn once for all the concurrent calls with the same key, which wait for
+// it and share its result, the way concurrent loads of a key share one call of the
+// LoaderFunc.
+func (sc *ScoreCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := sc.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return sc.doShared(key, fn)
+	}
+	v, err := sc.doCached(key, fn, sc.setLoaded)
+	if err != nil {
+		return nil, err
+	}
+	return sc.decode(key, v)
+}
+
+// loads an item using the loader
 func (sc *ScoreCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if sc.loaderFunc == nil {
+	if err := sc.checkKey(key); err != nil {
+		return nil, err
+	}
+	if sc.loaderExpireFunc == nil {
 		return nil, KeyNotFoundError
 	}
 
-	item, _, err := sc.load(key, func(v interface{}, e error) (interface{}, error) {
-		if e == nil {
-			return sc.set(key, v), nil
-		}
-		return nil, e
-	}, isWait)
+	v, _, err := sc.load(key, sc.setLoaded, isWait)
 	if err != nil {
 		return nil, err
 	}
-	return item.(*scoredItem).value, nil
+	return sc.decode(key, v)
+}
+
+// stores a value returned by the LoaderFunc
+func (sc *ScoreCache) setLoaded(key, value interface{}, _ *time.Duration, _ time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	sc.mu.Lock()
+	defer sc.unlock()
+	if item, ok := sc.items[key]; sc.superseded(key) && ok {
+		// the key was set while it was loading, keep the newer value
+		return item.value, nil
+	}
+	sc.refreshing = true
+	it, err := sc.set(key, value)
+	sc.refreshing = false
+	if err != nil {
+		return nil, err
+	}
+	return it.value, nil
 }
 
 // gets an item from the cache with an options load flag
 func (sc *ScoreCache) get(key interface{}, onLoad bool) (interface{}, error) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
-	return sc.getItem(key, onLoad)
+	return sc.getItem(key, !onLoad)
+}
+
+// lookup returns the stored value of key for the load group,
+// without counting a hit or miss.
+func (sc *ScoreCache) lookup(key interface{}) (interface{}, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if item, ok := sc.items[key]; ok && !released(item.value) {
+		return item.value, nil
+	}
+	return nil, KeyNotFoundError
+}
+
+// peek returns the value for key if it is present,
+// without recording an access or updating statistics.
+func (sc *ScoreCache) peek(key interface{}) (interface{}, bool) {
+	if v, ok := sc.stored(key); ok {
+		return sc.decoded(key, v)
+	}
+	return nil, false
+}
+
+// stored returns the stored form of the value of key, if it is present.
+func (sc *ScoreCache) stored(key interface{}) (interface{}, bool) {
+	if item, ok := sc.items[key]; ok {
+		return item.value, true
+	}
+	return nil, false
 }
 
 // gets an item from the cache (not threadsafe!)
 func (sc *ScoreCache) getItem(key interface{}, count bool) (*scoredItem, error) {
 	item, ok := sc.items[key]
-	if !ok {
+	if !ok || released(item.value) {
 		if count {
-			sc.IncrMissCount()
+			sc.recordMiss(key)
 		}
 		return item, KeyNotFoundError
 	}
 	if count {
-		sc.IncrHitCount()
+		sc.recordHit(key)
 	}
 	return item, nil
 }
 
+// evicts the lowest scored items until at least w weight has been freed
 func (sc *ScoreCache) evictUntil(w int) {
 	targetWeight := sc.totalWeight - w
-	var item *scoredItem
 	for sc.totalWeight > targetWeight {
-		item = heap.Pop(sc.evictList).(*scoredItem)
-		delete(sc.items, item.key)
-		sc.evictedCallback(item.key, item.value)
-		sc.totalWeight -= item.weight
+		if !sc.evictLowest() {
+			return
+		}
 	}
 }
 
-func (sc *ScoreCache) addedCallback(key, value interface{}) {
-	if sc.addedFunc != nil {
-		(*sc.addedFunc)(key, value)
-	}
+// evicts the lowest scored item which is not pinned, lowest class first,
+// returns false if there is no such item
+func (sc *ScoreCache) evictLowest() (evicted bool) {
+	sc.evictByClass(func() bool {
+		evicted = sc.evictLowestOfClass()
+		return evicted
+	})
+	return evicted
 }
 
-func (sc *ScoreCache) evictedCallback(key, value interface{}) {
-	if sc.evictedFunc != nil {
-		(*sc.evictedFunc)(key, value)
-	}
-}
-
-func (sc *ScoreCache) getIndex(key interface{}) (int, error) {
-	for i, item := range []*scoredItem(*sc.evictList) {
-		if item.key == key {
-			return i, nil
+// evicts the lowest scored item the current eviction pass admits
+func (sc *ScoreCache) evictLowestOfClass() bool {
+	var skipped []*scoredItem
+	defer func() {
+		for _, item := range skipped {
+			heap.Push(sc.evictList, item)
+		}
+	}()
+	for sc.evictList.Len() > 0 {
+		item := heap.Pop(sc.evictList).(*scoredItem)
+		if !sc.evictable(item.key) {
+			skipped = append(skipped, item)
+			continue
 		}
+		delete(sc.items, item.key)
+		sc.forget(item.key)
+		sc.release(item.key, item.value)
+		sc.recordEviction(item.key, item.created)
+		sc.evicted(item.key, item.value)
+		sc.totalWeight -= item.weight
+		return true
 	}
-	return -1, KeyNotFoundError
+	return false
 }
 
 type scoredItem struct {
-	key    interface{}
-	value  interface{}
-	score  int
-	weight int
+	key      interface{}
+	value    interface{}
+	score    int
+	weight   int
+	priority float64
+	accessed time.Time
+	created  time.Time
+	hits     uint64
+	inserted uint64 // sequence number of the insertion
+	used     uint64 // sequence number of the last use
+	index    int    // position in the priorityHeap
 }
 
 func (sc *ScoreCache) newScoredItem(key, value interface{}) *scoredItem {
-	score := sc.computeScore(value)
-	weight := sc.computeWeight(value)
+	score := sc.score(value)
+	weight := sc.weight(value)
 
-	return &scoredItem{key: key, value: value, score: score, weight: weight}
+	now := sc.clock.Now()
+	item := &scoredItem{key: key, value: value, score: score, weight: weight, accessed: now, created: now}
+	item.inserted = sc.nextSeq()
+	item.used = item.inserted
+	item.priority = sc.priority(item)
+	return item
 }
 
-type priorityHeap []*scoredItem
+type priorityHeap struct {
+	items      []*scoredItem
+	tieBreaker TieBreaker
+}
 
 func (h *priorityHeap) Push(x interface{}) {
 	item := x.(*scoredItem)
-	*h = append(*h, item)
+	item.index = len(h.items)
+	h.items = append(h.items, item)
 }
 
 func (h *priorityHeap) Pop() interface{} {
-	old := *h
+	old := h.items
 	item := old[len(old)-1]
-	*h = old[0 : len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	h.items = old[0 : len(old)-1]
 	return item
 }
 
-func (h priorityHeap) Len() int {
-	return len(h)
+func (h *priorityHeap) Len() int {
+	return len(h.items)
 }
 
-func (h priorityHeap) Less(i, j int) bool {
-	return h[i].score < h[j].score
+func (h *priorityHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.priority != b.priority || h.tieBreaker == nil {
+		return a.priority < b.priority
+	}
+	return h.tieBreaker(a.entry(), b.entry())
 }
 
-func (h priorityHeap) Swap(i, j int) {
-	h[i], h[j] = h[j], h[i]
+func (h *priorityHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
 }
@@ -3,6 +3,7 @@ package gcache
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -110,6 +111,37 @@ func TestScoreCache_MaxSize(t *testing.T) {
 	assert.Equal(t, 10, c.(*ScoreCache).totalWeight)
 }
 
+func TestScoreCache_Rejections(t *testing.T) {
+	c := buildScoreCache(10, 20)
+	c.Set("a", 1)
+	c.Set("a", 2)
+
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, uint64(2), c.Stats().Rejections)
+}
+
+func TestScoreCache_RejectedFunc(t *testing.T) {
+	var rejected []interface{}
+	c := New(10).
+		SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(v interface{}) int { return v.(int) }).
+		RejectedFunc(func(key, value interface{}, reason RejectionReason) {
+			assert.Equal(t, RejectedOverweight, reason)
+			rejected = append(rejected, value)
+		}).
+		Build()
+	c.Set("a", 1)
+	c.Set("a", 20)
+	c.Set("b", 30)
+
+	assert.Equal(t, []interface{}{20, 30}, rejected)
+	assert.Equal(t, uint64(2), c.Stats().Rejections)
+
+	_, err := New(10).LRU().RejectedFunc(func(_, _ interface{}, _ RejectionReason) {}).BuildE()
+	assert.Error(t, err)
+}
+
 func TestScoreCache_Eviction(t *testing.T) {
 	evictions := 0
 	evicted := make(map[int]int)
@@ -271,6 +303,274 @@ func TestScoreCache_Stats(t *testing.T) {
 	})
 }
 
+func TestScoreCache_Remove_First(t *testing.T) {
+	c := buildScoreCache(10, 2)
+	c.Set(1, 1)
+
+	assert.True(t, c.Remove(1))
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, 0, c.(*ScoreCache).totalWeight)
+	assert.Equal(t, 0, c.(*ScoreCache).evictList.Len())
+}
+
+func TestScoreCache_Rescore(t *testing.T) {
+	scores := map[int]int{1: 1, 2: 2, 3: 3}
+	weights := map[int]int{1: 1, 2: 1, 3: 1, 4: 1}
+	var evicted []interface{}
+	c := New(3).
+		SCORE().
+		ScoringFunc(func(v interface{}) int { return scores[v.(int)] }).
+		WeightingFunc(func(v interface{}) int { return weights[v.(int)] }).
+		EvictedFunc(func(key, _ interface{}) {
+			evicted = append(evicted, key)
+		}).
+		Build().(*ScoreCache)
+
+	for i := 1; i <= 3; i++ {
+		c.Set(i, i)
+	}
+
+	assert.False(t, c.Rescore(4))
+
+	scores[1] = 10
+	assert.True(t, c.Rescore(1))
+	c.Set(4, 4)
+	assert.Equal(t, []interface{}{2}, evicted)
+
+	scores[3] = 100
+	scores[4] = 100
+	weights[1] = 2
+	c.RescoreAll()
+	assert.Equal(t, []interface{}{2, 1}, evicted)
+	assert.Equal(t, 2, c.totalWeight)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestScoreCache_RescoreOverCapacity(t *testing.T) {
+	scores := map[string]int{"a": 1, "b": 2, "c": 3}
+	weights := map[string]int{"a": 1, "b": 1, "c": 1}
+	var evicted, rejected []interface{}
+	c := New(3).
+		SCORE().
+		ScoringFunc(func(v interface{}) int { return scores[v.(string)] }).
+		WeightingFunc(func(v interface{}) int { return weights[v.(string)] }).
+		KeyValidator(func(key interface{}) error {
+			if _, ok := key.(string); !ok {
+				return &InvalidKeyError{Key: key, Reason: "not a string"}
+			}
+			return nil
+		}).
+		EvictedFunc(func(key, _ interface{}) { evicted = append(evicted, key) }).
+		RejectedFunc(func(key, _ interface{}, reason RejectionReason) {
+			assert.Equal(t, RejectedOverweight, reason)
+			rejected = append(rejected, key)
+		}).
+		Build().(*ScoreCache)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(key, key)
+	}
+
+	assert.False(t, c.Rescore(1))
+
+	// rescoring c past the capacity evicts the lowest scored entry
+	weights["c"] = 2
+	assert.True(t, c.Rescore("c"))
+	assert.Equal(t, []interface{}{"a"}, evicted)
+	assert.Equal(t, 3, c.totalWeight)
+	assert.NoError(t, c.Verify())
+
+	// an entry heavier than the cache is rejected
+	weights["b"] = 4
+	assert.True(t, c.Rescore("b"))
+	assert.Equal(t, []interface{}{"b"}, rejected)
+	assert.Equal(t, []interface{}{"c"}, c.Keys())
+	assert.NoError(t, c.Verify())
+
+	weights["c"] = 5
+	c.RescoreAll()
+	assert.Equal(t, []interface{}{"b", "c"}, rejected)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, uint64(2), c.Stats().Rejections)
+	assert.NoError(t, c.Verify())
+}
+
+func TestScoreCache_ScoreDecay(t *testing.T) {
+	build := func() Cache {
+		return New(2).
+			SCORE().
+			ScoringFunc(func(v interface{}) int { return v.(int) }).
+			WeightingFunc(func(_ interface{}) int { return 1 }).
+			ScoreDecay(20 * time.Millisecond).
+			Build()
+	}
+
+	c := build()
+	c.Set("a", 4)
+	time.Sleep(70 * time.Millisecond)
+	c.Set("b", 1)
+	c.Set("c", 4)
+	_, err := c.GetIFPresent("a")
+	assert.Equal(t, KeyNotFoundError, err)
+	_, err = c.GetIFPresent("b")
+	assert.Nil(t, err)
+
+	// accessing an item restarts its decay
+	c = build()
+	c.Set("a", 4)
+	time.Sleep(70 * time.Millisecond)
+	c.Set("b", 1)
+	c.Get("a")
+	c.Set("c", 4)
+	_, err = c.GetIFPresent("a")
+	assert.Nil(t, err)
+	_, err = c.GetIFPresent("b")
+	assert.Equal(t, KeyNotFoundError, err)
+}
+
+func TestScoreCache_AccessBoost(t *testing.T) {
+	c := New(2).
+		SCORE().
+		ScoringFunc(func(v interface{}) int { return v.(int) }).
+		WeightingFunc(func(_ interface{}) int { return 1 }).
+		AccessBoost(1).
+		Build()
+
+	c.Set("a", 2)
+	c.Set("b", 3)
+	for i := 0; i < 5; i++ {
+		c.Get("a")
+	}
+	c.Set("c", 4)
+
+	_, err := c.GetIFPresent("a")
+	assert.Nil(t, err)
+	_, err = c.GetIFPresent("b")
+	assert.Equal(t, KeyNotFoundError, err)
+}
+
+func TestScoreCache_InvalidWeights(t *testing.T) {
+	c := New(10).
+		SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(v interface{}) int { return v.(int) }).
+		Build().(*ScoreCache)
+
+	c.Set("negative", -5)
+	c.Set("zero", 0)
+	assert.Equal(t, 1, c.items["negative"].weight)
+	assert.Equal(t, 2, c.totalWeight)
+
+	c.Set("a", 4)
+	c.Set("too heavy", 11)
+	assert.Equal(t, 3, c.Len())
+	assert.Equal(t, 6, c.totalWeight)
+
+	// growing an existing item beyond the cache size removes it
+	c.Set("a", 12)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 2, c.totalWeight)
+}
+
+func TestScoreCache_ScoringFallback(t *testing.T) {
+	build := func(b *CacheBuilder) *ScoreCache {
+		return b.
+			SCORE().
+			ScoringFunc(func(v interface{}) int {
+				if v == "bad" {
+					panic("bad score")
+				}
+				return 10
+			}).
+			WeightingFunc(func(v interface{}) int {
+				if v == "bad" {
+					panic("bad weight")
+				}
+				return 1
+			}).
+			Build().(*ScoreCache)
+	}
+
+	c := build(New(10))
+	c.Set("a", "bad")
+	assert.Equal(t, 0, c.items["a"].score)
+	assert.Equal(t, 1, c.items["a"].weight)
+	assert.Equal(t, 1, c.totalWeight)
+
+	c = build(New(10).ScoringFallback(-1, 3))
+	c.Set("a", "bad")
+	c.Set("b", "good")
+	assert.Equal(t, -1, c.items["a"].score)
+	assert.Equal(t, 3, c.items["a"].weight)
+	assert.Equal(t, 4, c.totalWeight)
+}
+
+func TestScoreCache_MaxEntries(t *testing.T) {
+	c := New(100).
+		SCORE().
+		ScoringFunc(func(v interface{}) int { return v.(int) }).
+		WeightingFunc(func(_ interface{}) int { return 1 }).
+		MaxEntries(5).
+		Build().(*ScoreCache)
+
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+	assert.Equal(t, 5, c.Len())
+	assert.Equal(t, 5, c.totalWeight)
+	for i := 5; i < 10; i++ {
+		_, err := c.GetIFPresent(i)
+		assert.Nil(t, err)
+	}
+}
+
+func TestScoreCache_EvictOnlyWhatIsNeeded(t *testing.T) {
+	c := New(10).
+		SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(v interface{}) int { return v.(int) }).
+		Build().(*ScoreCache)
+
+	c.Set("a", 3)
+	c.Set("b", 3)
+	c.Set("c", 6)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 9, c.totalWeight)
+}
+
+func TestScoreCache_TieBreaker(t *testing.T) {
+	build := func(tb TieBreaker) *ScoreCache {
+		c := New(3).
+			SCORE().
+			ScoringFunc(func(_ interface{}) int { return 1 }).
+			WeightingFunc(func(_ interface{}) int { return 1 }).
+			TieBreaker(tb).
+			Build().(*ScoreCache)
+		for i := 0; i < 3; i++ {
+			c.Set(i, i)
+		}
+		c.Get(0)
+		c.Set(3, 3)
+		return c
+	}
+	present := func(c *ScoreCache) []bool {
+		var p []bool
+		for i := 0; i < 4; i++ {
+			_, ok := c.items[i]
+			p = append(p, ok)
+		}
+		return p
+	}
+
+	assert.Equal(t, []bool{false, true, true, true}, present(build(InsertionOrder)))
+	assert.Equal(t, []bool{true, false, true, true}, present(build(LeastRecentlyUsed)))
+	assert.Equal(t, []bool{true, true, false, true}, present(build(func(a, b ScoredEntry) bool {
+		return a.Key.(int) > b.Key.(int)
+	})))
+
+	_, err := New(3).LRU().TieBreaker(InsertionOrder).BuildE()
+	assert.NotNil(t, err)
+}
+
 func BenchScoreCache_Set(b *testing.B) {
 
 }
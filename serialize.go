@@ -0,0 +1,101 @@
+package gcache
+
+// SerializeFunc encodes the value of a key before it is stored in the cache.
+type SerializeFunc func(key, value interface{}) (interface{}, error)
+
+// DeserializeFunc decodes a value which was stored in the cache.
+type DeserializeFunc func(key, value interface{}) (interface{}, error)
+
+// Store values in the form returned by serializeFunc, e.g. gob encoded bytes,
+// rather than as given. Items whose value cannot be serialized are not stored,
+// and any previous value of their key is removed.
+// ScoringFunc, WeightingFunc, AddedFunc and EvictedFunc receive the serialized value.
+func (cb *CacheBuilder) SerializeFunc(serializeFunc SerializeFunc) *CacheBuilder {
+	cb.serializeFunc = serializeFunc
+	return cb
+}
+
+// Decode the values stored in the cache with deserializeFunc before returning them.
+// Values which cannot be deserialized are treated as missing, so Get reloads them
+// if there is a LoaderFunc and returns the error if the loaded value cannot be
+// deserialized either.
+func (cb *CacheBuilder) DeserializeFunc(deserializeFunc DeserializeFunc) *CacheBuilder {
+	cb.deserializeFunc = deserializeFunc
+	return cb
+}
+
+// encode returns the value to store for key.
+func (c *baseCache) encode(key, value interface{}) (v interface{}, err error) {
+	v = value
+	if c.copyOnSet != nil {
+		v = c.copyOnSet(v)
+	}
+	if c.serializeFunc != nil {
+		v, err = c.serializeFunc(key, v)
+	}
+	if err == nil && c.overflow != nil {
+		v, err = c.spill(v)
+	}
+	if err == nil && c.codec != nil {
+		v, err = c.compress(v)
+	}
+	if err == nil && c.arena != nil {
+		v = c.store(v)
+	}
+	if err == nil && c.weak != nil {
+		v = c.weak.hold(v)
+	}
+	if err != nil && c.logger != nil {
+		c.logger.Warn("gcache: serialization failed", "key", key, "error", err)
+	}
+	return v, err
+}
+
+// decode returns the value of key from its stored form.
+func (c *baseCache) decode(key, value interface{}) (v interface{}, err error) {
+	if v, err = c.unwrap(value); err != nil {
+		return nil, err
+	}
+	if c.deserializeFunc != nil {
+		if v, err = c.deserializeFunc(key, v); err != nil {
+			return nil, err
+		}
+	}
+	if c.copyOnGet != nil {
+		v = c.copyOnGet(v)
+	}
+	return v, nil
+}
+
+// unwrap returns the value as it was set, or as serialized by the SerializeFunc,
+// from its stored form.
+func (c *baseCache) unwrap(value interface{}) (v interface{}, err error) {
+	v = value
+	if c.weak != nil {
+		if v, err = c.unweak(v); err != nil {
+			return nil, err
+		}
+	}
+	if c.arena != nil {
+		if v, err = c.fetch(v); err != nil {
+			return nil, err
+		}
+	}
+	if c.codec != nil {
+		if v, err = c.decompress(v); err != nil {
+			return nil, err
+		}
+	}
+	if c.overflow != nil {
+		if v, err = c.unspill(v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// decoded is decode for methods which report missing values rather than errors.
+func (c *baseCache) decoded(key, value interface{}) (interface{}, bool) {
+	v, err := c.decode(key, value)
+	return v, err == nil
+}
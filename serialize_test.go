@@ -0,0 +1,91 @@
+package gcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func gobSerialize(_, value interface{}) (interface{}, error) {
+	if _, ok := value.(chan int); ok {
+		return nil, errors.New("cannot serialize a channel")
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDeserialize(_, value interface{}) (interface{}, error) {
+	var v interface{}
+	err := gob.NewDecoder(bytes.NewReader(value.([]byte))).Decode(&v)
+	return v, err
+}
+
+func init() {
+	gob.Register([]int{})
+}
+
+func TestSerializeFunc(t *testing.T) {
+	for _, builder := range append(expiringCacheBuilders(10),
+		New(1000).SCORE().
+			ScoringFunc(func(_ interface{}) int { return 1 }).
+			WeightingFunc(func(v interface{}) int { return len(v.([]byte)) })) {
+		var stored interface{}
+		gc := builder.
+			SerializeFunc(gobSerialize).
+			DeserializeFunc(gobDeserialize).
+			AddedFunc(func(_, value interface{}) { stored = value }).
+			Build()
+
+		value := []int{1, 2, 3}
+		gc.Set("a", value)
+		value[0] = 100
+		if _, ok := stored.([]byte); !ok {
+			t.Errorf("%T: stored value is a %T, not []byte", gc, stored)
+		}
+
+		v, err := gc.Get("a")
+		if err != nil {
+			t.Fatalf("%T: %v", gc, err)
+		}
+		if !reflect.DeepEqual(v, []int{1, 2, 3}) {
+			t.Errorf("%T: %v != [1 2 3]", gc, v)
+		}
+		if all := gc.GetALL(); !reflect.DeepEqual(all["a"], []int{1, 2, 3}) {
+			t.Errorf("%T: GetALL: %v != [1 2 3]", gc, all["a"])
+		}
+
+		gc.Set("a", make(chan int))
+		if _, err := gc.Get("a"); err != KeyNotFoundError {
+			t.Errorf("%T: value which cannot be serialized should replace the previous one, got %v", gc, err)
+		}
+		if err := gc.Update("a", func(interface{}, bool) (interface{}, error) { return make(chan int), nil }); err == nil {
+			t.Errorf("%T: Update should return the serialization error", gc)
+		}
+	}
+}
+
+func TestDeserializeFuncWithLoader(t *testing.T) {
+	for _, builder := range expiringCacheBuilders(10) {
+		gc := builder.
+			SerializeFunc(gobSerialize).
+			DeserializeFunc(gobDeserialize).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				return key, nil
+			}).
+			Build()
+		for i := 0; i < 2; i++ {
+			if v, err := gc.Get("a"); err != nil || v != "a" {
+				t.Errorf("%T: Get: %v, %v", gc, v, err)
+			}
+		}
+		vs, err := gc.GetMulti([]interface{}{"a", "b"})
+		if err != nil || vs["a"] != "a" || vs["b"] != "b" {
+			t.Errorf("%T: GetMulti: %v, %v", gc, vs, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gcache
+
+// Shards splits a key space across several caches, picking the cache of a key
+// by its hash, so that writes to different shards do not contend for one lock.
+type Shards struct {
+	shards []Cache
+	hash   HashFunc
+}
+
+// BuildShards builds n caches with the configuration of the builder, which share
+// its size between them, and returns them as Shards. Keys are assigned to shards
+// with the Hasher. The entries of WarmFrom are set in the shards of their keys.
+func (cb *CacheBuilder) BuildShards(n int) (*Shards, error) {
+	if n <= 0 {
+		return nil, &ConfigError{Reason: "BuildShards requires a positive number of shards"}
+	}
+	if err := cb.validate(); err != nil {
+		return nil, err
+	}
+	if cb.autoSnapshotSink != nil {
+		return nil, &ConfigError{Reason: "AutoSnapshot cannot be shared by shards"}
+	}
+	s := &Shards{shards: make([]Cache, n), hash: cb.hasher}
+	if s.hash == nil {
+		s.hash = DefaultHash
+	}
+	shard := *cb
+	shard.size = (cb.size + n - 1) / n
+	shard.warmFrom = nil
+	for i := range s.shards {
+		s.shards[i] = shard.build()
+	}
+	if cb.warmFrom != nil {
+		for {
+			key, value, ok := cb.warmFrom()
+			if !ok {
+				break
+			}
+			s.Shard(key).Set(key, value)
+		}
+	}
+	return s, nil
+}
+
+// Shard returns the cache which holds key.
+func (s *Shards) Shard(key interface{}) Cache {
+	return s.shards[s.hash(key)%uint64(len(s.shards))]
+}
+
+// Each calls fn with every shard and its index.
+func (s *Shards) Each(fn func(i int, c Cache)) {
+	for i, c := range s.shards {
+		fn(i, c)
+	}
+}
+
+// Len returns the number of entries across all shards.
+func (s *Shards) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// Report returns the statistics and balance of the shards, see ShardReport.
+func (s *Shards) Report() ShardBalance {
+	return ShardReport(s.shards...)
+}
+
+// Close closes all the shards and returns the first error, if any.
+func (s *Shards) Close() error {
+	var first error
+	for _, c := range s.shards {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
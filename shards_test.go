@@ -0,0 +1,55 @@
+package gcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuildShards(t *testing.T) {
+	i := 0
+	shards, err := New(100).LRU().WarmFrom(func() (interface{}, interface{}, bool) {
+		i++
+		return fmt.Sprint("key-", i), i, i <= 50
+	}).BuildShards(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shards.Len() != 50 {
+		t.Errorf("Len() = %d after warming 50 keys", shards.Len())
+	}
+	for i := 1; i <= 50; i++ {
+		key := fmt.Sprint("key-", i)
+		if v, err := shards.Shard(key).Get(key); v != i || err != nil {
+			t.Errorf("Get(%v) = %v, %v", key, v, err)
+		}
+	}
+	shards.Each(func(i int, c Cache) {
+		if n := c.Len(); n == 0 || n > 25 {
+			t.Errorf("shard %d holds %d entries", i, n)
+		}
+	})
+	if b := shards.Report(); len(b.Shards) != 4 || b.Shards[0].Hits == 0 {
+		t.Errorf("unexpected report %+v", b)
+	}
+	if err := shards.Close(); err != nil {
+		t.Error(err)
+	}
+
+	byLength := func(key interface{}) uint64 { return uint64(len(key.(string))) }
+	shards, _ = New(8).LRU().Hasher(byLength).BuildShards(2)
+	shards.Shard("ab").Set("ab", 1)
+	shards.Each(func(i int, c Cache) {
+		if c.Has("ab") != (i == 0) {
+			t.Errorf("shard %d has the key: %v", i, c.Has("ab"))
+		}
+	})
+}
+
+func TestBuildShardsInvalid(t *testing.T) {
+	if _, err := New(8).LRU().BuildShards(0); err == nil {
+		t.Errorf("BuildShards(0) succeeded")
+	}
+	if _, err := New(8).LRU().AutoSnapshot(1, SnapshotSinkFunc(nil)).BuildShards(2); err == nil {
+		t.Errorf("BuildShards with AutoSnapshot succeeded")
+	}
+}
@@ -1,6 +1,7 @@
 package gcache
 
 import (
+	"io"
 	"time"
 )
 
@@ -16,24 +17,55 @@ func newSimpleCache(cb *CacheBuilder) *SimpleCache {
 
 	c.init()
 	c.loadGroup.cache = c
+	c.evictKey = c.remove
+	c.monitorMemory(c.shed, c.Len)
+	c.startAutoSnapshot(c.Export)
 	return c
 }
 
 func (c *SimpleCache) init() {
 	c.items = make(map[interface{}]*simpleItem, c.size)
+	c.pinned = nil
+	c.priorities = nil
+	c.copies = nil // Snapshots in progress keep reading the previous entries
 }
 
 // set a new key-value pair
 func (c *SimpleCache) Set(key, value interface{}) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.set(key, value)
 }
 
+// SetWithExpire sets a new key-value pair which expires after expiration,
+// instead of the Expiration of the cache.
+func (c *SimpleCache) SetWithExpire(key, value interface{}, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	it, err := c.set(key, value)
+	if err != nil {
+		return
+	}
+	t := c.clock.Now().Add(expiration)
+	it.(*simpleItem).expiration = &t
+}
+
 func (c *SimpleCache) set(key, value interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	c.written(key)
+	value, err := c.encode(key, value)
+	if err != nil {
+		c.remove(key)
+		return nil, err
+	}
+
 	// Check for existing item
 	item, ok := c.items[key]
 	if ok {
+		c.updated(key, item.value, value)
+		c.release(key, item.value)
 		item.value = value
 	} else {
 		// Verify size not exceeded
@@ -41,19 +73,20 @@ func (c *SimpleCache) set(key, value interface{}) (interface{}, error) {
 			c.evict(1)
 		}
 		item = &simpleItem{
-			value: value,
+			value:   value,
+			created: c.clock.Now(),
 		}
 		c.items[key] = item
 	}
 
 	if c.expiration != nil {
-		t := time.Now().Add(*c.expiration)
+		t := c.clock.Now().Add(c.jitter(*c.expiration))
 		item.expiration = &t
 	}
+	item.accessExpiration = c.newAccessExpiration()
+	item.refreshAt = c.newRefreshAt()
 
-	if c.addedFunc != nil {
-		(*c.addedFunc)(key, value)
-	}
+	c.added(key, value)
 
 	return item, nil
 }
@@ -69,6 +102,12 @@ func (c *SimpleCache) Get(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// GetAsync returns a channel which receives the result of Get for key once it is
+// available, without waiting for the LoaderFunc.
+func (c *SimpleCache) GetAsync(key interface{}) <-chan Result {
+	return c.getAsync(key, c.getValue, c.getWithLoader)
+}
+
 // Get a value from cache pool using key if it exists.
 // If it dose not exists key, returns KeyNotFoundError.
 // And send a request which refresh value for specified key if cache object has LoaderFunc.
@@ -80,115 +119,637 @@ func (c *SimpleCache) GetIFPresent(key interface{}) (interface{}, error) {
 	return v, nil
 }
 
+// Has reports whether key is cached and not expired, without counting
+// a hit or miss, updating the eviction policy or loading it.
+func (c *SimpleCache) Has(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.items[key]
+	return ok && !item.IsExpired(c.clock)
+}
+
+// GetMulti returns the values of all keys which are cached or can be loaded.
+func (c *SimpleCache) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	return c.getMulti(keys, c.getValue, c.getWithLoader, c.setLoaded)
+}
+
+// Warm loads the keys which are not cached yet with the LoaderFunc, running up to
+// concurrency loads at a time, e.g. to fill the cache before taking traffic.
+func (c *SimpleCache) Warm(keys []interface{}, concurrency int) error {
+	return c.warm(keys, concurrency, c.Has, c.getWithLoader)
+}
+
 func (c *SimpleCache) get(key interface{}, onLoad bool) (interface{}, error) {
 	c.mu.RLock()
 	item, ok := c.items[key]
 	c.mu.RUnlock()
 	if ok {
-		if !item.IsExpired(nil) {
+		if !item.IsExpired(c.clock) {
+			if c.expireAfterAccess != nil {
+				c.mu.Lock()
+				item.accessExpiration = c.newAccessExpiration()
+				c.unlock()
+			}
 			if !onLoad {
-				c.stats.IncrHitCount()
+				c.stats.recordHit(key)
 			}
 			return item, nil
 		}
-		c.mu.Lock()
-		c.remove(key)
-		c.mu.Unlock()
+		if !c.keepStale(item.expiration, item.accessExpiration) {
+			c.mu.Lock()
+			c.expire(item.created, func() { c.remove(key) })
+			c.unlock()
+		}
 	}
 	if !onLoad {
-		c.stats.IncrMissCount()
+		c.stats.recordMiss(key)
+	}
+	return nil, KeyNotFoundError
+}
+
+// lookup returns the stored value of key for the load group, without counting
+// a hit or miss. Expired entries are misses, and are left in place.
+func (c *SimpleCache) lookup(key interface{}) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, nil
 	}
 	return nil, KeyNotFoundError
 }
 
 func (c *SimpleCache) getValue(key interface{}) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
 	it, err := c.get(key, false)
 	if err != nil {
+		c.missed(key)
 		return nil, err
 	}
-	return it.(*simpleItem).value, nil
+	item := it.(*simpleItem)
+	if c.xfetchBeta > 0 || c.refreshesAfterWrite() {
+		c.mu.RLock()
+		v, expiration, delta, refreshAt := item.value, item.expiration, item.delta, item.refreshAt
+		c.mu.RUnlock()
+		c.refreshEarly(key, expiration, delta, c.setLoaded)
+		c.refreshAfterWrite(key, refreshAt, c.setLoaded)
+		return c.decode(key, v)
+	}
+	return c.decode(key, item.value)
+}
+
+// Do executes fn once for all the concurrent calls with the same key, which wait for
+// it and share its result, the way concurrent loads of a key share one call of the
+// LoaderFunc.
+func (c *SimpleCache) Do(key interface{}, fn func() (interface{}, error), cacheResult bool) (interface{}, error) {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if !cacheResult {
+		return c.doShared(key, fn)
+	}
+	v, err := c.doCached(key, fn, c.setLoaded)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(key, v)
 }
 
 func (c *SimpleCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderFunc == nil {
+	if err := c.checkKey(key); err != nil {
+		return nil, err
+	}
+	if c.loaderExpireFunc == nil {
 		return nil, KeyNotFoundError
 	}
-	it, _, err := c.load(key, func(v interface{}, e error) (interface{}, error) {
-		if e == nil {
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			return c.set(key, v)
+	v, _, err := c.load(key, c.setLoaded, isWait)
+	if err != nil {
+		if err != KeyNotFoundError {
+			if v, ok := c.stale(key); ok {
+				return v, nil
+			}
 		}
-		return nil, e
-	}, isWait)
+		return nil, err
+	}
+	return c.decode(key, v)
+}
+
+// stale returns the value of an expired entry which StaleIfError still allows to serve.
+func (c *SimpleCache) stale(key interface{}) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if item, ok := c.items[key]; ok && c.keepStale(item.expiration, item.accessExpiration) {
+		return c.decoded(key, item.value)
+	}
+	return nil, false
+}
+
+// stores a loaded value along with its expiration and the time it took to load
+func (c *SimpleCache) setLoaded(key, value interface{}, ttl *time.Duration, elapsed time.Duration, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	if item, ok := c.items[key]; c.superseded(key) && ok && !item.IsExpired(c.clock) {
+		// the key was set while it was loading, keep the newer value
+		return item.value, nil
+	}
+	c.refreshing = true
+	it, err := c.set(key, value)
+	c.refreshing = false
 	if err != nil {
 		return nil, err
 	}
-	return it.(*simpleItem).value, nil
+	item := it.(*simpleItem)
+	if ttl != nil {
+		t := c.clock.Now().Add(*ttl)
+		item.expiration = &t
+	}
+	item.delta = elapsed
+	return item.value, nil
+}
+
+// peek returns the value for key if it is present and not expired,
+// without updating statistics.
+func (c *SimpleCache) peek(key interface{}) (interface{}, bool) {
+	if v, ok := c.stored(key); ok {
+		return c.decoded(key, v)
+	}
+	return nil, false
+}
+
+// stored returns the stored form of the value of key, if it is present.
+func (c *SimpleCache) stored(key interface{}) (interface{}, bool) {
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		return item.value, true
+	}
+	return nil, false
 }
 
 func (c *SimpleCache) evict(count int) {
-	now := time.Now()
+	now := c.clock.Now()
 	current := 0
-	for key, item := range c.items {
-		if current >= count {
-			return
-		}
-		if item.expiration == nil || now.After(*item.expiration) {
-			defer c.remove(key)
-			current += 1
+	c.evictByClass(func() bool {
+		for key, item := range c.items {
+			if current >= count {
+				return true
+			}
+			if !c.evictable(key) {
+				continue
+			}
+			if item.expiration == nil {
+				c.remove(key)
+				c.recordEviction(key, item.created)
+				current += 1
+			} else if now.After(*item.expiration) {
+				c.expire(item.created, func() { c.remove(key) })
+				current += 1
+			}
 		}
-	}
+		return current >= count
+	})
 }
 
 // Removes the provided key from the cache.
 func (c *SimpleCache) Remove(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.remove(key)
+}
+
+// RemoveAll removes the provided keys from the cache under a single lock acquisition
+// and returns how many of them were in the cache.
+func (c *SimpleCache) RemoveAll(keys ...interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.removeKeys(keys, c.remove)
+}
+
+// GetOrSet returns the existing value for the key if present.
+func (c *SimpleCache) GetOrSet(key, value interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if item, ok := c.items[key]; ok && !item.IsExpired(c.clock) {
+		if v, ok := c.decoded(key, item.value); ok {
+			item.accessExpiration = c.newAccessExpiration()
+			return v, true
+		}
+	}
+	c.set(key, value)
+	return value, false
+}
+
+// Update atomically replaces the value for key with the result of fn,
+// which receives the current value and whether it exists.
+func (c *SimpleCache) Update(key interface{}, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	if err := c.checkKey(key); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	current, exists := c.peek(key)
+	value, err := fn(current, exists)
+	if err != nil {
+		return err
+	}
+	_, err = c.set(key, value)
+	return err
+}
+
+// Increment atomically adds delta to the integer stored under key and returns the result.
+// int, int64 and uint64 values keep their type; a missing key is created holding delta.
+func (c *SimpleCache) Increment(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, delta)
+}
+
+// Decrement atomically subtracts delta from the integer stored under key and returns the result.
+func (c *SimpleCache) Decrement(key interface{}, delta int64) (int64, error) {
+	return increment(c, key, -delta)
+}
+
+// Touch resets the expiration of key without reading its value.
+// The expiration is restarted with ttl if given, or with the default expiration otherwise.
+func (c *SimpleCache) Touch(key interface{}, ttl ...time.Duration) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok || item.IsExpired(c.clock) {
+		return false
+	}
+	item.expiration = c.touchedExpiration(item.expiration, ttl)
+	item.accessExpiration = c.newAccessExpiration()
+	return true
+}
+
+// Pin exempts key from capacity eviction until it is unpinned.
+func (c *SimpleCache) Pin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.peek(key); !ok {
+		return false
+	}
+	c.pin(key)
+	return true
+}
+
+// Unpin makes key evictable again.
+func (c *SimpleCache) Unpin(key interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.unpin(key)
+}
+
+// SetWithPriority sets a value like Set and assigns the entry an eviction class.
+func (c *SimpleCache) SetWithPriority(key, value interface{}, priority Priority) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+	if _, err := c.set(key, value); err == nil {
+		c.prioritize(key, priority)
+	}
+}
 
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the cache is equal to old.
+func (c *SimpleCache) CompareAndSwap(key, old, new interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
+	_, err := c.set(key, new)
+	return err == nil
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+func (c *SimpleCache) CompareAndDelete(key, old interface{}) bool {
+	if c.checkKey(key) != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	if v, ok := c.stored(key); !ok || !c.matches(key, v, old) {
+		return false
+	}
 	return c.remove(key)
 }
 
+// GetAndRemove removes the provided key from the cache and returns its value.
+func (c *SimpleCache) GetAndRemove(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.IsExpired(c.clock) {
+		c.expire(item.created, func() { c.remove(key) })
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, ok := c.decoded(key, item.value)
+	c.remove(key)
+	return value, ok
+}
+
+// RemoveGet removes the provided key from the cache and returns the value it held, so
+// that the value can be cleaned up.
+func (c *SimpleCache) RemoveGet(key interface{}) (interface{}, bool) {
+	if c.checkKey(key) != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	// decode first, removal frees the value when it is stored in an arena
+	value, _ := c.decoded(key, item.value)
+	c.remove(key)
+	return value, true
+}
+
+// RemoveAt schedules the removal of key at t, independent of its expiration.
+func (c *SimpleCache) RemoveAt(key interface{}, t time.Time) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, t.Sub(c.clock.Now()), c.Remove)
+}
+
+// RemoveAfter schedules the removal of key after d, independent of its expiration.
+func (c *SimpleCache) RemoveAfter(key interface{}, d time.Duration) {
+	if c.checkKey(key) != nil {
+		return
+	}
+	c.removals.schedule(key, d, c.Remove)
+}
+
 func (c *SimpleCache) remove(key interface{}) bool {
 	item, ok := c.items[key]
 	if ok {
 		delete(c.items, key)
-		if c.evictedFunc != nil {
-			(*c.evictedFunc)(key, item.value)
-		}
+		c.forget(key)
+		c.release(key, item.value)
+		c.evicted(key, item.value, item.expiration, item.accessExpiration)
 		return true
 	}
 	return false
 }
 
-// Returns a slice of the keys in the cache.
+// Returns a slice of the unexpired keys in the cache.
 func (c *SimpleCache) Keys() []interface{} {
+	return c.keys(true)
+}
+
+// KeysIncludingExpired returns a slice of the keys in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than Keys.
+func (c *SimpleCache) KeysIncludingExpired() []interface{} {
+	return c.keys(false)
+}
+
+func (c *SimpleCache) keys(checkExpired bool) []interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).Keys()
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	keys := make([]interface{}, len(c.items))
-	i := 0
-	for k := range c.items {
-		keys[i] = k
-		i++
+	keys := make([]interface{}, 0, len(c.items))
+	for k, item := range c.items {
+		if checkExpired && item.IsExpired(c.clock) {
+			continue
+		}
+		keys = append(keys, k)
 	}
-
 	return keys
 }
 
-// Returns all key-value pairs in the cache.
+// KeysSorted returns the unexpired keys in the order given by less.
+func (c *SimpleCache) KeysSorted(less func(a, b interface{}) bool) []interface{} {
+	return sortKeys(c.Keys(), less)
+}
+
+// KeysWithPrefix returns the unexpired string keys which start with prefix, in order.
+func (c *SimpleCache) KeysWithPrefix(prefix string) []interface{} {
+	return c.keysWithPrefix(prefix, c.Keys, c.Has)
+}
+
+// GetByIndex returns the unexpired entries whose attribute in the named Index is attr.
+func (c *SimpleCache) GetByIndex(name string, attr interface{}) map[interface{}]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getByIndex(name, attr, c.peek)
+}
+
+// RemoveByIndex removes the entries whose attribute in the named Index is attr
+// under a single lock acquisition, and returns how many were removed.
+func (c *SimpleCache) RemoveByIndex(name string, attr interface{}) int {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.removeByIndex(name, attr, c.remove)
+}
+
+// Namespace returns a view of the cache which keeps its keys apart from
+// the keys of the other namespaces and of the cache itself.
+func (c *SimpleCache) Namespace(name string) *NamespacedCache {
+	return newNamespacedCache(c, name)
+}
+
+// Returns all unexpired key-value pairs in the cache.
 func (c *SimpleCache) GetALL() map[interface{}]interface{} {
+	return c.all(true)
+}
+
+// GetALLIncludingExpired returns all key-value pairs in the cache, including the
+// expired ones which were not removed yet, which makes it cheaper than GetALL.
+func (c *SimpleCache) GetALLIncludingExpired() map[interface{}]interface{} {
+	return c.all(false)
+}
+
+func (c *SimpleCache) all(checkExpired bool) map[interface{}]interface{} {
+	if c.snapshot != nil {
+		return c.snapshot.get(c.getALL, checkExpired).GetALL()
+	}
+	return c.getALL(checkExpired)
+}
+
+func (c *SimpleCache) getALL(checkExpired bool) map[interface{}]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	m := make(map[interface{}]interface{})
 	for k, v := range c.items {
-		m[k] = v.value
+		if checkExpired && v.IsExpired(c.clock) {
+			continue
+		}
+		if value, ok := c.decoded(k, v.value); ok {
+			m[k] = value
+		}
 	}
 
 	return m
 }
 
+// Snapshot returns a consistent copy of the unexpired entries, as of when it is called.
+func (c *SimpleCache) Snapshot() map[interface{}]interface{} {
+	return c.consistentCopy(func() ([]interface{}, func(interface{}) (interface{}, bool)) {
+		items := c.items
+		keys := make([]interface{}, 0, len(items))
+		for k, item := range items {
+			if !item.IsExpired(c.clock) {
+				keys = append(keys, k)
+			}
+		}
+		return keys, func(key interface{}) (interface{}, bool) {
+			item, ok := items[key]
+			if !ok {
+				return nil, false
+			}
+			return c.decoded(key, item.value)
+		}
+	})
+}
+
+// Export writes the unexpired entries, with their expiration, to w with codec.
+func (c *SimpleCache) Export(w io.Writer, codec SnapshotCodec) error {
+	return exportSnapshot(c, w, codec)
+}
+
+// Import sets the entries written by Export which have not expired yet,
+// restoring their expiration.
+func (c *SimpleCache) Import(r io.Reader, codec SnapshotCodec) error {
+	return importSnapshot(c, r, codec)
+}
+
+func (c *SimpleCache) entries() []SnapshotEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]SnapshotEntry, 0, len(c.items))
+	for k, item := range c.items {
+		if item.IsExpired(c.clock) {
+			continue
+		}
+		if v, ok := c.decoded(k, item.value); ok {
+			entries = append(entries, SnapshotEntry{Key: k, Value: v, Expiration: item.expiration})
+		}
+	}
+	return entries
+}
+
+func (c *SimpleCache) importEntries(entries []SnapshotEntry) {
+	c.restore(entries, c.Set, c.Touch)
+}
+
+// Dump writes the entries of the cache with their age, hits and time to live to w in
+// format, for debugging.
+func (c *SimpleCache) Dump(w io.Writer, format DumpFormat) error {
+	return dump(c, w, format)
+}
+
+// Watch returns a channel receiving the changes of key. See Watcher.
+func (c *SimpleCache) Watch(key interface{}) (<-chan ValueChange, CancelFunc) {
+	return c.watch(key, key)
+}
+
+func (c *SimpleCache) dumpEntries() ([]dumpEntry, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]dumpEntry, 0, len(c.items))
+	for k, item := range c.items {
+		entries = append(entries, c.newDumpEntry(k, item.value, item.created, item.expiration, item.accessExpiration))
+	}
+	return entries, c.dumpLimit
+}
+
+// Verify checks the internal invariants of the cache and returns an InvariantError
+// describing the first one which does not hold.
+func (c *SimpleCache) Verify() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for key, item := range c.items {
+		if item == nil {
+			return violated("key %v has no item", key)
+		}
+	}
+	return c.verifyIndexes(func(key interface{}) bool {
+		_, ok := c.items[key]
+		return ok
+	})
+}
+
+// Explain reports whether key is a candidate for eviction.
+// SimpleCache evicts in map iteration order, so no rank is defined.
+func (c *SimpleCache) Explain(key interface{}) EvictionExplanation {
+	if c.checkKey(key) != nil {
+		return notPresentExplanation(key)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return notPresentExplanation(key)
+	}
+	ex := EvictionExplanation{
+		Key:     key,
+		Present: true,
+		Expired: item.IsExpired(c.clock),
+		Rank:    -1,
+	}
+	if item.expiration != nil && !c.clock.Now().After(*item.expiration) {
+		ex.Reason = "expiration has not passed yet, it is skipped by eviction"
+	} else {
+		ex.Reason = "eviction picks entries in arbitrary map order, it may be evicted next"
+	}
+	ex.Class = c.priorityOf(key)
+	if c.isPinned(key) {
+		ex.Pinned = true
+		ex.Reason = "pinned, it is exempt from eviction"
+	}
+	return ex
+}
+
 // Returns the number of items in the cache.
 func (c *SimpleCache) Len() int {
 	c.mu.RLock()
@@ -197,27 +758,63 @@ func (c *SimpleCache) Len() int {
 	return len(c.items)
 }
 
+// SetCapacity changes the maximum number of entries at runtime.
+func (c *SimpleCache) SetCapacity(n int) {
+	if n <= 0 {
+		panic("gcache: size <= 0")
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	c.size = n
+	if l := len(c.items); l > n {
+		c.evict(l - n)
+	}
+}
+
+// shed evicts up to n entries following the eviction policy and returns how many were evicted.
+func (c *SimpleCache) shed(n int) int {
+	c.mu.Lock()
+	defer c.unlock()
+	l := len(c.items)
+	c.evict(n)
+	return l - len(c.items)
+}
+
 // Completely clear the cache
 func (c *SimpleCache) Purge() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 
+	if c.purgeEvict {
+		for key, item := range c.items {
+			c.evicted(key, item.value, item.expiration, item.accessExpiration)
+		}
+	}
 	c.init()
+	c.resetArena()
+	c.resetIndexes()
+}
+
+// Close cancels scheduled removals, waits for background loads to finish and drops all
+// entries.
+func (c *SimpleCache) Close() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	c.Purge()
+	return nil
 }
 
 type simpleItem struct {
-	value      interface{}
-	expiration *time.Time
+	value            interface{}
+	expiration       *time.Time
+	accessExpiration *time.Time
+	delta            time.Duration // time it took to load the value
+	refreshAt        *time.Time    // when to reload the value in the background
+	created          time.Time
 }
 
 // returns boolean value whether this item is expired or not.
-func (si *simpleItem) IsExpired(now *time.Time) bool {
-	if si.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := time.Now()
-		now = &t
-	}
-	return si.expiration.Before(*now)
+func (si *simpleItem) IsExpired(clock Clock) bool {
+	return isExpired(clock, si.expiration, si.accessExpiration) || released(si.value)
 }
@@ -19,64 +19,215 @@ limitations under the License.
 // This module provides a duplicate function call suppression
 // mechanism.
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // call is an in-flight or completed Do call
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	wg   sync.WaitGroup
+	done atomic.Bool // set once val and err are
+	val  interface{}
+	err  error
 }
 
 // Group represents a class of work and forms a namespace in which
 // units of work can be executed with duplicate suppression.
 type Group struct {
-	cache Cache
-	mu    sync.Mutex            // protects m
-	m     map[interface{}]*call // lazily initialized
+	cache  Cache
+	clock  Clock
+	window time.Duration         // how long a completed call is reused
+	noWait bool                  // whether waiting calls return KeyLoadingError instead
+	mu     sync.Mutex            // protects m
+	m      map[interface{}]*call // lazily initialized
+	bg     sync.WaitGroup        // calls running in the background
 }
 
 // Do executes and returns the results of the given function, making
 // sure that only one execution is in-flight for a given key at a
 // time. If a duplicate comes in, the duplicate caller waits for the
-// original to complete and receives the same results.
+// original to complete and receives the same results, or a KeyLoadingError
+// right away if the group does not wait.
 func (g *Group) Do(key interface{}, fn func() (interface{}, error), isWait bool) (interface{}, bool, error) {
-	g.mu.Lock()
-	v, err := g.cache.get(key, true)
-	if err == nil {
-		g.mu.Unlock()
+	v, c, owned := g.join(key, true)
+	if c == nil {
 		return v, false, nil
 	}
-	if g.m == nil {
-		g.m = make(map[interface{}]*call)
-	}
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
+	if !owned {
 		if !isWait {
 			return nil, false, KeyNotFoundError
 		}
+		if g.noWait && !c.done.Load() {
+			return nil, false, KeyLoadingError
+		}
 		c.wg.Wait()
 		return c.val, false, c.err
 	}
-	c := new(call)
-	c.wg.Add(1)
-	g.m[key] = c
-	g.mu.Unlock()
 	if !isWait {
-		go g.call(c, key, fn)
+		g.background(c, key, fn)
 		return nil, false, KeyNotFoundError
 	}
-	v, err = g.call(c, key, fn)
+	v, err := g.call(c, key, fn)
 	return v, true, err
 }
 
-func (g *Group) call(c *call, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
-	c.val, c.err = fn()
-	c.wg.Done()
+// share executes fn like Do, without looking key up in the cache first.
+func (g *Group) share(key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	_, c, owned := g.join(key, false)
+	if !owned {
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	return g.call(c, key, fn)
+}
 
+// join returns the value of key if lookup is true and key is cached. Otherwise it
+// returns the call in-flight for key, or a new call for key which the caller owns
+// and must complete. g.mu is released even if looking key up panics.
+// Looking key up must not remove it, as its callbacks would run under g.mu.
+func (g *Group) join(key interface{}, lookup bool) (v interface{}, c *call, owned bool) {
 	g.mu.Lock()
-	delete(g.m, key)
-	g.mu.Unlock()
+	defer g.mu.Unlock()
+	if lookup {
+		if v, err := g.cache.lookup(key); err == nil {
+			return v, nil, false
+		}
+	}
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		return nil, c, false
+	}
+	c = new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	return nil, c, true
+}
 
+// DoMulti is like Do for several keys at once. Keys which are cached are
+// returned as is and keys which have a call in-flight share its result.
+// fn is executed once for all other keys and returns a result for each key
+// it found, keys without a result are omitted from the returned map.
+func (g *Group) DoMulti(keys []interface{}, fn func([]interface{}) (map[interface{}]interface{}, error)) (map[interface{}]interface{}, error) {
+	results := make(map[interface{}]interface{}, len(keys))
+	waiting := make(map[interface{}]*call)
+	owned := make(map[interface{}]*call)
+	var own []interface{}
+
+	func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.m == nil {
+			g.m = make(map[interface{}]*call)
+		}
+		for _, key := range keys {
+			if _, ok := results[key]; ok {
+				continue
+			}
+			if _, ok := waiting[key]; ok {
+				continue
+			}
+			if _, ok := owned[key]; ok {
+				continue
+			}
+			if v, err := g.cache.lookup(key); err == nil {
+				results[key] = v
+				continue
+			}
+			if c, ok := g.m[key]; ok {
+				waiting[key] = c
+				continue
+			}
+			c := new(call)
+			c.wg.Add(1)
+			g.m[key] = c
+			owned[key] = c
+			own = append(own, key)
+		}
+	}()
+
+	var err error
+	if len(own) > 0 {
+		var vs map[interface{}]interface{}
+		vs, err = fn(own)
+
+		g.mu.Lock()
+		for key, c := range owned {
+			if err != nil {
+				c.err = err
+			} else if v, ok := vs[key]; ok {
+				c.val = v
+				results[key] = v
+			} else {
+				c.err = KeyNotFoundError
+			}
+			c.done.Store(true)
+			c.wg.Done()
+		}
+		g.mu.Unlock()
+		for key, c := range owned {
+			g.forget(key, c)
+		}
+	}
+
+	for key, c := range waiting {
+		c.wg.Wait()
+		if c.err == nil {
+			results[key] = c.val
+		} else if c.err != KeyNotFoundError && err == nil {
+			err = c.err
+		}
+	}
+	return results, err
+}
+
+// refresh executes fn in the background even if key is cached,
+// unless a call for key is already in-flight.
+func (g *Group) refresh(key interface{}, fn func() (interface{}, error)) {
+	if _, c, owned := g.join(key, false); owned {
+		g.background(c, key, fn)
+	}
+}
+
+// background executes the call in a new goroutine.
+func (g *Group) background(c *call, key interface{}, fn func() (interface{}, error)) {
+	g.bg.Add(1)
+	go func() {
+		defer g.bg.Done()
+		g.call(c, key, fn)
+	}()
+}
+
+// wait blocks until all calls running in the background have completed.
+func (g *Group) wait() {
+	g.bg.Wait()
+}
+
+func (g *Group) call(c *call, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	c.val, c.err = fn()
+	c.done.Store(true)
+	c.wg.Done()
+	g.forget(key, c)
 	return c.val, c.err
 }
+
+// forget removes the completed call c for key, after the coalescing
+// window if there is one, so that calls arriving until then reuse it.
+func (g *Group) forget(key interface{}, c *call) {
+	if g.window <= 0 {
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		return
+	}
+	g.clock.AfterFunc(g.window, func() {
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+	})
+}
@@ -86,3 +86,34 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestLoadsOfExpiredKeysWithReentrantCallbacks(t *testing.T) {
+	for _, builder := range expiringCacheBuilders(8) {
+		clock := NewFakeClock(time.Now())
+		var cache Cache
+		cache = builder.Clock(clock).Expiration(time.Minute).
+			LoaderFunc(func(key interface{}) (interface{}, error) { return key, nil }).
+			ExpiredFunc(func(key, _ interface{}) { cache.Get("other") }).
+			Build()
+		ops := map[string]func(){
+			"Do":       func() { cache.Do("k", func() (interface{}, error) { return "k", nil }, true) },
+			"Get":      func() { cache.Get("k") },
+			"GetMulti": func() { cache.GetMulti([]interface{}{"k"}) },
+			"Warm":     func() { cache.Warm([]interface{}{"k"}, 1) },
+		}
+		for name, op := range ops {
+			cache.Set("k", "k")
+			clock.Advance(2 * time.Minute)
+			done := make(chan struct{})
+			go func() {
+				op()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("%T: %s of an expired key deadlocked", cache, name)
+			}
+		}
+	}
+}
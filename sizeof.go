@@ -0,0 +1,99 @@
+package gcache
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Weight the entries of a ScoreCache by the estimated memory footprint of their
+// value, so that the size given to New is an approximate budget in bytes.
+// See EstimateSize for how the footprint is estimated.
+func (cb *CacheBuilder) WeightBySize() *CacheBuilder {
+	return cb.WeightingFunc(EstimateSize)
+}
+
+// EstimateSize returns the approximate number of bytes v occupies in memory,
+// including the data its strings, slices, maps and pointers refer to.
+// Memory shared by several references is counted once, unexported struct
+// fields are included, and channels and functions count as a pointer.
+// The result is at least 1, so that any value has a weight.
+func EstimateSize(v interface{}) int {
+	switch v := v.(type) {
+	case *CompressedValue:
+		return int(unsafe.Sizeof(*v)) + cap(v.Data)
+	case ArenaRef:
+		return int(unsafe.Sizeof(v)) + slotSize(int(v.class))
+	case *DiskValue:
+		return sizeOfDiskValue(v)
+	}
+	if v == nil {
+		return 1
+	}
+	rv := reflect.ValueOf(v)
+	n := int(rv.Type().Size()) + sizeOfReferenced(rv, make(map[uintptr]struct{}))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// sizeOfReferenced returns the size of the memory v refers to, beyond v itself.
+// seen holds the addresses which were already counted.
+func sizeOfReferenced(v reflect.Value, seen map[uintptr]struct{}) int {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+	case reflect.Slice:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		n := v.Cap() * int(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			n += sizeOfReferenced(v.Index(i), seen)
+		}
+		return n
+	case reflect.Array:
+		n := 0
+		for i := 0; i < v.Len(); i++ {
+			n += sizeOfReferenced(v.Index(i), seen)
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		t := v.Type()
+		n := v.Len() * int(t.Key().Size()+t.Elem().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			n += sizeOfReferenced(iter.Key(), seen) + sizeOfReferenced(iter.Value(), seen)
+		}
+		return n
+	case reflect.Ptr:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		return int(v.Elem().Type().Size()) + sizeOfReferenced(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return int(v.Elem().Type().Size()) + sizeOfReferenced(v.Elem(), seen)
+	case reflect.Struct:
+		n := 0
+		for i := 0; i < v.NumField(); i++ {
+			n += sizeOfReferenced(v.Field(i), seen)
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+func visited(p uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[p]; ok {
+		return true
+	}
+	seen[p] = struct{}{}
+	return false
+}
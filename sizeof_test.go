@@ -0,0 +1,53 @@
+package gcache
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestEstimateSize(t *testing.T) {
+	type node struct {
+		name string
+		next *node
+	}
+	n := &node{name: "a"}
+	n.next = n
+
+	shared := make([]byte, 100)
+	var cases = []struct {
+		value    interface{}
+		min, max int
+	}{
+		{nil, 1, 1},
+		{int64(1), 8, 8},
+		{"hello", 5 + int(unsafe.Sizeof("")), 5 + int(unsafe.Sizeof(""))},
+		{make([]byte, 10, 1000), 1000, 1100},
+		{[]string{"abc", "de"}, 5, 100},
+		{map[string]int{"a": 1, "bb": 2}, 3, 200},
+		{n, 1, 100},
+		{[][]byte{shared, shared}, 100, 199},
+		{&CompressedValue{Data: make([]byte, 50)}, 50, 150},
+	}
+	for _, c := range cases {
+		if size := EstimateSize(c.value); size < c.min || size > c.max {
+			t.Errorf("%T: size %v not in [%v, %v]", c.value, size, c.min, c.max)
+		}
+	}
+}
+
+func TestWeightBySize(t *testing.T) {
+	gc := New(1000).SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightBySize().
+		Build()
+	for i := 0; i < 20; i++ {
+		gc.Set(i, make([]byte, 100))
+	}
+	if n := gc.Len(); n < 5 || n >= 10 {
+		t.Errorf("%v entries of over 100 bytes in a 1000 bytes cache", n)
+	}
+	gc.Set("huge", make([]byte, 2000))
+	if _, err := gc.Get("huge"); err != KeyNotFoundError {
+		t.Errorf("value larger than the cache should not be cached")
+	}
+}
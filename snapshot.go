@@ -0,0 +1,143 @@
+package gcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// snapshot is an immutable copy of the cache contents.
+type snapshot struct {
+	items     map[interface{}]interface{}
+	createdAt time.Time
+}
+
+// snapshotter serves GetALL and Keys from a read-only snapshot which is
+// rebuilt at most once per interval, so that monitoring consumers polling
+// those methods never contend with the hot path for the cache lock.
+// The snapshots with and without the expired entries are kept apart.
+type snapshotter struct {
+	clock    Clock
+	interval time.Duration
+	value    [2]atomic.Value // *snapshot, indexed by snapshotIndex
+	building [2]int32
+}
+
+func newSnapshotter(interval time.Duration, clock Clock) *snapshotter {
+	return &snapshotter{clock: clock, interval: interval}
+}
+
+// snapshotIndex returns the index of the snapshots built with checkExpired.
+func snapshotIndex(checkExpired bool) int {
+	if checkExpired {
+		return 1
+	}
+	return 0
+}
+
+// get returns the current snapshot, rebuilding it with build if it is older than the interval.
+// While one goroutine rebuilds, the others keep being served the previous snapshot.
+// Expired entries which were not removed yet are left out if checkExpired is true.
+func (s *snapshotter) get(build func(checkExpired bool) map[interface{}]interface{}, checkExpired bool) *snapshot {
+	i := snapshotIndex(checkExpired)
+	sn, _ := s.value[i].Load().(*snapshot)
+	if sn != nil && s.clock.Now().Sub(sn.createdAt) < s.interval {
+		return sn
+	}
+	if !atomic.CompareAndSwapInt32(&s.building[i], 0, 1) {
+		if sn != nil {
+			return sn
+		}
+		// The very first snapshot is still being built; build a private one.
+		return &snapshot{items: build(checkExpired), createdAt: s.clock.Now()}
+	}
+	defer atomic.StoreInt32(&s.building[i], 0)
+	sn = &snapshot{items: build(checkExpired), createdAt: s.clock.Now()}
+	s.value[i].Store(sn)
+	return sn
+}
+
+// GetALL returns a copy of the snapshot items.
+func (sn *snapshot) GetALL() map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, len(sn.items))
+	for k, v := range sn.items {
+		m[k] = v
+	}
+	return m
+}
+
+// Keys returns the keys of the snapshot.
+func (sn *snapshot) Keys() []interface{} {
+	keys := make([]interface{}, 0, len(sn.items))
+	for k := range sn.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// snapshotChunk is the number of entries Snapshot copies per lock acquisition.
+const snapshotChunk = 1024
+
+// copyLog keeps the values which entries had when a Snapshot started,
+// for the entries which are overwritten or removed while it copies them.
+type copyLog struct {
+	before map[interface{}]loggedValue
+}
+
+type loggedValue struct {
+	value interface{}
+	ok    bool // false if the value could not be decoded
+}
+
+// release is called with the value of an entry which is overwritten or removed (not thread safe).
+func (c *baseCache) release(key, value interface{}) {
+	for _, log := range c.copies {
+		if _, ok := log.before[key]; !ok {
+			v, ok := c.decoded(key, value)
+			log.before[key] = loggedValue{v, ok}
+		}
+	}
+	c.free(value)
+}
+
+// consistentCopy copies the unexpired entries as of the moment it is called,
+// without holding the lock for the whole copy: keys lists the entries under
+// the lock and returns a function which reads the value of a listed entry.
+// Entries are then read in chunks, and those which are overwritten or removed
+// in between are read from a log of their previous values.
+func (c *baseCache) consistentCopy(keys func() ([]interface{}, func(key interface{}) (interface{}, bool))) map[interface{}]interface{} {
+	c.mu.Lock()
+	listed, read := keys()
+	log := &copyLog{before: make(map[interface{}]loggedValue)}
+	c.copies = append(c.copies, log)
+	c.unlock()
+
+	m := make(map[interface{}]interface{}, len(listed))
+	for len(listed) > 0 {
+		chunk := listed
+		if len(chunk) > snapshotChunk {
+			chunk = chunk[:snapshotChunk]
+		}
+		listed = listed[len(chunk):]
+		c.mu.RLock()
+		for _, key := range chunk {
+			if logged, ok := log.before[key]; ok {
+				if logged.ok {
+					m[key] = logged.value
+				}
+			} else if v, ok := read(key); ok {
+				m[key] = v
+			}
+		}
+		c.mu.RUnlock()
+	}
+
+	c.mu.Lock()
+	for i, other := range c.copies {
+		if other == log {
+			c.copies = append(c.copies[:i], c.copies[i+1:]...)
+			break
+		}
+	}
+	c.unlock()
+	return m
+}
@@ -0,0 +1,101 @@
+package gcache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotInterval(t *testing.T) {
+	for _, builder := range cacheBuilders(32) {
+		cache := builder.SnapshotInterval(50 * time.Millisecond).Build()
+		cache.Set(1, 1)
+
+		if l := cache.Len(); l != 1 {
+			t.Errorf("Len() = %v; want 1", l)
+		}
+
+		// served from the snapshot until the interval elapses, except Len
+		cache.Keys()
+		cache.Set(2, 2)
+		if l := cache.Len(); l != 2 {
+			t.Errorf("Len() = %v; want 2", l)
+		}
+		if keys := cache.Keys(); len(keys) != 1 || keys[0] != 1 {
+			t.Errorf("Keys() = %v; want [1]", keys)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		m := cache.GetALL()
+		if len(m) != 2 || m[1] != 1 || m[2] != 2 {
+			t.Errorf("GetALL() = %v", m)
+		}
+
+		// the returned map is a copy
+		m[3] = 3
+		if l := cache.Len(); l != 2 {
+			t.Errorf("Len() = %v; want 2", l)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	size := 4 * snapshotChunk
+	for _, builder := range cacheBuilders(size) {
+		var cache Cache
+		var decoded int64
+		done := make(chan struct{})
+		// overwrite then remove the entries in order while they are copied,
+		// starting once the first chunk is read
+		write := func() {
+			defer close(done)
+			for i := 0; i < size; i++ {
+				cache.Set(i, -i-1)
+			}
+			for i := 0; i < size; i++ {
+				cache.Remove(i)
+			}
+		}
+		cache = builder.
+			DeserializeFunc(func(_, v interface{}) (interface{}, error) {
+				if atomic.AddInt64(&decoded, 1) == snapshotChunk {
+					go write()
+					time.Sleep(10 * time.Millisecond)
+				}
+				return v, nil
+			}).
+			Build()
+		for i := 0; i < size; i++ {
+			cache.Set(i, i)
+		}
+		m := cache.Snapshot()
+		<-done
+
+		// a consistent copy is a state the writer went through: a prefix of
+		// the entries overwritten and the rest unchanged, or a prefix of the
+		// entries removed and the rest overwritten
+		const removed, overwritten, unchanged = 0, 1, 2
+		state := func(i int) int {
+			v, ok := m[i]
+			switch {
+			case !ok:
+				return removed
+			case v == -i-1:
+				return overwritten
+			case v == i:
+				return unchanged
+			}
+			t.Fatalf("%T: unexpected value %v for %v", cache, v, i)
+			return 0
+		}
+		first, last := state(0), state(size-1)
+		for i := 1; i < size; i++ {
+			if state(i) < state(i-1) || (first == removed && last == unchanged) {
+				t.Fatalf("%T: inconsistent copy at %v", cache, i)
+			}
+		}
+		if len(cache.Snapshot()) != 0 {
+			t.Errorf("%T: expected an empty copy", cache)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package sqlcache caches the results of database/sql queries in a gcache Cache,
+// and invalidates them when the tables they read are written.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/britt/gcache"
+)
+
+// DB is what queries are run on: a *sql.DB, *sql.Tx or *sql.Conn.
+type DB interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ScanFunc reads the rows of a query into the value which is cached for it.
+// The rows are closed once it returns.
+type ScanFunc func(rows *sql.Rows) (interface{}, error)
+
+// queryKey is the key of a query result in the cache.
+type queryKey struct {
+	SQL  string
+	Args string
+}
+
+type config struct {
+	ttl time.Duration
+}
+
+// Option configures Queries.
+type Option func(*config)
+
+// WithTTL sets how long results are cached, until they are invalidated otherwise.
+func WithTTL(ttl time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ttl = ttl
+	}
+}
+
+// Queries caches the results of the queries run on a DB, tagged with the tables
+// they read, and invalidates them when Exec writes to one of those tables.
+type Queries struct {
+	cache gcache.Cache
+	db    DB
+	cfg   *config
+
+	mu     sync.Mutex
+	tags   map[string]map[queryKey]struct{} // keys by table
+	epochs map[string]uint64                // invalidations by table
+}
+
+// New returns Queries on db, caching their results in c.
+func New(c gcache.Cache, db DB, opts ...Option) *Queries {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Queries{
+		cache:  c,
+		db:     db,
+		cfg:    cfg,
+		tags:   make(map[string]map[queryKey]struct{}),
+		epochs: make(map[string]uint64),
+	}
+}
+
+// Cached returns the cached result of query with args, or runs it and caches
+// the value scan reads from its rows. Queries are keyed on their SQL with
+// whitespace normalized, and on their args.
+// A result is not cached if one of its tables was invalidated while it ran.
+// Queries on a *sql.Tx always run, and their results are not cached, since
+// they may read writes which other queries do not see.
+func (q *Queries) Cached(ctx context.Context, query string, args []interface{}, scan ScanFunc) (interface{}, error) {
+	if _, ok := q.db.(*sql.Tx); ok {
+		return q.query(ctx, query, args, scan)
+	}
+	key := queryKey{SQL: Normalize(query), Args: fmt.Sprintf("%#v", args)}
+	if v, err := q.cache.GetIFPresent(key); err == nil {
+		return v, nil
+	}
+	tables := Tables(query)
+	epochs := q.epochsOf(tables)
+
+	v, err := q.query(ctx, query, args, scan)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, table := range tables {
+		if q.epochs[table] != epochs[i] {
+			return v, nil
+		}
+	}
+	if q.cfg.ttl > 0 {
+		q.cache.SetWithExpire(key, v, q.cfg.ttl)
+	} else {
+		q.cache.Set(key, v)
+	}
+	for _, table := range tables {
+		q.tag(table, key)
+	}
+	return v, nil
+}
+
+// query runs query with args on the DB and returns the value scan reads from its rows.
+func (q *Queries) query(ctx context.Context, query string, args []interface{}, scan ScanFunc) (interface{}, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	v, err := scan(rows)
+	if cerr := rows.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (q *Queries) epochsOf(tables []string) []uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	epochs := make([]uint64, len(tables))
+	for i, table := range tables {
+		epochs[i] = q.epochs[table]
+	}
+	return epochs
+}
+
+// tag records that the result under key reads table. The keys of results which
+// are no longer cached are dropped whenever the tag doubles in size.
+func (q *Queries) tag(table string, key queryKey) {
+	keys, ok := q.tags[table]
+	if !ok {
+		keys = make(map[queryKey]struct{})
+		q.tags[table] = keys
+	}
+	keys[key] = struct{}{}
+	if n := q.cache.Len(); len(keys) > 2*n && len(keys) > 16 {
+		for k := range keys {
+			if !q.cache.Has(k) {
+				delete(keys, k)
+			}
+		}
+	}
+}
+
+// Exec runs a statement on the DB and invalidates the results which read the
+// tables it writes, as found by WrittenTables.
+func (q *Queries) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	res, err := q.db.ExecContext(ctx, query, args...)
+	// a failed statement may still have written, e.g. outside of a transaction
+	q.Invalidate(WrittenTables(query)...)
+	return res, err
+}
+
+// Invalidate removes the cached results which read any of tables.
+func (q *Queries) Invalidate(tables ...string) {
+	var keys []interface{}
+	q.mu.Lock()
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		q.epochs[table]++
+		for key := range q.tags[table] {
+			keys = append(keys, key)
+		}
+		delete(q.tags, table)
+	}
+	q.mu.Unlock()
+	q.cache.RemoveAll(keys...)
+}
+
+// Normalize collapses the runs of whitespace outside of quotes in query,
+// and trims it and its final semicolon.
+func Normalize(query string) string {
+	var b strings.Builder
+	var quote rune
+	space := false
+	for _, r := range strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";")) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	readTables    = regexp.MustCompile(`(?i)\b(?:from|join)\s+([\w."` + "`" + `]+)`)
+	writtenTables = regexp.MustCompile(`(?i)\b(?:update|into|delete\s+from|truncate(?:\s+table)?)\s+([\w."` + "`" + `]+)`)
+	// tableAlias is the alias which may follow a table
+	tableAlias = regexp.MustCompile(`(?i)^\s+(?:as\s+)?(\w+)`)
+	// nextTable is the next table of a comma separated list after FROM
+	nextTable = regexp.MustCompile(`^\s*,\s*([\w."` + "`" + `]+)`)
+	// clauseKeywords start the clause after a table, they are not its alias
+	clauseKeywords = map[string]bool{
+		"where": true, "join": true, "inner": true, "left": true, "right": true, "full": true,
+		"cross": true, "natural": true, "on": true, "using": true, "group": true, "order": true,
+		"having": true, "limit": true, "offset": true, "union": true, "for": true, "window": true,
+	}
+)
+
+// Tables returns the lowercased, unquoted names of the tables query reads, after FROM
+// and JOIN, including all those of a comma separated list such as FROM a, b AS x.
+func Tables(query string) []string {
+	tables := tablesOf(readTables, query)
+	seen := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		seen[table] = true
+	}
+	for _, loc := range readTables.FindAllStringIndex(query, -1) {
+		if !strings.EqualFold(query[loc[0]:loc[0]+4], "from") {
+			continue
+		}
+		rest := skipAlias(query[loc[1]:])
+		for {
+			m := nextTable.FindStringSubmatchIndex(rest)
+			if m == nil {
+				break
+			}
+			if table := unquote(rest[m[2]:m[3]]); !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+			rest = skipAlias(rest[m[1]:])
+		}
+	}
+	return tables
+}
+
+// skipAlias returns rest without the alias of the table before it, if any.
+func skipAlias(rest string) string {
+	m := tableAlias.FindStringSubmatchIndex(rest)
+	if m == nil || clauseKeywords[strings.ToLower(rest[m[2]:m[3]])] {
+		return rest
+	}
+	return rest[m[1]:]
+}
+
+// WrittenTables returns the lowercased, unquoted names of the tables a statement writes,
+// after UPDATE, INTO, DELETE FROM and TRUNCATE.
+func WrittenTables(query string) []string {
+	return tablesOf(writtenTables, query)
+}
+
+func tablesOf(re *regexp.Regexp, query string) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, m := range re.FindAllStringSubmatch(query, -1) {
+		table := unquote(m[1])
+		if !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// unquote lowercases the name of a table and removes its quotes.
+func unquote(table string) string {
+	return strings.ToLower(strings.NewReplacer(`"`, "", "`", "").Replace(table))
+}
@@ -0,0 +1,181 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/britt/gcache"
+)
+
+// countingDriver is a database driver whose queries return the number of queries
+// run so far as their single row, and whose statements do nothing.
+type countingDriver struct {
+	queries int64
+}
+
+func (d *countingDriver) Open(string) (driver.Conn, error) {
+	return countingConn{d}, nil
+}
+
+type countingConn struct {
+	d *countingDriver
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	return countingStmt{c.d}, nil
+}
+
+func (countingConn) Close() error              { return nil }
+func (countingConn) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+type countingStmt struct {
+	d *countingDriver
+}
+
+func (countingStmt) Close() error  { return nil }
+func (countingStmt) NumInput() int { return -1 }
+
+func (countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s countingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &countingRows{n: atomic.AddInt64(&s.d.queries, 1)}, nil
+}
+
+type countingRows struct {
+	n    int64
+	done bool
+}
+
+func (*countingRows) Columns() []string { return []string{"n"} }
+func (*countingRows) Close() error      { return nil }
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.n
+	return nil
+}
+
+func scanInt(rows *sql.Rows) (interface{}, error) {
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func TestQueries(t *testing.T) {
+	sql.Register("sqlcache-counting", &countingDriver{})
+	db, err := sql.Open("sqlcache-counting", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	q := New(gcache.New(10).LRU().Build(), db)
+
+	users := "SELECT n FROM users WHERE id = ?"
+	if v, err := q.Cached(ctx, users, []interface{}{1}, scanInt); err != nil || v != int64(1) {
+		t.Fatalf("Cached() = %v, %v", v, err)
+	}
+	if v, _ := q.Cached(ctx, "SELECT n  FROM users\n WHERE id = ?;", []interface{}{1}, scanInt); v != int64(1) {
+		t.Errorf("Cached() = %v; the normalized query was not cached", v)
+	}
+	if v, _ := q.Cached(ctx, users, []interface{}{2}, scanInt); v != int64(2) {
+		t.Errorf("Cached() = %v; want a query with other args to run", v)
+	}
+	orders := "SELECT n FROM orders JOIN users ON users.id = orders.user_id"
+	if v, _ := q.Cached(ctx, orders, nil, scanInt); v != int64(3) {
+		t.Errorf("Cached() = %v", v)
+	}
+
+	if _, err := q.Exec(ctx, "UPDATE orders SET total = 0"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := q.Cached(ctx, users, []interface{}{1}, scanInt); v != int64(1) {
+		t.Errorf("Cached() = %v; writing orders invalidated users", v)
+	}
+	if v, _ := q.Cached(ctx, orders, nil, scanInt); v != int64(4) {
+		t.Errorf("Cached() = %v; writing orders did not invalidate it", v)
+	}
+
+	q.Invalidate("USERS")
+	if v, _ := q.Cached(ctx, users, []interface{}{1}, scanInt); v != int64(5) {
+		t.Errorf("Cached() = %v after Invalidate", v)
+	}
+}
+
+func TestQueriesInTx(t *testing.T) {
+	sql.Register("sqlcache-counting-tx", &countingDriver{})
+	db, err := sql.Open("sqlcache-counting-tx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cache := gcache.New(10).LRU().Build()
+	q := New(cache, tx)
+
+	users := "SELECT n FROM users"
+	q.Cached(ctx, users, nil, scanInt)
+	if v, err := q.Cached(ctx, users, nil, scanInt); err != nil || v != int64(2) {
+		t.Errorf("Cached() = %v, %v; want the query to run again in a transaction", v, err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("%v results of a transaction were cached", cache.Len())
+	}
+}
+
+func TestTables(t *testing.T) {
+	if tables := Tables(`SELECT * FROM "Users" u JOIN public.orders o ON o.uid = u.id JOIN users x`); !reflect.DeepEqual(tables, []string{"users", "public.orders"}) {
+		t.Errorf("Tables() = %v", tables)
+	}
+	for query, want := range map[string][]string{
+		"SELECT * FROM a, b":                            {"a", "b"},
+		"SELECT * FROM a x, b AS y,c WHERE x.id = y.id": {"a", "b", "c"},
+		"select * from a where b, c":                    {"a"},
+		"SELECT * FROM a JOIN b ON a.id = b.id, c":      {"a", "b"},
+	} {
+		if tables := Tables(query); !reflect.DeepEqual(tables, want) {
+			t.Errorf("Tables(%q) = %v; want %v", query, tables, want)
+		}
+	}
+	for query, want := range map[string][]string{
+		"INSERT INTO users VALUES (1)": {"users"},
+		"delete from orders where 1":   {"orders"},
+		"TRUNCATE TABLE sessions":      {"sessions"},
+		"update `items` set n = n + 1": {"items"},
+		"SELECT 1":                     nil,
+	} {
+		if tables := WrittenTables(query); !reflect.DeepEqual(tables, want) {
+			t.Errorf("WrittenTables(%q) = %v; want %v", query, tables, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if s := Normalize("  SELECT  a,\n\tb FROM t WHERE s = 'x  y' ; "); s != "SELECT a, b FROM t WHERE s = 'x  y'" {
+		t.Errorf("Normalize() = %q", s)
+	}
+}
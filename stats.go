@@ -2,31 +2,123 @@ package gcache
 
 import (
 	"sync/atomic"
+	"time"
 )
 
+// LoadLatencyBounds are the upper bounds of the buckets of CacheStats.LoadLatency.
+// The last bucket counts the loads which took longer than the last bound.
+// It must not be modified.
+var LoadLatencyBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
 type statsAccessor interface {
 	HitCount() uint64
 	MissCount() uint64
 	LookupCount() uint64
 	HitRate() float64
+	EvictionCount() uint64
+	LoadCount() uint64
+	LoadSuccessCount() uint64
+	LoadErrorCount() uint64
+	AverageLoadTime() time.Duration
+	Stats() CacheStats
+	StatsByClass() map[string]CacheStats
+	TopKeys(n int) []KeyFreq
+	ResetStats()
 }
 
 // statistics
 type stats struct {
-	hitCount  uint64
-	missCount uint64
+	hitCount         uint64
+	missCount        uint64
+	evictionCount    uint64
+	expirationCount  uint64
+	rejectionCount   uint64
+	droppedCallbacks uint64
+	compressions     uint64
+	uncompressed     uint64 // bytes before compression
+	compressed       uint64 // bytes after compression
+	loadSuccessCount uint64
+	loadErrorCount   uint64
+	totalLoadTime    uint64 // nanoseconds
+	loadLatency      [len(LoadLatencyBounds) + 1]uint64
+	lifetimes        [lifetimeBuckets]uint64
+	window           *slidingWindow // nil unless WindowedStats is set
+	classes          *classStats    // nil unless StatsClassifier is set
+	topKeys          *topKeys       // nil unless TrackTopKeys is set
 }
 
 // increment hit count
 func (st *stats) IncrHitCount() uint64 {
+	if st.window != nil {
+		st.window.record(true)
+	}
 	return atomic.AddUint64(&st.hitCount, 1)
 }
 
 // increment miss count
 func (st *stats) IncrMissCount() uint64 {
+	if st.window != nil {
+		st.window.record(false)
+	}
 	return atomic.AddUint64(&st.missCount, 1)
 }
 
+// increment eviction count
+func (st *stats) IncrEvictionCount() uint64 {
+	return atomic.AddUint64(&st.evictionCount, 1)
+}
+
+// increment expiration count
+func (st *stats) IncrExpirationCount() uint64 {
+	return atomic.AddUint64(&st.expirationCount, 1)
+}
+
+// increment rejection count
+func (st *stats) IncrRejectionCount() uint64 {
+	return atomic.AddUint64(&st.rejectionCount, 1)
+}
+
+// increment the count of callbacks dropped by AsyncCallbacks
+func (st *stats) IncrDroppedCallbackCount() uint64 {
+	return atomic.AddUint64(&st.droppedCallbacks, 1)
+}
+
+// record the compression of size bytes into compressedSize bytes
+func (st *stats) RecordCompression(size, compressedSize int) {
+	atomic.AddUint64(&st.compressions, 1)
+	atomic.AddUint64(&st.uncompressed, uint64(size))
+	atomic.AddUint64(&st.compressed, uint64(compressedSize))
+}
+
+// record how long an evicted or expired entry stayed in the cache
+func (st *stats) RecordLifetime(d time.Duration) {
+	atomic.AddUint64(&st.lifetimes[lifetimeBucket(d)], 1)
+}
+
+// record a load which took elapsed and failed with err, if any
+func (st *stats) RecordLoad(elapsed time.Duration, err error) {
+	if err == nil {
+		atomic.AddUint64(&st.loadSuccessCount, 1)
+	} else {
+		atomic.AddUint64(&st.loadErrorCount, 1)
+	}
+	atomic.AddUint64(&st.totalLoadTime, uint64(elapsed))
+	i := 0
+	for i < len(LoadLatencyBounds) && elapsed > LoadLatencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&st.loadLatency[i], 1)
+}
+
 // HitCount returns hit count
 func (st *stats) HitCount() uint64 {
 	return atomic.LoadUint64(&st.hitCount)
@@ -37,17 +129,101 @@ func (st *stats) MissCount() uint64 {
 	return atomic.LoadUint64(&st.missCount)
 }
 
+// EvictionCount returns the number of entries evicted to make room for others
+func (st *stats) EvictionCount() uint64 {
+	return atomic.LoadUint64(&st.evictionCount)
+}
+
 // LookupCount returns lookup count
 func (st *stats) LookupCount() uint64 {
 	return st.HitCount() + st.MissCount()
 }
 
-// HitRate returns rate for cache hitting
+// HitRate returns rate for cache hitting, within the last window if WindowedStats is set
 func (st *stats) HitRate() float64 {
 	hc, mc := st.HitCount(), st.MissCount()
+	if st.window != nil {
+		hc, mc = st.window.counts()
+	}
 	total := hc + mc
 	if total == 0 {
 		return 0.0
 	}
 	return float64(hc) / float64(total)
 }
+
+// LoadCount returns the number of loader executions
+func (st *stats) LoadCount() uint64 {
+	return st.LoadSuccessCount() + st.LoadErrorCount()
+}
+
+// LoadSuccessCount returns the number of loader executions which returned a value
+func (st *stats) LoadSuccessCount() uint64 {
+	return atomic.LoadUint64(&st.loadSuccessCount)
+}
+
+// LoadErrorCount returns the number of loader executions which returned an error, including KeyNotFoundError
+func (st *stats) LoadErrorCount() uint64 {
+	return atomic.LoadUint64(&st.loadErrorCount)
+}
+
+// AverageLoadTime returns the average duration of loader executions
+func (st *stats) AverageLoadTime() time.Duration {
+	return st.Stats().AverageLoadTime()
+}
+
+// Stats returns a snapshot of the statistics
+func (st *stats) Stats() CacheStats {
+	s := CacheStats{
+		Hits:              st.HitCount(),
+		Misses:            st.MissCount(),
+		Evictions:         st.EvictionCount(),
+		Expirations:       atomic.LoadUint64(&st.expirationCount),
+		Rejections:        atomic.LoadUint64(&st.rejectionCount),
+		DroppedCallbacks:  atomic.LoadUint64(&st.droppedCallbacks),
+		Compressions:      atomic.LoadUint64(&st.compressions),
+		UncompressedBytes: atomic.LoadUint64(&st.uncompressed),
+		CompressedBytes:   atomic.LoadUint64(&st.compressed),
+		LoadSuccesses:     st.LoadSuccessCount(),
+		LoadErrors:        st.LoadErrorCount(),
+		TotalLoadTime:     time.Duration(atomic.LoadUint64(&st.totalLoadTime)),
+	}
+	for i := range st.loadLatency {
+		s.LoadLatency[i] = atomic.LoadUint64(&st.loadLatency[i])
+	}
+	for i := range st.lifetimes {
+		s.lifetimes[i] = atomic.LoadUint64(&st.lifetimes[i])
+	}
+	return s
+}
+
+// ResetStats sets all statistics back to zero
+func (st *stats) ResetStats() {
+	atomic.StoreUint64(&st.hitCount, 0)
+	atomic.StoreUint64(&st.missCount, 0)
+	atomic.StoreUint64(&st.evictionCount, 0)
+	atomic.StoreUint64(&st.expirationCount, 0)
+	atomic.StoreUint64(&st.rejectionCount, 0)
+	atomic.StoreUint64(&st.droppedCallbacks, 0)
+	atomic.StoreUint64(&st.compressions, 0)
+	atomic.StoreUint64(&st.uncompressed, 0)
+	atomic.StoreUint64(&st.compressed, 0)
+	atomic.StoreUint64(&st.loadSuccessCount, 0)
+	atomic.StoreUint64(&st.loadErrorCount, 0)
+	atomic.StoreUint64(&st.totalLoadTime, 0)
+	for i := range st.loadLatency {
+		atomic.StoreUint64(&st.loadLatency[i], 0)
+	}
+	for i := range st.lifetimes {
+		atomic.StoreUint64(&st.lifetimes[i], 0)
+	}
+	if st.window != nil {
+		st.window.reset()
+	}
+	if st.classes != nil {
+		st.classes.reset()
+	}
+	if st.topKeys != nil {
+		st.topKeys.reset()
+	}
+}
@@ -0,0 +1,89 @@
+package gcache
+
+import (
+	"sync"
+)
+
+// Bucket hits, misses and evictions by the class classify returns for their key,
+// in addition to the overall statistics. See StatsByClass.
+// classify is called on every lookup, so it should be cheap, e.g. a key prefix or a tenant.
+func (cb *CacheBuilder) StatsClassifier(classify func(key interface{}) string) *CacheBuilder {
+	cb.statsClassifier = classify
+	return cb
+}
+
+// classStats keeps separate statistics for each class of keys.
+type classStats struct {
+	classify func(interface{}) string
+	mu       sync.RWMutex
+	classes  map[string]*stats
+}
+
+func newClassStats(classify func(interface{}) string) *classStats {
+	return &classStats{classify: classify, classes: make(map[string]*stats)}
+}
+
+// of returns the statistics of the class of key.
+func (cs *classStats) of(key interface{}) *stats {
+	class := cs.classify(key)
+	cs.mu.RLock()
+	st, ok := cs.classes[class]
+	cs.mu.RUnlock()
+	if ok {
+		return st
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if st, ok = cs.classes[class]; !ok {
+		st = &stats{}
+		cs.classes[class] = st
+	}
+	return st
+}
+
+func (cs *classStats) snapshot() map[string]CacheStats {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	m := make(map[string]CacheStats, len(cs.classes))
+	for class, st := range cs.classes {
+		m[class] = st.Stats()
+	}
+	return m
+}
+
+func (cs *classStats) reset() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.classes = make(map[string]*stats)
+}
+
+// StatsByClass returns the hits, misses and evictions of each class of keys
+// seen so far, or nil if no StatsClassifier is set.
+func (st *stats) StatsByClass() map[string]CacheStats {
+	if st.classes == nil {
+		return nil
+	}
+	return st.classes.snapshot()
+}
+
+// recordHit counts a hit on key.
+func (st *stats) recordHit(key interface{}) {
+	st.IncrHitCount()
+	if st.topKeys != nil {
+		st.topKeys.record(key)
+	}
+	if st.classes != nil {
+		st.classes.of(key).IncrHitCount()
+	}
+}
+
+// recordMiss counts a miss on key.
+func (st *stats) recordMiss(key interface{}) {
+	st.IncrMissCount()
+	if st.topKeys != nil {
+		st.topKeys.record(key)
+	}
+	if st.classes != nil {
+		st.classes.of(key).IncrMissCount()
+	}
+}
@@ -0,0 +1,45 @@
+package gcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsByClass(t *testing.T) {
+	tenant := func(key interface{}) string {
+		return strings.SplitN(key.(string), ":", 2)[0]
+	}
+	for _, builder := range cacheBuilders(2) {
+		gc := builder.StatsClassifier(tenant).Build()
+		gc.Set("acme:1", 1)
+		gc.Get("acme:1")
+		gc.Get("acme:2")
+		gc.Get("globex:1")
+		gc.Set("globex:1", 1)
+		gc.Set("globex:2", 2)
+
+		byClass := gc.StatsByClass()
+		acme, globex := byClass["acme"], byClass["globex"]
+		if acme.Hits != 1 || acme.Misses != 1 {
+			t.Errorf("%T: unexpected acme stats %+v", gc, acme)
+		}
+		if globex.Hits != 0 || globex.Misses != 1 {
+			t.Errorf("%T: unexpected globex stats %+v", gc, globex)
+		}
+		if acme.Evictions+globex.Evictions != 1 {
+			t.Errorf("%T: %v evictions != 1", gc, acme.Evictions+globex.Evictions)
+		}
+		if gc.HitCount() != 1 || gc.MissCount() != 2 {
+			t.Errorf("%T: overall hits: %v, misses: %v", gc, gc.HitCount(), gc.MissCount())
+		}
+
+		gc.ResetStats()
+		if byClass := gc.StatsByClass(); len(byClass) != 0 {
+			t.Errorf("%T: %v classes after ResetStats", gc, len(byClass))
+		}
+	}
+
+	if byClass := New(2).LRU().Build().StatsByClass(); byClass != nil {
+		t.Errorf("StatsByClass without a classifier should be nil, not %v", byClass)
+	}
+}
@@ -0,0 +1,160 @@
+package gcache
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheStats is a snapshot of the statistics of a cache.
+// Subtracting an earlier snapshot with Sub gives the activity in between.
+type CacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	// Rejections counts the items which were not cached because they could never fit.
+	Rejections uint64
+	// DroppedCallbacks counts the callbacks dropped because the queue of AsyncCallbacks was full.
+	DroppedCallbacks uint64
+	// Compressions counts the values compressed, from UncompressedBytes to CompressedBytes in total.
+	Compressions      uint64
+	UncompressedBytes uint64
+	CompressedBytes   uint64
+	LoadSuccesses     uint64
+	LoadErrors        uint64
+	TotalLoadTime     time.Duration
+	// LoadLatency counts the loads by duration, bucketed by LoadLatencyBounds.
+	LoadLatency [len(LoadLatencyBounds) + 1]uint64
+	// lifetimes counts the evicted and expired entries by how long they were cached.
+	lifetimes [lifetimeBuckets]uint64
+}
+
+// HitRate returns the rate of lookups which were hits.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0.0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// CompressionRatio returns the size of compressed values relative to their original size.
+func (s CacheStats) CompressionRatio() float64 {
+	if s.UncompressedBytes == 0 {
+		return 0.0
+	}
+	return float64(s.CompressedBytes) / float64(s.UncompressedBytes)
+}
+
+// LoadCount returns the number of loader executions.
+func (s CacheStats) LoadCount() uint64 {
+	return s.LoadSuccesses + s.LoadErrors
+}
+
+// AverageLoadTime returns the average duration of loader executions.
+func (s CacheStats) AverageLoadTime() time.Duration {
+	if s.LoadCount() == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(s.LoadCount())
+}
+
+func (s CacheStats) add(o CacheStats) CacheStats {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+	s.Rejections += o.Rejections
+	s.DroppedCallbacks += o.DroppedCallbacks
+	s.Compressions += o.Compressions
+	s.UncompressedBytes += o.UncompressedBytes
+	s.CompressedBytes += o.CompressedBytes
+	s.LoadSuccesses += o.LoadSuccesses
+	s.LoadErrors += o.LoadErrors
+	s.TotalLoadTime += o.TotalLoadTime
+	for i := range s.LoadLatency {
+		s.LoadLatency[i] += o.LoadLatency[i]
+	}
+	for i := range s.lifetimes {
+		s.lifetimes[i] += o.lifetimes[i]
+	}
+	return s
+}
+
+// Sub returns the difference between s and an earlier snapshot o of the same cache.
+func (s CacheStats) Sub(o CacheStats) CacheStats {
+	s.Hits -= o.Hits
+	s.Misses -= o.Misses
+	s.Evictions -= o.Evictions
+	s.Expirations -= o.Expirations
+	s.Rejections -= o.Rejections
+	s.DroppedCallbacks -= o.DroppedCallbacks
+	s.Compressions -= o.Compressions
+	s.UncompressedBytes -= o.UncompressedBytes
+	s.CompressedBytes -= o.CompressedBytes
+	s.LoadSuccesses -= o.LoadSuccesses
+	s.LoadErrors -= o.LoadErrors
+	s.TotalLoadTime -= o.TotalLoadTime
+	for i := range s.LoadLatency {
+		s.LoadLatency[i] -= o.LoadLatency[i]
+	}
+	for i := range s.lifetimes {
+		s.lifetimes[i] -= o.lifetimes[i]
+	}
+	return s
+}
+
+// GroupStats holds the merged statistics of a set of caches and their breakdown by name.
+type GroupStats struct {
+	Total    CacheStats
+	PerCache map[string]CacheStats
+}
+
+// StatsGroup aggregates the statistics of an explicit set of named caches,
+// e.g. for a single cache health dashboard panel.
+type StatsGroup struct {
+	mu     sync.RWMutex
+	caches map[string]Cache
+}
+
+func NewStatsGroup() *StatsGroup {
+	return &StatsGroup{caches: make(map[string]Cache)}
+}
+
+// Add adds c to the group under name, replacing any cache added under the same name.
+func (g *StatsGroup) Add(name string, c Cache) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.caches[name] = c
+}
+
+// Remove removes the cache added under name.
+func (g *StatsGroup) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.caches, name)
+}
+
+// Stats returns the merged statistics of the group.
+func (g *StatsGroup) Stats() GroupStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	gs := GroupStats{PerCache: make(map[string]CacheStats, len(g.caches))}
+	for name, c := range g.caches {
+		s := c.Stats()
+		gs.PerCache[name] = s
+		gs.Total = gs.Total.add(s)
+	}
+	return gs
+}
+
+// Stats returns the merged statistics of all registered caches.
+func (r *CacheRegistry) Stats() GroupStats {
+	gs := GroupStats{PerCache: make(map[string]CacheStats)}
+	r.Each(func(name string, c Cache) {
+		s := c.Stats()
+		gs.PerCache[name] = s
+		gs.Total = gs.Total.add(s)
+	})
+	return gs
+}
@@ -0,0 +1,70 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestStatsGroup(t *testing.T) {
+	users := New(2).LRU().Build()
+	sessions := New(2).LFU().Build()
+	g := NewStatsGroup()
+	g.Add("users", users)
+	g.Add("sessions", sessions)
+
+	for i := 0; i < 3; i++ {
+		users.Set(i, i)
+	}
+	users.Get(2)
+	users.Get(0)
+	sessions.Get(0)
+
+	gs := g.Stats()
+	expected := map[string]CacheStats{
+		"users":    {Hits: 1, Misses: 1, Evictions: 1},
+		"sessions": {Hits: 0, Misses: 1, Evictions: 0},
+	}
+	for name, s := range expected {
+		if got := gs.PerCache[name]; got.Hits != s.Hits || got.Misses != s.Misses || got.Evictions != s.Evictions {
+			t.Errorf("%v: unexpected stats %+v", name, got)
+		}
+	}
+	if gs.Total.Hits != 1 || gs.Total.Misses != 2 || gs.Total.Evictions != 1 || gs.Total.LifetimeCount() != 1 {
+		t.Errorf("unexpected total %+v", gs.Total)
+	}
+	if r := gs.Total.HitRate(); r != 1.0/3 {
+		t.Errorf("unexpected hit rate %v", r)
+	}
+
+	g.Remove("sessions")
+	if gs := g.Stats(); len(gs.PerCache) != 1 || gs.Total.Misses != 1 {
+		t.Errorf("unexpected stats %+v", gs)
+	}
+}
+
+func TestRegistryStats(t *testing.T) {
+	r := NewRegistry()
+	a := New(2).Build()
+	b := New(2).Build()
+	r.Register("a", a)
+	r.Register("b", b)
+	a.Get(0)
+	b.Get(0)
+
+	if gs := r.Stats(); gs.Total.Misses != 2 || gs.PerCache["a"].Misses != 1 {
+		t.Errorf("unexpected stats %+v", gs)
+	}
+}
+
+func TestEvictionCount(t *testing.T) {
+	size := 4
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.Build()
+		for i := 0; i < 2*size; i++ {
+			cache.Set(i, i)
+		}
+		cache.Remove(2*size - 1)
+		if n := cache.EvictionCount(); n != uint64(size) {
+			t.Errorf("%T: unexpected eviction count %v", cache, n)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package gcache
+
+import "fmt"
+
+// ShardStats holds the statistics of one shard of a key space split across caches.
+type ShardStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+	// Weight is the TotalWeight of a ScoreCache, or the number of entries of other caches.
+	Weight int
+}
+
+// Lookups returns the number of hits and misses of the shard.
+func (s ShardStats) Lookups() uint64 {
+	return s.Hits + s.Misses
+}
+
+// ShardBalance reports how evenly lookups and entries are spread across shards.
+// A skew is the ratio of the busiest or heaviest shard to the mean of all shards:
+// 1 when the shards are balanced, the number of shards when one takes it all.
+type ShardBalance struct {
+	Shards        []ShardStats
+	LookupSkew    float64
+	WeightSkew    float64
+	HottestShard  int // with the most lookups
+	HeaviestShard int // with the most weight
+}
+
+func (b ShardBalance) String() string {
+	return fmt.Sprintf("%d shards, lookup skew %.2f (shard %d), weight skew %.2f (shard %d)",
+		len(b.Shards), b.LookupSkew, b.HottestShard, b.WeightSkew, b.HeaviestShard)
+}
+
+// ShardReport returns the statistics of shards, the caches which a key space is
+// split across, and their balance, so that hot shards can be detected and the
+// function assigning keys to shards tuned.
+func ShardReport(shards ...Cache) ShardBalance {
+	b := ShardBalance{Shards: make([]ShardStats, len(shards))}
+	var lookups uint64
+	var weight int
+	for i, c := range shards {
+		s := ShardStats{Hits: c.HitCount(), Misses: c.MissCount(), Len: c.Len()}
+		if w, ok := c.(interface{ TotalWeight() int }); ok {
+			s.Weight = w.TotalWeight()
+		} else {
+			s.Weight = s.Len
+		}
+		b.Shards[i] = s
+		lookups += s.Lookups()
+		weight += s.Weight
+		if s.Lookups() > b.Shards[b.HottestShard].Lookups() {
+			b.HottestShard = i
+		}
+		if s.Weight > b.Shards[b.HeaviestShard].Weight {
+			b.HeaviestShard = i
+		}
+	}
+	if lookups > 0 {
+		b.LookupSkew = float64(b.Shards[b.HottestShard].Lookups()) * float64(len(shards)) / float64(lookups)
+	}
+	if weight > 0 {
+		b.WeightSkew = float64(b.Shards[b.HeaviestShard].Weight) * float64(len(shards)) / float64(weight)
+	}
+	return b
+}
@@ -0,0 +1,48 @@
+package gcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShardReport(t *testing.T) {
+	hot := New(8).LRU().Build()
+	cold := New(8).LRU().Build()
+	heavy := New(8).SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(_ interface{}) int { return 3 }).
+		Build()
+	for i := 0; i < 2; i++ {
+		hot.Set(i, i)
+		heavy.Set(i, i)
+	}
+	for i := 0; i < 6; i++ {
+		hot.Get(i % 3)
+	}
+	cold.Get(0)
+	heavy.Get(0)
+
+	b := ShardReport(hot, cold, heavy)
+	if len(b.Shards) != 3 {
+		t.Fatalf("unexpected report %+v", b)
+	}
+	if s := b.Shards[0]; s.Hits != 4 || s.Misses != 2 || s.Len != 2 || s.Weight != 2 {
+		t.Errorf("unexpected stats of the hot shard %+v", s)
+	}
+	if s := b.Shards[2]; s.Len != 2 || s.Weight != 6 {
+		t.Errorf("unexpected stats of the heavy shard %+v", s)
+	}
+	if b.HottestShard != 0 || b.LookupSkew != 6*3/8.0 {
+		t.Errorf("hottest shard %d, lookup skew %v", b.HottestShard, b.LookupSkew)
+	}
+	if b.HeaviestShard != 2 || b.WeightSkew != 6*3/8.0 {
+		t.Errorf("heaviest shard %d, weight skew %v", b.HeaviestShard, b.WeightSkew)
+	}
+	if !strings.HasPrefix(b.String(), "3 shards, lookup skew 2.25 (shard 0)") {
+		t.Errorf("unexpected report %q", b)
+	}
+
+	if b := ShardReport(New(8).LRU().Build(), New(8).LRU().Build()); b.LookupSkew != 0 || b.WeightSkew != 0 {
+		t.Errorf("unexpected report of idle shards %+v", b)
+	}
+}
@@ -1,7 +1,9 @@
 package gcache
 
 import (
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestStats(t *testing.T) {
@@ -30,6 +32,53 @@ func TestStats(t *testing.T) {
 	}
 }
 
+func TestStatsRecordLoad(t *testing.T) {
+	st := &stats{}
+	st.RecordLoad(500*time.Microsecond, nil)
+	st.RecordLoad(7*time.Millisecond, nil)
+	st.RecordLoad(1500*time.Microsecond, errors.New("failed"))
+	st.RecordLoad(time.Minute, nil)
+
+	s := st.Stats()
+	if s.LoadCount() != 4 || s.LoadSuccesses != 3 || s.LoadErrors != 1 {
+		t.Errorf("loads: %v, successes: %v, errors: %v", s.LoadCount(), s.LoadSuccesses, s.LoadErrors)
+	}
+	expectedAverage := (500*time.Microsecond + 7*time.Millisecond + 1500*time.Microsecond + time.Minute) / 4
+	if avg := st.AverageLoadTime(); avg != expectedAverage {
+		t.Errorf("%v != %v", avg, expectedAverage)
+	}
+	expectedLatency := [len(LoadLatencyBounds) + 1]uint64{1, 1, 1, 0, 0, 0, 0, 0, 1}
+	if s.LoadLatency != expectedLatency {
+		t.Errorf("%v != %v", s.LoadLatency, expectedLatency)
+	}
+}
+
+func TestStatsReset(t *testing.T) {
+	st := &stats{}
+	st.IncrHitCount()
+	st.IncrMissCount()
+	st.IncrEvictionCount()
+	st.IncrExpirationCount()
+	st.IncrRejectionCount()
+	st.RecordLoad(time.Millisecond, nil)
+	before := st.Stats()
+
+	st.ResetStats()
+	if s := st.Stats(); s != (CacheStats{}) {
+		t.Errorf("%+v != zero stats", s)
+	}
+
+	st.IncrHitCount()
+	st.IncrHitCount()
+	after := st.Stats()
+	if d := after.Sub(CacheStats{Hits: 1}); d != (CacheStats{Hits: 1}) {
+		t.Errorf("%+v != %+v", d, CacheStats{Hits: 1})
+	}
+	if before.Expirations != 1 || before.Rejections != 1 {
+		t.Errorf("expirations: %v, rejections: %v", before.Expirations, before.Rejections)
+	}
+}
+
 func getter(key interface{}) (interface{}, error) {
 	return key, nil
 }
@@ -140,3 +189,33 @@ func TestCacheStats(t *testing.T) {
 		}
 	}
 }
+
+func TestLifetimePercentile(t *testing.T) {
+	st := &stats{}
+	if p := st.Stats().LifetimePercentile(50); p != 0 {
+		t.Errorf("%v != 0 without lifetimes", p)
+	}
+	for i := 0; i < 90; i++ {
+		st.RecordLifetime(10 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		st.RecordLifetime(time.Minute)
+	}
+
+	s := st.Stats()
+	if s.LifetimeCount() != 100 {
+		t.Errorf("%v != 100", s.LifetimeCount())
+	}
+	for _, c := range []struct {
+		p        float64
+		min, max time.Duration
+	}{
+		{50, 10 * time.Millisecond, 15 * time.Millisecond},
+		{90, 10 * time.Millisecond, 15 * time.Millisecond},
+		{99, time.Minute, 85 * time.Second},
+	} {
+		if d := s.LifetimePercentile(c.p); d < c.min || d > c.max {
+			t.Errorf("p%v: %v not in [%v, %v]", c.p, d, c.min, c.max)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package gcache
+
+import "time"
+
+// TieBreaker decides the eviction order of ScoreCache entries with the same
+// score: it reports whether a is evicted before b.
+// Without one, ties are broken arbitrarily.
+type TieBreaker func(a, b ScoredEntry) bool
+
+// ScoredEntry describes a ScoreCache entry to a TieBreaker.
+type ScoredEntry struct {
+	Key     interface{}
+	Score   int
+	Weight  int
+	Created time.Time
+	// Inserted and Used order the entries by when they were inserted and
+	// last read or written, with larger numbers being more recent.
+	Inserted uint64
+	Used     uint64
+}
+
+// InsertionOrder is a TieBreaker which evicts the entry inserted first.
+func InsertionOrder(a, b ScoredEntry) bool {
+	return a.Inserted < b.Inserted
+}
+
+// LeastRecentlyUsed is a TieBreaker which evicts the entry used least recently.
+func LeastRecentlyUsed(a, b ScoredEntry) bool {
+	return a.Used < b.Used
+}
+
+// TieBreaker sets how ScoreCache breaks ties between entries with the same
+// score, so that evictions are reproducible.
+func (cb *CacheBuilder) TieBreaker(t TieBreaker) *CacheBuilder {
+	cb.tieBreaker = t
+	return cb
+}
+
+func (item *scoredItem) entry() ScoredEntry {
+	return ScoredEntry{
+		Key:      item.key,
+		Score:    item.score,
+		Weight:   item.weight,
+		Created:  item.created,
+		Inserted: item.inserted,
+		Used:     item.used,
+	}
+}
@@ -0,0 +1,127 @@
+package gcache
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// KeyFreq is a key along with an estimate of how often it was looked up.
+// The actual count is between Count-Error and Count.
+type KeyFreq struct {
+	Key   interface{}
+	Count uint64
+	Error uint64
+}
+
+// Track the k most frequently looked up keys, see TopKeys.
+// The space-saving algorithm keeps only k counters, so the counts are estimates
+// which are most accurate for the hottest keys; k should be a few times larger
+// than the number of keys you are interested in.
+func (cb *CacheBuilder) TrackTopKeys(k int) *CacheBuilder {
+	cb.topKeys = k
+	return cb
+}
+
+// topKeys implements the space-saving algorithm: a new key takes over the
+// counter of the least frequent key once all counters are in use.
+type topKeys struct {
+	mu       sync.Mutex
+	capacity int
+	counters map[interface{}]*keyCounter
+	heap     keyCounterHeap
+}
+
+type keyCounter struct {
+	KeyFreq
+	index int // position in the heap
+}
+
+func newTopKeys(capacity int) *topKeys {
+	return &topKeys{
+		capacity: capacity,
+		counters: make(map[interface{}]*keyCounter, capacity),
+	}
+}
+
+func (tk *topKeys) record(key interface{}) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	if kc, ok := tk.counters[key]; ok {
+		kc.Count++
+		heap.Fix(&tk.heap, kc.index)
+		return
+	}
+	if len(tk.counters) < tk.capacity {
+		kc := &keyCounter{KeyFreq: KeyFreq{Key: key, Count: 1}}
+		tk.counters[key] = kc
+		heap.Push(&tk.heap, kc)
+		return
+	}
+	kc := tk.heap[0]
+	delete(tk.counters, kc.Key)
+	kc.Key, kc.Error = key, kc.Count
+	kc.Count++
+	tk.counters[key] = kc
+	heap.Fix(&tk.heap, 0)
+}
+
+// top returns the n most frequent keys, most frequent first.
+func (tk *topKeys) top(n int) []KeyFreq {
+	tk.mu.Lock()
+	kfs := make([]KeyFreq, 0, len(tk.heap))
+	for _, kc := range tk.heap {
+		kfs = append(kfs, kc.KeyFreq)
+	}
+	tk.mu.Unlock()
+	sort.Slice(kfs, func(i, j int) bool {
+		return kfs[i].Count > kfs[j].Count
+	})
+	if n >= 0 && n < len(kfs) {
+		kfs = kfs[:n]
+	}
+	return kfs
+}
+
+func (tk *topKeys) reset() {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	tk.counters = make(map[interface{}]*keyCounter, tk.capacity)
+	tk.heap = nil
+}
+
+// TopKeys returns the n most frequently looked up keys, most frequent first,
+// or nil if TrackTopKeys is not set.
+func (st *stats) TopKeys(n int) []KeyFreq {
+	if st.topKeys == nil {
+		return nil
+	}
+	return st.topKeys.top(n)
+}
+
+// keyCounterHeap is a min-heap of key counters by count.
+type keyCounterHeap []*keyCounter
+
+func (h keyCounterHeap) Len() int { return len(h) }
+
+func (h keyCounterHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h keyCounterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *keyCounterHeap) Push(x interface{}) {
+	kc := x.(*keyCounter)
+	kc.index = len(*h)
+	*h = append(*h, kc)
+}
+
+func (h *keyCounterHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	kc := old[n-1]
+	*h = old[:n-1]
+	return kc
+}
@@ -0,0 +1,58 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestTopKeysSpaceSaving(t *testing.T) {
+	tk := newTopKeys(3)
+	for i := 0; i < 10; i++ {
+		tk.record("hot")
+	}
+	for i := 0; i < 4; i++ {
+		tk.record(i)
+	}
+	for i := 0; i < 10; i++ {
+		tk.record("hot")
+	}
+
+	top := tk.top(2)
+	if len(top) != 2 {
+		t.Fatalf("%v keys != 2", len(top))
+	}
+	if top[0].Key != "hot" || top[0].Count != 20 || top[0].Error != 0 {
+		t.Errorf("unexpected top key %+v", top[0])
+	}
+	if top[1].Count < top[1].Error || top[1].Count-top[1].Error > 1 {
+		t.Errorf("unexpected second key %+v", top[1])
+	}
+	if all := tk.top(-1); len(all) != 3 {
+		t.Errorf("%v keys != 3", len(all))
+	}
+}
+
+func TestTopKeys(t *testing.T) {
+	for _, builder := range cacheBuilders(10) {
+		gc := builder.TrackTopKeys(10).Build()
+		gc.Set("a", 1)
+		for i := 0; i < 3; i++ {
+			gc.Get("a")
+			gc.Get("b")
+		}
+		gc.Get("a")
+		gc.Get("c")
+
+		top := gc.TopKeys(2)
+		if len(top) != 2 || top[0].Key != "a" || top[0].Count != 4 || top[1].Key != "b" || top[1].Count != 3 {
+			t.Errorf("%T: unexpected top keys %+v", gc, top)
+		}
+		gc.ResetStats()
+		if top := gc.TopKeys(2); len(top) != 0 {
+			t.Errorf("%T: %v top keys after ResetStats", gc, len(top))
+		}
+	}
+
+	if top := New(10).LRU().Build().TopKeys(1); top != nil {
+		t.Errorf("TopKeys without TrackTopKeys should be nil, not %v", top)
+	}
+}
@@ -1,5 +1,7 @@
 package gcache
 
+import "time"
+
 func minInt(x, y int) int {
 	if x < y {
 		return x
@@ -13,3 +15,21 @@ func maxInt(x, y int) int {
 	}
 	return y
 }
+
+// isExpired reports whether any of the given expiration times has passed,
+// reading the clock only if there is one.
+func isExpired(clock Clock, expirations ...*time.Time) bool {
+	var now time.Time
+	for _, e := range expirations {
+		if e == nil {
+			continue
+		}
+		if now.IsZero() {
+			now = clock.Now()
+		}
+		if e.Before(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package gcache
+
+import "fmt"
+
+// InvariantError is returned by Verify when the internal state of a cache is inconsistent.
+type InvariantError struct {
+	Reason string
+}
+
+func (e *InvariantError) Error() string {
+	return "Cache invariant violated: " + e.Reason
+}
+
+func violated(format string, args ...interface{}) error {
+	return &InvariantError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// verifyIndexes checks that the ordered keys are sorted and that the keys of the
+// ordered keys and value indexes are present according to has (not thread safe).
+func (c *baseCache) verifyIndexes(has func(key interface{}) bool) error {
+	if c.ordered != nil {
+		for i, key := range c.ordered.keys {
+			if i > 0 && c.ordered.keys[i-1] >= key {
+				return violated("ordered keys %q and %q are out of order", c.ordered.keys[i-1], key)
+			}
+			if !has(key) {
+				return violated("ordered key %q is not in the cache", key)
+			}
+		}
+	}
+	for name, idx := range c.indexes {
+		n := 0
+		for attr, keys := range idx.keys {
+			if len(keys) == 0 {
+				return violated("index %q has no keys for attribute %v", name, attr)
+			}
+			for key := range keys {
+				if a, ok := idx.attrs[key]; !ok || a != attr {
+					return violated("index %q has key %v under attribute %v, not %v", name, key, attr, a)
+				}
+				if !has(key) {
+					return violated("index %q has key %v which is not in the cache", name, key)
+				}
+			}
+			n += len(keys)
+		}
+		if n != len(idx.attrs) {
+			return violated("index %q has %d keys by attribute but %d attributes", name, n, len(idx.attrs))
+		}
+	}
+	return nil
+}
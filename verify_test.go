@@ -0,0 +1,60 @@
+package gcache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	testCaches := append(expiringCacheBuilders(16),
+		New(64).SCORE().
+			ScoringFunc(func(v interface{}) int { return v.(int) % 7 }).
+			WeightingFunc(func(v interface{}) int { return 1 + v.(int)%3 }))
+	for _, builder := range testCaches {
+		cache := builder.OrderedKeys().Index("mod", func(v interface{}) interface{} { return v.(int) % 5 }).Build()
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			key := string(rune('a' + r.Intn(40)))
+			switch r.Intn(4) {
+			case 0, 1:
+				cache.Set(key, r.Intn(100))
+			case 2:
+				cache.Get(key)
+			case 3:
+				cache.Remove(key)
+			}
+			if err := cache.Verify(); err != nil {
+				t.Fatalf("%T: Verify() = %v after %d operations", cache, err, i+1)
+			}
+		}
+		cache.Purge()
+		if err := cache.Verify(); err != nil {
+			t.Errorf("%T: Verify() = %v after Purge", cache, err)
+		}
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	lru := New(8).LRU().Build().(*LRUCache)
+	lru.Set("a", 1)
+	lru.evictList.PushBack(&lruItem{key: "b"})
+	if _, ok := lru.Verify().(*InvariantError); !ok {
+		t.Errorf("LRU Verify() did not report an inconsistent eviction list")
+	}
+
+	sc := New(8).SCORE().
+		ScoringFunc(func(v interface{}) int { return v.(int) }).
+		WeightingFunc(func(_ interface{}) int { return 1 }).
+		Build().(*ScoreCache)
+	sc.Set("a", 1)
+	sc.Set("b", 2)
+	sc.totalWeight++
+	if _, ok := sc.Verify().(*InvariantError); !ok {
+		t.Errorf("ScoreCache Verify() did not report a wrong total weight")
+	}
+	sc.totalWeight--
+	sc.evictList.items[0].priority = 10
+	if _, ok := sc.Verify().(*InvariantError); !ok {
+		t.Errorf("ScoreCache Verify() did not report an unordered heap")
+	}
+}
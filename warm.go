@@ -0,0 +1,78 @@
+package gcache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WarmError reports the keys which Warm failed to load, along with their errors.
+type WarmError struct {
+	Errors map[interface{}]error
+}
+
+func (e *WarmError) Error() string {
+	return fmt.Sprintf("Failed to warm %d keys.", len(e.Errors))
+}
+
+// WarmFrom seeds the cache at Build time with the entries iter returns, until it
+// reports that there are none left, e.g. from a database cursor, a file or
+// another cache. The entries are set without calling the LoaderFunc.
+func (cb *CacheBuilder) WarmFrom(iter func() (key, value interface{}, ok bool)) *CacheBuilder {
+	cb.warmFrom = iter
+	return cb
+}
+
+// seed sets the entries of WarmFrom in a newly built cache.
+func (cb *CacheBuilder) seed(c Cache) {
+	if cb.warmFrom == nil {
+		return
+	}
+	for {
+		key, value, ok := cb.warmFrom()
+		if !ok {
+			return
+		}
+		c.Set(key, value)
+	}
+}
+
+// warm loads the keys which has reports missing with load, running up to
+// concurrency loads at a time, and returns a WarmError if any of them fails.
+// Invalid keys are skipped.
+func (c *baseCache) warm(keys []interface{}, concurrency int, has func(interface{}) bool, load func(interface{}, bool) (interface{}, error)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		mu   sync.Mutex
+		errs map[interface{}]error
+		wg   sync.WaitGroup
+	)
+	slots := make(chan struct{}, concurrency)
+	for _, key := range keys {
+		if c.checkKey(key) != nil || has(key) {
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(key interface{}) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if _, err := load(key, true); err != nil {
+				mu.Lock()
+				if errs == nil {
+					errs = make(map[interface{}]error)
+				}
+				errs[key] = err
+				mu.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	if errs != nil {
+		return &WarmError{Errors: errs}
+	}
+	return nil
+}
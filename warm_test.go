@@ -0,0 +1,90 @@
+package gcache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	size := 16
+	testCaches := cacheBuilders(size)
+	failed := errors.New("failed")
+	for _, builder := range testCaches {
+		var running, maxRunning, loads int32
+		cache := builder.
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				atomic.AddInt32(&loads, 1)
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				if key == 3 {
+					return nil, failed
+				}
+				return key, nil
+			}).
+			Build()
+		cache.Set(0, "cached")
+
+		err := cache.Warm([]interface{}{0, 1, 2, 3, 4, 5, 6, 7}, 2)
+		werr, ok := err.(*WarmError)
+		if !ok || len(werr.Errors) != 1 || werr.Errors[3] != failed {
+			t.Errorf("%T: Warm() = %v", cache, err)
+		}
+		if loads != 7 || maxRunning > 2 {
+			t.Errorf("%T: loaded %v keys, up to %v at a time", cache, loads, maxRunning)
+		}
+		if v, _ := cache.GetIFPresent(0); v != "cached" {
+			t.Errorf("%T: Warm replaced a cached value with %v", cache, v)
+		}
+		if l := cache.Len(); l != 7 {
+			t.Errorf("%T: Len() = %v; want 7", cache, l)
+		}
+		if s := cache.Stats(); s.Hits != 1 || s.Misses != 0 {
+			t.Errorf("%T: Warm counted %+v", cache, s)
+		}
+	}
+}
+
+func TestWarmFrom(t *testing.T) {
+	source := New(8).LRU().Build()
+	for i := 0; i < 4; i++ {
+		source.Set(i, i*i)
+	}
+	entries := source.GetALL()
+	keys := source.Keys()
+
+	var loads int
+	cache := New(8).ARC().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			loads++
+			return key, nil
+		}).
+		WarmFrom(func() (interface{}, interface{}, bool) {
+			if len(keys) == 0 {
+				return nil, nil, false
+			}
+			key := keys[0]
+			keys = keys[1:]
+			return key, entries[key], true
+		}).
+		Build()
+	if l := cache.Len(); l != 4 {
+		t.Errorf("Len() = %v; want 4", l)
+	}
+	for i := 0; i < 4; i++ {
+		if v, err := cache.Get(i); err != nil || v != i*i {
+			t.Errorf("Get(%v) = %v, %v", i, v, err)
+		}
+	}
+	if loads != 0 {
+		t.Errorf("WarmFrom called the loader %v times", loads)
+	}
+}
@@ -0,0 +1,167 @@
+package gcache
+
+import "sync"
+
+// watchBuffer is the number of changes a watcher buffers before its oldest are dropped.
+const watchBuffer = 64
+
+// ChangeKind is what happened to a watched key.
+type ChangeKind int
+
+const (
+	// ValueSet is delivered when the key is set.
+	ValueSet ChangeKind = iota
+	// ValueRefreshed is delivered when a value returned by the LoaderFunc is stored,
+	// whether it was loaded on a miss or refreshed in the background.
+	ValueRefreshed
+	// ValueEvicted is delivered when the entry is evicted or removed.
+	ValueEvicted
+	// ValueExpired is delivered when the entry is removed because it expired.
+	ValueExpired
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ValueSet:
+		return "set"
+	case ValueRefreshed:
+		return "refreshed"
+	case ValueEvicted:
+		return "evicted"
+	case ValueExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// ValueChange is a change of a watched key.
+type ValueChange struct {
+	Key  interface{}
+	Kind ChangeKind
+	// Value is the value stored by ValueSet and ValueRefreshed changes, nil otherwise.
+	Value interface{}
+	// Dropped is the number of older changes which were dropped to make room
+	// for this one, because the watcher fell behind.
+	Dropped int
+}
+
+// Watcher is implemented by the caches whose keys can be watched for changes.
+// All the cache types are Watchers.
+type Watcher interface {
+	// Watch returns a channel receiving the changes of key, until the returned
+	// CancelFunc is called or the cache is closed, which close the channel.
+	// The channel of an invalid key is closed right away.
+	// Changes are delivered like callbacks, after the write which made them, but
+	// writes never wait for watchers: once a watcher falls 64 changes behind, its
+	// oldest changes are dropped to make room for the latest, which counts them in
+	// Dropped. Entries dropped by Purge are only delivered with PurgeEvict.
+	Watch(key interface{}) (<-chan ValueChange, CancelFunc)
+}
+
+// CancelFunc stops a watch and closes its channel.
+type CancelFunc func()
+
+// watcher delivers the changes of a key to its channel.
+type watcher struct {
+	key    interface{} // reported in the changes
+	ch     chan ValueChange
+	mu     sync.Mutex // held while sending, so that ch is not closed under a send
+	closed bool
+}
+
+// send delivers change without waiting, dropping the oldest buffered
+// changes to make room for it if the channel is full.
+func (w *watcher) send(change ValueChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	change.Key = w.key
+	for {
+		select {
+		case w.ch <- change:
+			return
+		default:
+		}
+		select {
+		case <-w.ch:
+			change.Dropped++
+		default:
+		}
+	}
+}
+
+func (w *watcher) cancel() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+}
+
+// watch watches key, reporting its changes as changes of as.
+func (c *baseCache) watch(key, as interface{}) (<-chan ValueChange, CancelFunc) {
+	w := &watcher{key: as, ch: make(chan ValueChange, watchBuffer)}
+	if c.checkKey(key) != nil {
+		w.cancel()
+		return w.ch, w.cancel
+	}
+	c.mu.Lock()
+	if c.isClosed() {
+		c.mu.Unlock()
+		w.cancel()
+		return w.ch, w.cancel
+	}
+	if c.watchers == nil {
+		c.watchers = make(map[interface{}]map[*watcher]struct{})
+	}
+	if c.watchers[key] == nil {
+		c.watchers[key] = make(map[*watcher]struct{})
+	}
+	c.watchers[key][w] = struct{}{}
+	c.mu.Unlock()
+
+	return w.ch, func() {
+		c.mu.Lock()
+		if ws := c.watchers[key]; ws != nil {
+			delete(ws, w)
+			if len(ws) == 0 {
+				delete(c.watchers, key)
+			}
+		}
+		c.mu.Unlock()
+		w.cancel()
+	}
+}
+
+// changed queues the delivery of a change of key to its watchers (not thread safe).
+// value is the stored value, which is decoded for ValueSet and ValueRefreshed changes.
+func (c *baseCache) changed(key interface{}, kind ChangeKind, value interface{}) {
+	ws := c.watchers[key]
+	if len(ws) == 0 {
+		return
+	}
+	change := ValueChange{Kind: kind}
+	if kind == ValueSet || kind == ValueRefreshed {
+		change.Value, _ = c.decoded(key, value)
+	}
+	for w := range ws {
+		w := w
+		c.callbacks = append(c.callbacks, func() { w.send(change) })
+	}
+}
+
+// closeWatchers cancels all the watches when the cache is closed.
+func (c *baseCache) closeWatchers() {
+	c.mu.Lock()
+	watchers := c.watchers
+	c.watchers = nil
+	c.mu.Unlock()
+	for _, ws := range watchers {
+		for w := range ws {
+			w.cancel()
+		}
+	}
+}
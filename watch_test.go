@@ -0,0 +1,110 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func nextChange(t *testing.T, ch <-chan ValueChange) ValueChange {
+	t.Helper()
+	select {
+	case change, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel was closed")
+		}
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("no change was delivered")
+	}
+	return ValueChange{}
+}
+
+func TestWatch(t *testing.T) {
+	size := 8
+	loader := func(key interface{}) (interface{}, error) { return "loaded", nil }
+	for _, builder := range cacheBuilders(size) {
+		cache := builder.LoaderFunc(loader).Build()
+		ch, cancel := cache.(Watcher).Watch("a")
+		cache.Set("b", 0)
+		cache.Set("a", 1)
+		if change := nextChange(t, ch); change.Key != "a" || change.Kind != ValueSet || change.Value != 1 {
+			t.Errorf("%T: got %+v, want a set of 1", cache, change)
+		}
+		cache.Remove("a")
+		if change := nextChange(t, ch); change.Kind != ValueEvicted || change.Value != nil {
+			t.Errorf("%T: got %+v, want an eviction", cache, change)
+		}
+		cache.Get("a")
+		if change := nextChange(t, ch); change.Kind != ValueRefreshed || change.Value != "loaded" {
+			t.Errorf("%T: got %+v, want a refresh", cache, change)
+		}
+		cancel()
+		cache.Set("a", 2)
+		if _, ok := <-ch; ok {
+			t.Errorf("%T: a change was delivered after cancel", cache)
+		}
+		cancel()
+	}
+}
+
+func TestWatchExpired(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := New(8).LRU().Clock(clock).Expiration(time.Second).Build()
+	ch, _ := cache.(Watcher).Watch("a")
+	cache.Set("a", 1)
+	nextChange(t, ch)
+	clock.Advance(2 * time.Second)
+	cache.Get("a")
+	if change := nextChange(t, ch); change.Kind != ValueExpired {
+		t.Errorf("got %+v, want an expiration", change)
+	}
+	cache.Close()
+	if _, ok := <-ch; ok {
+		t.Errorf("the watch channel is open after Close")
+	}
+	if ch, _ := cache.(Watcher).Watch("a"); ch != nil {
+		if _, ok := <-ch; ok {
+			t.Errorf("Watch after Close returned an open channel")
+		}
+	}
+}
+
+func TestWatchNamespace(t *testing.T) {
+	cache := New(8).LRU().Build()
+	ns := cache.Namespace("ns")
+	ch, cancel := ns.Watch("a")
+	defer cancel()
+	cache.Set("a", 0)
+	ns.Set("a", 1)
+	if change := nextChange(t, ch); change.Key != "a" || change.Value != 1 {
+		t.Errorf("got %+v, want a set of 1", change)
+	}
+}
+
+func TestWatchStalled(t *testing.T) {
+	cache := New(8).LRU().Build()
+	ch, cancel := cache.(Watcher).Watch("a")
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*watchBuffer; i++ {
+			cache.Set("a", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a watcher which does not read blocked Set")
+	}
+
+	dropped := 0
+	var last ValueChange
+	for i := 0; i < watchBuffer; i++ {
+		last = nextChange(t, ch)
+		dropped += last.Dropped
+	}
+	if last.Value != 2*watchBuffer-1 || dropped != watchBuffer {
+		t.Errorf("last change %+v after %d dropped changes", last, dropped)
+	}
+}
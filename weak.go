@@ -0,0 +1,124 @@
+package gcache
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Hold values so that the garbage collector may reclaim them: after every garbage
+// collection, the values which were not read since the previous one are released,
+// and are reclaimed by the next collection unless something else refers to them.
+// Values are released whatever the memory pressure, so this is an expiration
+// measured in collection cycles, not a weak reference: under steady allocation
+// an idle entry is dropped even if the cache has room for it.
+// Entries whose value was released are treated as expired, so Get reloads them
+// if there is a LoaderFunc.
+// Close the cache to stop tracking collections.
+// AddedFunc, EvictedFunc and WeightingFunc receive a *WeakValue in place of the value.
+func (cb *CacheBuilder) WeakValues() *CacheBuilder {
+	cb.weakValues = true
+	return cb
+}
+
+// WeakValue is how a value is held by a cache with WeakValues.
+type WeakValue struct {
+	value atomic.Pointer[interface{}] // nil once released
+	read  int32                       // since the last collection, accessed atomically
+}
+
+// Value returns the value, or false if it was released.
+func (wv *WeakValue) Value() (interface{}, bool) {
+	v := wv.value.Load()
+	if v == nil {
+		return nil, false
+	}
+	atomic.StoreInt32(&wv.read, 1)
+	return *v, true
+}
+
+// weakValues tracks the WeakValues of a cache and releases the unread ones
+// after every garbage collection.
+type weakValues struct {
+	mu      sync.Mutex
+	values  map[*WeakValue]struct{}
+	sweeps  int64 // accessed atomically
+	stopped int32
+}
+
+// gcSentinel is garbage collected at every collection, which runs its finalizer.
+// It holds a pointer so that it is not batched with other tiny allocations.
+type gcSentinel struct {
+	w *weakValues
+}
+
+func newWeakValues() *weakValues {
+	w := &weakValues{values: make(map[*WeakValue]struct{})}
+	w.arm()
+	return w
+}
+
+// arm calls sweep after the next garbage collection.
+func (w *weakValues) arm() {
+	runtime.SetFinalizer(&gcSentinel{w: w}, func(s *gcSentinel) {
+		if atomic.LoadInt32(&s.w.stopped) == 0 {
+			s.w.sweep()
+			s.w.arm()
+		}
+	})
+}
+
+// sweep releases the values which were not read since the previous sweep.
+func (w *weakValues) sweep() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for wv := range w.values {
+		if atomic.SwapInt32(&wv.read, 0) == 0 {
+			wv.value.Store(nil)
+		}
+	}
+	atomic.AddInt64(&w.sweeps, 1)
+}
+
+func (w *weakValues) hold(value interface{}) *WeakValue {
+	wv := &WeakValue{read: 1}
+	wv.value.Store(&value)
+	w.mu.Lock()
+	w.values[wv] = struct{}{}
+	w.mu.Unlock()
+	return wv
+}
+
+func (w *weakValues) drop(wv *WeakValue) {
+	w.mu.Lock()
+	delete(w.values, wv)
+	w.mu.Unlock()
+}
+
+func (w *weakValues) reset() {
+	w.mu.Lock()
+	w.values = make(map[*WeakValue]struct{})
+	w.mu.Unlock()
+}
+
+func (w *weakValues) stop() {
+	atomic.StoreInt32(&w.stopped, 1)
+}
+
+// released reports whether value is a WeakValue which was released.
+func released(value interface{}) bool {
+	wv, ok := value.(*WeakValue)
+	return ok && wv.value.Load() == nil
+}
+
+// unweak returns the value held by a WeakValue, or KeyNotFoundError if it was released.
+func (c *baseCache) unweak(value interface{}) (interface{}, error) {
+	wv, ok := value.(*WeakValue)
+	if !ok {
+		return value, nil
+	}
+	if v, ok := wv.Value(); ok {
+		return v, nil
+	}
+	return nil, KeyNotFoundError
+}
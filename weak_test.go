@@ -0,0 +1,88 @@
+package gcache
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func weakValuesOf(c Cache) *weakValues {
+	switch c := c.(type) {
+	case *SimpleCache:
+		return c.weak
+	case *LRUCache:
+		return c.weak
+	case *LFUCache:
+		return c.weak
+	case *ARC:
+		return c.weak
+	case *ScoreCache:
+		return c.weak
+	}
+	return nil
+}
+
+// collect runs a garbage collection and waits for the values of w to be swept.
+func collect(t *testing.T, w *weakValues) {
+	sweeps := atomic.LoadInt64(&w.sweeps)
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&w.sweeps) == sweeps {
+		if time.Now().After(deadline) {
+			t.Fatal("the values were not swept after a garbage collection")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWeakValues(t *testing.T) {
+	size := 8
+	for _, builder := range cacheBuilders(size) {
+		loads := 0
+		cache := builder.
+			WeakValues().
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				loads++
+				return "loaded", nil
+			}).
+			Build()
+		w := weakValuesOf(cache)
+		cache.Set("idle", "a")
+		cache.Set("read", "b")
+
+		for i := 0; i < 2; i++ {
+			collect(t, w)
+			if v, err := cache.GetIFPresent("read"); err != nil || v != "b" {
+				t.Fatalf("%T: GetIFPresent() = %v, %v for a value read between collections", cache, v, err)
+			}
+		}
+		if v, err := cache.Get("idle"); err != nil || v != "loaded" || loads != 1 {
+			t.Errorf("%T: Get() = %v, %v after %v loads; want the released value to be reloaded", cache, v, err, loads)
+		}
+		if n := cache.Stats().Expirations; n != 1 {
+			t.Errorf("%T: %d expirations; want the released value to count as expired", cache, n)
+		}
+		cache.Close()
+	}
+}
+
+func TestScoreCacheWeakValuesSet(t *testing.T) {
+	cache := New(100).SCORE().
+		ScoringFunc(func(_ interface{}) int { return 1 }).
+		WeightingFunc(func(_ interface{}) int { return 10 }).
+		WeakValues().
+		Build().(*ScoreCache)
+	defer cache.Close()
+	cache.Set("a", 1)
+	collect(t, cache.weak)
+	collect(t, cache.weak)
+
+	cache.Set("a", 2)
+	if err := cache.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := cache.Get("a"); err != nil || v != 2 || cache.Len() != 1 || cache.TotalWeight() != 10 {
+		t.Errorf("Get() = %v, %v with %d entries weighing %d", v, err, cache.Len(), cache.TotalWeight())
+	}
+}
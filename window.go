@@ -0,0 +1,70 @@
+package gcache
+
+import (
+	"sync"
+	"time"
+)
+
+// slidingWindow counts the hits and misses of the last window,
+// split in buckets which expire one at a time as the window slides.
+type slidingWindow struct {
+	mu      sync.Mutex
+	clock   Clock
+	width   time.Duration // of a bucket
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch  int64 // time at which the bucket starts, in units of width
+	hits   uint64
+	misses uint64
+}
+
+func newSlidingWindow(window time.Duration, buckets int, clock Clock) *slidingWindow {
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &slidingWindow{clock: clock, width: width, buckets: make([]windowBucket, buckets)}
+}
+
+func (w *slidingWindow) epoch() int64 {
+	return w.clock.Now().UnixNano() / int64(w.width)
+}
+
+func (w *slidingWindow) record(hit bool) {
+	epoch := w.epoch()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = windowBucket{epoch: epoch}
+	}
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+}
+
+// counts returns the hits and misses within the window.
+func (w *slidingWindow) counts() (hits, misses uint64) {
+	oldest := w.epoch() - int64(len(w.buckets)) + 1
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.buckets {
+		if b.epoch >= oldest {
+			hits += b.hits
+			misses += b.misses
+		}
+	}
+	return hits, misses
+}
+
+func (w *slidingWindow) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
@@ -0,0 +1,52 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	w := newSlidingWindow(40*time.Millisecond, 4, RealClock{})
+	w.record(true)
+	w.record(false)
+	if hits, misses := w.counts(); hits != 1 || misses != 1 {
+		t.Errorf("hits: %v, misses: %v", hits, misses)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	w.record(true)
+	if hits, misses := w.counts(); hits != 1 || misses != 0 {
+		t.Errorf("hits: %v, misses: %v", hits, misses)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if hits, misses := w.counts(); hits != 0 || misses != 0 {
+		t.Errorf("window should have slid past all lookups, hits: %v, misses: %v", hits, misses)
+	}
+}
+
+func TestWindowedStats(t *testing.T) {
+	gc := New(10).LRU().WindowedStats(20*time.Millisecond, 2).Build()
+	gc.Set("a", 1)
+	gc.Get("b")
+	gc.Get("b")
+	time.Sleep(40 * time.Millisecond)
+	gc.Get("a")
+
+	if rate := gc.HitRate(); rate != 1.0 {
+		t.Errorf("windowed hit rate %v != 1", rate)
+	}
+	if rate := gc.Stats().HitRate(); rate != 1.0/3 {
+		t.Errorf("lifetime hit rate %v != 1/3", rate)
+	}
+
+	for _, cb := range []*CacheBuilder{
+		New(10).WindowedStats(time.Minute, 0),
+		New(10).WindowedStats(0, 6),
+		New(10).WindowedStats(-time.Minute, 6),
+	} {
+		if _, err := cb.BuildE(); err == nil {
+			t.Errorf("WindowedStats(%v, %v) should be rejected", cb.statsWindow, cb.statsBuckets)
+		}
+	}
+}